@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/throttle"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// throttleSubject 从请求上下文中取出限速对象，通过 WebDAV Token 访问时同时包含 Token
+func throttleSubject(c *gin.Context) throttle.Subject {
+	sub := throttle.Subject{UserID: c.GetUint("userID")}
+	if v, ok := c.Get("webdav_token"); ok {
+		if token, ok := v.(*models.WebDAVToken); ok {
+			sub.TokenID = token.ID
+		}
+	}
+	return sub
+}
+
+// throttledUpload 按当前用户的上传限速包装请求数据
+func throttledUpload(c *gin.Context, r io.Reader) io.Reader {
+	return throttle.Default().UploadReader(c.Request.Context(), throttleSubject(c), r)
+}
+
+// throttleRequestBody 将请求体替换为按上传限速读取的 reader，需在读取请求体之前调用
+func throttleRequestBody(c *gin.Context) {
+	c.Request.Body = io.NopCloser(throttledUpload(c, c.Request.Body))
+}
+
+// throttledDownload 按当前用户的下载限速包装响应
+func throttledDownload(c *gin.Context) http.ResponseWriter {
+	return throttle.Default().ResponseWriter(c.Request.Context(), throttleSubject(c), c.Writer)
+}
+
+// BandwidthLimitHandler 带宽限速处理器
+type BandwidthLimitHandler struct {
+	*BaseHandler
+	DB *gorm.DB
+}
+
+// NewBandwidthLimitHandler 创建带宽限速处理器
+func NewBandwidthLimitHandler(db *gorm.DB) *BandwidthLimitHandler {
+	return &BandwidthLimitHandler{
+		BaseHandler: NewBaseHandler(),
+		DB:          db,
+	}
+}
+
+// bandwidthLimitRequest 创建/更新限速的请求参数
+type bandwidthLimitRequest struct {
+	ScopeType    string `json:"scope_type" binding:"required,oneof=USER ROLE TOKEN"`
+	ScopeID      uint   `json:"scope_id" binding:"required"`
+	UploadRate   int64  `json:"upload_rate" binding:"min=0"`
+	DownloadRate int64  `json:"download_rate" binding:"min=0"`
+}
+
+// Mine 获取对当前用户生效的限速
+func (h *BandwidthLimitHandler) Mine(c *gin.Context) {
+	if !throttle.Default().Enabled() {
+		h.Success(c, gin.H{"enabled": false})
+		return
+	}
+	eff, err := throttle.Default().Effective(throttleSubject(c))
+	if err != nil {
+		logger.Error("获取带宽限速失败", zap.Uint("user_id", c.GetUint("userID")), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取带宽限速失败")
+		return
+	}
+	h.Success(c, gin.H{
+		"enabled": true,
+		"user":    eff.User,
+		"token":   eff.Token,
+	})
+}
+
+// List 获取限速列表（仅管理员）
+func (h *BandwidthLimitHandler) List(c *gin.Context) {
+	query := h.DB.Model(&models.BandwidthLimit{})
+	if scopeType := c.Query("scope_type"); scopeType != "" {
+		query = query.Where("scope_type = ?", scopeType)
+	}
+	if scopeID := c.Query("scope_id"); scopeID != "" {
+		query = query.Where("scope_id = ?", scopeID)
+	}
+
+	var limits []models.BandwidthLimit
+	if err := query.Order("id").Find(&limits).Error; err != nil {
+		logger.Error("获取带宽限速列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取带宽限速列表失败")
+		return
+	}
+
+	h.Success(c, gin.H{
+		"items": limits,
+		"total": len(limits),
+	})
+}
+
+// Create 创建限速（仅管理员）
+func (h *BandwidthLimitHandler) Create(c *gin.Context) {
+	var req bandwidthLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.BadRequest(c, "参数错误")
+		return
+	}
+
+	var count int64
+	if err := h.DB.Model(&models.BandwidthLimit{}).
+		Where("scope_type = ? AND scope_id = ?", req.ScopeType, req.ScopeID).
+		Count(&count).Error; err != nil {
+		h.Error(c, utils.CodeServerError, "检查限速是否存在失败")
+		return
+	}
+	if count > 0 {
+		h.BadRequest(c, "相同范围的限速已存在")
+		return
+	}
+
+	l := models.BandwidthLimit{
+		ScopeType:    req.ScopeType,
+		ScopeID:      req.ScopeID,
+		UploadRate:   req.UploadRate,
+		DownloadRate: req.DownloadRate,
+	}
+	if err := h.DB.Create(&l).Error; err != nil {
+		logger.Error("创建带宽限速失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "创建带宽限速失败")
+		return
+	}
+
+	throttle.Default().Invalidate()
+	h.Success(c, l)
+}
+
+// Update 更新限速值（仅管理员）
+func (h *BandwidthLimitHandler) Update(c *gin.Context) {
+	var l models.BandwidthLimit
+	if err := h.DB.First(&l, c.Param("id")).Error; err != nil {
+		h.NotFound(c, "带宽限速不存在")
+		return
+	}
+
+	var req struct {
+		UploadRate   int64 `json:"upload_rate" binding:"min=0"`
+		DownloadRate int64 `json:"download_rate" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.BadRequest(c, "参数错误")
+		return
+	}
+
+	if err := h.DB.Model(&l).Updates(map[string]interface{}{
+		"upload_rate":   req.UploadRate,
+		"download_rate": req.DownloadRate,
+	}).Error; err != nil {
+		logger.Error("更新带宽限速失败", zap.Uint("id", l.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "更新带宽限速失败")
+		return
+	}
+
+	throttle.Default().Invalidate()
+	l.UploadRate, l.DownloadRate = req.UploadRate, req.DownloadRate
+	h.Success(c, l)
+}
+
+// Delete 删除限速（仅管理员）
+func (h *BandwidthLimitHandler) Delete(c *gin.Context) {
+	result := h.DB.Delete(&models.BandwidthLimit{}, c.Param("id"))
+	if result.Error != nil {
+		logger.Error("删除带宽限速失败", zap.Error(result.Error))
+		h.Error(c, utils.CodeServerError, "删除带宽限速失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		h.NotFound(c, "带宽限速不存在")
+		return
+	}
+
+	throttle.Default().Invalidate()
+	h.Success(c, nil)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/checksum"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/policy"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// HeaderDeduplicated 上传因命中已有内容而直接完成时返回的响应头
+const HeaderDeduplicated = "X-Upload-Deduplicated"
+
+// findDuplicate 查找同一存储桶中大小和 MD5 都相同的 ACTIVE 文件
+// 只有 MD5 计算完成且未被判定为感染的文件可以作为来源；未找到时返回 nil
+func findDuplicate(db *gorm.DB, bucketName string, size int64, expected *checksum.Expected) (*models.OSSFile, error) {
+	if expected == nil || expected.MD5 == nil || size <= 0 {
+		return nil, nil
+	}
+
+	var source models.OSSFile
+	err := db.Where("bucket = ? AND status = ? AND file_size = ? AND md5 = ? AND md5_status = ?",
+		bucketName, "ACTIVE", size, hex.EncodeToString(expected.MD5), models.MD5StatusCompleted).
+		Where("scan_status IS NULL OR scan_status <> ?", models.ScanStatusInfected).
+		Order("id DESC").First(&source).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// deduplicate 客户端声明的内容已存在时，通过服务端复制生成目标对象并保存文件记录，无需再上传内容
+// 每条记录都拥有独立的对象而不是共享同一个对象，删除或覆盖任一文件都不会影响其他文件，因此不需要引用计数；
+// 同桶复制由存储端完成，不消耗本服务的带宽。未命中或复制失败时返回 nil，调用方继续正常上传。
+// 上传策略需要检测内容时不去重：来源文件可能早于当前策略上传，复制会绕过 MIME 类型检查
+func (h *OSSFileHandler) deduplicate(c *gin.Context, storage oss.StorageService, uploadPolicy *policy.Policy, config models.OSSConfig, expected *checksum.Expected, size int64, objectKey, originalFilename, bucketName string) *models.OSSFile {
+	if uploadPolicy.NeedsContent() {
+		return nil
+	}
+
+	source, err := findDuplicate(h.DB, bucketName, size, expected)
+	if err != nil {
+		logger.Warn("查找重复文件失败", zap.String("bucket", bucketName), zap.Error(err))
+		return nil
+	}
+	if source == nil {
+		return nil
+	}
+
+	if source.ObjectKey != objectKey {
+		if err := storage.CopyObject(bucketName, source.ObjectKey, bucketName, objectKey); err != nil {
+			logger.Warn("复制重复文件失败，继续正常上传",
+				zap.String("source", source.ObjectKey),
+				zap.String("object_key", objectKey),
+				zap.Error(err),
+			)
+			return nil
+		}
+	}
+
+	downloadURL := source.DownloadURL
+	if strings.HasSuffix(downloadURL, source.ObjectKey) {
+		downloadURL = strings.TrimSuffix(downloadURL, source.ObjectKey) + objectKey
+	}
+	ossFile := newFileRecord(config, objectKey, originalFilename, size, bucketName, downloadURL, utils.GetUserID(c), c.ClientIP())
+	ossFile.MD5 = source.MD5
+	ossFile.MD5Status = models.MD5StatusCompleted
+	// 内容与来源相同，来源已扫描通过时沿用扫描结果
+	if source.ScanStatus == models.ScanStatusClean {
+		ossFile.ScanStatus = source.ScanStatus
+		ossFile.ScanResult = source.ScanResult
+		ossFile.ScannedAt = source.ScannedAt
+	}
+	if err := createFileRecord(h.DB, &ossFile); err != nil {
+		logger.Error("保存文件记录失败", zap.String("object_key", objectKey), zap.Error(err))
+		return nil
+	}
+
+	if err := hashing.Copy(h.DB, source.ID, ossFile.ID); err != nil {
+		logger.Warn("复制文件摘要失败", zap.Uint("source_id", source.ID), zap.Uint("file_id", ossFile.ID), zap.Error(err))
+	}
+
+	logger.Info("上传内容已存在，直接复制完成",
+		zap.Uint("source_id", source.ID),
+		zap.Uint("file_id", ossFile.ID),
+		zap.String("object_key", objectKey),
+		zap.Int64("size", size),
+	)
+	c.Header(HeaderDeduplicated, "true")
+	return &ossFile
+}
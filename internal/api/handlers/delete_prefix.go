@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/tasks"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// TaskTypeDeletePrefix 删除目录（存储桶中指定前缀下的所有文件）
+const TaskTypeDeletePrefix = "delete_prefix"
+
+// deletePrefixBatchSize 删除目录时每批读取的文件记录数
+const deletePrefixBatchSize = 100
+
+// deletePrefixParams 删除目录任务的参数
+type deletePrefixParams struct {
+	RegionCode string `json:"region_code"`
+	BucketName string `json:"bucket_name"`
+	Prefix     string `json:"prefix"`
+}
+
+// deletePrefixResult 删除目录任务的结果
+type deletePrefixResult struct {
+	Deleted int `json:"deleted"`
+	Failed  int `json:"failed"`
+}
+
+// RegisterTasks 注册文件相关的后台任务类型
+func (h *OSSFileHandler) RegisterTasks(m *tasks.Manager) {
+	h.tasks = m
+	m.Register(TaskTypeDeletePrefix, h.runDeletePrefix)
+}
+
+// submitTask 提交后台任务并返回任务信息
+func submitTask(c *gin.Context, base *BaseHandler, m *tasks.Manager, taskType string, params any) {
+	if m == nil {
+		base.Error(c, utils.CodeServerError, "后台任务未启用")
+		return
+	}
+	task, err := m.Submit(c.GetUint("userID"), taskType, params)
+	if err != nil {
+		logger.Error("提交后台任务失败", zap.String("type", taskType), zap.Error(err))
+		base.Error(c, utils.CodeServerError, "提交后台任务失败")
+		return
+	}
+	base.Success(c, task)
+}
+
+// DeletePrefix 删除目录：提交后台任务删除存储桶中指定前缀下的所有文件，通过 /tasks/:id 查询进度
+func (h *OSSFileHandler) DeletePrefix(c *gin.Context) {
+	var req deletePrefixParams
+	if err := c.ShouldBindJSON(&req); err != nil || req.BucketName == "" {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+
+	// 不允许通过该接口清空整个存储桶
+	req.Prefix = strings.TrimLeft(req.Prefix, "/")
+	if req.Prefix == "" || strings.Contains(req.Prefix, "..") || (h.trash != nil && h.trash.Contains(req.Prefix)) {
+		h.Error(c, utils.CodeInvalidParams, "无效的目录")
+		return
+	}
+	if !strings.HasSuffix(req.Prefix, "/") {
+		req.Prefix += "/"
+	}
+
+	if req.RegionCode == "" {
+		var err error
+		if req.RegionCode, err = h.getRegionByBucket(req.BucketName); err != nil {
+			h.Error(c, utils.CodeInvalidParams, "未找到存储桶对应的区域信息")
+			return
+		}
+	}
+	if !auth.CheckBucketAccess(h.DB, c.GetUint("userID"), req.RegionCode, req.BucketName) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return
+	}
+
+	submitTask(c, h.BaseHandler, h.tasks, TaskTypeDeletePrefix, req)
+}
+
+// runDeletePrefix 逐批删除前缀下的对象和文件记录，启用回收站时移入回收站；单个文件删除失败时跳过并计数，重复执行时只处理剩余的文件
+func (h *OSSFileHandler) runDeletePrefix(ctx context.Context, task *models.Task, progress *tasks.Progress) (any, error) {
+	var params deletePrefixParams
+	if err := tasks.Decode(task, &params); err != nil {
+		return nil, err
+	}
+
+	query := h.DB.Model(&models.OSSFile{}).
+		Where("bucket = ? AND object_key LIKE ?", params.BucketName, escapeLike(params.Prefix)+"%").
+		Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("统计文件数失败: %w", err)
+	}
+	progress.SetTotal(total)
+
+	var result deletePrefixResult
+	storages := make(map[string]oss.StorageService)
+	var lastID uint
+	for {
+		var files []models.OSSFile
+		if err := query.Where("id > ?", lastID).
+			Order("id").Limit(deletePrefixBatchSize).Find(&files).Error; err != nil {
+			return result, fmt.Errorf("获取文件列表失败: %w", err)
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			lastID = file.ID
+			progress.SetMessage(file.ObjectKey)
+
+			if err := h.deleteFile(storages, &file, params.RegionCode, task.UserID); err != nil {
+				logger.Warn("删除目录中的文件失败",
+					zap.Uint("task_id", task.ID),
+					zap.String("object_key", file.ObjectKey),
+					zap.Error(err))
+				result.Failed++
+			} else {
+				result.Deleted++
+			}
+			progress.Add(1)
+		}
+	}
+
+	progress.SetMessage("")
+	if result.Failed > 0 {
+		return result, fmt.Errorf("%d 个文件删除失败", result.Failed)
+	}
+	return result, nil
+}
+
+// deleteFile 删除对象和文件记录，启用回收站时移入回收站；storages 按存储类型缓存存储服务
+func (h *OSSFileHandler) deleteFile(storages map[string]oss.StorageService, file *models.OSSFile, regionCode string, userID uint) error {
+	if h.trash.Enabled() && file.Status != models.FileStatusDeleted {
+		return h.trash.Move(file, userID)
+	}
+
+	storage, ok := storages[file.StorageType]
+	if !ok {
+		var err error
+		if storage, err = h.storageFactory.GetStorageService(file.StorageType); err != nil {
+			return fmt.Errorf("获取存储服务失败: %w", err)
+		}
+		storages[file.StorageType] = storage
+	}
+
+	if err := storage.DeleteObjectFromBucket(file.ObjectKey, regionCode, file.Bucket); err != nil && !errors.Is(err, oss.ErrObjectNotFound) {
+		return err
+	}
+	return h.DB.Delete(file).Error
+}
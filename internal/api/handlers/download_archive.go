@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/archive"
+	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxArchiveFiles 单次打包下载的最大文件数
+const maxArchiveFiles = 10000
+
+var (
+	errInvalidArchivePrefix = errors.New("目录路径包含非法字符")
+	errTooManyArchiveFiles  = fmt.Errorf("一次最多打包 %d 个文件", maxArchiveFiles)
+	errArchiveFileBlocked   = errors.New("文件未通过病毒扫描，无法下载")
+)
+
+// archiveRequest 打包下载的请求参数，file_ids 与 bucket_name + prefix 二选一
+type archiveRequest struct {
+	FileIDs    []uint `json:"file_ids"`
+	RegionCode string `json:"region_code"`
+	BucketName string `json:"bucket_name"`
+	Prefix     string `json:"prefix"`
+	Name       string `json:"name"` // 下载的 zip 文件名，为空时自动生成
+}
+
+// zipFile 压缩包中的一个文件
+type zipFile struct {
+	record     models.OSSFile
+	name       string // 在压缩包中的路径
+	regionCode string
+}
+
+// DownloadArchive 将多个文件或一个目录打包为 zip 流式返回
+// 逐个对象边读取边写入响应，不缓存整个对象；开始输出前完成所有权限和扫描状态检查
+func (h *OSSFileHandler) DownloadArchive(c *gin.Context) {
+	var req archiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+
+	var (
+		files []zipFile
+		ok    bool
+	)
+	switch {
+	case len(req.FileIDs) > 0:
+		files, ok = h.archiveFilesByID(c, req.FileIDs)
+	case req.BucketName != "":
+		files, ok = h.archiveFilesByPrefix(c, req.RegionCode, req.BucketName, req.Prefix)
+	default:
+		h.Error(c, utils.CodeInvalidParams, "请指定 file_ids 或 bucket_name")
+		return
+	}
+	if !ok {
+		return
+	}
+	if len(files) == 0 {
+		h.Error(c, utils.CodeFileNotFound, "没有可下载的文件")
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = strings.Trim(req.Prefix, "/")
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+	}
+	if name == "" {
+		name = "files-" + time.Now().Format("20060102150405")
+	}
+	streamZip(c, h.storageFactory, h.DB, name, files)
+}
+
+// archiveFilesByID 按文件ID选择文件，逐个检查存储桶权限
+func (h *OSSFileHandler) archiveFilesByID(c *gin.Context, ids []uint) ([]zipFile, bool) {
+	if len(ids) > maxArchiveFiles {
+		h.Error(c, utils.CodeInvalidParams, fmt.Sprintf("一次最多打包 %d 个文件", maxArchiveFiles))
+		return nil, false
+	}
+
+	var records []models.OSSFile
+	if err := h.DB.Where("id IN ? AND status = ?", ids, "ACTIVE").Order("id").Find(&records).Error; err != nil {
+		h.Error(c, utils.CodeServerError, "查询文件失败")
+		return nil, false
+	}
+
+	userID := c.GetUint("userID")
+	regions := make(map[string]string)
+	files := make([]zipFile, 0, len(records))
+	for _, record := range records {
+		regionCode, cached := regions[record.Bucket]
+		if !cached {
+			var err error
+			regionCode, err = h.getRegionByBucket(record.Bucket)
+			if err != nil {
+				logger.Error("获取存储桶区域信息失败", zap.String("bucket", record.Bucket), zap.Error(err))
+				h.Error(c, utils.CodeServerError, "获取存储桶区域信息失败")
+				return nil, false
+			}
+			if !auth.CheckBucketAccess(h.DB, userID, regionCode, record.Bucket) {
+				h.Error(c, utils.CodeForbidden, "没有权限访问存储桶 "+record.Bucket)
+				return nil, false
+			}
+			regions[record.Bucket] = regionCode
+		}
+		if err := scan.CheckDownload(&record); err != nil {
+			h.Error(c, utils.CodeForbidden, fmt.Sprintf("%s: %v", record.OriginalFilename, err))
+			return nil, false
+		}
+		files = append(files, zipFile{record: record, name: record.ObjectKey, regionCode: regionCode})
+	}
+
+	// 文件来自多个存储桶时以存储桶名作为顶层目录，不同存储桶中相同的对象键在压缩包中不会重名
+	if len(regions) > 1 {
+		for i := range files {
+			files[i].name = files[i].record.Bucket + "/" + files[i].name
+		}
+	}
+	return files, true
+}
+
+// archiveFilesByPrefix 选择存储桶中指定前缀下的所有文件，压缩包中的路径相对于该前缀
+func (h *OSSFileHandler) archiveFilesByPrefix(c *gin.Context, regionCode, bucketName, prefix string) ([]zipFile, bool) {
+	if regionCode == "" {
+		var err error
+		if regionCode, err = h.getRegionByBucket(bucketName); err != nil {
+			h.Error(c, utils.CodeInvalidParams, "未找到存储桶对应的区域信息")
+			return nil, false
+		}
+	}
+	if !auth.CheckBucketAccess(h.DB, c.GetUint("userID"), regionCode, bucketName) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return nil, false
+	}
+
+	files, err := archiveFilesUnder(h.DB, bucketName, regionCode, prefix)
+	if err != nil {
+		archiveSelectionError(c, h.BaseHandler, err)
+		return nil, false
+	}
+	return files, true
+}
+
+// archiveFilesUnder 查询存储桶中 prefix 目录下的所有 ACTIVE 文件
+func archiveFilesUnder(db *gorm.DB, bucketName, regionCode, prefix string) ([]zipFile, error) {
+	prefix = strings.TrimLeft(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if strings.Contains(prefix, "..") {
+		return nil, errInvalidArchivePrefix
+	}
+
+	var records []models.OSSFile
+	if err := db.Where("bucket = ? AND status = ? AND object_key LIKE ?", bucketName, "ACTIVE", escapeLike(prefix)+"%").
+		Order("object_key").Limit(maxArchiveFiles + 1).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if len(records) > maxArchiveFiles {
+		return nil, errTooManyArchiveFiles
+	}
+
+	files := make([]zipFile, 0, len(records))
+	for _, record := range records {
+		if err := scan.CheckDownload(&record); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errArchiveFileBlocked, record.ObjectKey, err)
+		}
+		name := strings.TrimPrefix(record.ObjectKey, prefix)
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		files = append(files, zipFile{record: record, name: name, regionCode: regionCode})
+	}
+	return files, nil
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// streamZip 输出 zip 响应，每个对象从存储读取后直接写入响应
+// 响应开始后无法再返回错误码，出错时记录日志并中断，客户端会得到不完整的 zip
+func streamZip(c *gin.Context, storageFactory oss.StorageFactory, db *gorm.DB, name string, files []zipFile) {
+	if !strings.HasSuffix(strings.ToLower(name), ".zip") {
+		name += ".zip"
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Header("Cache-Control", "no-store")
+	c.Status(200)
+
+	storages := make(map[uint]oss.StorageService)
+	zs := archive.NewZipStream(throttledDownload(c))
+	for _, f := range files {
+		storage, err := archiveStorage(storageFactory, db, storages, f.record.ConfigID)
+		if err != nil {
+			logger.Error("打包下载获取存储服务失败", zap.Uint("config_id", f.record.ConfigID), zap.Error(err))
+			return
+		}
+		body, err := storage.GetObjectFromBucket(f.record.ObjectKey, f.regionCode, f.record.Bucket)
+		if err != nil {
+			logger.Error("打包下载读取对象失败", zap.String("object_key", f.record.ObjectKey), zap.Error(err))
+			return
+		}
+		err = zs.Add(f.name, f.record.UpdatedAt, body)
+		body.Close()
+		if err != nil {
+			logger.Warn("打包下载写入失败", zap.String("object_key", f.record.ObjectKey), zap.Error(err))
+			return
+		}
+		c.Writer.Flush()
+	}
+	if err := zs.Close(); err != nil {
+		logger.Warn("打包下载写入失败", zap.Error(err))
+		return
+	}
+
+	logger.Info("打包下载完成", zap.String("name", name), zap.Int("files", len(files)))
+}
+
+// archiveStorage 按文件记录的存储配置获取存储服务，WebDAV 写入的记录没有配置ID时使用默认存储
+func archiveStorage(factory oss.StorageFactory, db *gorm.DB, cache map[uint]oss.StorageService, configID uint) (oss.StorageService, error) {
+	if storage, ok := cache[configID]; ok {
+		return storage, nil
+	}
+	var (
+		storage oss.StorageService
+		err     error
+	)
+	var config models.OSSConfig
+	if configID != 0 && db.First(&config, configID).Error == nil {
+		storage, err = factory.GetStorageService(config.StorageType)
+	} else {
+		storage, err = factory.GetDefaultStorageService()
+	}
+	if err != nil {
+		return nil, err
+	}
+	cache[configID] = storage
+	return storage, nil
+}
+
+// archiveSelectionError 将选择文件时的错误转换为响应
+func archiveSelectionError(c *gin.Context, h *BaseHandler, err error) {
+	switch {
+	case errors.Is(err, errInvalidArchivePrefix), errors.Is(err, errTooManyArchiveFiles):
+		h.Error(c, utils.CodeInvalidParams, err.Error())
+	case errors.Is(err, errArchiveFileBlocked):
+		h.Error(c, utils.CodeForbidden, err.Error())
+	default:
+		logger.Error("查询打包文件失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "查询文件失败")
+	}
+}
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/myysophia/ossmanager/internal/archive"
+	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/policy"
+	"github.com/myysophia/ossmanager/internal/quota"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"github.com/myysophia/ossmanager/internal/upload"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+)
+
+// extractRequest 解压归档文件的请求参数
+type extractRequest struct {
+	TargetPrefix string `json:"target_prefix"` // 解压到的目录，为空时使用归档所在目录下与归档同名的目录
+	Overwrite    bool   `json:"overwrite"`     // 目标路径已有文件时是否覆盖，否则跳过
+	TaskID       string `json:"task_id"`       // 进度任务ID，为空时自动生成
+}
+
+// extractJob 一次解压在后台执行所需的信息
+type extractJob struct {
+	file       models.OSSFile
+	config     models.OSSConfig
+	storage    oss.StorageService
+	policy     *policy.Policy
+	format     archive.Format
+	prefix     string
+	regionCode string
+	overwrite  bool
+	taskID     string
+	userID     uint
+	clientIP   string
+
+	skipped int // 因已存在或不符合策略而跳过的文件数
+}
+
+// archiveLimits 返回配置的解压限制
+func archiveLimits() archive.Limits {
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return archive.DefaultLimits
+	}
+	return archive.Limits{
+		MaxEntries:   cfg.Archive.MaxEntries,
+		MaxTotalSize: cfg.Archive.MaxTotalSize,
+		MaxEntrySize: cfg.Archive.MaxEntrySize,
+		MaxRatio:     cfg.Archive.MaxRatio,
+	}
+}
+
+// defaultExtractPrefix 归档所在目录下与归档同名（去掉扩展名）的目录
+func defaultExtractPrefix(objectKey string) string {
+	name := objectKey
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			name = name[:len(name)-len(ext)]
+			break
+		}
+	}
+	return name
+}
+
+// Extract 将 zip、tar、tar.gz 归档解压到目标目录，每个文件保存为独立的对象和文件记录
+// 校验通过后立即返回任务ID，解压在后台进行，进度（已处理的归档字节数）通过 upload.Manager 查询
+func (h *OSSFileHandler) Extract(c *gin.Context) {
+	var req extractRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+
+	var file models.OSSFile
+	if err := h.DB.Where("status = ?", "ACTIVE").First(&file, c.Param("id")).Error; err != nil {
+		h.Error(c, utils.CodeFileNotFound, "文件不存在")
+		return
+	}
+
+	format, err := archive.DetectFormat(file.OriginalFilename)
+	if err != nil {
+		format, err = archive.DetectFormat(file.ObjectKey)
+	}
+	if err != nil {
+		h.Error(c, utils.CodeInvalidParams, err.Error())
+		return
+	}
+
+	// 不解压感染病毒或尚未完成扫描的归档
+	if err := scan.CheckDownload(&file); err != nil {
+		h.Error(c, utils.CodeForbidden, err.Error())
+		return
+	}
+
+	regionCode, err := h.getRegionByBucket(file.Bucket)
+	if err != nil {
+		logger.Error("获取存储桶区域信息失败", zap.String("bucket", file.Bucket), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取存储桶区域信息失败")
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if !auth.CheckBucketAccess(h.DB, userID, regionCode, file.Bucket) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return
+	}
+
+	var ossConfig models.OSSConfig
+	if err := h.DB.First(&ossConfig, file.ConfigID).Error; err != nil {
+		h.Error(c, utils.CodeConfigNotFound, "存储配置不存在")
+		return
+	}
+	storage, err := h.storageFactory.GetStorageService(ossConfig.StorageType)
+	if err != nil {
+		h.Error(c, utils.CodeServerError, "获取存储服务失败")
+		return
+	}
+
+	prefix := strings.Trim(req.TargetPrefix, "/")
+	if prefix == "" {
+		prefix = defaultExtractPrefix(file.ObjectKey)
+	}
+	if strings.Contains(prefix, "..") || strings.ContainsAny(prefix, "\\<>:\"|?*") {
+		h.Error(c, utils.CodeInvalidParams, "目标路径包含非法字符")
+		return
+	}
+
+	uploadPolicy, err := h.policy.Load(regionCode, file.Bucket)
+	if err != nil {
+		logger.Error("获取上传策略失败", zap.String("bucket", file.Bucket), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取上传策略失败")
+		return
+	}
+
+	job := &extractJob{
+		file:       file,
+		config:     ossConfig,
+		storage:    storage,
+		policy:     uploadPolicy,
+		format:     format,
+		prefix:     prefix,
+		regionCode: regionCode,
+		overwrite:  req.Overwrite,
+		taskID:     req.TaskID,
+		userID:     userID,
+		clientIP:   c.ClientIP(),
+	}
+	if job.taskID == "" {
+		job.taskID = uuid.NewString()
+	}
+	upload.DefaultManager.Start(job.taskID, file.FileSize)
+
+	logger.Info("开始解压归档",
+		zap.String("task_id", job.taskID),
+		zap.Uint("file_id", file.ID),
+		zap.String("format", string(format)),
+		zap.String("target_prefix", prefix),
+	)
+
+	go h.runExtract(job)
+
+	h.Success(c, gin.H{
+		"task_id":       job.taskID,
+		"format":        format,
+		"target_prefix": prefix,
+	})
+}
+
+// runExtract 下载归档到临时文件后逐个条目写入存储桶
+func (h *OSSFileHandler) runExtract(job *extractJob) {
+	stats, err := h.extractArchive(job)
+	if err != nil {
+		logger.Error("解压归档失败",
+			zap.String("task_id", job.taskID),
+			zap.Uint("file_id", job.file.ID),
+			zap.Int("entries", stats.Entries),
+			zap.Error(err),
+		)
+		upload.DefaultManager.Fail(job.taskID, fmt.Sprintf("已解压 %d 个文件后失败: %v", stats.Entries-job.skipped, err))
+		return
+	}
+	upload.DefaultManager.Finish(job.taskID)
+
+	logger.Info("解压归档完成",
+		zap.String("task_id", job.taskID),
+		zap.Uint("file_id", job.file.ID),
+		zap.Int("entries", stats.Entries),
+		zap.Int("skipped", job.skipped+stats.Skipped),
+		zap.Int64("bytes", stats.Bytes),
+	)
+}
+
+func (h *OSSFileHandler) extractArchive(job *extractJob) (archive.Stats, error) {
+	tmp, err := os.CreateTemp("", "ossmanager-extract-*")
+	if err != nil {
+		return archive.Stats{}, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	// zip 需要随机访问，先完整下载归档
+	body, err := job.storage.GetObjectFromBucket(job.file.ObjectKey, job.regionCode, job.file.Bucket)
+	if err != nil {
+		return archive.Stats{}, fmt.Errorf("下载归档失败: %w", err)
+	}
+	size, err := io.Copy(tmp, body)
+	body.Close()
+	if err != nil {
+		return archive.Stats{}, fmt.Errorf("下载归档失败: %w", err)
+	}
+
+	src := &progressReaderAt{r: tmp, taskID: job.taskID, limit: size}
+	return archive.Extract(job.format, src, size, archiveLimits(), func(entry archive.Entry, r io.Reader) error {
+		return h.extractEntry(job, entry, r)
+	})
+}
+
+// extractEntry 上传一个条目并保存文件记录
+// 已存在且未要求覆盖、或不符合存储桶策略的条目会被跳过；超出配额时终止整个任务
+func (h *OSSFileHandler) extractEntry(job *extractJob, entry archive.Entry, r io.Reader) error {
+	objectKey := entry.Path
+	if job.prefix != "" {
+		objectKey = job.prefix + "/" + entry.Path
+	}
+	filename := path.Base(entry.Path)
+
+	if !job.overwrite {
+		var count int64
+		if err := h.DB.Model(&models.OSSFile{}).Where("object_key = ? AND bucket = ? AND status = ?",
+			objectKey, job.file.Bucket, "ACTIVE").Count(&count).Error; err != nil {
+			return fmt.Errorf("检查文件是否存在失败: %w", err)
+		}
+		if count > 0 {
+			job.skipped++
+			return nil
+		}
+	}
+
+	if err := job.policy.CheckFile(filename, entry.Size); err != nil {
+		logger.Warn("跳过不符合策略的归档条目", zap.String("object_key", objectKey), zap.Error(err))
+		job.skipped++
+		return nil
+	}
+	if job.policy.NeedsContent() {
+		head, rest, err := policy.Peek(r)
+		if err != nil {
+			return fmt.Errorf("读取条目 %s 失败: %w", entry.Path, err)
+		}
+		if err := job.policy.CheckContent(filename, head); err != nil {
+			logger.Warn("跳过不符合策略的归档条目", zap.String("object_key", objectKey), zap.Error(err))
+			job.skipped++
+			return nil
+		}
+		r = rest
+	}
+
+	if err := h.quota.Check(job.userID, job.file.Bucket, entry.Size); err != nil {
+		if errors.Is(err, quota.ErrQuotaExceeded) {
+			return err
+		}
+		return fmt.Errorf("校验存储配额失败: %w", err)
+	}
+
+	hashes := hashing.NewSet(hashing.Configured()...)
+	counter := &byteCounter{r: hashes.Reader(r)}
+	uploadURL, err := job.storage.UploadToBucket(counter, objectKey, job.regionCode, job.file.Bucket)
+	if err != nil {
+		return fmt.Errorf("上传条目 %s 失败: %w", entry.Path, err)
+	}
+
+	ossFile := newFileRecord(job.config, objectKey, filename, counter.n, job.file.Bucket, uploadURL, job.userID, job.clientIP)
+	if err := createFileRecordWithHashes(h.DB, &ossFile, hashes.Sums()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// progressReaderAt 统计从归档读取的字节数并更新任务进度
+type progressReaderAt struct {
+	r      io.ReaderAt
+	taskID string
+	limit  int64
+	read   int64
+}
+
+func (p *progressReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := p.r.ReadAt(b, off)
+	if n > 0 {
+		read := atomic.AddInt64(&p.read, int64(n))
+		if read > p.limit {
+			read = p.limit
+		}
+		upload.DefaultManager.Update(p.taskID, read)
+	}
+	return n, err
+}
+
+// byteCounter 统计经过的字节数
+type byteCounter struct {
+	r io.Reader
+	n int64
+}
+
+func (b *byteCounter) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	return n, err
+}
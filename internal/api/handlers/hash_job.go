@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/function"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// HashJobHandler 摘要计算任务管理处理器（仅管理员）
+type HashJobHandler struct {
+	*BaseHandler
+	DB            *gorm.DB
+	md5Calculator *function.MD5Calculator
+}
+
+// NewHashJobHandler 创建摘要计算任务管理处理器
+func NewHashJobHandler(db *gorm.DB, md5Calculator *function.MD5Calculator) *HashJobHandler {
+	return &HashJobHandler{
+		BaseHandler:   NewBaseHandler(),
+		DB:            db,
+		md5Calculator: md5Calculator,
+	}
+}
+
+// List 分页列出摘要任务，可按状态（PENDING、RUNNING、DONE、DEAD）和文件ID过滤
+func (h *HashJobHandler) List(c *gin.Context) {
+	query := h.DB.Model(&models.HashJob{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if fileID := c.Query("file_id"); fileID != "" {
+		query = query.Where("file_id = ?", fileID)
+	}
+
+	pagination := utils.GetPagination(c)
+	var jobs []models.HashJob
+	if err := query.Scopes(utils.Paginate(pagination)).Order("id DESC").Find(&jobs).Error; err != nil {
+		logger.Error("获取摘要任务列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取摘要任务列表失败")
+		return
+	}
+
+	var stats []struct {
+		Status string `json:"status"`
+		Count  int64  `json:"count"`
+	}
+	if err := h.DB.Model(&models.HashJob{}).Select("status, count(*) AS count").Group("status").Scan(&stats).Error; err != nil {
+		logger.Error("统计摘要任务失败", zap.Error(err))
+	}
+
+	result := utils.GetPaginationResult(pagination, jobs)
+	result["stats"] = stats
+	h.Success(c, result)
+}
+
+// Retry 重新执行任务，执行次数清零
+func (h *HashJobHandler) Retry(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.BadRequest(c, "无效的任务ID")
+		return
+	}
+
+	job, err := h.md5Calculator.RetryJob(uint(id))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		h.NotFound(c, "任务不存在")
+	case errors.Is(err, function.ErrJobRunning):
+		h.BadRequest(c, err.Error())
+	case err != nil:
+		logger.Error("重试摘要任务失败", zap.Uint64("job_id", id), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "重试摘要任务失败")
+	default:
+		h.Success(c, job)
+	}
+}
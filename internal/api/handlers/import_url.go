@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/fetch"
+	"github.com/myysophia/ossmanager/internal/hashing"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/upload"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// defaultImportTimeout 未配置 import.timeout 时单次导入的总超时
+const defaultImportTimeout = time.Hour
+
+// importURLRequest 从 URL 导入文件的请求参数
+type importURLRequest struct {
+	URL        string `json:"url" binding:"required"`
+	RegionCode string `json:"region_code" binding:"required"`
+	BucketName string `json:"bucket_name" binding:"required"`
+	Path       string `json:"path"`      // 目标目录，规则与上传时的 X-Custom-Path 相同
+	Filename   string `json:"filename"`  // 为空时从 Content-Disposition 或 URL 推断
+	Overwrite  bool   `json:"overwrite"` // 目标路径已有文件时是否覆盖
+	TaskID     string `json:"task_id"`   // 进度任务ID，为空时自动生成
+}
+
+// importTask 一次导入在后台继续执行所需的信息
+type importTask struct {
+	config     models.OSSConfig
+	storage    oss.StorageService
+	body       io.ReadCloser
+	reader     *upload.Reader
+	hashes     *hashing.Set // 导入过程中计算的摘要
+	size       int64        // 远程声明的大小，未知时为 -1
+	objectKey  string
+	filename   string
+	regionCode string
+	bucketName string
+	taskID     string
+	userID     uint
+	clientIP   string
+	cancel     context.CancelFunc
+}
+
+// importSettings 返回导入配置，未加载配置时视为关闭
+func importSettings() config.ImportConfig {
+	if cfg := config.GetConfig(); cfg != nil {
+		return cfg.Import
+	}
+	return config.ImportConfig{}
+}
+
+// importMaxSize 单个导入文件的大小上限，取导入配置、全局配置和存储桶策略中最严格的值，0 表示不限制
+func importMaxSize(settings config.ImportConfig, policyMax int64) int64 {
+	limit := settings.MaxSize
+	if limit <= 0 {
+		limit = tusMaxSize()
+	}
+	if policyMax > 0 && (limit <= 0 || policyMax < limit) {
+		limit = policyMax
+	}
+	return limit
+}
+
+// ImportURL 从 HTTP(S) URL 拉取文件并保存到存储桶
+// 校验通过后立即返回任务ID，下载和上传在后台进行，进度通过 upload.Manager 查询
+func (h *OSSFileHandler) ImportURL(c *gin.Context) {
+	var req importURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+
+	settings := importSettings()
+	if !settings.Enabled {
+		h.Error(c, utils.CodeForbidden, "未启用从 URL 导入文件")
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if !auth.CheckBucketAccess(h.DB, userID, req.RegionCode, req.BucketName) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return
+	}
+
+	var ossConfig models.OSSConfig
+	if err := h.DB.Where("is_default = ?", true).First(&ossConfig).Error; err != nil {
+		h.Error(c, utils.CodeServerError, "获取默认存储配置失败")
+		return
+	}
+	storage, err := h.storageFactory.GetStorageService(ossConfig.StorageType)
+	if err != nil {
+		h.Error(c, utils.CodeServerError, "获取存储服务失败")
+		return
+	}
+
+	client, err := fetch.NewClient(fetch.Options{
+		AllowPrivate:    settings.AllowPrivate,
+		AllowedNetworks: settings.AllowedNetworks,
+	})
+	if err != nil {
+		logger.Error("导入配置无效", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "导入配置无效")
+		return
+	}
+
+	timeout := defaultImportTimeout
+	if settings.Timeout > 0 {
+		timeout = time.Duration(settings.Timeout) * time.Second
+	}
+	// 下载在请求返回后继续进行，不能使用请求的 context
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	resp, err := client.Get(ctx, req.URL)
+	if err != nil {
+		cancel()
+		logger.Warn("拉取远程文件失败", zap.String("url", req.URL), zap.Error(err))
+		if errors.Is(err, fetch.ErrForbiddenAddress) {
+			h.Error(c, utils.CodeForbidden, "不允许访问该地址")
+			return
+		}
+		h.Error(c, utils.CodeInvalidParams, fmt.Sprintf("拉取远程文件失败: %v", err))
+		return
+	}
+	task := &importTask{
+		config:     ossConfig,
+		storage:    storage,
+		body:       resp.Body,
+		size:       resp.ContentLength,
+		regionCode: req.RegionCode,
+		bucketName: req.BucketName,
+		userID:     userID,
+		clientIP:   c.ClientIP(),
+		cancel:     cancel,
+	}
+	started := false
+	defer func() {
+		if !started {
+			task.close()
+		}
+	}()
+
+	task.filename = req.Filename
+	if task.filename == "" {
+		task.filename = fetch.Filename(resp)
+	}
+	if task.filename == "" {
+		h.Error(c, utils.CodeInvalidParams, "无法从 URL 推断文件名，请指定 filename")
+		return
+	}
+
+	username, _ := c.Get("username")
+	objectKey, ok := buildObjectKey(req.Path, username.(string), task.filename)
+	if !ok {
+		h.Error(c, utils.CodeInvalidParams, "自定义路径包含非法字符")
+		return
+	}
+	task.objectKey = objectKey
+
+	if !req.Overwrite {
+		var existingFile models.OSSFile
+		err := h.DB.Where("object_key = ? AND bucket = ? AND status = ?",
+			objectKey, req.BucketName, "ACTIVE").First(&existingFile).Error
+		if err == nil {
+			h.Error(c, utils.CodeFileExists, "在相同路径下文件已存在，请确认是否要覆盖")
+			return
+		} else if err != gorm.ErrRecordNotFound {
+			h.Error(c, utils.CodeServerError, "检查文件是否存在失败")
+			return
+		}
+	}
+
+	// 远程未声明大小时按 0 预检配额，导入完成后按实际大小重新校验
+	knownSize := task.size
+	if knownSize < 0 {
+		knownSize = 0
+	}
+	if !h.checkQuota(c, userID, req.BucketName, knownSize) {
+		return
+	}
+	uploadPolicy, ok := h.checkPolicy(c, req.RegionCode, req.BucketName, task.filename, task.size)
+	if !ok {
+		return
+	}
+
+	var policyMax int64
+	if uploadPolicy != nil {
+		policyMax = uploadPolicy.MaxFileSize
+	}
+	maxSize := importMaxSize(settings, policyMax)
+	if maxSize > 0 && task.size > maxSize {
+		h.Error(c, utils.CodeInvalidParams, fmt.Sprintf("远程文件大小 %d 字节超过限制 %d 字节", task.size, maxSize))
+		return
+	}
+
+	var body io.Reader = resp.Body
+	if maxSize > 0 {
+		body = fetch.LimitReader(body, maxSize)
+	}
+	body, ok = h.checkContent(c, uploadPolicy, task.filename, body)
+	if !ok {
+		return
+	}
+
+	task.taskID = req.TaskID
+	if task.taskID == "" {
+		task.taskID = uuid.NewString()
+	}
+	task.hashes = hashing.NewSet(hashing.Configured()...)
+	task.reader = upload.NewReader(task.taskID, task.hashes.Reader(body))
+	upload.DefaultManager.Start(task.taskID, knownSize)
+
+	logger.Info("开始从 URL 导入文件",
+		zap.String("task_id", task.taskID),
+		zap.String("url", req.URL),
+		zap.String("object_key", objectKey),
+		zap.Int64("size", task.size),
+	)
+
+	started = true
+	go h.runImport(ctx, task, maxSize)
+
+	h.Success(c, gin.H{
+		"task_id":    task.taskID,
+		"object_key": objectKey,
+		"filename":   task.filename,
+		"size":       task.size,
+	})
+}
+
+// close 关闭远程响应并释放超时 context
+func (t *importTask) close() {
+	t.body.Close()
+	t.cancel()
+}
+
+// runImport 在后台将远程内容以分片方式写入存储桶并保存文件记录
+func (h *OSSFileHandler) runImport(ctx context.Context, task *importTask, maxSize int64) {
+	defer task.close()
+
+	uploadURL, size, err := h.importObject(ctx, task, maxSize)
+	if err != nil {
+		logger.Error("从 URL 导入文件失败",
+			zap.String("task_id", task.taskID),
+			zap.String("object_key", task.objectKey),
+			zap.Error(err),
+		)
+		upload.DefaultManager.Fail(task.taskID, err.Error())
+		return
+	}
+
+	// 远程未声明大小时开始前只按 0 预检了配额，按实际写入的大小重新校验
+	if err := h.quota.Check(task.userID, task.bucketName, size); err != nil {
+		logger.Warn("导入文件的实际大小超出配额，删除对象",
+			zap.String("task_id", task.taskID),
+			zap.String("object_key", task.objectKey),
+			zap.Int64("size", size),
+			zap.Error(err),
+		)
+		h.discardRejectedObject(task.storage, task.objectKey, task.regionCode, task.bucketName)
+		upload.DefaultManager.Fail(task.taskID, err.Error())
+		return
+	}
+
+	ossFile := newFileRecord(task.config, task.objectKey, task.filename, size, task.bucketName, uploadURL, task.userID, task.clientIP)
+	if err := createFileRecordWithHashes(h.DB, &ossFile, task.hashes.Sums()); err != nil {
+		logger.Error("保存文件记录失败", zap.String("object_key", task.objectKey), zap.Error(err))
+		upload.DefaultManager.Fail(task.taskID, "保存文件记录失败")
+		return
+	}
+	upload.DefaultManager.Finish(task.taskID)
+
+	logger.Info("从 URL 导入文件完成",
+		zap.String("task_id", task.taskID),
+		zap.Uint("file_id", ossFile.ID),
+		zap.Int64("size", size),
+	)
+}
+
+// importObject 通过分片上传写入对象，返回下载地址和实际大小
+// 远程大小未知时读到 EOF 为止，分片大小按大小上限计算以保证分片数不超过存储端限制
+func (h *OSSFileHandler) importObject(ctx context.Context, task *importTask, maxSize int64) (string, int64, error) {
+	if task.size == 0 {
+		uploadURL, err := task.storage.UploadToBucket(task.reader, task.objectKey, task.regionCode, task.bucketName)
+		return uploadURL, 0, err
+	}
+
+	sizeHint := task.size
+	if sizeHint < 0 {
+		sizeHint = maxSize
+	}
+	partSize := upload.PartSize(sizeHint, 0)
+
+	uploadID, _, err := task.storage.InitMultipartUploadToBucket(task.objectKey, task.regionCode, task.bucketName)
+	if err != nil {
+		return "", 0, fmt.Errorf("初始化分片上传失败: %v", err)
+	}
+
+	concurrency := defaultMaxChunkConcurrency
+	if cfg := config.GetConfig(); cfg != nil && cfg.App.ChunkConcurrency > 0 {
+		concurrency = cfg.App.ChunkConcurrency
+	}
+	var reader io.Reader = task.reader
+	if task.size < 0 && maxSize <= 0 {
+		// 大小未知且不限制大小时，最多读取存储端分片数上限能容纳的数据
+		reader = fetch.LimitReader(reader, partSize*upload.MaxParts)
+	}
+	parts, err := upload.UploadParts(ctx, reader, upload.PartsOptions{
+		PartSize:    partSize,
+		Size:        task.size,
+		Concurrency: concurrency,
+	}, func(ctx context.Context, partNumber int, data []byte) (string, error) {
+		return h.uploadPart(ctx, task.storage, data, partNumber, uploadID, task.objectKey, task.regionCode, task.bucketName)
+	})
+	if err == nil && len(parts) == 0 {
+		err = errors.New("远程文件内容为空")
+	}
+	if err != nil {
+		h.safeAbortMultipartUpload(task.storage, uploadID, task.objectKey, task.regionCode, task.bucketName)
+		return "", 0, err
+	}
+
+	uploadURL, err := task.storage.CompleteMultipartUploadToBucket(task.objectKey, uploadID, parts, task.regionCode, task.bucketName)
+	if err != nil {
+		h.safeAbortMultipartUpload(task.storage, uploadID, task.objectKey, task.regionCode, task.bucketName)
+		return "", 0, fmt.Errorf("完成分片上传失败: %v", err)
+	}
+	return uploadURL, task.reader.BytesRead(), nil
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/scrub"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// IntegrityHandler 存储完整性巡检处理器（仅管理员）
+type IntegrityHandler struct {
+	*BaseHandler
+	DB       *gorm.DB
+	scrubber *scrub.Scrubber
+}
+
+// NewIntegrityHandler 创建存储完整性巡检处理器
+func NewIntegrityHandler(db *gorm.DB, scrubber *scrub.Scrubber) *IntegrityHandler {
+	return &IntegrityHandler{
+		BaseHandler: NewBaseHandler(),
+		DB:          db,
+		scrubber:    scrubber,
+	}
+}
+
+// Report 巡检报告：各状态文件数、未解决的问题数、巡检覆盖情况以及本实例最近一轮巡检的汇总
+func (h *IntegrityHandler) Report(c *gin.Context) {
+	var statusCounts []struct {
+		Status string `json:"status"`
+		Count  int64  `json:"count"`
+	}
+	if err := h.DB.Model(&models.OSSFile{}).
+		Select("status, count(*) AS count").
+		Where("status IN ?", []string{"ACTIVE", models.FileStatusCorrupt, models.FileStatusMissing}).
+		Group("status").Scan(&statusCounts).Error; err != nil {
+		logger.Error("统计文件状态失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取巡检报告失败")
+		return
+	}
+
+	var issueCounts []struct {
+		Kind  string `json:"kind"`
+		Count int64  `json:"count"`
+	}
+	if err := h.DB.Model(&models.IntegrityIssue{}).
+		Select("kind, count(*) AS count").
+		Where("resolved_at IS NULL").
+		Group("kind").Scan(&issueCounts).Error; err != nil {
+		logger.Error("统计完整性问题失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取巡检报告失败")
+		return
+	}
+
+	var coverage struct {
+		NeverVerified  int64      `json:"never_verified"`
+		OldestVerified *time.Time `json:"oldest_verified_at"`
+	}
+	h.DB.Model(&models.OSSFile{}).Where("status = ? AND verified_at IS NULL", "ACTIVE").Count(&coverage.NeverVerified)
+	h.DB.Model(&models.OSSFile{}).Where("status = ?", "ACTIVE").Select("MIN(verified_at)").Row().Scan(&coverage.OldestVerified)
+
+	lastRun, running := h.scrubber.LastRun()
+	h.Success(c, gin.H{
+		"files":    statusCounts,
+		"issues":   issueCounts,
+		"coverage": coverage,
+		"last_run": lastRun,
+		"running":  running,
+	})
+}
+
+// Issues 分页列出完整性问题，默认只列出未解决的问题
+// 参数：kind、bucket、file_id，resolved=true 时列出已解决的问题，resolved=all 时列出全部
+func (h *IntegrityHandler) Issues(c *gin.Context) {
+	query := h.DB.Model(&models.IntegrityIssue{})
+	switch c.Query("resolved") {
+	case "true":
+		query = query.Where("resolved_at IS NOT NULL")
+	case "all":
+	default:
+		query = query.Where("resolved_at IS NULL")
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if bucket := c.Query("bucket"); bucket != "" {
+		query = query.Where("bucket = ?", bucket)
+	}
+	if fileID := c.Query("file_id"); fileID != "" {
+		query = query.Where("file_id = ?", fileID)
+	}
+
+	pagination := utils.GetPagination(c)
+	var issues []models.IntegrityIssue
+	if err := query.Scopes(utils.Paginate(pagination)).Order("detected_at DESC, id DESC").Find(&issues).Error; err != nil {
+		logger.Error("获取完整性问题列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取完整性问题列表失败")
+		return
+	}
+
+	h.Success(c, utils.GetPaginationResult(pagination, issues))
+}
+
+// Run 立即执行一轮巡检，巡检在后台进行，通过报告接口查看结果
+func (h *IntegrityHandler) Run(c *gin.Context) {
+	if _, running := h.scrubber.LastRun(); running {
+		h.BadRequest(c, "巡检正在进行中")
+		return
+	}
+	h.scrubber.Trigger()
+	h.Success(c, gin.H{"message": "巡检已触发，请稍后查看报告"})
+}
+
+// VerifyFile 立即检查单个文件，content=true 时下载对象校验摘要
+func (h *IntegrityHandler) VerifyFile(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.BadRequest(c, "无效的文件ID")
+		return
+	}
+
+	var file models.OSSFile
+	if err := h.DB.First(&file, fileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.NotFound(c, "文件不存在")
+			return
+		}
+		h.Error(c, utils.CodeServerError, "获取文件信息失败")
+		return
+	}
+
+	result, err := h.scrubber.Verify(&file, c.Query("content") == "true")
+	if err != nil {
+		logger.Error("检查文件完整性失败", zap.Uint("file_id", file.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "检查文件完整性失败: "+err.Error())
+		return
+	}
+	h.Success(c, result)
+}
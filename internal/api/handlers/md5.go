@@ -1,19 +1,51 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/auth"
 	"github.com/myysophia/ossmanager/internal/db"
 	"github.com/myysophia/ossmanager/internal/db/models"
 	"github.com/myysophia/ossmanager/internal/function"
+	"github.com/myysophia/ossmanager/internal/hashing"
 	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/tasks"
+	"github.com/myysophia/ossmanager/internal/utils"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 	"strconv"
 )
 
+// maxHashSearchResults 按摘要搜索时最多返回的文件数
+const maxHashSearchResults = 100
+
+// TaskTypeBulkHash 为存储桶（或其中的目录）下的所有文件创建摘要计算任务
+const TaskTypeBulkHash = "bulk_hash"
+
+// bulkHashBatchSize 批量计算摘要时每批读取的文件记录数
+const bulkHashBatchSize = 500
+
+// bulkHashParams 批量计算摘要任务的参数，Prefix 为空时处理整个存储桶
+type bulkHashParams struct {
+	BucketName string `json:"bucket_name"`
+	Prefix     string `json:"prefix"`
+}
+
+// bulkHashResult 批量计算摘要任务的结果
+type bulkHashResult struct {
+	Triggered int `json:"triggered"`
+	Failed    int `json:"failed"`
+}
+
 // MD5Handler MD5计算处理器
 type MD5Handler struct {
 	*BaseHandler
 	md5Calculator *function.MD5Calculator
+	tasks         *tasks.Manager // 后台任务管理器，由 RegisterTasks 设置
 }
 
 // NewMD5Handler 创建MD5计算处理器
@@ -52,7 +84,7 @@ func (h *MD5Handler) TriggerCalculation(c *gin.Context) {
 	}
 
 	h.Success(c, gin.H{
-		"message": "MD5计算已触发，请稍后查询结果",
+		"message": "摘要计算已触发，请稍后查询结果",
 		"file_id": file.ID,
 	})
 }
@@ -89,3 +121,162 @@ func (h *MD5Handler) GetMD5(c *gin.Context) {
 		"status":  "completed",
 	})
 }
+
+// GetHashes 获取文件的所有摘要（MD5、SHA-1、SHA-256、CRC64-ECMA）
+func (h *MD5Handler) GetHashes(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.BadRequest(c, "无效的文件ID")
+		return
+	}
+
+	var file models.OSSFile
+	if err := db.GetDB().First(&file, fileID).Error; err != nil {
+		h.NotFound(c, "文件不存在")
+		return
+	}
+	if !auth.CheckBucketAccess(db.GetDB(), c.GetUint("userID"), "", file.Bucket) {
+		h.Forbidden(c, "没有权限访问该存储桶")
+		return
+	}
+
+	sums, err := hashing.Load(db.GetDB(), file.ID)
+	if err != nil {
+		logger.Error("获取文件摘要失败", zap.Uint("file_id", file.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取文件摘要失败")
+		return
+	}
+	if file.MD5 != "" {
+		sums[hashing.MD5] = file.MD5
+	}
+
+	h.Success(c, gin.H{
+		"file_id":    file.ID,
+		"md5_status": file.MD5Status,
+		"hashes":     sums,
+		"missing":    hashing.Missing(sums, h.md5Calculator.Algorithms()),
+	})
+}
+
+// SearchByHash 按摘要查找用户可访问存储桶中的文件
+// 参数 algorithm 为 md5、sha1、sha256 或 crc64ecma，value 为十六进制摘要（CRC64 为十进制）
+func (h *MD5Handler) SearchByHash(c *gin.Context) {
+	alg, err := hashing.ParseAlgorithm(c.Query("algorithm"))
+	if err != nil {
+		h.BadRequest(c, err.Error())
+		return
+	}
+	value, err := hashing.NormalizeValue(alg, c.Query("value"))
+	if err != nil {
+		h.BadRequest(c, err.Error())
+		return
+	}
+
+	buckets, err := auth.GetUserAccessibleBuckets(db.GetDB(), c.GetUint("userID"), "")
+	if err != nil {
+		h.Error(c, utils.CodeServerError, "获取可访问桶列表失败")
+		return
+	}
+
+	matched := db.GetDB().Model(&models.FileHash{}).Select("file_id").Where("algorithm = ? AND value = ?", alg, value)
+	query := db.GetDB().Where("bucket IN ? AND status = ?", buckets, "ACTIVE")
+	if alg == hashing.MD5 {
+		// 早于摘要表写入的文件只在 oss_files.md5 中有记录
+		query = query.Where("md5 = ? OR id IN (?)", value, matched)
+	} else {
+		query = query.Where("id IN (?)", matched)
+	}
+
+	var files []models.OSSFile
+	if err := query.Order("id DESC").Limit(maxHashSearchResults).Find(&files).Error; err != nil {
+		logger.Error("按摘要搜索文件失败", zap.String("algorithm", alg), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "按摘要搜索文件失败")
+		return
+	}
+
+	h.Success(c, gin.H{
+		"algorithm": alg,
+		"value":     value,
+		"items":     files,
+		"total":     len(files),
+	})
+}
+
+// RegisterTasks 注册摘要相关的后台任务类型
+func (h *MD5Handler) RegisterTasks(m *tasks.Manager) {
+	h.tasks = m
+	m.Register(TaskTypeBulkHash, h.runBulkHash)
+}
+
+// BulkCalculate 提交后台任务，为存储桶（或其中的目录）下所有缺少摘要的文件触发摘要计算
+func (h *MD5Handler) BulkCalculate(c *gin.Context) {
+	var req bulkHashParams
+	if err := c.ShouldBindJSON(&req); err != nil || req.BucketName == "" {
+		h.BadRequest(c, "无效的请求参数")
+		return
+	}
+	req.Prefix = strings.TrimLeft(req.Prefix, "/")
+
+	buckets, err := auth.GetUserAccessibleBuckets(db.GetDB(), c.GetUint("userID"), "")
+	if err != nil {
+		h.Error(c, utils.CodeServerError, "获取可访问桶列表失败")
+		return
+	}
+	if !slices.Contains(buckets, req.BucketName) {
+		h.Forbidden(c, "没有权限访问该存储桶")
+		return
+	}
+
+	submitTask(c, h.BaseHandler, h.tasks, TaskTypeBulkHash, req)
+}
+
+// runBulkHash 逐批为文件触发摘要计算，已计算所有算法的文件由 TriggerCalculation 跳过
+func (h *MD5Handler) runBulkHash(ctx context.Context, task *models.Task, progress *tasks.Progress) (any, error) {
+	var params bulkHashParams
+	if err := tasks.Decode(task, &params); err != nil {
+		return nil, err
+	}
+
+	query := db.GetDB().Model(&models.OSSFile{}).Where("bucket = ? AND status = ?", params.BucketName, "ACTIVE")
+	if params.Prefix != "" {
+		query = query.Where("object_key LIKE ?", escapeLike(params.Prefix)+"%")
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("统计文件数失败: %w", err)
+	}
+	progress.SetTotal(total)
+
+	var result bulkHashResult
+	var lastID uint
+	for {
+		var files []models.OSSFile
+		if err := query.Where("id > ?", lastID).Order("id").Limit(bulkHashBatchSize).Find(&files).Error; err != nil {
+			return result, fmt.Errorf("获取文件列表失败: %w", err)
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			lastID = file.ID
+			if err := h.md5Calculator.TriggerCalculation(&file); err != nil {
+				logger.Warn("触发摘要计算失败", zap.Uint("task_id", task.ID), zap.Uint("file_id", file.ID), zap.Error(err))
+				result.Failed++
+			} else {
+				result.Triggered++
+			}
+			progress.Add(1)
+		}
+	}
+
+	if result.Failed > 0 {
+		return result, fmt.Errorf("%d 个文件触发摘要计算失败", result.Failed)
+	}
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/janitor"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+)
+
+// MultipartJanitorHandler 未完成分片上传管理处理器（仅管理员）
+type MultipartJanitorHandler struct {
+	*BaseHandler
+	janitor *janitor.MultipartJanitor
+}
+
+// NewMultipartJanitorHandler 创建未完成分片上传管理处理器
+func NewMultipartJanitorHandler(j *janitor.MultipartJanitor) *MultipartJanitorHandler {
+	return &MultipartJanitorHandler{
+		BaseHandler: NewBaseHandler(),
+		janitor:     j,
+	}
+}
+
+// List 列出未完成的分片上传及其存在时间和占用空间
+func (h *MultipartJanitorHandler) List(c *gin.Context) {
+	var minAge time.Duration
+	if hoursStr := c.Query("min_age_hours"); hoursStr != "" {
+		hours, err := strconv.ParseFloat(hoursStr, 64)
+		if err != nil || hours < 0 {
+			h.BadRequest(c, "min_age_hours 参数无效")
+			return
+		}
+		minAge = time.Duration(hours * float64(time.Hour))
+	}
+
+	uploads, err := h.janitor.List(c.Query("region_code"), c.Query("bucket_name"), minAge)
+	if err != nil {
+		logger.Error("列出未完成分片上传失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "列出未完成分片上传失败")
+		return
+	}
+
+	h.Success(c, gin.H{
+		"items":        uploads,
+		"total":        len(uploads),
+		"total_bytes":  janitor.TotalSize(uploads),
+		"policy_hours": h.janitor.MaxAge().Hours(),
+	})
+}
+
+// Abort 中止指定的分片上传
+func (h *MultipartJanitorHandler) Abort(c *gin.Context) {
+	var req struct {
+		RegionCode string `json:"region_code" binding:"required"`
+		BucketName string `json:"bucket_name" binding:"required"`
+		ObjectKey  string `json:"object_key" binding:"required"`
+		UploadID   string `json:"upload_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.BadRequest(c, "参数错误")
+		return
+	}
+
+	if err := h.janitor.Abort(req.RegionCode, req.BucketName, req.ObjectKey, req.UploadID); err != nil {
+		logger.Error("中止分片上传失败", zap.String("upload_id", req.UploadID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "中止分片上传失败")
+		return
+	}
+
+	h.Success(c, nil)
+}
+
+// Purge 按策略中止超过指定时长的分片上传，未指定时长时使用配置的保留时间
+func (h *MultipartJanitorHandler) Purge(c *gin.Context) {
+	var req struct {
+		RegionCode     string  `json:"region_code"`
+		BucketName     string  `json:"bucket_name"`
+		OlderThanHours float64 `json:"older_than_hours"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.BadRequest(c, "参数错误")
+		return
+	}
+
+	age := h.janitor.MaxAge()
+	if req.OlderThanHours > 0 {
+		age = time.Duration(req.OlderThanHours * float64(time.Hour))
+	}
+
+	aborted, err := h.janitor.AbortOlderThan(req.RegionCode, req.BucketName, age)
+	if err != nil {
+		logger.Error("清理未完成分片上传失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "清理未完成分片上传失败")
+		return
+	}
+
+	h.Success(c, gin.H{
+		"items":       aborted,
+		"total":       len(aborted),
+		"total_bytes": janitor.TotalSize(aborted),
+	})
+}
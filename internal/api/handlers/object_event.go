@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/events"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/search"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxEventBodySize 单个事件通知请求体的大小上限
+const maxEventBodySize = 1 << 20
+
+// ObjectEventHandler 存储桶对象事件处理器：接收存储服务的事件通知，管理员查看处理结果
+type ObjectEventHandler struct {
+	*BaseHandler
+	DB       *gorm.DB
+	ingestor *events.Ingestor
+}
+
+// NewObjectEventHandler 创建存储桶对象事件处理器
+func NewObjectEventHandler(db *gorm.DB, ingestor *events.Ingestor) *ObjectEventHandler {
+	return &ObjectEventHandler{
+		BaseHandler: NewBaseHandler(),
+		DB:          db,
+		ingestor:    ingestor,
+	}
+}
+
+// Receive 接收存储服务的事件通知（webhook），事件保存后由后台延迟处理
+// 支持阿里云 OSS 事件、S3 事件以及 MNS/SQS/SNS 推送的封装；令牌通过 Authorization: Bearer、X-Event-Token 头或 token 参数传递
+func (h *ObjectEventHandler) Receive(c *gin.Context) {
+	if !h.ingestor.Enabled() {
+		h.NotFound(c, "存储桶事件接入未启用")
+		return
+	}
+	if !h.ingestor.Authorize(eventToken(c)) {
+		h.Unauthorized(c, "无效的事件令牌")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxEventBodySize))
+	if err != nil {
+		h.BadRequest(c, "读取请求失败")
+		return
+	}
+	received, err := events.Parse(body)
+	if errors.Is(err, events.ErrConfirmation) {
+		logger.Warn("收到主题订阅确认请求，请访问其中的地址完成订阅", zap.String("detail", err.Error()))
+		h.Success(c, gin.H{"received": 0})
+		return
+	}
+	if err != nil {
+		logger.Warn("无法解析存储桶事件", zap.Error(err))
+		h.BadRequest(c, err.Error())
+		return
+	}
+	if err := h.ingestor.Enqueue(received); err != nil {
+		logger.Error("保存存储桶事件失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "保存事件失败")
+		return
+	}
+	h.Success(c, gin.H{"received": len(received)})
+}
+
+// List 分页列出收到的事件及处理结果，参数：status、type、bucket、object_key（前缀）
+func (h *ObjectEventHandler) List(c *gin.Context) {
+	query := h.DB.Model(&models.ObjectEvent{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if bucket := c.Query("bucket"); bucket != "" {
+		query = query.Where("bucket = ?", bucket)
+	}
+	if objectKey := c.Query("object_key"); objectKey != "" {
+		query = query.Where("object_key LIKE ?", search.EscapeLike(objectKey)+"%")
+	}
+
+	pagination := utils.GetPagination(c)
+	var list []models.ObjectEvent
+	if err := query.Scopes(utils.Paginate(pagination)).Order("id DESC").Find(&list).Error; err != nil {
+		logger.Error("获取存储桶事件列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取存储桶事件列表失败")
+		return
+	}
+	h.Success(c, utils.GetPaginationResult(pagination, list))
+}
+
+// eventToken 读取 webhook 请求携带的令牌；存储服务的推送通常不能设置请求头，可以把令牌放在地址参数中
+func eventToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if token := c.GetHeader("X-Event-Token"); token != "" {
+		return token
+	}
+	return c.Query("token")
+}
@@ -1,26 +1,34 @@
 package handlers
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/checksum"
 	"github.com/myysophia/ossmanager/internal/logger"
 
 	"github.com/gin-gonic/gin"
 	"github.com/myysophia/ossmanager/internal/config"
 	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
 	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/policy"
+	"github.com/myysophia/ossmanager/internal/quota"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"github.com/myysophia/ossmanager/internal/scrub"
+	"github.com/myysophia/ossmanager/internal/search"
+	"github.com/myysophia/ossmanager/internal/tasks"
+	"github.com/myysophia/ossmanager/internal/trash"
 	"github.com/myysophia/ossmanager/internal/upload"
 	"github.com/myysophia/ossmanager/internal/utils"
 	"go.uber.org/zap"
@@ -59,6 +67,10 @@ type OSSFileHandler struct {
 	*BaseHandler
 	storageFactory oss.StorageFactory
 	DB             *gorm.DB
+	quota          *quota.Service
+	policy         *policy.Service
+	tasks          *tasks.Manager // 后台任务管理器，由 RegisterTasks 设置
+	trash          *trash.Bin     // 回收站，由 UseTrash 设置，未设置或未启用时删除立即生效
 }
 
 func NewOSSFileHandler(storageFactory oss.StorageFactory, db *gorm.DB) *OSSFileHandler {
@@ -66,9 +78,128 @@ func NewOSSFileHandler(storageFactory oss.StorageFactory, db *gorm.DB) *OSSFileH
 		BaseHandler:    NewBaseHandler(),
 		storageFactory: storageFactory,
 		DB:             db,
+		quota:          quota.NewService(db),
+		policy:         policy.NewService(db),
 	}
 }
 
+// fileDownloadURL 为文件生成预签名下载链接
+// 阿里云 OSS 按上传时记录的下载地址定位存储桶所在区域，没有记录时使用默认存储桶
+func fileDownloadURL(storage oss.StorageService, file *models.OSSFile, expiration time.Duration) (string, time.Time, error) {
+	if aliyunStorage, ok := storage.(*oss.AliyunOSSService); ok && file.DownloadURL != "" {
+		return aliyunStorage.GenerateDownloadURLWithBucket(file.ObjectKey, file.DownloadURL, expiration)
+	}
+	return storage.GenerateDownloadURL(file.ObjectKey, expiration)
+}
+
+// UseTrash 设置回收站，删除文件时先移入回收站
+func (h *OSSFileHandler) UseTrash(bin *trash.Bin) {
+	h.trash = bin
+}
+
+// checkQuota 校验上传是否超出配额，超出或校验失败时写入错误响应并返回 false
+func (h *OSSFileHandler) checkQuota(c *gin.Context, userID uint, bucketName string, size int64) bool {
+	err := h.quota.Check(userID, bucketName, size)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, quota.ErrQuotaExceeded) {
+		h.Error(c, utils.CodeQuotaExceeded, err.Error())
+		return false
+	}
+	logger.Error("校验存储配额失败", zap.Uint("user_id", userID), zap.String("bucket", bucketName), zap.Error(err))
+	h.Error(c, utils.CodeServerError, "校验存储配额失败")
+	return false
+}
+
+// checkPolicy 按存储桶上传策略校验文件名和大小，通过时返回策略（可能为 nil）
+func (h *OSSFileHandler) checkPolicy(c *gin.Context, regionCode, bucketName, filename string, size int64) (*policy.Policy, bool) {
+	p, err := h.policy.Load(regionCode, bucketName)
+	if err != nil {
+		logger.Error("获取上传策略失败", zap.String("bucket", bucketName), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取上传策略失败")
+		return nil, false
+	}
+	if err := p.CheckFile(filename, size); err != nil {
+		h.Error(c, utils.CodePolicyViolation, err.Error())
+		return nil, false
+	}
+	return p, true
+}
+
+// checkContent 检测文件头并按策略校验，返回可以从头读取完整内容的 reader
+func (h *OSSFileHandler) checkContent(c *gin.Context, p *policy.Policy, filename string, r io.Reader) (io.Reader, bool) {
+	if !p.NeedsContent() {
+		return r, true
+	}
+	head, r, err := policy.Peek(r)
+	if err != nil {
+		h.Error(c, utils.CodeServerError, "读取文件失败")
+		return nil, false
+	}
+	if err := p.CheckContent(filename, head); err != nil {
+		logger.Warn("上传内容不符合策略", zap.String("filename", filename), zap.Error(err))
+		h.Error(c, utils.CodePolicyViolation, err.Error())
+		return nil, false
+	}
+	return r, true
+}
+
+// checksumVerifier 解析客户端通过 Content-MD5 / X-Checksum-Sha256 声明的校验和
+// 表单上传时校验和描述的是文件内容而不是整个请求体；头格式错误时写入错误响应并返回 false
+func (h *OSSFileHandler) checksumVerifier(c *gin.Context) (*checksum.Verifier, bool) {
+	expected, err := checksum.FromHeader(c.Request.Header)
+	if err != nil {
+		h.Error(c, utils.CodeInvalidParams, err.Error())
+		return nil, false
+	}
+	return checksum.NewVerifier(expected), true
+}
+
+// verifyUploaded 校验已写入存储端的内容，不一致时删除对象，保证不会为损坏的内容创建文件记录
+func (h *OSSFileHandler) verifyUploaded(c *gin.Context, storage oss.StorageService, verifier *checksum.Verifier, objectKey, regionCode, bucketName string) bool {
+	err := verifier.Verify()
+	if err == nil {
+		return true
+	}
+
+	logger.Warn("上传内容校验和不一致，删除对象",
+		zap.String("bucket", bucketName),
+		zap.String("object_key", objectKey),
+		zap.Error(err))
+	h.discardRejectedObject(storage, objectKey, regionCode, bucketName)
+	h.Error(c, utils.CodeChecksumMismatch, err.Error())
+	return false
+}
+
+// discardRejectedObject 删除未通过校验的对象
+// 覆盖上传时该对象键仍属于一条在用的文件记录，删除会让该记录指向不存在的对象，此时保留对象
+func (h *OSSFileHandler) discardRejectedObject(storage oss.StorageService, objectKey, regionCode, bucketName string) {
+	var live int64
+	if err := h.DB.Model(&models.OSSFile{}).
+		Where("bucket = ? AND object_key = ? AND status IN ?", bucketName, objectKey, models.LiveFileStatuses).
+		Count(&live).Error; err != nil {
+		logger.Error("检查对象是否被文件记录使用失败，保留对象", zap.String("object_key", objectKey), zap.Error(err))
+		return
+	}
+	if live > 0 {
+		logger.Warn("对象仍被在用的文件记录使用，保留对象", zap.String("bucket", bucketName), zap.String("object_key", objectKey))
+		return
+	}
+	if err := storage.DeleteObjectFromBucket(objectKey, regionCode, bucketName); err != nil {
+		logger.Error("删除未通过校验的对象失败", zap.String("object_key", objectKey), zap.Error(err))
+	}
+}
+
+// chunkedUploadError 分片上传失败时写入错误响应，区分校验和不一致和其他错误
+func (h *OSSFileHandler) chunkedUploadError(c *gin.Context, err error) {
+	if errors.Is(err, checksum.ErrMismatch) {
+		h.Error(c, utils.CodeChecksumMismatch, err.Error())
+		return
+	}
+	h.Error(c, utils.CodeServerError, err.Error())
+}
+
 // Upload 上传文件 - 智能选择上传方式
 func (h *OSSFileHandler) Upload(c *gin.Context) {
 	// 检查Content-Type以确定使用哪种上传方式
@@ -82,6 +213,9 @@ func (h *OSSFileHandler) Upload(c *gin.Context) {
 		}
 	}
 
+	// 在读取请求体（包括解析表单）之前应用带宽限速
+	throttleRequestBody(c)
+
 	// 如果是multipart/form-data，使用表单上传方式
 	if strings.Contains(contentType, "multipart/form-data") {
 		h.uploadFormFileWithChunking(c, chunkThreshold)
@@ -125,6 +259,15 @@ func (h *OSSFileHandler) uploadFormFileWithChunking(c *gin.Context, chunkThresho
 		return
 	}
 
+	if !h.checkQuota(c, userID, bucketName, file.Size) {
+		return
+	}
+
+	uploadPolicy, ok := h.checkPolicy(c, regionCode, bucketName, file.Filename, file.Size)
+	if !ok {
+		return
+	}
+
 	// 获取存储服务
 	storage, err := h.storageFactory.GetStorageService(config.StorageType)
 	if err != nil {
@@ -135,30 +278,12 @@ func (h *OSSFileHandler) uploadFormFileWithChunking(c *gin.Context, chunkThresho
 	// 检查是否强制覆盖
 	forceOverwrite := c.GetHeader("X-Force-Overwrite") == "true"
 
-	// 声明 objectKey 变量
-	var objectKey string
-
-	// 获取自定义路径
-	customPath := c.GetHeader("X-Custom-Path")
-	if customPath != "" {
-		// 清理和验证自定义路径
-		customPath = strings.Trim(customPath, "/")
-		// 验证路径中不包含危险字符
-		if strings.Contains(customPath, "..") || strings.ContainsAny(customPath, "\\<>:\"|?*") {
-			h.Error(c, utils.CodeInvalidParams, "自定义路径包含非法字符")
-			return
-		}
-		// 使用用户自定义路径
-		if customPath == "" {
-			// 自定义路径为空，直接上传到根目录
-			objectKey = file.Filename
-		} else {
-			objectKey = customPath + "/" + file.Filename
-		}
-	} else {
-		// 没有提供自定义路径，使用固定路径生成方式
-		username, _ := c.Get("username")
-		objectKey = utils.GenerateFixedObjectKey(username.(string), file.Filename)
+	// 根据自定义路径生成 objectKey
+	username, _ := c.Get("username")
+	objectKey, ok := buildObjectKey(c.GetHeader("X-Custom-Path"), username.(string), file.Filename)
+	if !ok {
+		h.Error(c, utils.CodeInvalidParams, "自定义路径包含非法字符")
+		return
 	}
 
 	// 如果不是强制覆盖，检查文件是否已存在（基于完整路径）
@@ -194,13 +319,23 @@ func (h *OSSFileHandler) uploadFormFileWithChunking(c *gin.Context, chunkThresho
 	}
 	defer src.Close()
 
+	body, ok := h.checkContent(c, uploadPolicy, file.Filename, src)
+	if !ok {
+		return
+	}
+	verifier, ok := h.checksumVerifier(c)
+	if !ok {
+		return
+	}
+	body = verifier.Reader(body)
+
 	// 根据文件大小选择上传方式
 	if file.Size <= chunkThreshold {
 		// 简单上传
 		logger.Info("使用简单上传", zap.Int64("file_size", file.Size), zap.Int64("threshold", chunkThreshold))
 		upload.DefaultManager.Start(taskID, file.Size)
 
-		uploadURL, err := storage.UploadToBucketWithProgress(src, objectKey, regionCode, bucketName, func(consumed, total int64) {
+		uploadURL, err := storage.UploadToBucketWithProgress(body, objectKey, regionCode, bucketName, func(consumed, total int64) {
 			if total == 0 {
 				total = file.Size
 			}
@@ -213,20 +348,24 @@ func (h *OSSFileHandler) uploadFormFileWithChunking(c *gin.Context, chunkThresho
 		}
 		upload.DefaultManager.Finish(taskID)
 
+		if !h.verifyUploaded(c, storage, verifier, objectKey, regionCode, bucketName) {
+			return
+		}
+
 		// 保存文件记录并返回
-		h.saveFileRecord(c, config, objectKey, file.Filename, file.Size, bucketName, uploadURL)
+		h.saveFileRecord(c, config, objectKey, file.Filename, file.Size, bucketName, uploadURL, verifier.Sums())
 	} else {
 		// 分片上传
 		logger.Info("使用分片上传", zap.Int64("file_size", file.Size), zap.Int64("threshold", chunkThreshold))
-		uploadURL, err := h.uploadFileWithChunks(c, storage, src, objectKey, regionCode, bucketName, file.Size, taskID, file.Filename)
+		uploadURL, err := h.uploadFileWithChunks(c, storage, body, verifier, objectKey, regionCode, bucketName, file.Size, taskID, file.Filename)
 		if err != nil {
-			h.Error(c, utils.CodeServerError, err.Error())
+			h.chunkedUploadError(c, err)
 			upload.DefaultManager.Finish(taskID)
 			return
 		}
 
 		// 保存文件记录并返回
-		h.saveFileRecord(c, config, objectKey, file.Filename, file.Size, bucketName, uploadURL)
+		h.saveFileRecord(c, config, objectKey, file.Filename, file.Size, bucketName, uploadURL, verifier.Sums())
 	}
 }
 
@@ -272,6 +411,15 @@ func (h *OSSFileHandler) uploadStreamWithChunking(c *gin.Context, chunkThreshold
 		return
 	}
 
+	if !h.checkQuota(c, userID, bucketName, contentLength) {
+		return
+	}
+
+	uploadPolicy, ok := h.checkPolicy(c, regionCode, bucketName, originalFilename, contentLength)
+	if !ok {
+		return
+	}
+
 	// 获取存储服务
 	storage, err := h.storageFactory.GetStorageService(config.StorageType)
 	if err != nil {
@@ -282,30 +430,12 @@ func (h *OSSFileHandler) uploadStreamWithChunking(c *gin.Context, chunkThreshold
 	// 检查是否强制覆盖
 	forceOverwrite := c.GetHeader("X-Force-Overwrite") == "true"
 
-	// 声明 objectKey 变量
-	var objectKey string
-
-	// 获取自定义路径
-	customPath := c.GetHeader("X-Custom-Path")
-	if customPath != "" {
-		// 清理和验证自定义路径
-		customPath = strings.Trim(customPath, "/")
-		// 验证路径中不包含危险字符
-		if strings.Contains(customPath, "..") || strings.ContainsAny(customPath, "\\<>:\"|?*") {
-			h.Error(c, utils.CodeInvalidParams, "自定义路径包含非法字符")
-			return
-		}
-		// 使用用户自定义路径
-		if customPath == "" {
-			// 自定义路径为空，直接上传到根目录
-			objectKey = originalFilename
-		} else {
-			objectKey = customPath + "/" + originalFilename
-		}
-	} else {
-		// 没有提供自定义路径，使用固定路径生成方式
-		username, _ := c.Get("username")
-		objectKey = utils.GenerateFixedObjectKey(username.(string), originalFilename)
+	// 根据自定义路径生成 objectKey
+	username, _ := c.Get("username")
+	objectKey, ok := buildObjectKey(c.GetHeader("X-Custom-Path"), username.(string), originalFilename)
+	if !ok {
+		h.Error(c, utils.CodeInvalidParams, "自定义路径包含非法字符")
+		return
 	}
 
 	// 如果不是强制覆盖，检查文件是否已存在（基于完整路径）
@@ -325,6 +455,24 @@ func (h *OSSFileHandler) uploadStreamWithChunking(c *gin.Context, chunkThreshold
 		}
 	}
 
+	verifier, ok := h.checksumVerifier(c)
+	if !ok {
+		return
+	}
+	// 声明的内容已存在时直接完成，不读取请求体（使用 Expect: 100-continue 的客户端不会发送内容）
+	if c.GetHeader("X-Upload-Id") == "" && c.Query("upload_id") == "" {
+		if ossFile := h.deduplicate(c, storage, uploadPolicy, config, verifier.Expected(), contentLength, objectKey, originalFilename, bucketName); ossFile != nil {
+			h.Success(c, ossFile)
+			return
+		}
+	}
+
+	body, ok := h.checkContent(c, uploadPolicy, originalFilename, c.Request.Body)
+	if !ok {
+		return
+	}
+	body = verifier.Reader(body)
+
 	// 获取任务ID
 	taskID := c.GetHeader("Upload-Task-ID")
 	if taskID == "" {
@@ -340,7 +488,7 @@ func (h *OSSFileHandler) uploadStreamWithChunking(c *gin.Context, chunkThreshold
 		logger.Info("使用简单上传", zap.Int64("content_length", contentLength), zap.Int64("threshold", chunkThreshold))
 		upload.DefaultManager.Start(taskID, contentLength)
 
-		uploadURL, err := storage.UploadToBucketWithProgress(c.Request.Body, objectKey, regionCode, bucketName, func(consumed, total int64) {
+		uploadURL, err := storage.UploadToBucketWithProgress(body, objectKey, regionCode, bucketName, func(consumed, total int64) {
 			if total == 0 {
 				total = contentLength
 			}
@@ -353,46 +501,73 @@ func (h *OSSFileHandler) uploadStreamWithChunking(c *gin.Context, chunkThreshold
 		}
 		upload.DefaultManager.Finish(taskID)
 
+		if !h.verifyUploaded(c, storage, verifier, objectKey, regionCode, bucketName) {
+			return
+		}
+
 		// 保存文件记录并返回
-		h.saveFileRecord(c, config, objectKey, originalFilename, contentLength, bucketName, uploadURL)
+		h.saveFileRecord(c, config, objectKey, originalFilename, contentLength, bucketName, uploadURL, verifier.Sums())
 	} else {
 		// 分片上传
 		logger.Info("使用分片上传", zap.Int64("content_length", contentLength), zap.Int64("threshold", chunkThreshold))
-		uploadURL, err := h.uploadFileWithChunks(c, storage, c.Request.Body, objectKey, regionCode, bucketName, contentLength, taskID, originalFilename)
+		uploadURL, err := h.uploadFileWithChunks(c, storage, body, verifier, objectKey, regionCode, bucketName, contentLength, taskID, originalFilename)
 		if err != nil {
-			h.Error(c, utils.CodeServerError, err.Error())
+			h.chunkedUploadError(c, err)
 			upload.DefaultManager.Finish(taskID)
 			return
 		}
 
 		// 保存文件记录并返回
-		h.saveFileRecord(c, config, objectKey, originalFilename, contentLength, bucketName, uploadURL)
+		h.saveFileRecord(c, config, objectKey, originalFilename, contentLength, bucketName, uploadURL, verifier.Sums())
 	}
 }
 
-// uploadFileWithChunks 分片上传文件
-func (h *OSSFileHandler) uploadFileWithChunks(c *gin.Context, storage oss.StorageService, reader io.Reader, objectKey, regionCode, bucketName string, totalSize int64, taskID, originalFilename string) (string, error) {
-	// 默认分片大小：10MB
-	chunkSize := int64(10 * 1024 * 1024)
-	if chunkSizeStr := c.GetHeader("X-Chunk-Size"); chunkSizeStr != "" {
-		if size, err := strconv.ParseInt(chunkSizeStr, 10, 64); err == nil && size > 0 {
-			chunkSize = size
-		}
+// buildObjectKey 根据自定义路径生成对象键
+// 未提供自定义路径时使用固定路径生成方式；自定义路径包含非法字符时返回 false
+func buildObjectKey(customPath, username, filename string) (string, bool) {
+	if customPath == "" {
+		return utils.GenerateFixedObjectKey(username, filename), true
+	}
+
+	// 清理和验证自定义路径
+	customPath = strings.Trim(customPath, "/")
+	// 验证路径中不包含危险字符
+	if strings.Contains(customPath, "..") || strings.ContainsAny(customPath, "\\<>:\"|?*") {
+		return "", false
 	}
+	// 自定义路径为空，直接上传到根目录
+	if customPath == "" {
+		return filename, true
+	}
+	return customPath + "/" + filename, true
+}
+
+// defaultMaxChunkConcurrency 未配置 chunk_concurrency 时客户端可请求的最大分片并发数
+const defaultMaxChunkConcurrency = 4
 
-	// 并发量，默认为配置值或1
-	concurrency := 1
-	cfg := config.GetConfig()
-	if cfg != nil && cfg.App.ChunkConcurrency > 0 {
-		concurrency = cfg.App.ChunkConcurrency
+// chunkConcurrency 分片上传并发数：默认取配置值，客户端可通过 X-Chunk-Concurrency 调整，但不能超过配置值
+func chunkConcurrency(c *gin.Context) int {
+	concurrency, limit := 1, defaultMaxChunkConcurrency
+	if cfg := config.GetConfig(); cfg != nil && cfg.App.ChunkConcurrency > 0 {
+		concurrency, limit = cfg.App.ChunkConcurrency, cfg.App.ChunkConcurrency
 	}
-	if concStr := c.GetHeader("X-Chunk-Concurrency"); concStr != "" {
-		if cc, err := strconv.Atoi(concStr); err == nil && cc > 0 {
-			concurrency = cc
+	if cc, err := strconv.Atoi(c.GetHeader("X-Chunk-Concurrency")); err == nil && cc > 0 {
+		concurrency = cc
+		if concurrency > limit {
+			concurrency = limit
 		}
 	}
+	return concurrency
+}
 
-	// 计算总分片数
+// uploadFileWithChunks 分片上传文件
+// 分片读入可复用的缓冲区后由有限个 worker 并发上传，内存占用不超过 并发数 × 分片大小；
+// 分片大小按文件大小自动放大以满足存储端 10000 片的限制。
+// reader 需经过 verifier 计算摘要，合并分片前校验，不一致时中止分片上传
+func (h *OSSFileHandler) uploadFileWithChunks(c *gin.Context, storage oss.StorageService, reader io.Reader, verifier *checksum.Verifier, objectKey, regionCode, bucketName string, totalSize int64, taskID, originalFilename string) (string, error) {
+	requestedSize, _ := strconv.ParseInt(c.GetHeader("X-Chunk-Size"), 10, 64)
+	chunkSize := upload.PartSize(totalSize, requestedSize)
+	concurrency := chunkConcurrency(c)
 	totalChunks := int((totalSize + chunkSize - 1) / chunkSize)
 
 	resumeUploadID := c.GetHeader("X-Upload-Id")
@@ -424,20 +599,28 @@ func (h *OSSFileHandler) uploadFileWithChunks(c *gin.Context, storage oss.Storag
 		zap.Int64("total_size", totalSize),
 		zap.Int64("chunk_size", chunkSize),
 		zap.Int("total_chunks", totalChunks),
+		zap.Int("concurrency", concurrency),
 	)
 
 	// 开始分片上传进度追踪
 	upload.DefaultManager.StartWithChunks(taskID, totalSize, true, totalChunks)
 
-	var parts []oss.Part
-	var uploadedBytes int64
-	partNumber := 1
 	// 包装请求体以在读取过程中实时更新上传进度
 	progressReader := upload.NewReader(taskID, reader)
-	// 创建带缓冲的reader，并设置合理的缓冲区大小
-	//bufferedReader := bufio.NewReaderSize(reader, int(chunkSize))
-	bufferedReader := bufio.NewReaderSize(progressReader, int(chunkSize))
 
+	// 读取分片超时时间，可通过头部 X-Chunk-Read-Timeout 调整，默认 5 分钟
+	readTimeout := 5 * time.Minute
+	if t, err := strconv.Atoi(c.GetHeader("X-Chunk-Read-Timeout")); err == nil && t > 0 {
+		readTimeout = time.Duration(t) * time.Second
+	}
+	// 通过连接读超时控制每个分片的读取时间，不支持时（例如测试环境）退化为服务器的全局超时
+	rc := http.NewResponseController(c.Writer)
+	setReadDeadline := func() { _ = rc.SetReadDeadline(time.Now().Add(readTimeout)) }
+	defer rc.SetReadDeadline(time.Time{})
+
+	var parts []oss.Part
+	var uploadedBytes int64
+	partNumber := 1
 	if resumeUploadID != "" {
 		existing, err := storage.ListUploadedPartsToBucket(objectKey, uploadID, regionCode, bucketName)
 		if err == nil && len(existing) > 0 {
@@ -446,164 +629,48 @@ func (h *OSSFileHandler) uploadFileWithChunks(c *gin.Context, storage oss.Storag
 				if p.PartNumber != partNumber {
 					break
 				}
-				parts = append(parts, p)
 				size := chunkSize
 				if p.PartNumber == totalChunks {
 					size = totalSize - int64(totalChunks-1)*chunkSize
 				}
-				if _, err := io.CopyN(io.Discard, bufferedReader, size); err != nil {
+				setReadDeadline()
+				if _, err := io.CopyN(io.Discard, progressReader, size); err != nil {
 					return "", fmt.Errorf("跳过已上传分片失败: %v", err)
 				}
+				parts = append(parts, p)
+				upload.DefaultManager.UpdateChunk(taskID, p.PartNumber, true)
 				uploadedBytes += size
 				partNumber++
 			}
 		}
 	}
 
-	// 读取分片超时时间，可通过头部 X-Chunk-Read-Timeout 调整，默认 5 分钟
-	readTimeout := 5 * time.Minute
-	if timeoutStr := c.GetHeader("X-Chunk-Read-Timeout"); timeoutStr != "" {
-		if t, err := strconv.Atoi(timeoutStr); err == nil && t > 0 {
-			readTimeout = time.Duration(t) * time.Second
-		}
-	}
-
-	maxRetries := 10
-
-	sem := make(chan struct{}, concurrency)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	errCh := make(chan error, 1)
-
-	for uploadedBytes < totalSize && partNumber <= totalChunks {
-		if len(errCh) > 0 {
-			break
-		}
-		// 计算当前分片大小
-		currentChunkSize := chunkSize
-		if uploadedBytes+chunkSize > totalSize {
-			currentChunkSize = totalSize - uploadedBytes
-		}
-
-		logger.Debug("准备上传分片",
-			zap.Int("part_number", partNumber),
-			zap.Int64("chunk_size", currentChunkSize),
-			zap.Int64("uploaded_bytes", uploadedBytes),
-		)
-
-		// 读取分片数据，带重试机制
-		var chunkData []byte
-		var readErr error
-
-		readStart := time.Now()
-		for retry := 0; retry < maxRetries; retry++ {
-			if retry > 0 {
-				logger.Warn("重试读取分片数据",
-					zap.Int("part_number", partNumber),
-					zap.Int("retry", retry),
-				)
-				time.Sleep(time.Duration(retry) * time.Second) // 递增延迟
-			}
-
-			chunkData = make([]byte, currentChunkSize)
-
-			// 使用通道和协程实现超时控制
-			done := make(chan error, 1)
-			go func() {
-				_, err := io.ReadFull(bufferedReader, chunkData)
-				done <- err
-			}()
-
-			select {
-			case readErr = <-done:
-				// 读取完成
-				break
-			case <-time.After(readTimeout):
-				readErr = fmt.Errorf("读取分片数据超时")
-				logger.Warn("读取分片数据超时",
-					zap.Int("part_number", partNumber),
-					zap.Duration("timeout", readTimeout),
-				)
-				continue // 重试
-			}
-
-			if readErr == nil || readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
-				break // 成功或预期的EOF
-			}
-		}
-
-		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
-			upload.DefaultManager.Fail(taskID, "读取分片数据失败")
-			return "", fmt.Errorf("读取分片数据失败: %v", readErr)
-		}
-
-		if len(chunkData) == 0 {
-			break
-		}
-
-		logger.Debug("读取分片完成",
-			zap.Int("part_number", partNumber),
-			zap.Duration("elapsed", time.Since(readStart)),
-		)
-		// 上传分片
-		curPart := partNumber
-		dataCopy := make([]byte, len(chunkData))
-		copy(dataCopy, chunkData)
-		uploadedBytes += int64(len(chunkData))
-		partNumber++
-
-		sem <- struct{}{}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			defer func() { <-sem }()
-			urlStart := time.Now()
-
-			uploadURL, err := storage.GeneratePartUploadURL(objectKey, uploadID, curPart, regionCode, bucketName)
-			if err != nil {
-				select {
-				case errCh <- fmt.Errorf("获取分片 %d 上传URL失败: %v", curPart, err):
-				default:
-				}
-				return
-			}
-			logger.Debug("生成上传URL完成",
-				zap.Int("part_number", curPart),
-				zap.Duration("elapsed", time.Since(urlStart)),
-			)
-			uploadStart := time.Now()
-			etag, err := h.uploadChunk(storage, uploadURL, dataCopy, curPart)
-			if err != nil {
-				select {
-				case errCh <- fmt.Errorf("上传分片 %d 失败: %v", curPart, err):
-				default:
-				}
-				return
-			}
-			logger.Debug("上传分片完成",
-				zap.Int("part_number", curPart),
-				zap.Duration("elapsed", time.Since(uploadStart)),
-			)
-			mu.Lock()
-			parts = append(parts, oss.Part{PartNumber: curPart, ETag: etag})
-			mu.Unlock()
-			upload.DefaultManager.UpdateChunk(taskID, curPart, true)
-			logger.Debug("分片上传成功",
-				zap.Int("part_number", curPart),
-				zap.String("etag", etag),
-			)
-		}()
+	uploaded, err := upload.UploadParts(c.Request.Context(), progressReader, upload.PartsOptions{
+		PartSize:    chunkSize,
+		Size:        totalSize - uploadedBytes,
+		Concurrency: concurrency,
+		FirstPart:   partNumber,
+		BeforeRead:  setReadDeadline,
+		OnPart: func(partNumber int) {
+			upload.DefaultManager.UpdateChunk(taskID, partNumber, true)
+		},
+	}, func(ctx context.Context, partNumber int, data []byte) (string, error) {
+		return h.uploadPart(ctx, storage, data, partNumber, uploadID, objectKey, regionCode, bucketName)
+	})
+	if err != nil {
+		h.safeAbortMultipartUpload(storage, uploadID, objectKey, regionCode, bucketName)
+		upload.DefaultManager.Fail(taskID, err.Error())
+		return "", err
 	}
+	parts = append(parts, uploaded...)
 
-	wg.Wait()
-	if len(errCh) > 0 {
+	// 所有数据都已读取，合并前校验客户端声明的校验和
+	if err := verifier.Verify(); err != nil {
 		h.safeAbortMultipartUpload(storage, uploadID, objectKey, regionCode, bucketName)
-		upload.DefaultManager.Fail(taskID, (<-errCh).Error())
-		return "", fmt.Errorf("%v", <-errCh)
+		upload.DefaultManager.Fail(taskID, err.Error())
+		return "", err
 	}
 
-	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
-
 	logger.Info("所有分片上传完成，开始合并",
 		zap.String("upload_id", uploadID),
 		zap.Int("total_parts", len(parts)),
@@ -663,94 +730,96 @@ func (h *OSSFileHandler) uploadChunkGeneric(storage oss.StorageService, data []b
 	return "", fmt.Errorf("通用分片上传方法未实现")
 }
 
-// uploadChunk 上传单个分片
-func (h *OSSFileHandler) uploadChunk(storage oss.StorageService, uploadURL string, data []byte, partNumber int) (string, error) {
-	// 这里需要根据具体的存储服务实现分片上传
-	// 由于不同的云服务商有不同的分片上传API，这里提供一个通用的HTTP PUT方法
+// maxPartRetries 单个分片的最大尝试次数
+const maxPartRetries = 3
 
-	maxRetries := 3
-	var lastErr error
+// partUploadClient 上传分片使用的 HTTP 客户端，超时按分片大小通过 context 单独设置
+var partUploadClient = &http.Client{}
+
+// partUploadTimeout 单次分片上传的超时时间：30 秒基础时间，再按 256KB/s 的最低速度放宽
+func partUploadTimeout(size int) time.Duration {
+	return 30*time.Second + time.Duration(size/(256*1024))*time.Second
+}
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
+// uploadPart 生成预签名URL并上传单个分片，失败时按递增间隔重试
+// 分片携带 Content-MD5，由存储端校验分片内容
+func (h *OSSFileHandler) uploadPart(ctx context.Context, storage oss.StorageService, data []byte, partNumber int, uploadID, objectKey, regionCode, bucketName string) (string, error) {
+	contentMD5 := checksum.PartMD5(data)
+
+	var lastErr error
+	for attempt := 0; attempt < maxPartRetries; attempt++ {
 		if attempt > 0 {
 			logger.Warn("重试上传分片",
 				zap.Int("part_number", partNumber),
 				zap.Int("retry", attempt),
+				zap.Error(lastErr),
 			)
-			time.Sleep(time.Duration(attempt) * time.Second)
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
 		}
 
-		req, err := http.NewRequest("PUT", uploadURL, bytes.NewReader(data))
+		uploadURL, err := storage.GeneratePartUploadURL(objectKey, uploadID, partNumber, contentMD5, regionCode, bucketName)
 		if err != nil {
-			return "", err
+			lastErr = fmt.Errorf("获取分片 %d 上传URL失败: %v", partNumber, err)
+			continue
 		}
-
-		req.Header.Set("Content-Type", "application/octet-stream")
-		req.Header.Set("Content-Length", strconv.Itoa(len(data)))
-
-		client := &http.Client{Timeout: 30 * time.Second}
-
-		resp, err := client.Do(req)
+		etag, err := h.uploadChunk(ctx, uploadURL, data, contentMD5)
 		if err != nil {
-			lastErr = err
+			lastErr = fmt.Errorf("上传分片 %d 失败: %v", partNumber, err)
 			continue
 		}
-		defer resp.Body.Close()
+		return etag, nil
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			lastErr = fmt.Errorf("上传分片失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
-			continue
-		}
+	return "", lastErr
+}
 
-		// 获取ETag
-		etag := resp.Header.Get("ETag")
-		if etag == "" {
-			lastErr = fmt.Errorf("无法获取分片ETag")
-			continue
-		}
-		// 移除ETag中的引号
-		etag = strings.Trim(etag, "\"")
+// uploadChunk 通过预签名URL上传单个分片（不重试），contentMD5 需与生成预签名URL时一致
+func (h *OSSFileHandler) uploadChunk(ctx context.Context, uploadURL string, data []byte, contentMD5 string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, partUploadTimeout(len(data)))
+	defer cancel()
 
-		return etag, nil
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if contentMD5 != "" {
+		req.Header.Set(checksum.HeaderContentMD5, contentMD5)
 	}
 
-	return "", lastErr
+	resp, err := partUploadClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("状态码: %d, 响应: %s", resp.StatusCode, string(body))
+	}
+
+	// 获取ETag并移除引号
+	etag := strings.Trim(resp.Header.Get("ETag"), "\"")
+	if etag == "" {
+		return "", fmt.Errorf("无法获取分片ETag")
+	}
+	return etag, nil
 }
 
-// saveFileRecord 保存文件记录
-func (h *OSSFileHandler) saveFileRecord(c *gin.Context, config models.OSSConfig, objectKey, originalFilename string, fileSize int64, bucketName, uploadURL string) {
+// newFileRecord 构造一条 ACTIVE 状态的文件记录
+func newFileRecord(config models.OSSConfig, objectKey, originalFilename string, fileSize int64, bucketName, uploadURL string, uploaderID uint, uploadIP string) models.OSSFile {
 	// 从配置中获取过期时间，如果未配置则默认为24小时
 	expireTime := config.URLExpireTime
 	if expireTime <= 0 {
 		expireTime = 24 * 3600 // 默认24小时
 	}
-	expiresAt := time.Now().Add(time.Duration(expireTime) * time.Second)
-
-	// 开始数据库事务，确保原子性
-	tx := h.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// 1. 首先将相同object_key的旧记录标记为REPLACED
-	if err := tx.Model(&models.OSSFile{}).Where(
-		"object_key = ? AND bucket = ? AND status = ?",
-		objectKey, bucketName, "ACTIVE",
-	).Update("status", "REPLACED").Error; err != nil {
-		logger.Warn("标记旧文件记录失败", 
-			zap.String("object_key", objectKey),
-			zap.Error(err),
-		)
-		tx.Rollback()
-		h.Error(c, utils.CodeServerError, "更新旧文件记录失败")
-		return
-	}
 
-	// 2. 创建新的文件记录
-	ossFile := models.OSSFile{
+	return models.OSSFile{
 		ConfigID:         config.ID,
 		Filename:         objectKey,
 		OriginalFilename: originalFilename,
@@ -759,21 +828,65 @@ func (h *OSSFileHandler) saveFileRecord(c *gin.Context, config models.OSSConfig,
 		Bucket:           bucketName,
 		ObjectKey:        objectKey,
 		DownloadURL:      uploadURL,
-		UploaderID:       utils.GetUserID(c),
-		UploadIP:         c.ClientIP(),
-		ExpiresAt:        expiresAt,
+		UploaderID:       uploaderID,
+		UploadIP:         uploadIP,
+		ExpiresAt:        time.Now().Add(time.Duration(expireTime) * time.Second),
 		Status:           "ACTIVE",
+		ScanStatus:       scan.InitialStatus(),
 	}
+}
 
-	if err := tx.Create(&ossFile).Error; err != nil {
-		tx.Rollback()
-		h.Error(c, utils.CodeServerError, "保存文件记录失败")
-		return
+// createFileRecord 在事务中保存文件记录，并将相同 object_key 的旧记录标记为 REPLACED
+// 保存成功后唤醒病毒扫描器
+func createFileRecord(db *gorm.DB, ossFile *models.OSSFile) error {
+	return createFileRecordWithHashes(db, ossFile, nil)
+}
+
+// createFileRecordWithHashes 与 createFileRecord 相同，同时在同一事务中保存上传时计算的摘要
+// sums 为空时文件摘要保持待计算状态
+func createFileRecordWithHashes(db *gorm.DB, ossFile *models.OSSFile, sums map[string]string) error {
+	if md5Sum := sums[hashing.MD5]; md5Sum != "" {
+		ossFile.MD5 = md5Sum
+		ossFile.MD5Status = models.MD5StatusCompleted
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 1. 首先将相同object_key的旧记录标记为REPLACED
+		if err := tx.Model(&models.OSSFile{}).Where(
+			"object_key = ? AND bucket = ? AND status = ?",
+			ossFile.ObjectKey, ossFile.Bucket, "ACTIVE",
+		).Update("status", "REPLACED").Error; err != nil {
+			logger.Warn("标记旧文件记录失败",
+				zap.String("object_key", ossFile.ObjectKey),
+				zap.Error(err),
+			)
+			return fmt.Errorf("更新旧文件记录失败: %w", err)
+		}
+
+		// 2. 创建新的文件记录
+		if err := tx.Create(ossFile).Error; err != nil {
+			return fmt.Errorf("保存文件记录失败: %w", err)
+		}
+
+		// 3. 保存上传时计算的摘要
+		if len(sums) > 0 {
+			if err := hashing.Save(tx, ossFile.ID, sums); err != nil {
+				return fmt.Errorf("保存文件摘要失败: %w", err)
+			}
+		}
+		return nil
+	})
+	if err == nil && ossFile.ScanStatus == models.ScanStatusPending {
+		scan.Notify()
 	}
+	return err
+}
 
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		h.Error(c, utils.CodeServerError, "提交事务失败")
+// saveFileRecord 保存文件记录，sums 为上传时计算的摘要
+func (h *OSSFileHandler) saveFileRecord(c *gin.Context, config models.OSSConfig, objectKey, originalFilename string, fileSize int64, bucketName, uploadURL string, sums map[string]string) {
+	ossFile := newFileRecord(config, objectKey, originalFilename, fileSize, bucketName, uploadURL, utils.GetUserID(c), c.ClientIP())
+	if err := createFileRecordWithHashes(h.DB, &ossFile, sums); err != nil {
+		logger.Error("保存文件记录失败", zap.String("object_key", objectKey), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "保存文件记录失败")
 		return
 	}
 
@@ -786,65 +899,15 @@ func (h *OSSFileHandler) saveFileRecord(c *gin.Context, config models.OSSConfig,
 	h.Success(c, ossFile)
 }
 
-// saveFileRecordForMultipart 分片上传专用文件记录保存函数
-func (h *OSSFileHandler) saveFileRecordForMultipart(c *gin.Context, config models.OSSConfig, objectKey, originalFilename string, fileSize int64, bucketName, uploadURL string) {
-	// 从配置中获取过期时间，如果未配置则默认为24小时
-	expireTime := config.URLExpireTime
-	if expireTime <= 0 {
-		expireTime = 24 * 3600 // 默认24小时
-	}
-	expiresAt := time.Now().Add(time.Duration(expireTime) * time.Second)
-
-	// 开始数据库事务，确保原子性
-	tx := h.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// 1. 首先将相同object_key的旧记录标记为REPLACED
-	if err := tx.Model(&models.OSSFile{}).Where(
-		"object_key = ? AND bucket = ? AND status = ?",
-		objectKey, bucketName, "ACTIVE",
-	).Update("status", "REPLACED").Error; err != nil {
-		logger.Warn("标记旧文件记录失败",
-			zap.String("object_key", objectKey),
-			zap.Error(err),
-		)
-		tx.Rollback()
-		h.Error(c, utils.CodeServerError, "更新旧文件记录失败")
-		return
-	}
-
-	// 2. 创建新的文件记录
-	ossFile := models.OSSFile{
-		ConfigID:         config.ID,
-		Filename:         objectKey,
-		OriginalFilename: originalFilename,
-		FileSize:         fileSize,
-		StorageType:      config.StorageType,
-		Bucket:           bucketName,
-		ObjectKey:        objectKey,
-		DownloadURL:      uploadURL,
-		UploaderID:       utils.GetUserID(c),
-		UploadIP:         c.ClientIP(),
-		ExpiresAt:        expiresAt,
-		Status:           "ACTIVE",
-	}
-
-	if err := tx.Create(&ossFile).Error; err != nil {
-		tx.Rollback()
+// saveFileRecordForMultipart 分片上传专用文件记录保存函数，sums 为校验合并后对象时计算的摘要
+func (h *OSSFileHandler) saveFileRecordForMultipart(c *gin.Context, config models.OSSConfig, objectKey, originalFilename string, fileSize int64, bucketName, uploadURL string, sums map[string]string) {
+	ossFile := newFileRecord(config, objectKey, originalFilename, fileSize, bucketName, uploadURL, utils.GetUserID(c), c.ClientIP())
+	if err := createFileRecordWithHashes(h.DB, &ossFile, sums); err != nil {
+		logger.Error("保存文件记录失败", zap.String("object_key", objectKey), zap.Error(err))
 		h.Error(c, utils.CodeServerError, "保存文件记录失败")
 		return
 	}
 
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		h.Error(c, utils.CodeServerError, "提交事务失败")
-		return
-	}
-
 	logger.Info("分片上传文件记录保存成功",
 		zap.String("task_id", c.GetHeader("X-Task-ID")),
 		zap.Uint("file_id", ossFile.ID),
@@ -862,12 +925,20 @@ func (h *OSSFileHandler) InitMultipartUpload(c *gin.Context) {
 		RegionCode string `json:"region_code" binding:"required"`
 		BucketName string `json:"bucket_name" binding:"required"`
 		FileName   string `json:"file_name" binding:"required"`
+		FileSize   int64  `json:"file_size"` // 声明的文件大小，用于配额预检，可不传；完成时按合并后的实际大小再次校验
+		MD5        string `json:"md5"`       // 声明的内容 MD5，与 file_size 一起用于秒传
+		SHA256     string `json:"sha256"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil || req.FileSize < 0 {
 		h.Error(c, utils.CodeInvalidParams, "参数错误")
 		return
 	}
+	expected, err := checksum.Parse(req.MD5, req.SHA256)
+	if err != nil {
+		h.Error(c, utils.CodeInvalidParams, err.Error())
+		return
+	}
 
 	// 获取存储配置
 	var config models.OSSConfig
@@ -882,6 +953,17 @@ func (h *OSSFileHandler) InitMultipartUpload(c *gin.Context) {
 		return
 	}
 
+	// 未声明大小时无法预检配额，完成时按实际大小校验
+	if req.FileSize > 0 && !h.checkQuota(c, c.GetUint("userID"), req.BucketName, req.FileSize) {
+		return
+	}
+
+	// 分片由客户端直传，初始化时只能校验文件名和声明的大小，内容在完成时检测
+	uploadPolicy, ok := h.checkPolicy(c, req.RegionCode, req.BucketName, req.FileName, req.FileSize)
+	if !ok {
+		return
+	}
+
 	storage, err := h.storageFactory.GetStorageService(config.StorageType)
 	if err != nil {
 		h.Error(c, utils.CodeServerError, "获取存储服务失败")
@@ -892,6 +974,16 @@ func (h *OSSFileHandler) InitMultipartUpload(c *gin.Context) {
 	username, _ := c.Get("username")
 	objectKey := utils.GenerateObjectKey(username.(string), ext)
 
+	// 内容已存在时不需要上传分片，直接返回文件记录
+	if ossFile := h.deduplicate(c, storage, uploadPolicy, config, expected, req.FileSize, objectKey, req.FileName, req.BucketName); ossFile != nil {
+		h.Success(c, gin.H{
+			"deduplicated": true,
+			"object_key":   objectKey,
+			"file":         ossFile,
+		})
+		return
+	}
+
 	uploadID, urls, err := storage.InitMultipartUploadToBucket(objectKey, req.RegionCode, req.BucketName)
 	if err != nil {
 		h.Error(c, utils.CodeServerError, "初始化分片上传失败")
@@ -942,6 +1034,23 @@ func (h *OSSFileHandler) CompleteMultipartUpload(c *gin.Context) {
 		return
 	}
 
+	// 设置默认值
+	originalFilename := req.OriginalFilename
+	if originalFilename == "" {
+		originalFilename = req.ObjectKey
+	}
+
+	uploadPolicy, ok := h.checkPolicy(c, req.RegionCode, req.BucketName, originalFilename, req.FileSize)
+	if !ok {
+		return
+	}
+
+	expected, err := checksum.FromHeader(c.Request.Header)
+	if err != nil {
+		h.Error(c, utils.CodeInvalidParams, err.Error())
+		return
+	}
+
 	// 转换parts为oss.Part类型
 	ossParts := make([]oss.Part, len(req.Parts))
 	for i, part := range req.Parts {
@@ -968,10 +1077,49 @@ func (h *OSSFileHandler) CompleteMultipartUpload(c *gin.Context) {
 		return
 	}
 
-	// 设置默认值
-	originalFilename := req.OriginalFilename
-	if originalFilename == "" {
-		originalFilename = req.ObjectKey
+	// 分片由客户端直传，声明的大小不可信，按合并后对象的实际大小校验策略和配额并记录
+	fileSize, err := h.checkStoredSize(storage, uploadPolicy, c.GetUint("userID"), originalFilename, req.ObjectKey, req.RegionCode, req.BucketName)
+	if err != nil {
+		if req.TaskID != "" {
+			upload.DefaultManager.Fail(req.TaskID, err.Error())
+		}
+		if errors.Is(err, quota.ErrQuotaExceeded) {
+			h.Error(c, utils.CodeQuotaExceeded, err.Error())
+		} else if errors.Is(err, policy.ErrPolicyViolation) {
+			h.Error(c, utils.CodePolicyViolation, err.Error())
+		} else {
+			h.Error(c, utils.CodeServerError, "获取文件信息失败")
+		}
+		return
+	}
+
+	// 分片由客户端直传，合并后再检测文件头，不符合策略的对象直接删除
+	if err := h.checkStoredContent(storage, uploadPolicy, originalFilename, req.ObjectKey, req.RegionCode, req.BucketName); err != nil {
+		if req.TaskID != "" {
+			upload.DefaultManager.Fail(req.TaskID, err.Error())
+		}
+		if errors.Is(err, policy.ErrPolicyViolation) {
+			h.Error(c, utils.CodePolicyViolation, err.Error())
+		} else {
+			h.Error(c, utils.CodeServerError, "检测文件内容失败")
+		}
+		return
+	}
+
+	// 分片由客户端直传，声明了校验和时读取合并后的对象校验，不一致的对象直接删除
+	var sums map[string]string
+	if expected != nil {
+		if sums, err = h.verifyStoredChecksum(storage, expected, req.ObjectKey, req.RegionCode, req.BucketName); err != nil {
+			if req.TaskID != "" {
+				upload.DefaultManager.Fail(req.TaskID, err.Error())
+			}
+			if errors.Is(err, checksum.ErrMismatch) {
+				h.Error(c, utils.CodeChecksumMismatch, err.Error())
+			} else {
+				h.Error(c, utils.CodeServerError, "校验文件内容失败")
+			}
+			return
+		}
 	}
 
 	// 从配置中获取过期时间，如果未配置则默认为24小时
@@ -981,7 +1129,7 @@ func (h *OSSFileHandler) CompleteMultipartUpload(c *gin.Context) {
 	}
 
 	// 使用改进的文件记录保存逻辑
-	h.saveFileRecordForMultipart(c, config, req.ObjectKey, originalFilename, req.FileSize, req.BucketName, url)
+	h.saveFileRecordForMultipart(c, config, req.ObjectKey, originalFilename, fileSize, req.BucketName, url, sums)
 
 	// 完成进度追踪
 	if req.TaskID != "" {
@@ -989,6 +1137,85 @@ func (h *OSSFileHandler) CompleteMultipartUpload(c *gin.Context) {
 	}
 }
 
+// checkStoredSize 获取合并后对象的实际大小，按策略的大小上限和配额校验，不通过时删除对象
+func (h *OSSFileHandler) checkStoredSize(storage oss.StorageService, p *policy.Policy, userID uint, filename, objectKey, regionCode, bucketName string) (int64, error) {
+	info, err := storage.StatObjectInBucket(objectKey, regionCode, bucketName)
+	if err != nil {
+		return 0, err
+	}
+
+	err = p.CheckFile(filename, info.Size)
+	if err == nil {
+		err = h.quota.Check(userID, bucketName, info.Size)
+	}
+	if err != nil {
+		if !errors.Is(err, policy.ErrPolicyViolation) && !errors.Is(err, quota.ErrQuotaExceeded) {
+			return 0, err
+		}
+		logger.Warn("分片上传的实际大小超出限制，删除对象",
+			zap.Uint("user_id", userID),
+			zap.String("bucket", bucketName),
+			zap.String("object_key", objectKey),
+			zap.Int64("size", info.Size),
+			zap.Error(err))
+		h.discardRejectedObject(storage, objectKey, regionCode, bucketName)
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// checkStoredContent 读取已上传对象的文件头并按策略校验，不符合策略时删除对象
+func (h *OSSFileHandler) checkStoredContent(storage oss.StorageService, p *policy.Policy, filename, objectKey, regionCode, bucketName string) error {
+	if !p.NeedsContent() {
+		return nil
+	}
+
+	body, err := storage.GetObjectFromBucket(objectKey, regionCode, bucketName)
+	if err != nil {
+		return err
+	}
+	head, _, err := policy.Peek(body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := p.CheckContent(filename, head); err != nil {
+		logger.Warn("分片上传内容不符合策略，删除对象",
+			zap.String("bucket", bucketName),
+			zap.String("object_key", objectKey),
+			zap.Error(err))
+		h.discardRejectedObject(storage, objectKey, regionCode, bucketName)
+		return err
+	}
+	return nil
+}
+
+// verifyStoredChecksum 读取已合并的对象计算摘要并与声明的校验和比较，不一致时删除对象
+// 校验通过时返回计算出的摘要，随文件记录一起保存
+func (h *OSSFileHandler) verifyStoredChecksum(storage oss.StorageService, expected *checksum.Expected, objectKey, regionCode, bucketName string) (map[string]string, error) {
+	body, err := storage.GetObjectFromBucket(objectKey, regionCode, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	verifier := checksum.NewVerifier(expected)
+	_, err = io.Copy(verifier, body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifier.Verify(); err != nil {
+		logger.Warn("分片上传内容校验和不一致，删除对象",
+			zap.String("bucket", bucketName),
+			zap.String("object_key", objectKey),
+			zap.Error(err))
+		h.discardRejectedObject(storage, objectKey, regionCode, bucketName)
+		return nil, err
+	}
+	return verifier.Sums(), nil
+}
+
 // AbortMultipartUpload 取消分片上传
 func (h *OSSFileHandler) AbortMultipartUpload(c *gin.Context) {
 	var req struct {
@@ -1073,7 +1300,10 @@ func (h *OSSFileHandler) ListUploadedParts(c *gin.Context) {
 	h.Success(c, gin.H{"parts": partNumbers})
 }
 
-// List 获取文件列表，相同文件名只获取最新一个
+// List 获取文件列表，相同文件名只获取最新一个，按文件名排序
+//
+// 传入 cursor（上一页返回的 next_cursor）时按游标翻页，不再统计总数；
+// 否则兼容 offset/limit 和 page/page_size 分页
 func (h *OSSFileHandler) List(c *gin.Context) {
 	// 获取用户ID
 	userID := c.GetUint("userID")
@@ -1090,6 +1320,8 @@ func (h *OSSFileHandler) List(c *gin.Context) {
 	if offsetStr := c.Query("offset"); offsetStr != "" {
 		offset, _ = strconv.Atoi(offsetStr)
 		limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	} else if c.Query("cursor") != "" {
+		limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
 	} else {
 		// Legacy pagination support
 		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -1100,7 +1332,7 @@ func (h *OSSFileHandler) List(c *gin.Context) {
 		offset = (page - 1) * pageSize
 		limit = pageSize
 	}
-	
+
 	// Apply reasonable limits
 	if offset < 0 {
 		offset = 0
@@ -1111,67 +1343,60 @@ func (h *OSSFileHandler) List(c *gin.Context) {
 	if limit > 100 {
 		limit = 100 // Max 100 items per request
 	}
-	configID := c.Query("config_id")
-	// 首先，获取去重后的所有文件名
-	var uniqueFileNames []string
-	query := h.DB.Model(&models.OSSFile{}).Select("DISTINCT original_filename").Where("bucket IN ?", buckets)
-	if configID != "" {
+
+	query := h.DB.Model(&models.OSSFile{}).Where("bucket IN ? AND status <> ?", buckets, models.FileStatusDeleted)
+	if configID := c.Query("config_id"); configID != "" {
 		query = query.Where("config_id = ?", configID)
 	}
+	query = query.Session(&gorm.Session{})
 
-	if err := query.Pluck("original_filename", &uniqueFileNames).Error; err != nil {
-		h.Error(c, utils.CodeServerError, "获取唯一文件名失败")
-		return
+	var cursor *search.Cursor
+	if s := c.Query("cursor"); s != "" {
+		if cursor, err = search.DecodeCursor(s); err != nil || cursor.Sort != search.SortName {
+			h.BadRequest(c, search.ErrInvalidCursor.Error())
+			return
+		}
 	}
 
-	total := int64(len(uniqueFileNames))
-
-	// Apply pagination
-	startIdx := offset
-	endIdx := offset + limit
-	if startIdx >= len(uniqueFileNames) {
-		h.Success(c, gin.H{
-			"total":   total,
-			"items":   []models.OSSFile{},
-			"offset":  offset,
-			"limit":   limit,
-			"hasMore": false,
-		})
-		return
-	}
-	if endIdx > len(uniqueFileNames) {
-		endIdx = len(uniqueFileNames)
+	// 游标翻页不统计总数，避免每页都扫描整个目录
+	var total int64
+	if cursor == nil {
+		if err := query.Distinct("original_filename").Count(&total).Error; err != nil {
+			logger.Error("统计文件数失败", zap.Error(err))
+			h.Error(c, utils.CodeServerError, "获取文件列表失败")
+			return
+		}
 	}
 
-	// 获取当前页的文件名
-	pageFileNames := uniqueFileNames[startIdx:endIdx]
+	// 每个文件名只取最新的一条记录，文件名唯一，因此游标只需比较文件名
+	latest := query.Select("DISTINCT ON (original_filename) *").
+		Order("original_filename, created_at DESC, id DESC").
+		Limit(limit + 1)
+	if cursor != nil {
+		latest = latest.Where("original_filename > ?", cursor.Value)
+	} else {
+		latest = latest.Offset(offset)
+	}
 
-	// 对于每个文件名，获取最新的记录
 	var files []models.OSSFile
-	for _, fileName := range pageFileNames {
-		var latest models.OSSFile
-		subQuery := h.DB.Model(&models.OSSFile{}).Where("original_filename = ? AND bucket IN ?", fileName, buckets)
-		if configID != "" {
-			subQuery = subQuery.Where("config_id = ?", configID)
-		}
-
-		if err := subQuery.Order("created_at DESC").First(&latest).Error; err != nil {
-			// 如果查询出错，跳过这个文件名
-			continue
-		}
-
-		files = append(files, latest)
+	if err := latest.Find(&files).Error; err != nil {
+		logger.Error("获取文件列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取文件列表失败")
+		return
 	}
+	files, next := (&search.Page{Sort: search.SortName, Limit: limit}).Next(files)
 
-	hasMore := endIdx < len(uniqueFileNames)
-	
-	h.Success(c, gin.H{
-		"total":   total,
-		"items":   files,
-		"offset":  offset,
-		"limit":   limit,
-		"hasMore": hasMore,
-	})
+	result := gin.H{
+		"items":       files,
+		"limit":       limit,
+		"hasMore":     next != "",
+		"next_cursor": next,
+	}
+	if cursor == nil {
+		result["total"] = total
+		result["offset"] = offset
+	}
+	h.Success(c, result)
 }
 
 // getRegionByBucket 通过存储桶名称获取区域代码
@@ -1184,7 +1409,7 @@ func (h *OSSFileHandler) getRegionByBucket(bucketName string) (string, error) {
 	return mapping.RegionCode, nil
 }
 
-// Delete 删除文件
+// Delete 删除文件：启用回收站时移入回收站，permanent=true 或文件已在回收站中时彻底删除
 func (h *OSSFileHandler) Delete(c *gin.Context) {
 	// 获取用户ID
 	userID := c.GetUint("userID")
@@ -1219,6 +1444,24 @@ func (h *OSSFileHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	if h.trash.Enabled() && file.Status != models.FileStatusDeleted && c.Query("permanent") != "true" {
+		if err := h.trash.Move(&file, userID); err != nil {
+			logger.Error("移入回收站失败",
+				zap.Uint("fileID", file.ID),
+				zap.String("objectKey", file.ObjectKey),
+				zap.String("bucket", file.Bucket),
+				zap.Error(err))
+			h.Error(c, utils.CodeServerError, "删除文件失败")
+			return
+		}
+		logger.Info("文件已移入回收站",
+			zap.Uint("fileID", file.ID),
+			zap.String("objectKey", file.OriginalKey),
+			zap.String("bucket", file.Bucket))
+		h.Success(c, file)
+		return
+	}
+
 	storage, err := h.storageFactory.GetStorageService(config.StorageType)
 	if err != nil {
 		h.Error(c, utils.CodeServerError, "获取存储服务失败")
@@ -1385,6 +1628,23 @@ func (h *OSSFileHandler) GetDownloadURL(c *gin.Context) {
 		expireDuration = 1 * time.Hour
 	}
 
+	if file.Status == models.FileStatusDeleted {
+		h.Error(c, utils.CodeFileNotFound, "文件已移入回收站")
+		return
+	}
+
+	// 感染病毒或尚未完成扫描的文件不生成下载链接
+	if err := scan.CheckDownload(&file); err != nil {
+		h.Error(c, utils.CodeForbidden, err.Error())
+		return
+	}
+
+	// 巡检发现已丢失或已损坏的文件不生成下载链接
+	if err := scrub.CheckDownload(&file); err != nil {
+		h.Error(c, utils.CodeForbidden, err.Error())
+		return
+	}
+
 	// 通过存储桶名称获取区域信息
 	regionCode, err := h.getRegionByBucket(file.Bucket)
 	if err != nil {
@@ -1419,39 +1679,20 @@ func (h *OSSFileHandler) GetDownloadURL(c *gin.Context) {
 	var expires time.Time
 
 	if neverExpires {
-		// 永不过期：返回文件的原始下载链接（如果是公共访问的桶）或者使用一个很长的过期时间
-		if aliyunStorage, ok := storage.(*oss.AliyunOSSService); ok {
-			// 对于阿里云OSS，使用最大允许的过期时间（7天）作为近似永不过期
-			// 实际应用中可能需要定期刷新链接
-			downloadURL, expires, err = aliyunStorage.GenerateDownloadURLWithBucket(file.ObjectKey, file.DownloadURL, 7*24*time.Hour)
-			if err != nil {
-				h.Error(c, utils.CodeServerError, "生成下载链接失败")
-				return
-			}
-			// 设置一个特殊的过期时间表示永不过期
-			expires = time.Time{} // 零值表示永不过期
-		} else {
-			downloadURL, expires, err = storage.GenerateDownloadURL(file.ObjectKey, 7*24*time.Hour)
-			if err != nil {
-				h.Error(c, utils.CodeServerError, "生成下载链接失败")
-				return
-			}
-			expires = time.Time{} // 零值表示永不过期
+		// 永不过期：使用最大允许的过期时间（7天）作为近似永不过期
+		// 实际应用中可能需要定期刷新链接
+		downloadURL, _, err = fileDownloadURL(storage, &file, 7*24*time.Hour)
+		if err != nil {
+			h.Error(c, utils.CodeServerError, "生成下载链接失败")
+			return
 		}
+		expires = time.Time{} // 零值表示永不过期
 	} else {
 		// 使用指定的过期时间
-		if aliyunStorage, ok := storage.(*oss.AliyunOSSService); ok {
-			downloadURL, expires, err = aliyunStorage.GenerateDownloadURLWithBucket(file.ObjectKey, file.DownloadURL, expireDuration)
-			if err != nil {
-				h.Error(c, utils.CodeServerError, "生成下载链接失败")
-				return
-			}
-		} else {
-			downloadURL, expires, err = storage.GenerateDownloadURL(file.ObjectKey, expireDuration)
-			if err != nil {
-				h.Error(c, utils.CodeServerError, "生成下载链接失败")
-				return
-			}
+		downloadURL, expires, err = fileDownloadURL(storage, &file, expireDuration)
+		if err != nil {
+			h.Error(c, utils.CodeServerError, "生成下载链接失败")
+			return
 		}
 	}
 
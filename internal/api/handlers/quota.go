@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/quota"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// QuotaHandler 存储配额处理器
+type QuotaHandler struct {
+	*BaseHandler
+	DB      *gorm.DB
+	service *quota.Service
+}
+
+// NewQuotaHandler 创建存储配额处理器
+func NewQuotaHandler(db *gorm.DB) *QuotaHandler {
+	return &QuotaHandler{
+		BaseHandler: NewBaseHandler(),
+		DB:          db,
+		service:     quota.NewService(db),
+	}
+}
+
+// quotaRequest 创建/更新配额的请求参数
+type quotaRequest struct {
+	ScopeType  string `json:"scope_type" binding:"required,oneof=USER ROLE BUCKET"`
+	ScopeID    uint   `json:"scope_id"`
+	BucketName string `json:"bucket_name"`
+	MaxBytes   int64  `json:"max_bytes" binding:"min=0"`
+	MaxFiles   int64  `json:"max_files" binding:"min=0"`
+}
+
+// validate 校验作用范围与参数是否匹配
+func (r *quotaRequest) validate() string {
+	switch r.ScopeType {
+	case models.QuotaScopeBucket:
+		if r.BucketName == "" {
+			return "存储桶配额必须指定 bucket_name"
+		}
+		r.ScopeID = 0
+	default:
+		if r.ScopeID == 0 {
+			return "用户或角色配额必须指定 scope_id"
+		}
+	}
+	return ""
+}
+
+// MyUsage 获取当前用户的配额使用情况
+func (h *QuotaHandler) MyUsage(c *gin.Context) {
+	h.usage(c, c.GetUint("userID"))
+}
+
+// UserUsage 获取指定用户的配额使用情况（仅管理员）
+func (h *QuotaHandler) UserUsage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		h.BadRequest(c, "无效的用户ID")
+		return
+	}
+	h.usage(c, uint(id))
+}
+
+func (h *QuotaHandler) usage(c *gin.Context, userID uint) {
+	report, err := h.service.Report(userID, c.Query("bucket_name"))
+	if err != nil {
+		logger.Error("获取配额使用情况失败", zap.Uint("user_id", userID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取配额使用情况失败")
+		return
+	}
+	h.Success(c, report)
+}
+
+// List 获取配额列表（仅管理员）
+func (h *QuotaHandler) List(c *gin.Context) {
+	query := h.DB.Model(&models.StorageQuota{})
+	if scopeType := c.Query("scope_type"); scopeType != "" {
+		query = query.Where("scope_type = ?", scopeType)
+	}
+	if scopeID := c.Query("scope_id"); scopeID != "" {
+		query = query.Where("scope_id = ?", scopeID)
+	}
+	if bucketName := c.Query("bucket_name"); bucketName != "" {
+		query = query.Where("bucket_name = ?", bucketName)
+	}
+
+	var quotas []models.StorageQuota
+	if err := query.Order("id").Find(&quotas).Error; err != nil {
+		logger.Error("获取配额列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取配额列表失败")
+		return
+	}
+
+	h.Success(c, gin.H{
+		"items": quotas,
+		"total": len(quotas),
+	})
+}
+
+// Create 创建配额（仅管理员）
+func (h *QuotaHandler) Create(c *gin.Context) {
+	var req quotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.BadRequest(c, "参数错误")
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		h.BadRequest(c, msg)
+		return
+	}
+
+	var count int64
+	if err := h.DB.Model(&models.StorageQuota{}).
+		Where("scope_type = ? AND scope_id = ? AND bucket_name = ?", req.ScopeType, req.ScopeID, req.BucketName).
+		Count(&count).Error; err != nil {
+		h.Error(c, utils.CodeServerError, "检查配额是否存在失败")
+		return
+	}
+	if count > 0 {
+		h.BadRequest(c, "相同范围的配额已存在")
+		return
+	}
+
+	q := models.StorageQuota{
+		ScopeType:  req.ScopeType,
+		ScopeID:    req.ScopeID,
+		BucketName: req.BucketName,
+		MaxBytes:   req.MaxBytes,
+		MaxFiles:   req.MaxFiles,
+	}
+	if err := h.DB.Create(&q).Error; err != nil {
+		logger.Error("创建配额失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "创建配额失败")
+		return
+	}
+
+	h.Success(c, q)
+}
+
+// Update 更新配额上限（仅管理员）
+func (h *QuotaHandler) Update(c *gin.Context) {
+	var q models.StorageQuota
+	if err := h.DB.First(&q, c.Param("id")).Error; err != nil {
+		h.NotFound(c, "配额不存在")
+		return
+	}
+
+	var req struct {
+		MaxBytes int64 `json:"max_bytes" binding:"min=0"`
+		MaxFiles int64 `json:"max_files" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.BadRequest(c, "参数错误")
+		return
+	}
+
+	if err := h.DB.Model(&q).Updates(map[string]interface{}{
+		"max_bytes": req.MaxBytes,
+		"max_files": req.MaxFiles,
+	}).Error; err != nil {
+		logger.Error("更新配额失败", zap.Uint("id", q.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "更新配额失败")
+		return
+	}
+
+	q.MaxBytes, q.MaxFiles = req.MaxBytes, req.MaxFiles
+	h.Success(c, q)
+}
+
+// Delete 删除配额（仅管理员）
+func (h *QuotaHandler) Delete(c *gin.Context) {
+	result := h.DB.Delete(&models.StorageQuota{}, c.Param("id"))
+	if result.Error != nil {
+		logger.Error("删除配额失败", zap.Error(result.Error))
+		h.Error(c, utils.CodeServerError, "删除配额失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		h.NotFound(c, "配额不存在")
+		return
+	}
+
+	h.Success(c, nil)
+}
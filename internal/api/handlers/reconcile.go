@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/reconcile"
+	"github.com/myysophia/ossmanager/internal/tasks"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// TaskTypeReconcile 对账存储桶
+	TaskTypeReconcile = "reconcile"
+	// TaskTypeResolveDiffs 批量处理对账差异
+	TaskTypeResolveDiffs = "resolve_reconcile_diffs"
+)
+
+// resolveDiffsBatchSize 批量处理差异时每批读取的差异数
+const resolveDiffsBatchSize = 100
+
+// resolveDiffParams 处理差异的参数，ObjectKey 只在重新关联没有对象的记录时使用
+type resolveDiffParams struct {
+	Action    string `json:"action" binding:"required,oneof=IMPORT RELINK DELETE"`
+	ObjectKey string `json:"object_key"`
+}
+
+// resolveDiffsParams 批量处理差异任务的参数：处理一次对账中某一类未处理的差异
+type resolveDiffsParams struct {
+	TaskID uint   `json:"task_id" binding:"required"`
+	Kind   string `json:"kind" binding:"required"`
+	Action string `json:"action" binding:"required,oneof=IMPORT RELINK DELETE"`
+}
+
+// resolveDiffsResult 批量处理差异任务的结果
+type resolveDiffsResult struct {
+	Resolved int `json:"resolved"`
+	Failed   int `json:"failed"`
+}
+
+// ReconcileHandler 对账处理器（仅管理员）：提交对账任务，查看和处理差异
+type ReconcileHandler struct {
+	*BaseHandler
+	DB         *gorm.DB
+	reconciler *reconcile.Reconciler
+	tasks      *tasks.Manager // 后台任务管理器，由 RegisterTasks 设置
+}
+
+// NewReconcileHandler 创建对账处理器
+func NewReconcileHandler(db *gorm.DB, reconciler *reconcile.Reconciler) *ReconcileHandler {
+	return &ReconcileHandler{
+		BaseHandler: NewBaseHandler(),
+		DB:          db,
+		reconciler:  reconciler,
+	}
+}
+
+// RegisterTasks 注册对账相关的后台任务类型
+func (h *ReconcileHandler) RegisterTasks(m *tasks.Manager) {
+	h.tasks = m
+	m.Register(TaskTypeReconcile, h.runReconcile)
+	m.Register(TaskTypeResolveDiffs, h.runResolveDiffs)
+}
+
+// Run 提交对账任务，遍历存储桶（或其中的目录）并与文件记录比较，通过 /tasks/:id 查询进度和汇总
+func (h *ReconcileHandler) Run(c *gin.Context) {
+	var req reconcile.Params
+	if err := c.ShouldBindJSON(&req); err != nil || req.BucketName == "" {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+	req.Prefix = strings.TrimLeft(req.Prefix, "/")
+	if strings.Contains(req.Prefix, "..") {
+		h.Error(c, utils.CodeInvalidParams, "无效的目录")
+		return
+	}
+
+	var mapping models.RegionBucketMapping
+	if err := h.DB.Where("bucket_name = ?", req.BucketName).First(&mapping).Error; err != nil {
+		h.Error(c, utils.CodeInvalidParams, "未找到存储桶对应的区域信息")
+		return
+	}
+	if req.RegionCode == "" {
+		req.RegionCode = mapping.RegionCode
+	}
+
+	submitTask(c, h.BaseHandler, h.tasks, TaskTypeReconcile, req)
+}
+
+// Diffs 分页列出对账差异，默认只列出未处理的差异
+// 参数：task_id、bucket、kind，resolved=true 时列出已处理的差异，resolved=all 时列出全部
+func (h *ReconcileHandler) Diffs(c *gin.Context) {
+	query := h.DB.Model(&models.ReconcileDiff{})
+	switch c.Query("resolved") {
+	case "true":
+		query = query.Where("resolved_at IS NOT NULL")
+	case "all":
+	default:
+		query = query.Where("resolved_at IS NULL")
+	}
+	if taskID := c.Query("task_id"); taskID != "" {
+		query = query.Where("task_id = ?", taskID)
+	}
+	if bucket := c.Query("bucket"); bucket != "" {
+		query = query.Where("bucket = ?", bucket)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	pagination := utils.GetPagination(c)
+	var diffs []models.ReconcileDiff
+	if err := query.Scopes(utils.Paginate(pagination)).Order("id DESC").Find(&diffs).Error; err != nil {
+		logger.Error("获取对账差异列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取对账差异列表失败")
+		return
+	}
+	h.Success(c, utils.GetPaginationResult(pagination, diffs))
+}
+
+// Resolve 处理一条差异：导入没有记录的对象、重新关联记录或删除多余的对象和记录
+func (h *ReconcileHandler) Resolve(c *gin.Context) {
+	var req resolveDiffParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+
+	var diff models.ReconcileDiff
+	if err := h.DB.First(&diff, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.NotFound(c, "差异不存在")
+			return
+		}
+		h.Error(c, utils.CodeServerError, "获取差异失败")
+		return
+	}
+
+	err := h.reconciler.Resolve(&diff, req.Action, c.GetUint("userID"), strings.TrimLeft(req.ObjectKey, "/"))
+	switch {
+	case errors.Is(err, reconcile.ErrResolved), errors.Is(err, reconcile.ErrUnsupported),
+		errors.Is(err, reconcile.ErrTargetRequired), errors.Is(err, reconcile.ErrStale):
+		h.BadRequest(c, err.Error())
+	case errors.Is(err, reconcile.ErrConflict):
+		h.Error(c, utils.CodeFileExists, err.Error())
+	case errors.Is(err, oss.ErrObjectNotFound):
+		h.NotFound(c, "对象不存在")
+	case err != nil:
+		logger.Error("处理对账差异失败", zap.Uint("diff_id", diff.ID), zap.String("action", req.Action), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "处理差异失败")
+	default:
+		logger.Info("对账差异已处理",
+			zap.Uint("diff_id", diff.ID),
+			zap.String("kind", diff.Kind),
+			zap.String("action", req.Action),
+			zap.String("object_key", diff.ObjectKey))
+		h.Success(c, diff)
+	}
+}
+
+// ResolveAll 提交后台任务，按同一种方式处理一次对账中某一类的所有未处理差异
+// 没有对象的记录需要逐条指定新的对象键，不能批量重新关联
+func (h *ReconcileHandler) ResolveAll(c *gin.Context) {
+	var req resolveDiffsParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+	if req.Kind == models.ReconcileDiffMissing && req.Action == models.ReconcileActionRelink {
+		h.BadRequest(c, "没有对象的记录需要逐条指定对象键后重新关联")
+		return
+	}
+	if !reconcile.Supported(req.Kind, req.Action) {
+		h.BadRequest(c, reconcile.ErrUnsupported.Error())
+		return
+	}
+
+	submitTask(c, h.BaseHandler, h.tasks, TaskTypeResolveDiffs, req)
+}
+
+// runReconcile 执行对账，任务结果为对账汇总，差异通过 /reconcile/diffs?task_id= 查看
+func (h *ReconcileHandler) runReconcile(ctx context.Context, task *models.Task, progress *tasks.Progress) (any, error) {
+	var params reconcile.Params
+	if err := tasks.Decode(task, &params); err != nil {
+		return nil, err
+	}
+
+	summary, err := h.reconciler.Run(ctx, task.ID, params, progress)
+	if err != nil {
+		return summary, err
+	}
+	logger.Info("对账完成",
+		zap.Uint("task_id", task.ID),
+		zap.String("bucket", params.BucketName),
+		zap.String("prefix", params.Prefix),
+		zap.Int("objects", summary.Objects),
+		zap.Int("records", summary.Records),
+		zap.Int("differences", summary.Differences()))
+	return summary, nil
+}
+
+// runResolveDiffs 逐批处理差异；单条失败时跳过并计数，重复执行时只处理剩余的差异
+func (h *ReconcileHandler) runResolveDiffs(ctx context.Context, task *models.Task, progress *tasks.Progress) (any, error) {
+	var params resolveDiffsParams
+	if err := tasks.Decode(task, &params); err != nil {
+		return nil, err
+	}
+
+	query := h.DB.Model(&models.ReconcileDiff{}).
+		Where("task_id = ? AND kind = ? AND resolved_at IS NULL", params.TaskID, params.Kind).
+		Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("统计差异数失败: %w", err)
+	}
+	progress.SetTotal(total)
+
+	var result resolveDiffsResult
+	var lastID uint
+	for {
+		var diffs []models.ReconcileDiff
+		if err := query.Where("id > ?", lastID).
+			Order("id").Limit(resolveDiffsBatchSize).Find(&diffs).Error; err != nil {
+			return result, fmt.Errorf("获取差异列表失败: %w", err)
+		}
+		if len(diffs) == 0 {
+			break
+		}
+
+		for i := range diffs {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			diff := &diffs[i]
+			lastID = diff.ID
+			progress.SetMessage(diff.ObjectKey)
+
+			if err := h.reconciler.Resolve(diff, params.Action, task.UserID, diff.ObjectKey); err != nil {
+				logger.Warn("批量处理对账差异失败",
+					zap.Uint("task_id", task.ID),
+					zap.Uint("diff_id", diff.ID),
+					zap.Error(err))
+				result.Failed++
+			} else {
+				result.Resolved++
+			}
+			progress.Add(1)
+		}
+	}
+
+	progress.SetMessage("")
+	if result.Failed > 0 {
+		return result, fmt.Errorf("%d 条差异处理失败", result.Failed)
+	}
+	return result, nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/myysophia/ossmanager/internal/db/models"
 	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/policy"
 	"github.com/myysophia/ossmanager/internal/utils"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -262,6 +263,13 @@ func (h *RegionBucketHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	// 删除上传策略
+	if err := tx.Unscoped().Where("region_bucket_mapping_id = ?", id).Delete(&models.UploadPolicy{}).Error; err != nil {
+		tx.Rollback()
+		h.InternalError(c, "删除上传策略失败")
+		return
+	}
+
 	// 删除映射
 	if err := tx.Delete(&mapping).Error; err != nil {
 		tx.Rollback()
@@ -276,3 +284,76 @@ func (h *RegionBucketHandler) Delete(c *gin.Context) {
 
 	h.Success(c, nil)
 }
+
+// GetPolicy 获取地域-桶映射的上传策略，未设置时返回 null
+func (h *RegionBucketHandler) GetPolicy(c *gin.Context) {
+	var mapping models.RegionBucketMapping
+	if err := h.DB.First(&mapping, c.Param("id")).Error; err != nil {
+		h.NotFound(c, "映射不存在")
+		return
+	}
+
+	var p models.UploadPolicy
+	err := h.DB.Where("region_bucket_mapping_id = ?", mapping.ID).First(&p).Error
+	if err == gorm.ErrRecordNotFound {
+		h.Success(c, nil)
+		return
+	}
+	if err != nil {
+		h.InternalError(c, "获取上传策略失败")
+		return
+	}
+
+	h.Success(c, p)
+}
+
+// UpdatePolicy 设置地域-桶映射的上传策略（不存在时创建）
+func (h *RegionBucketHandler) UpdatePolicy(c *gin.Context) {
+	var mapping models.RegionBucketMapping
+	if err := h.DB.First(&mapping, c.Param("id")).Error; err != nil {
+		h.NotFound(c, "映射不存在")
+		return
+	}
+
+	var input models.UploadPolicy
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.BadRequest(c, "参数错误")
+		return
+	}
+	if err := policy.Validate(&input); err != nil {
+		h.BadRequest(c, err.Error())
+		return
+	}
+
+	var p models.UploadPolicy
+	err := h.DB.Where("region_bucket_mapping_id = ?", mapping.ID).First(&p).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		h.InternalError(c, "获取上传策略失败")
+		return
+	}
+
+	p.RegionBucketMappingID = mapping.ID
+	p.AllowedExtensions = input.AllowedExtensions
+	p.AllowedMIMETypes = input.AllowedMIMETypes
+	p.MaxFileSize = input.MaxFileSize
+	p.ForbiddenPatterns = input.ForbiddenPatterns
+
+	if err := h.DB.Save(&p).Error; err != nil {
+		logger.Error("保存上传策略失败", zap.Uint("mapping_id", mapping.ID), zap.Error(err))
+		h.InternalError(c, "保存上传策略失败")
+		return
+	}
+
+	h.Success(c, p)
+}
+
+// DeletePolicy 删除地域-桶映射的上传策略
+// 策略表对映射ID有唯一索引，直接物理删除以便之后重新创建
+func (h *RegionBucketHandler) DeletePolicy(c *gin.Context) {
+	if err := h.DB.Unscoped().Where("region_bucket_mapping_id = ?", c.Param("id")).Delete(&models.UploadPolicy{}).Error; err != nil {
+		h.InternalError(c, "删除上传策略失败")
+		return
+	}
+
+	h.Success(c, nil)
+}
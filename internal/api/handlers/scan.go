@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ScanHandler 病毒扫描处理器
+type ScanHandler struct {
+	*BaseHandler
+	DB *gorm.DB
+}
+
+// NewScanHandler 创建病毒扫描处理器
+func NewScanHandler(db *gorm.DB) *ScanHandler {
+	return &ScanHandler{
+		BaseHandler: NewBaseHandler(),
+		DB:          db,
+	}
+}
+
+// Rescan 将文件重新加入扫描队列
+func (h *ScanHandler) Rescan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的文件ID")
+		return
+	}
+
+	if err := scan.Rescan(h.DB, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.NotFound(c, "文件不存在")
+			return
+		}
+		logger.Error("重新扫描文件失败", zap.Uint64("file_id", id), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "重新扫描文件失败")
+		return
+	}
+
+	h.Success(c, nil)
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/search"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 文件标签限制
+const (
+	maxFileTags      = 20
+	maxFileTagLength = 50
+)
+
+// searchResultFile 搜索结果中的文件，附带标签
+type searchResultFile struct {
+	models.OSSFile
+	Tags []string `json:"tags"`
+}
+
+// Search 按文件名（子串或 * ? 通配符）、目录前缀、存储桶、大小、上传时间、上传者、MD5状态、标签和摘要搜索文件，
+// 只返回用户可访问存储桶中的文件；结果按 sort/order 排序，使用 cursor 翻页
+func (h *OSSFileHandler) Search(c *gin.Context) {
+	filter, err := search.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		h.BadRequest(c, err.Error())
+		return
+	}
+	page, err := search.ParsePage(c.Request.URL.Query())
+	if err != nil {
+		h.BadRequest(c, err.Error())
+		return
+	}
+
+	buckets, err := auth.GetUserAccessibleBuckets(h.DB, c.GetUint("userID"), "")
+	if err != nil {
+		h.Error(c, utils.CodeServerError, "获取可访问桶列表失败")
+		return
+	}
+	if filter.Bucket != "" && !slices.Contains(buckets, filter.Bucket) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return
+	}
+
+	query := filter.Apply(h.DB.Model(&models.OSSFile{}).Where("oss_files.bucket IN ?", buckets))
+	var files []models.OSSFile
+	if err := page.Apply(query).Find(&files).Error; err != nil {
+		logger.Error("搜索文件失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "搜索文件失败")
+		return
+	}
+	files, next := page.Next(files)
+
+	tags, err := h.loadTags(files)
+	if err != nil {
+		logger.Error("获取文件标签失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "搜索文件失败")
+		return
+	}
+	items := make([]searchResultFile, 0, len(files))
+	for _, file := range files {
+		items = append(items, searchResultFile{OSSFile: file, Tags: tags[file.ID]})
+	}
+
+	h.Success(c, gin.H{
+		"items":       items,
+		"limit":       page.Limit,
+		"has_more":    next != "",
+		"next_cursor": next,
+	})
+}
+
+// loadTags 按文件ID分组读取标签
+func (h *OSSFileHandler) loadTags(files []models.OSSFile) (map[uint][]string, error) {
+	tags := make(map[uint][]string, len(files))
+	if len(files) == 0 {
+		return tags, nil
+	}
+	ids := make([]uint, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, file.ID)
+		tags[file.ID] = []string{}
+	}
+
+	var rows []models.FileTag
+	if err := h.DB.Where("file_id IN ?", ids).Order("name").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tags[row.FileID] = append(tags[row.FileID], row.Name)
+	}
+	return tags, nil
+}
+
+// GetTags 获取文件标签
+func (h *OSSFileHandler) GetTags(c *gin.Context) {
+	file, ok := h.loadTaggedFile(c)
+	if !ok {
+		return
+	}
+	tags, err := h.loadTags([]models.OSSFile{*file})
+	if err != nil {
+		logger.Error("获取文件标签失败", zap.Uint("file_id", file.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取文件标签失败")
+		return
+	}
+	h.Success(c, gin.H{"tags": tags[file.ID]})
+}
+
+// SetTags 替换文件的全部标签，标签不区分大小写
+func (h *OSSFileHandler) SetTags(c *gin.Context) {
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+
+	var names []string
+	for _, tag := range req.Tags {
+		tag = search.NormalizeTag(tag)
+		if tag == "" || slices.Contains(names, tag) {
+			continue
+		}
+		if len([]rune(tag)) > maxFileTagLength {
+			h.BadRequest(c, "标签长度不能超过 "+strconv.Itoa(maxFileTagLength)+" 个字符")
+			return
+		}
+		names = append(names, tag)
+	}
+	if len(names) > maxFileTags {
+		h.BadRequest(c, "每个文件最多 "+strconv.Itoa(maxFileTags)+" 个标签")
+		return
+	}
+
+	file, ok := h.loadTaggedFile(c)
+	if !ok {
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", file.ID).Delete(&models.FileTag{}).Error; err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		rows := make([]models.FileTag, 0, len(names))
+		for _, name := range names {
+			rows = append(rows, models.FileTag{FileID: file.ID, Name: name})
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		logger.Error("保存文件标签失败", zap.Uint("file_id", file.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "保存文件标签失败")
+		return
+	}
+
+	slices.Sort(names)
+	if names == nil {
+		names = []string{}
+	}
+	h.Success(c, gin.H{"tags": names})
+}
+
+// loadTaggedFile 读取路径参数中的文件并检查存储桶访问权限
+func (h *OSSFileHandler) loadTaggedFile(c *gin.Context) (*models.OSSFile, bool) {
+	var file models.OSSFile
+	if err := h.DB.First(&file, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.Error(c, utils.CodeFileNotFound, "文件不存在")
+		} else {
+			logger.Error("获取文件记录失败", zap.String("file_id", c.Param("id")), zap.Error(err))
+			h.Error(c, utils.CodeServerError, "获取文件记录失败")
+		}
+		return nil, false
+	}
+
+	regionCode, err := h.getRegionByBucket(file.Bucket)
+	if err != nil || !auth.CheckBucketAccess(h.DB, c.GetUint("userID"), regionCode, file.Bucket) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return nil, false
+	}
+	return &file, true
+}
@@ -0,0 +1,559 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"github.com/myysophia/ossmanager/internal/scrub"
+	"github.com/myysophia/ossmanager/internal/search"
+	"github.com/myysophia/ossmanager/internal/share"
+	"github.com/myysophia/ossmanager/internal/throttle"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// shareURLExpiration 分享下载时生成的预签名链接有效期，每次下载都重新生成
+const shareURLExpiration = 5 * time.Minute
+
+// sharePasswordHeader 访问者提交分享密码的请求头，也可以使用 password 查询参数
+const sharePasswordHeader = "X-Share-Password"
+
+// ShareHandler 分享链接处理器：所有者管理分享，访问者通过 /s/:slug 匿名访问
+type ShareHandler struct {
+	*BaseHandler
+	DB             *gorm.DB
+	storageFactory oss.StorageFactory
+}
+
+// NewShareHandler 创建分享链接处理器
+func NewShareHandler(storageFactory oss.StorageFactory, db *gorm.DB) *ShareHandler {
+	return &ShareHandler{
+		BaseHandler:    NewBaseHandler(),
+		DB:             db,
+		storageFactory: storageFactory,
+	}
+}
+
+// createShareRequest 创建分享的请求参数，FileID 与 BucketName+Prefix 二选一
+type createShareRequest struct {
+	FileID       uint   `json:"file_id"`
+	BucketName   string `json:"bucket_name"`
+	Prefix       string `json:"prefix"`
+	Password     string `json:"password"`
+	ExpiresHours int    `json:"expires_hours" binding:"min=0"` // 0 表示永不过期
+	MaxDownloads int    `json:"max_downloads" binding:"min=0"` // 0 表示不限制
+	PreviewOnly  bool   `json:"preview_only"`
+}
+
+// updateShareRequest 修改分享的请求参数，未提供的字段保持不变；Password 为空字符串时取消密码
+type updateShareRequest struct {
+	Password     *string `json:"password"`
+	ExpiresHours *int    `json:"expires_hours" binding:"omitempty,min=0"`
+	MaxDownloads *int    `json:"max_downloads" binding:"omitempty,min=0"`
+	PreviewOnly  *bool   `json:"preview_only"`
+}
+
+// shareInfo 返回给访问者的分享信息，不包含存储位置
+type shareInfo struct {
+	Slug        string           `json:"slug"`
+	Name        string           `json:"name"`
+	IsFolder    bool             `json:"is_folder"`
+	File        *sharedFileEntry `json:"file,omitempty"`
+	ExpiresAt   *time.Time       `json:"expires_at,omitempty"`
+	PreviewOnly bool             `json:"preview_only"`
+	Remaining   *int             `json:"remaining_downloads,omitempty"`
+}
+
+// sharedFileEntry 分享中的文件，Path 为相对分享目录的路径
+type sharedFileEntry struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Create 为文件或目录创建分享链接
+func (h *ShareHandler) Create(c *gin.Context) {
+	var req createShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+
+	s := models.Share{
+		OwnerID:      c.GetUint("userID"),
+		MaxDownloads: req.MaxDownloads,
+		PreviewOnly:  req.PreviewOnly,
+	}
+	if req.FileID != 0 {
+		var file models.OSSFile
+		if err := h.DB.Where("status = ?", "ACTIVE").First(&file, req.FileID).Error; err != nil {
+			h.Error(c, utils.CodeFileNotFound, "文件不存在")
+			return
+		}
+		s.FileID, s.Bucket, s.Name = file.ID, file.Bucket, file.OriginalFilename
+	} else {
+		prefix := strings.TrimLeft(req.Prefix, "/")
+		if req.BucketName == "" || prefix == "" || strings.Contains(prefix, "..") {
+			h.BadRequest(c, "请指定要分享的文件或目录")
+			return
+		}
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		s.Bucket, s.Prefix, s.Name = req.BucketName, prefix, path.Base(strings.TrimSuffix(prefix, "/"))
+	}
+	if !h.ownerCanAccess(s.OwnerID, s.Bucket) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return
+	}
+
+	if req.ExpiresHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresHours) * time.Hour)
+		s.ExpiresAt = &expiresAt
+	}
+	var err error
+	if s.PasswordHash, err = share.HashPassword(req.Password); err != nil {
+		h.Error(c, utils.CodeServerError, "创建分享失败")
+		return
+	}
+	if s.Slug, err = share.NewSlug(); err != nil {
+		h.Error(c, utils.CodeServerError, "创建分享失败")
+		return
+	}
+
+	if err := h.DB.Create(&s).Error; err != nil {
+		logger.Error("创建分享失败", zap.Uint("owner_id", s.OwnerID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "创建分享失败")
+		return
+	}
+	logger.Info("创建分享链接",
+		zap.Uint("share_id", s.ID),
+		zap.Uint("owner_id", s.OwnerID),
+		zap.String("bucket", s.Bucket),
+		zap.Uint("file_id", s.FileID),
+		zap.String("prefix", s.Prefix))
+	h.Success(c, h.ownerView(&s))
+}
+
+// List 分页列出当前用户创建的分享
+func (h *ShareHandler) List(c *gin.Context) {
+	pagination := utils.GetPagination(c)
+	var shares []models.Share
+	if err := h.DB.Model(&models.Share{}).Where("owner_id = ?", c.GetUint("userID")).
+		Scopes(utils.Paginate(pagination)).Order("id DESC").Find(&shares).Error; err != nil {
+		logger.Error("获取分享列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取分享列表失败")
+		return
+	}
+
+	items := make([]gin.H, 0, len(shares))
+	for i := range shares {
+		items = append(items, h.ownerView(&shares[i]))
+	}
+	h.Success(c, utils.GetPaginationResult(pagination, items))
+}
+
+// Get 获取分享详情
+func (h *ShareHandler) Get(c *gin.Context) {
+	s, ok := h.loadOwned(c)
+	if !ok {
+		return
+	}
+	h.Success(c, h.ownerView(s))
+}
+
+// Update 修改分享的密码、有效期、下载次数上限和预览模式
+func (h *ShareHandler) Update(c *gin.Context) {
+	var req updateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+	s, ok := h.loadOwned(c)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Password != nil {
+		hash, err := share.HashPassword(*req.Password)
+		if err != nil {
+			h.Error(c, utils.CodeServerError, "修改分享失败")
+			return
+		}
+		updates["password_hash"] = hash
+	}
+	if req.ExpiresHours != nil {
+		var expiresAt *time.Time
+		if *req.ExpiresHours > 0 {
+			t := time.Now().Add(time.Duration(*req.ExpiresHours) * time.Hour)
+			expiresAt = &t
+		}
+		updates["expires_at"] = expiresAt
+	}
+	if req.MaxDownloads != nil {
+		updates["max_downloads"] = *req.MaxDownloads
+	}
+	if req.PreviewOnly != nil {
+		updates["preview_only"] = *req.PreviewOnly
+	}
+	if len(updates) > 0 {
+		if err := h.DB.Model(s).Updates(updates).Error; err != nil {
+			logger.Error("修改分享失败", zap.Uint("share_id", s.ID), zap.Error(err))
+			h.Error(c, utils.CodeServerError, "修改分享失败")
+			return
+		}
+	}
+
+	if err := h.DB.First(s, s.ID).Error; err != nil {
+		h.Error(c, utils.CodeServerError, "修改分享失败")
+		return
+	}
+	h.Success(c, h.ownerView(s))
+}
+
+// Delete 撤销分享，链接立即失效
+func (h *ShareHandler) Delete(c *gin.Context) {
+	s, ok := h.loadOwned(c)
+	if !ok {
+		return
+	}
+	if err := h.DB.Delete(s).Error; err != nil {
+		logger.Error("撤销分享失败", zap.Uint("share_id", s.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "撤销分享失败")
+		return
+	}
+	logger.Info("撤销分享链接", zap.Uint("share_id", s.ID), zap.Uint("owner_id", s.OwnerID))
+	h.Success(c, nil)
+}
+
+// Logs 分页列出分享的访问记录，最近的在前
+func (h *ShareHandler) Logs(c *gin.Context) {
+	s, ok := h.loadOwned(c)
+	if !ok {
+		return
+	}
+
+	pagination := utils.GetPagination(c)
+	var logs []models.ShareAccessLog
+	if err := h.DB.Model(&models.ShareAccessLog{}).Where("share_id = ?", s.ID).
+		Scopes(utils.Paginate(pagination)).Order("id DESC").Find(&logs).Error; err != nil {
+		logger.Error("获取分享访问记录失败", zap.Uint("share_id", s.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取访问记录失败")
+		return
+	}
+	h.Success(c, utils.GetPaginationResult(pagination, logs))
+}
+
+// Info 访问者查看分享信息
+func (h *ShareHandler) Info(c *gin.Context) {
+	s, ok := h.resolve(c, models.ShareActionView)
+	if !ok {
+		return
+	}
+
+	info := shareInfo{
+		Slug:        s.Slug,
+		Name:        s.Name,
+		IsFolder:    s.FileID == 0,
+		ExpiresAt:   s.ExpiresAt,
+		PreviewOnly: s.PreviewOnly,
+	}
+	if s.MaxDownloads > 0 {
+		remaining := s.MaxDownloads - s.Downloads
+		info.Remaining = &remaining
+	}
+	if s.FileID != 0 {
+		file, err := h.sharedFile(s, s.FileID)
+		if err != nil {
+			h.denied(c, s, models.ShareActionView, s.FileID, err)
+			return
+		}
+		entry := sharedEntry(s, file)
+		info.File = &entry
+	}
+
+	share.Log(h.DB, h.accessLog(c, s, models.ShareActionView, 0), nil)
+	h.Success(c, info)
+}
+
+// Files 访问者分页浏览分享目录中的文件，按文件名排序，使用 cursor 翻页
+func (h *ShareHandler) Files(c *gin.Context) {
+	s, ok := h.resolve(c, models.ShareActionView)
+	if !ok {
+		return
+	}
+	if s.FileID != 0 {
+		h.BadRequest(c, "该分享不是目录")
+		return
+	}
+
+	values := c.Request.URL.Query()
+	values.Set("sort", search.SortName)
+	values.Set("order", "asc")
+	page, err := search.ParsePage(values)
+	if err != nil {
+		h.BadRequest(c, err.Error())
+		return
+	}
+
+	query := h.DB.Model(&models.OSSFile{}).
+		Where("oss_files.bucket = ? AND oss_files.object_key LIKE ? AND oss_files.status = ?", s.Bucket, escapeLike(s.Prefix)+"%", "ACTIVE")
+	var files []models.OSSFile
+	if err := page.Apply(query).Find(&files).Error; err != nil {
+		logger.Error("获取分享目录文件失败", zap.Uint("share_id", s.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取文件列表失败")
+		return
+	}
+	files, next := page.Next(files)
+
+	items := make([]sharedFileEntry, 0, len(files))
+	for i := range files {
+		items = append(items, sharedEntry(s, &files[i]))
+	}
+	h.Success(c, gin.H{
+		"items":       items,
+		"has_more":    next != "",
+		"next_cursor": next,
+	})
+}
+
+// Download 访问者下载文件：占用一次下载次数后重定向到新生成的预签名链接
+func (h *ShareHandler) Download(c *gin.Context) {
+	s, ok := h.resolve(c, models.ShareActionDownload)
+	if !ok {
+		return
+	}
+	if s.PreviewOnly {
+		h.denied(c, s, models.ShareActionDownload, 0, share.ErrPreviewOnly)
+		return
+	}
+	file, storage, ok := h.consume(c, s, models.ShareActionDownload)
+	if !ok {
+		return
+	}
+
+	downloadURL, _, err := fileDownloadURL(storage, file, shareURLExpiration)
+	if err != nil {
+		logger.Error("分享下载生成链接失败", zap.Uint("share_id", s.ID), zap.Uint("file_id", file.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "生成下载链接失败")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, downloadURL)
+}
+
+// Preview 访问者在线预览文件：由服务端读取对象输出，图片、PDF 和纯文本 inline 显示，其余类型作为附件下载
+func (h *ShareHandler) Preview(c *gin.Context) {
+	s, ok := h.resolve(c, models.ShareActionPreview)
+	if !ok {
+		return
+	}
+	file, storage, ok := h.consume(c, s, models.ShareActionPreview)
+	if !ok {
+		return
+	}
+
+	body, err := storage.GetObjectFromBucket(file.ObjectKey, h.regionOf(file.Bucket), file.Bucket)
+	if err != nil {
+		logger.Error("分享预览读取对象失败", zap.Uint("share_id", s.ID), zap.Uint("file_id", file.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "读取文件失败")
+		return
+	}
+	defer body.Close()
+
+	// 只有不会执行脚本的类型 inline 输出，其余作为附件下载；sandbox 禁止页面脚本访问本站
+	contentType, inline := share.PreviewType(file.OriginalFilename)
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(file.FileSize, 10))
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": file.OriginalFilename}))
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+
+	// 按分享所有者的下载限速输出
+	w := throttle.Default().ResponseWriter(c.Request.Context(), throttle.Subject{UserID: s.OwnerID}, c.Writer)
+	if _, err := io.Copy(w, body); err != nil {
+		logger.Warn("分享预览输出中断", zap.Uint("share_id", s.ID), zap.Uint("file_id", file.ID), zap.Error(err))
+	}
+}
+
+// resolve 按短链读取分享并校验有效期、下载次数和访问密码，失败时写入错误响应并记录访问
+func (h *ShareHandler) resolve(c *gin.Context, action string) (*models.Share, bool) {
+	var s models.Share
+	if err := h.DB.Where("slug = ?", c.Param("slug")).First(&s).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Error("获取分享失败", zap.String("slug", c.Param("slug")), zap.Error(err))
+		}
+		h.NotFound(c, "分享不存在或已撤销")
+		return nil, false
+	}
+
+	err := share.Check(&s, time.Now())
+	if err == nil {
+		password := c.GetHeader(sharePasswordHeader)
+		if password == "" {
+			password = c.Query("password")
+		}
+		err = share.CheckPassword(s.PasswordHash, password)
+	}
+	if err == nil && !h.ownerCanAccess(s.OwnerID, s.Bucket) {
+		err = share.ErrNotShared
+	}
+	if err != nil {
+		h.denied(c, &s, action, 0, err)
+		return nil, false
+	}
+	return &s, true
+}
+
+// consume 读取要访问的文件并占用一次下载次数
+func (h *ShareHandler) consume(c *gin.Context, s *models.Share, action string) (*models.OSSFile, oss.StorageService, bool) {
+	fileID := s.FileID
+	if fileID == 0 {
+		id, err := strconv.ParseUint(c.Query("file_id"), 10, 32)
+		if err != nil {
+			h.BadRequest(c, "请指定要下载的文件")
+			return nil, nil, false
+		}
+		fileID = uint(id)
+	}
+
+	file, err := h.sharedFile(s, fileID)
+	if err != nil {
+		h.denied(c, s, action, fileID, err)
+		return nil, nil, false
+	}
+	storage, err := h.storageFactory.GetStorageService(file.StorageType)
+	if err != nil {
+		logger.Error("分享访问获取存储服务失败", zap.Uint("share_id", s.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取存储服务失败")
+		return nil, nil, false
+	}
+	if err := share.Consume(h.DB, s); err != nil {
+		if !errors.Is(err, share.ErrLimitReached) {
+			logger.Error("更新分享下载次数失败", zap.Uint("share_id", s.ID), zap.Error(err))
+			h.Error(c, utils.CodeServerError, "访问分享失败")
+			return nil, nil, false
+		}
+		h.denied(c, s, action, fileID, err)
+		return nil, nil, false
+	}
+
+	share.Log(h.DB, h.accessLog(c, s, action, file.ID), nil)
+	return file, storage, true
+}
+
+// sharedFile 读取分享范围内可以下载的文件
+func (h *ShareHandler) sharedFile(s *models.Share, fileID uint) (*models.OSSFile, error) {
+	var file models.OSSFile
+	if err := h.DB.Where("status = ?", "ACTIVE").First(&file, fileID).Error; err != nil {
+		return nil, share.ErrNotShared
+	}
+	if !share.Contains(s, &file) {
+		return nil, share.ErrNotShared
+	}
+	if err := scan.CheckDownload(&file); err != nil {
+		return nil, err
+	}
+	if err := scrub.CheckDownload(&file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// denied 记录被拒绝的访问并写入错误响应
+func (h *ShareHandler) denied(c *gin.Context, s *models.Share, action string, fileID uint, err error) {
+	share.Log(h.DB, h.accessLog(c, s, action, fileID), err)
+	switch {
+	case errors.Is(err, share.ErrPasswordRequired), errors.Is(err, share.ErrWrongPassword):
+		h.Unauthorized(c, err.Error())
+	case errors.Is(err, share.ErrNotShared):
+		h.NotFound(c, err.Error())
+	default:
+		h.Forbidden(c, err.Error())
+	}
+}
+
+// accessLog 根据请求生成访问记录
+func (h *ShareHandler) accessLog(c *gin.Context, s *models.Share, action string, fileID uint) models.ShareAccessLog {
+	return models.ShareAccessLog{
+		ShareID:   s.ID,
+		FileID:    fileID,
+		Action:    action,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// loadOwned 读取路径参数中当前用户创建的分享
+func (h *ShareHandler) loadOwned(c *gin.Context) (*models.Share, bool) {
+	var s models.Share
+	err := h.DB.Where("owner_id = ?", c.GetUint("userID")).First(&s, c.Param("id")).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		h.NotFound(c, "分享不存在")
+		return nil, false
+	case err != nil:
+		logger.Error("获取分享失败", zap.String("share_id", c.Param("id")), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取分享失败")
+		return nil, false
+	}
+	return &s, true
+}
+
+// ownerCanAccess 分享所有者是否仍可访问存储桶，失去权限后分享随之失效
+func (h *ShareHandler) ownerCanAccess(ownerID uint, bucket string) bool {
+	return auth.CheckBucketAccess(h.DB, ownerID, h.regionOf(bucket), bucket)
+}
+
+// regionOf 返回存储桶所属区域，未映射时返回空字符串
+func (h *ShareHandler) regionOf(bucket string) string {
+	var mapping models.RegionBucketMapping
+	h.DB.Where("bucket_name = ?", bucket).First(&mapping)
+	return mapping.RegionCode
+}
+
+// ownerView 返回给所有者的分享信息，附带访问地址和是否设置了密码
+func (h *ShareHandler) ownerView(s *models.Share) gin.H {
+	return gin.H{
+		"share":        s,
+		"url":          "/s/" + s.Slug,
+		"has_password": s.HasPassword(),
+	}
+}
+
+// sharedEntry 将文件记录转换为访问者可见的信息
+func sharedEntry(s *models.Share, file *models.OSSFile) sharedFileEntry {
+	rel := file.OriginalFilename
+	if s.FileID == 0 {
+		rel = strings.TrimPrefix(file.ObjectKey, s.Prefix)
+	}
+	return sharedFileEntry{
+		ID:        file.ID,
+		Name:      file.OriginalFilename,
+		Path:      rel,
+		Size:      file.FileSize,
+		UpdatedAt: file.UpdatedAt,
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/tasks"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// TaskHandler 后台任务查询和取消，用户只能访问自己提交的任务
+type TaskHandler struct {
+	*BaseHandler
+	DB      *gorm.DB
+	manager *tasks.Manager
+}
+
+// NewTaskHandler 创建后台任务处理器
+func NewTaskHandler(db *gorm.DB, manager *tasks.Manager) *TaskHandler {
+	return &TaskHandler{
+		BaseHandler: NewBaseHandler(),
+		DB:          db,
+		manager:     manager,
+	}
+}
+
+// List 分页列出当前用户的任务，可按类型和状态过滤
+func (h *TaskHandler) List(c *gin.Context) {
+	query := h.DB.Model(&models.Task{}).Where("user_id = ?", c.GetUint("userID"))
+	if taskType := c.Query("type"); taskType != "" {
+		query = query.Where("type = ?", taskType)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	pagination := utils.GetPagination(c)
+	var items []models.Task
+	if err := query.Scopes(utils.Paginate(pagination)).Order("id DESC").Find(&items).Error; err != nil {
+		logger.Error("获取后台任务列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取任务列表失败")
+		return
+	}
+
+	h.Success(c, utils.GetPaginationResult(pagination, items))
+}
+
+// Get 获取任务详情
+func (h *TaskHandler) Get(c *gin.Context) {
+	task, ok := h.load(c)
+	if !ok {
+		return
+	}
+	h.Success(c, task)
+}
+
+// Cancel 取消任务，执行中的任务在下次保存进度时中断
+func (h *TaskHandler) Cancel(c *gin.Context) {
+	task, ok := h.load(c)
+	if !ok {
+		return
+	}
+
+	id := task.ID
+	task, err := h.manager.Cancel(id)
+	switch {
+	case errors.Is(err, tasks.ErrFinished):
+		h.BadRequest(c, err.Error())
+	case err != nil:
+		logger.Error("取消后台任务失败", zap.Uint("task_id", id), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "取消任务失败")
+	default:
+		h.Success(c, task)
+	}
+}
+
+// Stream 使用 SSE 推送任务进度：状态或进度变化时发送 progress 事件，任务结束时发送 complete 事件
+func (h *TaskHandler) Stream(c *gin.Context) {
+	task, ok := h.load(c)
+	if !ok {
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	c.Writer.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Writer.Header().Set("Connection", "keep-alive")
+
+	flush := func() {
+		if flusher, ok := c.Writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	c.SSEvent("connected", gin.H{"taskId": task.ID, "timestamp": time.Now().Unix()})
+	flush()
+
+	ctx := c.Request.Context()
+	updates := h.manager.Watch(ctx, task.ID)
+
+	// 心跳定时器，防止连接超时
+	heartbeat := time.NewTicker(10 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case t, ok := <-updates:
+			if !ok {
+				return
+			}
+			if t.Finished() {
+				c.SSEvent("complete", t)
+				flush()
+				return
+			}
+			c.SSEvent("progress", t)
+			flush()
+
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", gin.H{"timestamp": time.Now().Unix()})
+			flush()
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// load 读取路径参数中的任务，任务不存在或不属于当前用户时返回 404
+func (h *TaskHandler) load(c *gin.Context) (*models.Task, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.BadRequest(c, "无效的任务ID")
+		return nil, false
+	}
+
+	task, err := h.manager.Get(uint(id))
+	if err == nil && task.UserID != c.GetUint("userID") {
+		err = gorm.ErrRecordNotFound
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		h.NotFound(c, "任务不存在")
+		return nil, false
+	case err != nil:
+		logger.Error("获取后台任务失败", zap.Uint64("task_id", id), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取任务失败")
+		return nil, false
+	}
+	return task, true
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/tasks"
+	"github.com/myysophia/ossmanager/internal/trash"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// TaskTypeEmptyTrash 清空存储桶的回收站
+const TaskTypeEmptyTrash = "empty_trash"
+
+// emptyTrashBatchSize 清空回收站时每批读取的文件记录数
+const emptyTrashBatchSize = 100
+
+// emptyTrashParams 清空回收站任务的参数
+type emptyTrashParams struct {
+	BucketName string `json:"bucket_name"`
+}
+
+// emptyTrashResult 清空回收站任务的结果
+type emptyTrashResult struct {
+	Purged int `json:"purged"`
+	Failed int `json:"failed"`
+}
+
+// trashedFile 回收站中的文件，附带预计彻底删除的时间
+type trashedFile struct {
+	models.OSSFile
+	PurgeAt *time.Time `json:"purge_at,omitempty"`
+}
+
+// TrashHandler 回收站处理器：列出、恢复、彻底删除文件和清空回收站
+type TrashHandler struct {
+	*BaseHandler
+	DB    *gorm.DB
+	bin   *trash.Bin
+	tasks *tasks.Manager // 后台任务管理器，由 RegisterTasks 设置
+}
+
+// NewTrashHandler 创建回收站处理器
+func NewTrashHandler(db *gorm.DB, bin *trash.Bin) *TrashHandler {
+	return &TrashHandler{
+		BaseHandler: NewBaseHandler(),
+		DB:          db,
+		bin:         bin,
+	}
+}
+
+// RegisterTasks 注册回收站相关的后台任务类型
+func (h *TrashHandler) RegisterTasks(m *tasks.Manager) {
+	h.tasks = m
+	m.Register(TaskTypeEmptyTrash, h.runEmptyTrash)
+}
+
+// List 分页列出用户可访问存储桶中回收站里的文件，可按存储桶过滤，最近删除的在前
+func (h *TrashHandler) List(c *gin.Context) {
+	buckets, err := auth.GetUserAccessibleBuckets(h.DB, c.GetUint("userID"), "")
+	if err != nil {
+		h.Error(c, utils.CodeServerError, "获取可访问桶列表失败")
+		return
+	}
+
+	query := h.DB.Model(&models.OSSFile{}).Where("status = ? AND bucket IN ?", models.FileStatusDeleted, buckets)
+	if bucket := c.Query("bucket"); bucket != "" {
+		query = query.Where("bucket = ?", bucket)
+	}
+
+	pagination := utils.GetPagination(c)
+	var files []models.OSSFile
+	if err := query.Scopes(utils.Paginate(pagination)).Order("trashed_at DESC, id DESC").Find(&files).Error; err != nil {
+		logger.Error("获取回收站文件列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取回收站文件列表失败")
+		return
+	}
+
+	items := make([]trashedFile, 0, len(files))
+	for _, file := range files {
+		item := trashedFile{OSSFile: file}
+		if file.TrashedAt != nil {
+			purgeAt := file.TrashedAt.Add(h.bin.Retention())
+			item.PurgeAt = &purgeAt
+		}
+		items = append(items, item)
+	}
+	h.Success(c, utils.GetPaginationResult(pagination, items))
+}
+
+// Restore 将文件恢复到删除前的位置
+func (h *TrashHandler) Restore(c *gin.Context) {
+	file, ok := h.load(c)
+	if !ok {
+		return
+	}
+
+	err := h.bin.Restore(file)
+	switch {
+	case errors.Is(err, trash.ErrConflict):
+		h.Error(c, utils.CodeFileExists, err.Error())
+	case err != nil:
+		logger.Error("恢复回收站文件失败", zap.Uint("file_id", file.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "恢复文件失败")
+	default:
+		logger.Info("文件已从回收站恢复", zap.Uint("file_id", file.ID), zap.String("object_key", file.ObjectKey))
+		h.Success(c, file)
+	}
+}
+
+// Purge 彻底删除回收站中的文件
+func (h *TrashHandler) Purge(c *gin.Context) {
+	file, ok := h.load(c)
+	if !ok {
+		return
+	}
+
+	if err := h.bin.Purge(file); err != nil {
+		logger.Error("彻底删除回收站文件失败", zap.Uint("file_id", file.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "删除文件失败")
+		return
+	}
+	logger.Info("回收站文件已彻底删除", zap.Uint("file_id", file.ID), zap.String("object_key", file.ObjectKey))
+	h.Success(c, nil)
+}
+
+// Empty 清空存储桶的回收站：提交后台任务彻底删除其中的所有文件，通过 /tasks/:id 查询进度
+func (h *TrashHandler) Empty(c *gin.Context) {
+	var req emptyTrashParams
+	if err := c.ShouldBindJSON(&req); err != nil || req.BucketName == "" {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+
+	var mapping models.RegionBucketMapping
+	if err := h.DB.Where("bucket_name = ?", req.BucketName).First(&mapping).Error; err != nil {
+		h.Error(c, utils.CodeInvalidParams, "未找到存储桶对应的区域信息")
+		return
+	}
+	if !auth.CheckBucketAccess(h.DB, c.GetUint("userID"), mapping.RegionCode, req.BucketName) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return
+	}
+
+	submitTask(c, h.BaseHandler, h.tasks, TaskTypeEmptyTrash, req)
+}
+
+// runEmptyTrash 逐批彻底删除存储桶回收站中的文件；单个文件失败时跳过并计数，重复执行时只处理剩余的文件
+func (h *TrashHandler) runEmptyTrash(ctx context.Context, task *models.Task, progress *tasks.Progress) (any, error) {
+	var params emptyTrashParams
+	if err := tasks.Decode(task, &params); err != nil {
+		return nil, err
+	}
+
+	query := h.DB.Model(&models.OSSFile{}).
+		Where("bucket = ? AND status = ?", params.BucketName, models.FileStatusDeleted).
+		Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("统计文件数失败: %w", err)
+	}
+	progress.SetTotal(total)
+
+	var result emptyTrashResult
+	var lastID uint
+	for {
+		var files []models.OSSFile
+		if err := query.Where("id > ?", lastID).
+			Order("id").Limit(emptyTrashBatchSize).Find(&files).Error; err != nil {
+			return result, fmt.Errorf("获取文件列表失败: %w", err)
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for i := range files {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			file := &files[i]
+			lastID = file.ID
+			progress.SetMessage(file.OriginalKey)
+
+			if err := h.bin.Purge(file); err != nil {
+				logger.Warn("清空回收站时删除文件失败",
+					zap.Uint("task_id", task.ID),
+					zap.Uint("file_id", file.ID),
+					zap.Error(err))
+				result.Failed++
+			} else {
+				result.Purged++
+			}
+			progress.Add(1)
+		}
+	}
+
+	progress.SetMessage("")
+	if result.Failed > 0 {
+		return result, fmt.Errorf("%d 个文件删除失败", result.Failed)
+	}
+	return result, nil
+}
+
+// load 读取路径参数中回收站里的文件并检查存储桶访问权限
+func (h *TrashHandler) load(c *gin.Context) (*models.OSSFile, bool) {
+	var file models.OSSFile
+	err := h.DB.Where("status = ?", models.FileStatusDeleted).First(&file, c.Param("id")).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		h.Error(c, utils.CodeFileNotFound, "回收站中不存在该文件")
+		return nil, false
+	case err != nil:
+		logger.Error("获取回收站文件失败", zap.String("file_id", c.Param("id")), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取文件失败")
+		return nil, false
+	}
+
+	var mapping models.RegionBucketMapping
+	if err := h.DB.Where("bucket_name = ?", file.Bucket).First(&mapping).Error; err != nil ||
+		!auth.CheckBucketAccess(h.DB, c.GetUint("userID"), mapping.RegionCode, file.Bucket) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return nil, false
+	}
+	return &file, true
+}
@@ -0,0 +1,705 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/policy"
+	"github.com/myysophia/ossmanager/internal/quota"
+	"github.com/myysophia/ossmanager/internal/tus"
+	"github.com/myysophia/ossmanager/internal/upload"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// tusMinPartSize 存储端分片上传要求的最小分片（最后一片除外）
+	tusMinPartSize = int64(5 * 1024 * 1024)
+	// tusDefaultExpireHours 未完成上传默认保留时间
+	tusDefaultExpireHours = 24
+)
+
+// TusHandler tus 1.0 断点续传协议处理器
+// PATCH 数据先追加到本地暂存文件，满一个分片后写入存储端的分片上传；
+// 上传完成后合并分片并创建 OSSFile 记录
+type TusHandler struct {
+	*BaseHandler
+	files          *OSSFileHandler
+	storageFactory oss.StorageFactory
+	DB             *gorm.DB
+	locks          sync.Map // uploadKey -> *sync.Mutex
+}
+
+// NewTusHandler 创建 tus 上传处理器
+func NewTusHandler(storageFactory oss.StorageFactory, db *gorm.DB) *TusHandler {
+	return &TusHandler{
+		BaseHandler:    NewBaseHandler(),
+		files:          NewOSSFileHandler(storageFactory, db),
+		storageFactory: storageFactory,
+		DB:             db,
+	}
+}
+
+// Options 返回服务端支持的协议版本和扩展
+func (h *TusHandler) Options(c *gin.Context) {
+	c.Header(tus.HeaderResumable, tus.Version)
+	c.Header(tus.HeaderVersion, tus.Version)
+	c.Header(tus.HeaderExtension, tus.Extensions)
+	c.Header(tus.HeaderChecksumAlgorithm, tus.ChecksumAlgorithm)
+	if maxSize := tusMaxSize(); maxSize > 0 {
+		c.Header(tus.HeaderMaxSize, strconv.FormatInt(maxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Create 创建上传（creation 扩展），请求体携带数据时按 creation-with-upload 处理
+func (h *TusHandler) Create(c *gin.Context) {
+	if !h.checkResumable(c) {
+		return
+	}
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.String(http.StatusBadRequest, "Upload-Defer-Length is not supported")
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader(tus.HeaderUploadLength), 10, 64)
+	if err != nil || length < 0 {
+		c.String(http.StatusBadRequest, "invalid Upload-Length header")
+		return
+	}
+	if maxSize := tusMaxSize(); maxSize > 0 && length > maxSize {
+		c.String(http.StatusRequestEntityTooLarge, "upload exceeds Tus-Max-Size")
+		return
+	}
+
+	rawMetadata := c.GetHeader(tus.HeaderUploadMetadata)
+	meta, err := tus.ParseMetadata(rawMetadata)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid Upload-Metadata header")
+		return
+	}
+
+	// 元数据优先，兼容普通上传接口使用的请求头
+	regionCode := firstNonEmpty(meta["region_code"], c.GetHeader("region_code"))
+	bucketName := firstNonEmpty(meta["bucket_name"], c.GetHeader("bucket_name"))
+	filename := firstNonEmpty(meta["filename"], meta["name"])
+	if regionCode == "" || bucketName == "" {
+		c.String(http.StatusBadRequest, "region_code and bucket_name metadata are required")
+		return
+	}
+	if filename == "" {
+		c.String(http.StatusBadRequest, "filename metadata is required")
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if !auth.CheckBucketAccess(h.DB, userID, regionCode, bucketName) {
+		c.String(http.StatusForbidden, "没有权限访问该存储桶")
+		return
+	}
+
+	uploadPolicy, err := h.files.policy.Load(regionCode, bucketName)
+	if err != nil {
+		logger.Error("获取上传策略失败", zap.String("bucket", bucketName), zap.Error(err))
+		c.String(http.StatusInternalServerError, "获取上传策略失败")
+		return
+	}
+	if err := uploadPolicy.CheckFile(filename, length); err != nil {
+		c.String(http.StatusForbidden, err.Error())
+		return
+	}
+
+	if err := h.files.quota.Check(userID, bucketName, length); err != nil {
+		if errors.Is(err, quota.ErrQuotaExceeded) {
+			c.String(http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		logger.Error("校验存储配额失败", zap.Uint("user_id", userID), zap.Error(err))
+		c.String(http.StatusInternalServerError, "校验存储配额失败")
+		return
+	}
+
+	var ossConfig models.OSSConfig
+	if err := h.DB.Where("is_default = ?", true).First(&ossConfig).Error; err != nil {
+		c.String(http.StatusInternalServerError, "获取默认存储配置失败")
+		return
+	}
+
+	username, _ := c.Get("username")
+	objectKey, ok := buildObjectKey(firstNonEmpty(meta["path"], c.GetHeader("X-Custom-Path")), username.(string), filename)
+	if !ok {
+		c.String(http.StatusBadRequest, "自定义路径包含非法字符")
+		return
+	}
+
+	forceOverwrite := meta["overwrite"] == "true" || c.GetHeader("X-Force-Overwrite") == "true"
+	if !forceOverwrite {
+		var existingFile models.OSSFile
+		err := h.DB.Where("object_key = ? AND bucket = ? AND status = ?",
+			objectKey, bucketName, "ACTIVE").First(&existingFile).Error
+		if err == nil {
+			c.String(http.StatusConflict, "在相同路径下文件已存在，请确认是否要覆盖")
+			return
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusInternalServerError, "检查文件是否存在失败")
+			return
+		}
+	}
+
+	tusUpload := &models.TusUpload{
+		UploadKey:        uuid.NewString(),
+		UserID:           userID,
+		ConfigID:         ossConfig.ID,
+		RegionCode:       regionCode,
+		BucketName:       bucketName,
+		ObjectKey:        objectKey,
+		OriginalFilename: filename,
+		UploadLength:     length,
+		PartSize:         tusPartSize(length),
+		Metadata:         rawMetadata,
+		UploadIP:         c.ClientIP(),
+		Status:           models.TusStatusUploading,
+		ExpiresAt:        time.Now().Add(tusExpiration()),
+	}
+	if err := h.DB.Create(tusUpload).Error; err != nil {
+		logger.Error("创建tus上传记录失败", zap.Error(err))
+		c.String(http.StatusInternalServerError, "创建上传失败")
+		return
+	}
+
+	logger.Info("创建tus上传",
+		zap.String("upload_key", tusUpload.UploadKey),
+		zap.String("object_key", objectKey),
+		zap.Int64("upload_length", length),
+		zap.Int64("part_size", tusUpload.PartSize),
+	)
+
+	c.Header("Location", c.Request.URL.Path+"/"+tusUpload.UploadKey)
+	c.Header(tus.HeaderUploadExpires, tusUpload.ExpiresAt.UTC().Format(http.TimeFormat))
+
+	unlock := h.lock(tusUpload.UploadKey)
+	defer unlock()
+
+	// 空文件或 creation-with-upload：直接写入数据
+	if length == 0 || c.ContentType() == tus.OffsetContentType {
+		if status, msg := h.write(c, tusUpload); status != http.StatusNoContent {
+			c.String(status, msg)
+			return
+		}
+		c.Header(tus.HeaderUploadOffset, strconv.FormatInt(tusUpload.UploadOffset, 10))
+	}
+
+	c.Status(http.StatusCreated)
+}
+
+// Head 查询上传偏移量
+func (h *TusHandler) Head(c *gin.Context) {
+	if !h.checkResumable(c) {
+		return
+	}
+
+	tusUpload, ok := h.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header(tus.HeaderUploadOffset, strconv.FormatInt(tusUpload.UploadOffset, 10))
+	c.Header(tus.HeaderUploadLength, strconv.FormatInt(tusUpload.UploadLength, 10))
+	if tusUpload.Metadata != "" {
+		c.Header(tus.HeaderUploadMetadata, tusUpload.Metadata)
+	}
+	if tusUpload.Status == models.TusStatusUploading {
+		c.Header(tus.HeaderUploadExpires, tusUpload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusOK)
+}
+
+// Patch 从指定偏移量继续写入数据
+func (h *TusHandler) Patch(c *gin.Context) {
+	if !h.checkResumable(c) {
+		return
+	}
+
+	if c.ContentType() != tus.OffsetContentType {
+		c.String(http.StatusUnsupportedMediaType, "Content-Type must be "+tus.OffsetContentType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader(tus.HeaderUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		c.String(http.StatusBadRequest, "invalid Upload-Offset header")
+		return
+	}
+
+	throttleRequestBody(c)
+
+	unlock := h.lock(c.Param("id"))
+	defer unlock()
+
+	tusUpload, ok := h.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	if offset != tusUpload.UploadOffset {
+		c.Header(tus.HeaderUploadOffset, strconv.FormatInt(tusUpload.UploadOffset, 10))
+		c.String(http.StatusConflict, "Upload-Offset does not match current offset")
+		return
+	}
+
+	if status, msg := h.write(c, tusUpload); status != http.StatusNoContent {
+		c.String(status, msg)
+		return
+	}
+
+	c.Header(tus.HeaderUploadOffset, strconv.FormatInt(tusUpload.UploadOffset, 10))
+	if tusUpload.Status == models.TusStatusUploading {
+		c.Header(tus.HeaderUploadExpires, tusUpload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Terminate 终止上传（termination 扩展），中止存储端分片上传并清理暂存数据
+func (h *TusHandler) Terminate(c *gin.Context) {
+	if !h.checkResumable(c) {
+		return
+	}
+
+	unlock := h.lock(c.Param("id"))
+	defer unlock()
+
+	tusUpload, ok := h.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	if tusUpload.Status == models.TusStatusCompleted {
+		c.String(http.StatusForbidden, "upload already completed")
+		return
+	}
+
+	if tusUpload.MultipartUploadID != "" {
+		if _, storage, err := h.storageFor(tusUpload); err == nil {
+			h.files.safeAbortMultipartUpload(storage, tusUpload.MultipartUploadID, tusUpload.ObjectKey, tusUpload.RegionCode, tusUpload.BucketName)
+		}
+	}
+
+	if err := os.Remove(h.pendingPath(tusUpload.UploadKey)); err != nil && !os.IsNotExist(err) {
+		logger.Warn("删除tus暂存文件失败", zap.String("upload_key", tusUpload.UploadKey), zap.Error(err))
+	}
+
+	if err := h.DB.Model(tusUpload).Update("status", models.TusStatusTerminated).Error; err != nil {
+		c.String(http.StatusInternalServerError, "终止上传失败")
+		return
+	}
+	h.locks.Delete(tusUpload.UploadKey)
+
+	logger.Info("终止tus上传", zap.String("upload_key", tusUpload.UploadKey))
+	c.Status(http.StatusNoContent)
+}
+
+// checkContent 按存储桶策略检测请求体开头的文件头，通过时返回 0
+func (h *TusHandler) checkContent(c *gin.Context, tusUpload *models.TusUpload) (int, string) {
+	p, err := h.files.policy.Load(tusUpload.RegionCode, tusUpload.BucketName)
+	if err != nil {
+		logger.Error("获取上传策略失败", zap.String("bucket", tusUpload.BucketName), zap.Error(err))
+		return http.StatusInternalServerError, "获取上传策略失败"
+	}
+	if !p.NeedsContent() {
+		return 0, ""
+	}
+
+	head, body, err := policy.Peek(c.Request.Body)
+	if err != nil {
+		return http.StatusBadRequest, "读取请求体失败"
+	}
+	if err := p.CheckContent(tusUpload.OriginalFilename, head); err != nil {
+		logger.Warn("tus上传内容不符合策略", zap.String("upload_key", tusUpload.UploadKey), zap.Error(err))
+		h.DB.Model(tusUpload).Update("status", models.TusStatusTerminated)
+		return http.StatusUnsupportedMediaType, err.Error()
+	}
+	c.Request.Body = io.NopCloser(body)
+	return 0, ""
+}
+
+// write 将请求体追加到暂存文件，写满的分片上传到存储端，数据完整后合并并保存文件记录
+// 返回 http.StatusNoContent 表示成功，否则返回错误状态码和消息
+func (h *TusHandler) write(c *gin.Context, tusUpload *models.TusUpload) (int, string) {
+	// 已完成的上传只接受空请求（例如客户端重试最后一次 PATCH）
+	if tusUpload.Status == models.TusStatusCompleted {
+		return http.StatusNoContent, ""
+	}
+
+	// 第一段数据到达时检测文件头，不符合存储桶策略的上传直接终止
+	if tusUpload.UploadOffset == 0 {
+		if status, msg := h.checkContent(c, tusUpload); status != 0 {
+			return status, msg
+		}
+	}
+
+	var checksum *tus.Checksum
+	if header := c.GetHeader(tus.HeaderUploadChecksum); header != "" {
+		var err error
+		checksum, err = tus.ParseChecksum(header)
+		if err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.pendingPath(tusUpload.UploadKey)), 0755); err != nil {
+		logger.Error("创建tus暂存目录失败", zap.Error(err))
+		return http.StatusInternalServerError, "创建暂存目录失败"
+	}
+
+	pending, err := os.OpenFile(h.pendingPath(tusUpload.UploadKey), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Error("打开tus暂存文件失败", zap.Error(err))
+		return http.StatusInternalServerError, "打开暂存文件失败"
+	}
+	defer pending.Close()
+
+	info, err := pending.Stat()
+	if err != nil {
+		return http.StatusInternalServerError, "读取暂存文件失败"
+	}
+
+	parts, err := decodeTusParts(tusUpload.Parts)
+	if err != nil {
+		return http.StatusInternalServerError, "读取分片信息失败"
+	}
+	uploadedBytes := int64(len(parts)) * tusUpload.PartSize
+
+	// 暂存文件与记录的偏移量不一致（例如请求落到了其他实例），回退到已写入存储端的偏移量
+	if uploadedBytes+info.Size() != tusUpload.UploadOffset {
+		logger.Warn("tus暂存数据与偏移量不一致，回退偏移量",
+			zap.String("upload_key", tusUpload.UploadKey),
+			zap.Int64("offset", tusUpload.UploadOffset),
+			zap.Int64("uploaded_bytes", uploadedBytes),
+			zap.Int64("pending_size", info.Size()),
+		)
+		_ = pending.Truncate(0)
+		tusUpload.UploadOffset = uploadedBytes
+		h.DB.Model(tusUpload).Update("upload_offset", uploadedBytes)
+		c.Header(tus.HeaderUploadOffset, strconv.FormatInt(uploadedBytes, 10))
+		return http.StatusConflict, "upload offset was reset, please resume from Upload-Offset"
+	}
+
+	remaining := tusUpload.UploadLength - tusUpload.UploadOffset
+	var dst io.Writer = pending
+	if checksum != nil {
+		dst = io.MultiWriter(pending, checksum.Hash())
+	}
+
+	// 多读一个字节用于判断请求体是否超过 Upload-Length
+	written, copyErr := io.Copy(dst, io.LimitReader(c.Request.Body, remaining+1))
+	if written > remaining {
+		_ = pending.Truncate(info.Size())
+		return http.StatusRequestEntityTooLarge, "request body exceeds Upload-Length"
+	}
+	if checksum != nil {
+		if copyErr != nil || !checksum.Verify() {
+			_ = pending.Truncate(info.Size())
+			if copyErr != nil {
+				return http.StatusBadRequest, "读取请求体失败"
+			}
+			return tus.StatusChecksumMismatch, "Checksum Mismatch"
+		}
+	}
+	// 未携带校验和时，中断的请求保留已接收的数据，客户端可从新的偏移量继续
+	if copyErr != nil {
+		logger.Warn("tus请求体读取中断，保留已接收数据",
+			zap.String("upload_key", tusUpload.UploadKey),
+			zap.Int64("written", written),
+			zap.Error(copyErr),
+		)
+	}
+
+	tusUpload.UploadOffset += written
+	tusUpload.ExpiresAt = time.Now().Add(tusExpiration())
+	if err := h.DB.Model(tusUpload).Updates(map[string]interface{}{
+		"upload_offset": tusUpload.UploadOffset,
+		"expires_at":    tusUpload.ExpiresAt,
+	}).Error; err != nil {
+		_ = pending.Truncate(info.Size())
+		tusUpload.UploadOffset -= written
+		return http.StatusInternalServerError, "保存上传偏移量失败"
+	}
+
+	if copyErr != nil {
+		return http.StatusBadRequest, "读取请求体失败"
+	}
+
+	ossConfig, storage, err := h.storageFor(tusUpload)
+	if err != nil {
+		return http.StatusInternalServerError, "获取存储服务失败"
+	}
+
+	final := tusUpload.UploadOffset == tusUpload.UploadLength
+	if err := h.flushParts(storage, tusUpload, pending, parts, final); err != nil {
+		logger.Error("tus分片写入存储失败", zap.String("upload_key", tusUpload.UploadKey), zap.Error(err))
+		return http.StatusInternalServerError, "写入存储失败"
+	}
+
+	if final {
+		if err := h.complete(storage, ossConfig, tusUpload, pending); err != nil {
+			logger.Error("完成tus上传失败", zap.String("upload_key", tusUpload.UploadKey), zap.Error(err))
+			return http.StatusInternalServerError, "完成上传失败"
+		}
+	}
+
+	return http.StatusNoContent, ""
+}
+
+// flushParts 将暂存文件中写满的分片上传到存储端；final 为 true 时连同最后不足一片的数据一起上传
+// 小文件在最后一次写入前不会初始化分片上传，由 complete 直接简单上传
+func (h *TusHandler) flushParts(storage oss.StorageService, tusUpload *models.TusUpload, pending *os.File, parts []oss.Part, final bool) error {
+	info, err := pending.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	if size < tusUpload.PartSize && (!final || tusUpload.MultipartUploadID == "") {
+		return nil
+	}
+
+	if tusUpload.MultipartUploadID == "" {
+		uploadID, _, err := storage.InitMultipartUploadToBucket(tusUpload.ObjectKey, tusUpload.RegionCode, tusUpload.BucketName)
+		if err != nil {
+			return fmt.Errorf("初始化分片上传失败: %v", err)
+		}
+		tusUpload.MultipartUploadID = uploadID
+		if err := h.DB.Model(tusUpload).Update("multipart_upload_id", uploadID).Error; err != nil {
+			return err
+		}
+	}
+
+	var consumed int64
+	buf := make([]byte, tusUpload.PartSize)
+	for size-consumed >= tusUpload.PartSize || (final && size-consumed > 0) {
+		n, err := pending.ReadAt(buf, consumed)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		partNumber := len(parts) + 1
+		etag, err := h.files.uploadPart(context.Background(), storage, buf[:n], partNumber,
+			tusUpload.MultipartUploadID, tusUpload.ObjectKey, tusUpload.RegionCode, tusUpload.BucketName)
+		if err != nil {
+			return err
+		}
+
+		parts = append(parts, oss.Part{PartNumber: partNumber, ETag: etag})
+		consumed += int64(n)
+	}
+
+	if consumed == 0 {
+		return nil
+	}
+
+	// 移除已上传的数据，仅保留不足一片的剩余部分
+	if err := compactPending(pending, consumed); err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(parts)
+	if err != nil {
+		return err
+	}
+	tusUpload.Parts = string(encoded)
+	return h.DB.Model(tusUpload).Update("parts", tusUpload.Parts).Error
+}
+
+// complete 合并分片（或直接上传小文件）并创建文件记录
+func (h *TusHandler) complete(storage oss.StorageService, ossConfig models.OSSConfig, tusUpload *models.TusUpload, pending *os.File) error {
+	var uploadURL string
+	if tusUpload.MultipartUploadID == "" {
+		if _, err := pending.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		url, err := storage.UploadToBucket(pending, tusUpload.ObjectKey, tusUpload.RegionCode, tusUpload.BucketName)
+		if err != nil {
+			return fmt.Errorf("上传文件失败: %v", err)
+		}
+		uploadURL = url
+	} else {
+		parts, err := decodeTusParts(tusUpload.Parts)
+		if err != nil {
+			return err
+		}
+		sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+		url, err := storage.CompleteMultipartUploadToBucket(tusUpload.ObjectKey, tusUpload.MultipartUploadID, parts, tusUpload.RegionCode, tusUpload.BucketName)
+		if err != nil {
+			return fmt.Errorf("完成分片上传失败: %v", err)
+		}
+		uploadURL = url
+	}
+
+	ossFile := newFileRecord(ossConfig, tusUpload.ObjectKey, tusUpload.OriginalFilename, tusUpload.UploadLength,
+		tusUpload.BucketName, uploadURL, tusUpload.UserID, tusUpload.UploadIP)
+	if err := createFileRecord(h.DB, &ossFile); err != nil {
+		return err
+	}
+
+	tusUpload.Status = models.TusStatusCompleted
+	tusUpload.FileID = ossFile.ID
+	if err := h.DB.Model(tusUpload).Updates(map[string]interface{}{
+		"status":  tusUpload.Status,
+		"file_id": tusUpload.FileID,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := os.Remove(pending.Name()); err != nil && !os.IsNotExist(err) {
+		logger.Warn("删除tus暂存文件失败", zap.String("upload_key", tusUpload.UploadKey), zap.Error(err))
+	}
+	h.locks.Delete(tusUpload.UploadKey)
+
+	logger.Info("tus上传完成",
+		zap.String("upload_key", tusUpload.UploadKey),
+		zap.String("object_key", tusUpload.ObjectKey),
+		zap.Uint("file_id", ossFile.ID),
+	)
+	return nil
+}
+
+// checkResumable 校验 Tus-Resumable 请求头并设置响应头
+func (h *TusHandler) checkResumable(c *gin.Context) bool {
+	c.Header(tus.HeaderResumable, tus.Version)
+	if c.GetHeader(tus.HeaderResumable) != tus.Version {
+		c.Header(tus.HeaderVersion, tus.Version)
+		c.String(http.StatusPreconditionFailed, "unsupported tus version")
+		return false
+	}
+	return true
+}
+
+// loadUpload 加载当前用户的上传记录，不存在时返回 404，已终止或过期时返回 410
+func (h *TusHandler) loadUpload(c *gin.Context) (*models.TusUpload, bool) {
+	var tusUpload models.TusUpload
+	err := h.DB.Where("upload_key = ? AND user_id = ?", c.Param("id"), c.GetUint("userID")).First(&tusUpload).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "upload not found")
+		} else {
+			c.String(http.StatusInternalServerError, "查询上传记录失败")
+		}
+		return nil, false
+	}
+
+	if tusUpload.Status == models.TusStatusTerminated ||
+		(tusUpload.Status == models.TusStatusUploading && tusUpload.IsExpired()) {
+		c.String(http.StatusGone, "upload terminated or expired")
+		return nil, false
+	}
+
+	return &tusUpload, true
+}
+
+// storageFor 获取上传记录对应的存储配置和存储服务
+func (h *TusHandler) storageFor(tusUpload *models.TusUpload) (models.OSSConfig, oss.StorageService, error) {
+	var ossConfig models.OSSConfig
+	if err := h.DB.First(&ossConfig, tusUpload.ConfigID).Error; err != nil {
+		return ossConfig, nil, err
+	}
+	storage, err := h.storageFactory.GetStorageService(ossConfig.StorageType)
+	return ossConfig, storage, err
+}
+
+// lock 对同一上传的并发请求加锁，返回解锁函数
+func (h *TusHandler) lock(uploadKey string) func() {
+	value, _ := h.locks.LoadOrStore(uploadKey, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// pendingPath 返回上传暂存文件路径
+func (h *TusHandler) pendingPath(uploadKey string) string {
+	return tus.PendingPath(uploadKey)
+}
+
+// compactPending 丢弃暂存文件前 consumed 字节
+func compactPending(pending *os.File, consumed int64) error {
+	info, err := pending.Stat()
+	if err != nil {
+		return err
+	}
+
+	rest := make([]byte, info.Size()-consumed)
+	if _, err := pending.ReadAt(rest, consumed); err != nil && err != io.EOF {
+		return err
+	}
+	if err := pending.Truncate(0); err != nil {
+		return err
+	}
+	_, err = io.Copy(pending, bytes.NewReader(rest))
+	return err
+}
+
+// decodeTusParts 解析已上传分片列表
+func decodeTusParts(raw string) ([]oss.Part, error) {
+	var parts []oss.Part
+	if raw == "" {
+		return parts, nil
+	}
+	err := json.Unmarshal([]byte(raw), &parts)
+	return parts, err
+}
+
+// tusPartSize 计算分片大小，与其他分片上传使用相同的规则，配置的分片大小不小于存储端要求的最小分片
+func tusPartSize(length int64) int64 {
+	var partSize int64
+	if cfg := config.GetConfig(); cfg != nil && cfg.App.TusPartSize > 0 {
+		partSize = max(cfg.App.TusPartSize, tusMinPartSize)
+	}
+	return upload.PartSize(length, partSize)
+}
+
+// tusExpiration 返回未完成上传的保留时间
+func tusExpiration() time.Duration {
+	hours := tusDefaultExpireHours
+	if cfg := config.GetConfig(); cfg != nil && cfg.App.TusExpireHours > 0 {
+		hours = cfg.App.TusExpireHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// tusMaxSize 返回允许的最大上传大小，0 表示不限制
+func tusMaxSize() int64 {
+	if cfg := config.GetConfig(); cfg != nil {
+		return cfg.App.MaxFileSize
+	}
+	return 0
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
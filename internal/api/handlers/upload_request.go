@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/policy"
+	"github.com/myysophia/ossmanager/internal/share"
+	"github.com/myysophia/ossmanager/internal/throttle"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// uploadRequestFormOverhead 限制请求体大小时为表单字段和分隔符预留的字节数
+const uploadRequestFormOverhead = 1 << 20
+
+// UploadRequestHandler 上传请求链接处理器：所有者管理链接，外部人员通过 /u/:slug 匿名上传
+// 上传复用 OSSFileHandler 的配额、策略和校验和检查，文件记在链接所有者名下
+type UploadRequestHandler struct {
+	*BaseHandler
+	DB    *gorm.DB
+	files *OSSFileHandler
+}
+
+// NewUploadRequestHandler 创建上传请求链接处理器
+func NewUploadRequestHandler(files *OSSFileHandler) *UploadRequestHandler {
+	return &UploadRequestHandler{
+		BaseHandler: NewBaseHandler(),
+		DB:          files.DB,
+		files:       files,
+	}
+}
+
+// uploadRequestParams 创建和修改上传请求的参数，修改时未提供的字段保持不变；Password 为空字符串时取消密码
+type uploadRequestParams struct {
+	BucketName        string  `json:"bucket_name"`
+	Prefix            string  `json:"prefix"`
+	Name              *string `json:"name"`
+	Description       *string `json:"description"`
+	Password          *string `json:"password"`
+	ExpiresHours      *int    `json:"expires_hours" binding:"omitempty,min=0"`  // 0 表示永不过期
+	MaxTotalSize      *int64  `json:"max_total_size" binding:"omitempty,min=0"` // 0 表示不限制
+	AllowedExtensions *string `json:"allowed_extensions"`
+	AllowedMIMETypes  *string `json:"allowed_mime_types"`
+}
+
+// uploadRequestInfo 返回给上传者的链接信息，不包含存储位置
+type uploadRequestInfo struct {
+	Slug              string     `json:"slug"`
+	Name              string     `json:"name"`
+	Description       string     `json:"description"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	AllowedExtensions string     `json:"allowed_extensions"`
+	AllowedMIMETypes  string     `json:"allowed_mime_types"`
+	Remaining         *int64     `json:"remaining_size,omitempty"`
+}
+
+// uploadedFile 返回给上传者的上传结果
+type uploadedFile struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// Create 为存储桶目录创建上传请求链接
+func (h *UploadRequestHandler) Create(c *gin.Context) {
+	var req uploadRequestParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+
+	prefix := strings.Trim(req.Prefix, "/")
+	if req.BucketName == "" || prefix == "" || strings.Contains(prefix, "..") {
+		h.BadRequest(c, "请指定接收文件的存储桶和目录")
+		return
+	}
+	r := models.UploadRequest{
+		OwnerID: c.GetUint("userID"),
+		Bucket:  req.BucketName,
+		Prefix:  prefix + "/",
+		Name:    path.Base(prefix),
+	}
+	if !auth.CheckBucketAccess(h.DB, r.OwnerID, h.regionOf(r.Bucket), r.Bucket) {
+		h.Error(c, utils.CodeForbidden, "没有权限访问该存储桶")
+		return
+	}
+	if !h.apply(c, &r, &req) {
+		return
+	}
+
+	var err error
+	if r.Slug, err = share.NewSlug(); err != nil {
+		h.Error(c, utils.CodeServerError, "创建上传链接失败")
+		return
+	}
+	if err := h.DB.Create(&r).Error; err != nil {
+		logger.Error("创建上传链接失败", zap.Uint("owner_id", r.OwnerID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "创建上传链接失败")
+		return
+	}
+	logger.Info("创建上传链接",
+		zap.Uint("upload_request_id", r.ID),
+		zap.Uint("owner_id", r.OwnerID),
+		zap.String("bucket", r.Bucket),
+		zap.String("prefix", r.Prefix))
+	h.Success(c, h.ownerView(&r))
+}
+
+// List 分页列出当前用户创建的上传请求
+func (h *UploadRequestHandler) List(c *gin.Context) {
+	pagination := utils.GetPagination(c)
+	var requests []models.UploadRequest
+	if err := h.DB.Model(&models.UploadRequest{}).Where("owner_id = ?", c.GetUint("userID")).
+		Scopes(utils.Paginate(pagination)).Order("id DESC").Find(&requests).Error; err != nil {
+		logger.Error("获取上传链接列表失败", zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取上传链接列表失败")
+		return
+	}
+
+	items := make([]gin.H, 0, len(requests))
+	for i := range requests {
+		items = append(items, h.ownerView(&requests[i]))
+	}
+	h.Success(c, utils.GetPaginationResult(pagination, items))
+}
+
+// Get 获取上传请求详情
+func (h *UploadRequestHandler) Get(c *gin.Context) {
+	r, ok := h.loadOwned(c)
+	if !ok {
+		return
+	}
+	h.Success(c, h.ownerView(r))
+}
+
+// Update 修改上传请求的标题、说明、密码、有效期、总大小上限和允许的文件类型，存储位置不能修改
+func (h *UploadRequestHandler) Update(c *gin.Context) {
+	var req uploadRequestParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Error(c, utils.CodeInvalidParams, "无效的请求参数")
+		return
+	}
+	r, ok := h.loadOwned(c)
+	if !ok {
+		return
+	}
+	if !h.apply(c, r, &req) {
+		return
+	}
+
+	if err := h.DB.Model(r).Select("name", "description", "password_hash", "expires_at",
+		"max_total_size", "allowed_extensions", "allowed_mime_types").Updates(r).Error; err != nil {
+		logger.Error("修改上传链接失败", zap.Uint("upload_request_id", r.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "修改上传链接失败")
+		return
+	}
+	h.Success(c, h.ownerView(r))
+}
+
+// Delete 撤销上传请求，链接立即失效，已上传的文件保留
+func (h *UploadRequestHandler) Delete(c *gin.Context) {
+	r, ok := h.loadOwned(c)
+	if !ok {
+		return
+	}
+	if err := h.DB.Delete(r).Error; err != nil {
+		logger.Error("撤销上传链接失败", zap.Uint("upload_request_id", r.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "撤销上传链接失败")
+		return
+	}
+	logger.Info("撤销上传链接", zap.Uint("upload_request_id", r.ID), zap.Uint("owner_id", r.OwnerID))
+	h.Success(c, nil)
+}
+
+// Info 上传者查看链接的说明和限制
+func (h *UploadRequestHandler) Info(c *gin.Context) {
+	r, _, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+
+	info := uploadRequestInfo{
+		Slug:              r.Slug,
+		Name:              r.Name,
+		Description:       r.Description,
+		ExpiresAt:         r.ExpiresAt,
+		AllowedExtensions: r.AllowedExtensions,
+		AllowedMIMETypes:  r.AllowedMIMETypes,
+	}
+	if remaining := share.Remaining(r); remaining >= 0 {
+		info.Remaining = &remaining
+	}
+	h.Success(c, info)
+}
+
+// Upload 上传者通过表单字段 file 上传一个文件，可以用 uploader 字段留下姓名
+// 文件名总是加上随机后缀，不会覆盖已有文件，并发上传同名文件也不会互相覆盖
+func (h *UploadRequestHandler) Upload(c *gin.Context) {
+	r, regionCode, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+
+	bucketPolicy, err := h.files.policy.Load(regionCode, r.Bucket)
+	if err != nil {
+		logger.Error("获取上传策略失败", zap.String("bucket", r.Bucket), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取上传策略失败")
+		return
+	}
+
+	// 请求体超过剩余容量或文件大小上限（存储桶策略和 app.max_file_size 中较小者）时不再继续读取，
+	// 避免匿名上传者把超大文件写入临时目录；按链接所有者的上传限速读取
+	limit := share.Remaining(r)
+	var policyMax int64
+	if bucketPolicy != nil {
+		policyMax = bucketPolicy.MaxFileSize
+	}
+	limitedByPolicy := policyMax > 0 && (limit < 0 || policyMax < limit)
+	if limitedByPolicy {
+		limit = policyMax
+	}
+	if limit >= 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+uploadRequestFormOverhead)
+	}
+	c.Request.Body = io.NopCloser(throttle.Default().UploadReader(c.Request.Context(), throttle.Subject{UserID: r.OwnerID}, c.Request.Body))
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			if limitedByPolicy {
+				h.Error(c, utils.CodePolicyViolation, fmt.Sprintf("文件大小超过限制 %d 字节", policyMax))
+				return
+			}
+			h.Error(c, utils.CodeQuotaExceeded, share.ErrSizeExceeded.Error())
+			return
+		}
+		h.Error(c, utils.CodeInvalidParams, "获取文件失败")
+		return
+	}
+	filename, size := fileHeader.Filename, fileHeader.Size
+
+	if err := share.CheckUpload(r, time.Now(), size); err != nil {
+		h.Error(c, utils.CodeQuotaExceeded, err.Error())
+		return
+	}
+	requestPolicy := share.UploadPolicy(r)
+	if err := requestPolicy.CheckFile(filename, size); err != nil {
+		h.Error(c, utils.CodePolicyViolation, err.Error())
+		return
+	}
+	if err := bucketPolicy.CheckFile(filename, size); err != nil {
+		h.Error(c, utils.CodePolicyViolation, err.Error())
+		return
+	}
+	if !h.files.checkQuota(c, r.OwnerID, r.Bucket, size) {
+		return
+	}
+	objectKey, err := share.UploadKey(r, filename)
+	if err != nil {
+		h.BadRequest(c, err.Error())
+		return
+	}
+	if objectKey, err = h.availableKey(r.Bucket, objectKey); err != nil {
+		h.Error(c, utils.CodeServerError, "检查文件是否存在失败")
+		return
+	}
+
+	var config models.OSSConfig
+	if err := h.DB.Where("is_default = ?", true).First(&config).Error; err != nil {
+		h.Error(c, utils.CodeServerError, "获取默认存储配置失败")
+		return
+	}
+	storage, err := h.files.storageFactory.GetStorageService(config.StorageType)
+	if err != nil {
+		h.Error(c, utils.CodeServerError, "获取存储服务失败")
+		return
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		h.Error(c, utils.CodeServerError, "打开文件失败")
+		return
+	}
+	defer src.Close()
+
+	body, ok := h.files.checkContent(c, requestPolicy, filename, src)
+	if !ok {
+		return
+	}
+	if body, ok = h.files.checkContent(c, bucketPolicy, filename, body); !ok {
+		return
+	}
+	verifier, ok := h.files.checksumVerifier(c)
+	if !ok {
+		return
+	}
+	body = verifier.Reader(body)
+
+	// 先占用容量，并发上传时不会超过总大小上限
+	if err := share.Reserve(h.DB, r, size); err != nil {
+		if errors.Is(err, share.ErrSizeExceeded) {
+			h.Error(c, utils.CodeQuotaExceeded, err.Error())
+			return
+		}
+		logger.Error("占用上传链接容量失败", zap.Uint("upload_request_id", r.ID), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "上传文件失败")
+		return
+	}
+	stored := false
+	defer func() {
+		if !stored {
+			if err := share.Release(h.DB, r, size); err != nil {
+				logger.Warn("归还上传链接容量失败", zap.Uint("upload_request_id", r.ID), zap.Error(err))
+			}
+		}
+	}()
+
+	uploadURL, err := storage.UploadToBucket(body, objectKey, regionCode, r.Bucket)
+	if err != nil {
+		logger.Error("上传链接上传文件失败", zap.Uint("upload_request_id", r.ID), zap.String("object_key", objectKey), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "上传文件失败")
+		return
+	}
+	if !h.files.verifyUploaded(c, storage, verifier, objectKey, regionCode, r.Bucket) {
+		return
+	}
+
+	file := newFileRecord(config, objectKey, path.Base(objectKey), size, r.Bucket, uploadURL, r.OwnerID, c.ClientIP())
+	if err := createFileRecordWithHashes(h.DB, &file, verifier.Sums()); err != nil {
+		logger.Error("保存文件记录失败", zap.String("object_key", objectKey), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "保存文件记录失败")
+		return
+	}
+	stored = true
+
+	uploader := strings.TrimSpace(c.PostForm("uploader"))
+	h.auditUpload(c, r, &file, uploader)
+	logger.Info("通过上传链接上传文件",
+		zap.Uint("upload_request_id", r.ID),
+		zap.Uint("file_id", file.ID),
+		zap.String("object_key", objectKey),
+		zap.Int64("size", size),
+		zap.String("uploader", uploader))
+	h.Success(c, uploadedFile{Name: file.OriginalFilename, Size: file.FileSize, UploadedAt: file.CreatedAt})
+}
+
+// apply 校验参数并写入上传请求
+func (h *UploadRequestHandler) apply(c *gin.Context, r *models.UploadRequest, req *uploadRequestParams) bool {
+	if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+		r.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		r.Description = *req.Description
+	}
+	if req.Password != nil {
+		hash, err := share.HashPassword(*req.Password)
+		if err != nil {
+			h.Error(c, utils.CodeServerError, "设置访问密码失败")
+			return false
+		}
+		r.PasswordHash = hash
+	}
+	if req.ExpiresHours != nil {
+		r.ExpiresAt = nil
+		if *req.ExpiresHours > 0 {
+			expiresAt := time.Now().Add(time.Duration(*req.ExpiresHours) * time.Hour)
+			r.ExpiresAt = &expiresAt
+		}
+	}
+	if req.MaxTotalSize != nil {
+		r.MaxTotalSize = *req.MaxTotalSize
+	}
+	if req.AllowedExtensions != nil {
+		r.AllowedExtensions = *req.AllowedExtensions
+	}
+	if req.AllowedMIMETypes != nil {
+		r.AllowedMIMETypes = *req.AllowedMIMETypes
+	}
+	if err := policy.Validate(&models.UploadPolicy{AllowedExtensions: r.AllowedExtensions, AllowedMIMETypes: r.AllowedMIMETypes}); err != nil {
+		h.BadRequest(c, err.Error())
+		return false
+	}
+	return true
+}
+
+// resolve 按短链读取上传请求并校验有效期和访问密码，返回存储桶所属区域；失败时写入错误响应
+func (h *UploadRequestHandler) resolve(c *gin.Context) (*models.UploadRequest, string, bool) {
+	var r models.UploadRequest
+	if err := h.DB.Where("slug = ?", c.Param("slug")).First(&r).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Error("获取上传链接失败", zap.String("slug", c.Param("slug")), zap.Error(err))
+		}
+		h.NotFound(c, "上传链接不存在或已撤销")
+		return nil, "", false
+	}
+
+	if err := share.CheckUpload(&r, time.Now(), 0); err != nil {
+		h.Forbidden(c, err.Error())
+		return nil, "", false
+	}
+	password := c.GetHeader(sharePasswordHeader)
+	if password == "" {
+		password = c.Query("password")
+	}
+	if err := share.CheckPassword(r.PasswordHash, password); err != nil {
+		h.Unauthorized(c, err.Error())
+		return nil, "", false
+	}
+
+	// 所有者失去存储桶权限后链接随之失效
+	regionCode := h.regionOf(r.Bucket)
+	if !auth.CheckBucketAccess(h.DB, r.OwnerID, regionCode, r.Bucket) {
+		h.Forbidden(c, "上传链接已失效")
+		return nil, "", false
+	}
+	return &r, regionCode, true
+}
+
+// availableKey 在文件名后加随机后缀，外部上传不覆盖任何在用文件（包括已隔离、损坏的文件）
+// 只在已有文件时才加后缀的话，两个同时上传的同名文件会得到同一个对象键
+func (h *UploadRequestHandler) availableKey(bucket, objectKey string) (string, error) {
+	ext := path.Ext(objectKey)
+	key := strings.TrimSuffix(objectKey, ext) + "-" + uuid.NewString()[:8] + ext
+
+	var count int64
+	if err := h.DB.Model(&models.OSSFile{}).
+		Where("bucket = ? AND object_key = ? AND status IN ?", bucket, key, models.LiveFileStatuses).
+		Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", fmt.Errorf("对象键 %s 已存在", key)
+	}
+	return key, nil
+}
+
+// auditUpload 以链接所有者的名义记录上传审计日志，写入失败只记录日志
+func (h *UploadRequestHandler) auditUpload(c *gin.Context, r *models.UploadRequest, file *models.OSSFile, uploader string) {
+	var owner models.User
+	h.DB.Select("id", "username").First(&owner, r.OwnerID)
+
+	details, _ := json.Marshal(gin.H{
+		"upload_request_id": r.ID,
+		"slug":              r.Slug,
+		"bucket":            file.Bucket,
+		"object_key":        file.ObjectKey,
+		"file_size":         file.FileSize,
+		"uploader":          uploader,
+	})
+	auditLog := models.AuditLog{
+		UserID:       r.OwnerID,
+		Username:     owner.Username,
+		Action:       "UPLOAD",
+		ResourceType: "FILE",
+		ResourceID:   strconv.FormatUint(uint64(file.ID), 10),
+		Details:      string(details),
+		IPAddress:    c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		Status:       "SUCCESS",
+	}
+	if err := h.DB.Create(&auditLog).Error; err != nil {
+		logger.Error("创建审计日志失败", zap.Uint("upload_request_id", r.ID), zap.Uint("file_id", file.ID), zap.Error(err))
+	}
+}
+
+// loadOwned 读取路径参数中当前用户创建的上传请求
+func (h *UploadRequestHandler) loadOwned(c *gin.Context) (*models.UploadRequest, bool) {
+	var r models.UploadRequest
+	err := h.DB.Where("owner_id = ?", c.GetUint("userID")).First(&r, c.Param("id")).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		h.NotFound(c, "上传链接不存在")
+		return nil, false
+	case err != nil:
+		logger.Error("获取上传链接失败", zap.String("upload_request_id", c.Param("id")), zap.Error(err))
+		h.Error(c, utils.CodeServerError, "获取上传链接失败")
+		return nil, false
+	}
+	return &r, true
+}
+
+// regionOf 返回存储桶所属区域，未映射时返回空字符串
+func (h *UploadRequestHandler) regionOf(bucket string) string {
+	var mapping models.RegionBucketMapping
+	h.DB.Where("bucket_name = ?", bucket).First(&mapping)
+	return mapping.RegionCode
+}
+
+// ownerView 返回给所有者的上传请求信息，附带访问地址和是否设置了密码
+func (h *UploadRequestHandler) ownerView(r *models.UploadRequest) gin.H {
+	return gin.H{
+		"upload_request": r,
+		"url":            "/u/" + r.Slug,
+		"has_password":   r.HasPassword(),
+	}
+}
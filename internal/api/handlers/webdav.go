@@ -4,6 +4,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -13,9 +14,12 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/checksum"
 	"github.com/myysophia/ossmanager/internal/db/models"
 	"github.com/myysophia/ossmanager/internal/logger"
 	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/policy"
+	"github.com/myysophia/ossmanager/internal/quota"
 	"github.com/myysophia/ossmanager/internal/security"
 	webdavfs "github.com/myysophia/ossmanager/internal/webdav"
 	"go.uber.org/zap"
@@ -89,6 +93,38 @@ func (h *WebDAVHandler) ServeHTTP(c *gin.Context) {
 	// 创建 WebDAV 文件系统
 	fs := webdavfs.NewOSSFileSystem(storage, h.db, user.UserID, bucket)
 
+	// PUT 请求预先按文件名和声明的长度校验策略与配额，写入完成后文件系统还会按实际内容再校验一次
+	if c.Request.Method == http.MethodPut {
+		// 客户端声明的校验和通过 context 传给文件系统，写入存储端前校验
+		expected, err := checksum.FromHeader(c.Request.Header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if expected != nil {
+			c.Request = c.Request.WithContext(checksum.NewContext(c.Request.Context(), expected))
+		}
+
+		if err := fs.CheckPolicy(filePath, c.Request.ContentLength); err != nil {
+			if errors.Is(err, policy.ErrPolicyViolation) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check upload policy"})
+			}
+			return
+		}
+	}
+	if c.Request.Method == http.MethodPut && c.Request.ContentLength > 0 {
+		if err := fs.CheckQuota(c.Request.ContentLength); err != nil {
+			if errors.Is(err, quota.ErrQuotaExceeded) {
+				c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+			}
+			return
+		}
+	}
+
 	// 创建 WebDAV Handler
 	webdavHandler := &webdav.Handler{
 		Prefix:     "/webdav/" + bucket,
@@ -104,8 +140,9 @@ func (h *WebDAVHandler) ServeHTTP(c *gin.Context) {
 	originalPath := c.Request.URL.Path
 	c.Request.URL.Path = filePath
 
-	// 处理 WebDAV 请求
-	webdavHandler.ServeHTTP(c.Writer, c.Request)
+	// 处理 WebDAV 请求，读写数据按用户和 WebDAV Token 的带宽限速
+	throttleRequestBody(c)
+	webdavHandler.ServeHTTP(throttledDownload(c), c.Request)
 
 	// 恢复原始路径
 	c.Request.URL.Path = originalPath
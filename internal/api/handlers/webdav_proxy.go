@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,9 +15,14 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/myysophia/ossmanager/internal/auth"
+	"github.com/myysophia/ossmanager/internal/checksum"
+	"github.com/myysophia/ossmanager/internal/db/models"
 	"github.com/myysophia/ossmanager/internal/logger"
 	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/policy"
+	"github.com/myysophia/ossmanager/internal/quota"
 	"github.com/myysophia/ossmanager/internal/security"
+	"github.com/myysophia/ossmanager/internal/utils"
 	webdavfs "github.com/myysophia/ossmanager/internal/webdav"
 	"go.uber.org/zap"
 )
@@ -273,11 +279,30 @@ func (h *WebDAVProxyHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
+	// Reject uploads whose name or declared size violates the bucket policy or quota before reading the body
+	if err := fs.CheckPolicy(filePath, c.Request.ContentLength); err != nil {
+		h.uploadError(c, err)
+		return
+	}
+	if c.Request.ContentLength > 0 {
+		if err := fs.CheckQuota(c.Request.ContentLength); err != nil {
+			h.uploadError(c, err)
+			return
+		}
+	}
+
+	// Declared checksums travel with the context and are verified before the object is written
+	expected, err := checksum.FromHeader(c.Request.Header)
+	if err != nil {
+		h.BadRequest(c, err.Error())
+		return
+	}
+
 	// Clean file path
 	cleanPath := strings.TrimPrefix(filePath, "/")
 
 	// Create file for writing
-	ctx := context.Background()
+	ctx := checksum.NewContext(context.Background(), expected)
 	file, err := fs.OpenFile(ctx, cleanPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		logger.Error("Failed to create file", zap.String("path", cleanPath), zap.Error(err))
@@ -287,13 +312,20 @@ func (h *WebDAVProxyHandler) UploadFile(c *gin.Context) {
 	defer file.Close()
 
 	// Copy request body to file
-	_, err = io.Copy(file, c.Request.Body)
+	_, err = io.Copy(file, throttledUpload(c, c.Request.Body))
 	if err != nil {
 		logger.Error("Failed to write file", zap.String("path", cleanPath), zap.Error(err))
 		h.InternalError(c, "failed to write file")
 		return
 	}
 
+	// The object is uploaded on Close, so its error (e.g. quota exceeded) must be reported
+	if err := file.Close(); err != nil {
+		logger.Error("Failed to upload file", zap.String("path", cleanPath), zap.Error(err))
+		h.uploadError(c, err)
+		return
+	}
+
 	response := OperationResponse{
 		Success: true,
 		Message: "file uploaded successfully",
@@ -530,6 +562,61 @@ func (h *WebDAVProxyHandler) CreateDirectory(c *gin.Context) {
 	h.Success(c, response)
 }
 
+// DownloadArchive handles GET /{bucket}/archive?path=/dir → download a directory as a zip stream
+func (h *WebDAVProxyHandler) DownloadArchive(c *gin.Context) {
+	bucket := c.Param("bucket")
+	dirPath := c.Query("path")
+	if dirPath == "" {
+		dirPath = "/"
+	}
+
+	if bucket == "" || strings.Contains(bucket, "..") || strings.ContainsAny(bucket, "/\\<>:\"|?*") {
+		h.BadRequest(c, "invalid bucket name")
+		return
+	}
+	if _, valid := security.ValidateWebDAVPath(bucket, dirPath); !valid {
+		h.BadRequest(c, "invalid directory path")
+		return
+	}
+
+	user, exists := h.getCurrentUser(c)
+	if !exists {
+		h.Unauthorized(c, "authentication required")
+		return
+	}
+	if !h.checkBucketAccess(user.UserID, bucket) {
+		h.Forbidden(c, "access denied to bucket")
+		return
+	}
+	if !h.checkResourceAccess(user.UserID, bucket, dirPath, "GET") {
+		h.Forbidden(c, "access denied to resource")
+		return
+	}
+
+	// The region is only needed to pick the storage endpoint; buckets without a mapping use the default one
+	var mapping models.RegionBucketMapping
+	regionCode := ""
+	if err := h.db.Where("bucket_name = ?", bucket).First(&mapping).Error; err == nil {
+		regionCode = mapping.RegionCode
+	}
+
+	files, err := archiveFilesUnder(h.db, bucket, regionCode, dirPath)
+	if err != nil {
+		archiveSelectionError(c, h.BaseHandler, err)
+		return
+	}
+	if len(files) == 0 {
+		h.NotFound(c, "directory is empty or not found")
+		return
+	}
+
+	name := path.Base(strings.TrimSuffix(dirPath, "/"))
+	if name == "/" || name == "." {
+		name = bucket
+	}
+	streamZip(c, h.storageFactory, h.db, name, files)
+}
+
 // Helper methods
 
 // getCurrentUser extracts user information from context
@@ -543,6 +630,20 @@ func (h *WebDAVProxyHandler) getCurrentUser(c *gin.Context) (*auth.Claims, bool)
 	return user, ok
 }
 
+// uploadError writes a quota, policy or checksum violation response, or an internal error for any other failure
+func (h *WebDAVProxyHandler) uploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, checksum.ErrMismatch):
+		h.Error(c, utils.CodeChecksumMismatch, err.Error())
+	case errors.Is(err, quota.ErrQuotaExceeded):
+		h.Error(c, utils.CodeQuotaExceeded, err.Error())
+	case errors.Is(err, policy.ErrPolicyViolation):
+		h.Error(c, utils.CodePolicyViolation, err.Error())
+	default:
+		h.InternalError(c, "failed to upload file")
+	}
+}
+
 // getWebDAVFileSystem creates a WebDAV filesystem instance
 func (h *WebDAVProxyHandler) getWebDAVFileSystem(userID uint, bucket string) (*webdavfs.OSSFileSystem, error) {
 	storage, err := h.storageFactory.GetDefaultStorageService()
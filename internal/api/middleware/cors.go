@@ -3,6 +3,7 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strings"
 )
 
 // CorsMiddleware 跨域中间件
@@ -11,15 +12,17 @@ func CorsMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		// 支持WebDAV和REST API所需的所有头部
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Depth, If, If-None-Match, Lock-Token, Overwrite, Timeout, Destination, X-User-ID, X-Bucket, X-Requested-With, Accept, Origin")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Depth, If, If-None-Match, Lock-Token, Overwrite, Timeout, Destination, X-User-ID, X-Bucket, X-Requested-With, Accept, Origin, Tus-Resumable, Upload-Length, Upload-Metadata, Upload-Offset, Upload-Checksum, Upload-Defer-Length, Content-MD5, X-Checksum-Sha256")
 		// 支持WebDAV和REST API所需的所有方法
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, MKCOL, COPY, MOVE, PROPFIND, PROPPATCH, LOCK, UNLOCK")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS, MKCOL, COPY, MOVE, PROPFIND, PROPPATCH, LOCK, UNLOCK")
 		// 暴露浏览器可以访问的响应头部
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, Last-Modified, ETag, DAV, X-Total-Count")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, Content-Disposition, Last-Modified, ETag, DAV, X-Total-Count, Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Tus-Checksum-Algorithm, Upload-Offset, Upload-Length, Upload-Metadata, Upload-Expires, X-Upload-Deduplicated")
 		// 预检请求缓存24小时
 		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
 
-		if c.Request.Method == "OPTIONS" {
+		// tus 协议的 OPTIONS 请求用于能力发现，非预检请求交由处理器响应
+		if c.Request.Method == "OPTIONS" &&
+			(c.GetHeader("Access-Control-Request-Method") != "" || !strings.HasPrefix(c.Request.URL.Path, "/v1/uploads/tus")) {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -5,8 +5,14 @@ import (
 	"github.com/myysophia/ossmanager/internal/api/handlers"
 	"github.com/myysophia/ossmanager/internal/api/middleware"
 	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/events"
 	"github.com/myysophia/ossmanager/internal/function"
+	"github.com/myysophia/ossmanager/internal/janitor"
 	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/reconcile"
+	"github.com/myysophia/ossmanager/internal/scrub"
+	"github.com/myysophia/ossmanager/internal/tasks"
+	"github.com/myysophia/ossmanager/internal/trash"
 	"gorm.io/gorm"
 )
 
@@ -28,17 +34,91 @@ func SetupRouter(storageFactory oss.StorageFactory, md5Calculator *function.MD5C
 	ossFileHandler := handlers.NewOSSFileHandler(storageFactory, db)
 	ossConfigHandler := handlers.NewOSSConfigHandler(storageFactory)
 	md5Handler := handlers.NewMD5Handler(md5Calculator)
+	hashJobHandler := handlers.NewHashJobHandler(db, md5Calculator)
 	auditLogHandler := handlers.NewAuditLogHandler()           // 审计日志处理器
 	userHandler := handlers.NewUserHandler()                   // 用户管理处理器
 	roleHandler := handlers.NewRoleHandler(db)                 // 角色管理处理器
 	permissionHandler := handlers.NewPermissionHandler(db)     // 权限管理处理器
 	regionBucketHandler := handlers.NewRegionBucketHandler(db) // 区域存储桶处理器
 	uploadProgressHandler := handlers.NewUploadProgressHandler()
+	tusHandler := handlers.NewTusHandler(storageFactory, db) // tus 断点续传处理器
+	var janitorCfg config.JanitorConfig
+	if cfg != nil {
+		janitorCfg = cfg.Janitor
+	}
+	multipartJanitorHandler := handlers.NewMultipartJanitorHandler(janitor.NewMultipartJanitor(storageFactory, db, janitorCfg)) // 未完成分片上传管理
+	quotaHandler := handlers.NewQuotaHandler(db) // 存储配额处理器
+	scanHandler := handlers.NewScanHandler(db)   // 病毒扫描处理器
+	bandwidthLimitHandler := handlers.NewBandwidthLimitHandler(db) // 带宽限速处理器
+	scrubber := scrub.Default()
+	if scrubber == nil {
+		var scrubCfg config.ScrubConfig
+		if cfg != nil {
+			scrubCfg = cfg.Scrub
+		}
+		scrubber = scrub.NewScrubber(storageFactory, db, scrubCfg)
+	}
+	integrityHandler := handlers.NewIntegrityHandler(db, scrubber) // 完整性巡检处理器
+	taskManager := tasks.Default()
+	if taskManager == nil {
+		var tasksCfg config.TasksConfig
+		if cfg != nil {
+			tasksCfg = cfg.Tasks
+		}
+		taskManager = tasks.NewManager(db, tasksCfg)
+	}
+	ossFileHandler.RegisterTasks(taskManager)
+	md5Handler.RegisterTasks(taskManager)
+	taskHandler := handlers.NewTaskHandler(db, taskManager) // 后台任务处理器
+	trashBin := trash.Default()
+	if trashBin == nil {
+		var trashCfg config.TrashConfig
+		if cfg != nil {
+			trashCfg = cfg.Trash
+		}
+		trashBin = trash.NewBin(storageFactory, db, trashCfg)
+	}
+	ossFileHandler.UseTrash(trashBin)
+	trashHandler := handlers.NewTrashHandler(db, trashBin) // 回收站处理器
+	trashHandler.RegisterTasks(taskManager)
+	reconcileHandler := handlers.NewReconcileHandler(db, reconcile.NewReconciler(storageFactory, db, trashBin)) // 对账处理器
+	reconcileHandler.RegisterTasks(taskManager)
+	ingestor := events.Default()
+	if ingestor == nil {
+		var eventsCfg config.EventsConfig
+		var awsUploadDir string
+		if cfg != nil {
+			eventsCfg = cfg.Events
+			awsUploadDir = cfg.OSS.AWSS3.UploadDir
+		}
+		ingestor = events.NewIngestor(db, eventsCfg, trashBin, awsUploadDir)
+	}
+	objectEventHandler := handlers.NewObjectEventHandler(db, ingestor) // 存储桶事件处理器
+	shareHandler := handlers.NewShareHandler(storageFactory, db) // 分享链接处理器
+	uploadRequestHandler := handlers.NewUploadRequestHandler(ossFileHandler) // 上传请求链接处理器
 	// WebDAV 处理器
 	// webdavHandler := handlers.NewWebDAVHandler(storageFactory, db) // 在需要时启用
 	webdavTokenHandler := handlers.NewWebDAVTokenHandler(db) // WebDAV Token 处理器
 	webdavProxyHandler := handlers.NewWebDAVProxyHandler(storageFactory, db) // WebDAV Proxy 处理器
 
+	// 分享链接匿名访问，密码通过 X-Share-Password 请求头或 password 查询参数提交
+	shares := router.Group("/s/:slug")
+	shares.Use(middleware.RateLimitMiddleware(nil))
+	{
+		shares.GET("", shareHandler.Info)
+		shares.GET("/files", shareHandler.Files)
+		shares.GET("/download", shareHandler.Download)
+		shares.GET("/preview", shareHandler.Preview)
+	}
+
+	// 上传请求链接匿名上传，密码的提交方式与分享链接相同
+	uploadRequests := router.Group("/u/:slug")
+	uploadRequests.Use(middleware.RateLimitMiddleware(nil))
+	{
+		uploadRequests.GET("", uploadRequestHandler.Info)
+		uploadRequests.POST("", uploadRequestHandler.Upload)
+	}
+
 	// 公开路由
 	public := router.Group("/v1")
 	{
@@ -58,6 +138,21 @@ func SetupRouter(storageFactory oss.StorageFactory, md5Calculator *function.MD5C
 			uploads.GET("/:id/progress", uploadProgressHandler.GetProgress)
 			uploads.GET("/:id/stream", uploadProgressHandler.StreamProgress)
 		}
+
+		// 存储桶事件通知（webhook），以事件令牌认证
+		public.POST("/events/oss", objectEventHandler.Receive)
+	}
+
+	// tus 1.0 断点续传协议（OPTIONS 用于能力发现，不需要认证）
+	router.OPTIONS("/v1/uploads/tus", tusHandler.Options)
+	router.OPTIONS("/v1/uploads/tus/:id", tusHandler.Options)
+	tusUploads := router.Group("/v1/uploads/tus")
+	tusUploads.Use(middleware.AuthMiddleware())
+	{
+		tusUploads.POST("", tusHandler.Create)
+		tusUploads.HEAD("/:id", tusHandler.Head)
+		tusUploads.PATCH("/:id", tusHandler.Patch)
+		tusUploads.DELETE("/:id", tusHandler.Terminate)
 	}
 
 	// 需要认证的路由
@@ -116,9 +211,46 @@ func SetupRouter(storageFactory oss.StorageFactory, md5Calculator *function.MD5C
 			// 上传操作使用更严格的速率限制
 			ossFiles.POST("", middleware.UploadRateLimitMiddleware(), ossFileHandler.Upload)
 			ossFiles.GET("", ossFileHandler.List)
+			ossFiles.GET("/search", ossFileHandler.Search)
 			ossFiles.DELETE("/:id", ossFileHandler.Delete)
 			ossFiles.GET("/:id/download", ossFileHandler.GetDownloadURL)
+			ossFiles.GET("/:id/tags", ossFileHandler.GetTags)
+			ossFiles.PUT("/:id/tags", ossFileHandler.SetTags)
 			ossFiles.GET("/check-duplicate", ossFileHandler.CheckDuplicateFile)
+			ossFiles.POST("/import-url", middleware.UploadRateLimitMiddleware(), ossFileHandler.ImportURL)
+			ossFiles.POST("/:id/extract", ossFileHandler.Extract)
+			ossFiles.POST("/archive", ossFileHandler.DownloadArchive)
+			ossFiles.POST("/delete-prefix", ossFileHandler.DeletePrefix)
+		}
+
+		// 分享链接管理：用户只能管理自己创建的分享
+		shareRoutes := authorized.Group("/shares")
+		{
+			shareRoutes.POST("", shareHandler.Create)
+			shareRoutes.GET("", shareHandler.List)
+			shareRoutes.GET("/:id", shareHandler.Get)
+			shareRoutes.PUT("/:id", shareHandler.Update)
+			shareRoutes.DELETE("/:id", shareHandler.Delete)
+			shareRoutes.GET("/:id/logs", shareHandler.Logs)
+		}
+
+		// 上传请求链接管理
+		uploadRequestRoutes := authorized.Group("/upload-requests")
+		{
+			uploadRequestRoutes.POST("", uploadRequestHandler.Create)
+			uploadRequestRoutes.GET("", uploadRequestHandler.List)
+			uploadRequestRoutes.GET("/:id", uploadRequestHandler.Get)
+			uploadRequestRoutes.PUT("/:id", uploadRequestHandler.Update)
+			uploadRequestRoutes.DELETE("/:id", uploadRequestHandler.Delete)
+		}
+
+		// 回收站：删除的文件保留到期后自动彻底删除
+		trashRoutes := authorized.Group("/oss/trash")
+		{
+			trashRoutes.GET("", trashHandler.List)
+			trashRoutes.POST("/:id/restore", trashHandler.Restore)
+			trashRoutes.DELETE("/:id", trashHandler.Purge)
+			trashRoutes.POST("/empty", trashHandler.Empty)
 		}
 
 		// 分片上传（应用上传速率限制）
@@ -131,9 +263,96 @@ func SetupRouter(storageFactory oss.StorageFactory, md5Calculator *function.MD5C
 			multipart.GET("/parts", ossFileHandler.ListUploadedParts)
 		}
 
-		// MD5计算相关
+		// 未完成分片上传管理（仅管理员可访问）
+		multipartUploads := authorized.Group("/oss/multipart-uploads")
+		multipartUploads.Use(middleware.AdminMiddleware())
+		{
+			multipartUploads.GET("", multipartJanitorHandler.List)
+			multipartUploads.POST("/abort", multipartJanitorHandler.Abort)
+			multipartUploads.POST("/purge", multipartJanitorHandler.Purge)
+		}
+
+		// 存储配额：用量查询对所有用户开放，配额管理仅管理员可访问
+		authorized.GET("/quotas/usage", quotaHandler.MyUsage)
+		quotas := authorized.Group("/quotas")
+		quotas.Use(middleware.AdminMiddleware())
+		{
+			quotas.GET("", quotaHandler.List)
+			quotas.POST("", quotaHandler.Create)
+			quotas.PUT("/:id", quotaHandler.Update)
+			quotas.DELETE("/:id", quotaHandler.Delete)
+			quotas.GET("/users/:id/usage", quotaHandler.UserUsage)
+		}
+
+		// 带宽限速：查询自己的限速对所有用户开放，限速管理仅管理员可访问
+		authorized.GET("/bandwidth-limits/mine", bandwidthLimitHandler.Mine)
+		bandwidthLimits := authorized.Group("/bandwidth-limits")
+		bandwidthLimits.Use(middleware.AdminMiddleware())
+		{
+			bandwidthLimits.GET("", bandwidthLimitHandler.List)
+			bandwidthLimits.POST("", bandwidthLimitHandler.Create)
+			bandwidthLimits.PUT("/:id", bandwidthLimitHandler.Update)
+			bandwidthLimits.DELETE("/:id", bandwidthLimitHandler.Delete)
+		}
+
+		// 病毒扫描：重新扫描仅管理员可访问
+		authorized.POST("/oss/files/:id/scan", middleware.AdminMiddleware(), scanHandler.Rescan)
+
+		// MD5及其他摘要计算相关
 		authorized.POST("/oss/files/:id/md5", md5Handler.TriggerCalculation)
 		authorized.GET("/oss/files/:id/md5", md5Handler.GetMD5)
+		authorized.POST("/oss/files/:id/hashes", md5Handler.TriggerCalculation)
+		authorized.GET("/oss/files/:id/hashes", md5Handler.GetHashes)
+		authorized.GET("/oss/files/by-hash", md5Handler.SearchByHash)
+		authorized.POST("/oss/files/hashes/bulk", md5Handler.BulkCalculate)
+
+		// 后台任务：用户只能查看和取消自己提交的任务
+		taskRoutes := authorized.Group("/tasks")
+		{
+			taskRoutes.GET("", taskHandler.List)
+			taskRoutes.GET("/:id", taskHandler.Get)
+			taskRoutes.POST("/:id/cancel", taskHandler.Cancel)
+			taskRoutes.GET("/:id/stream",
+				middleware.SSEMiddleware(),
+				middleware.HTTP1OnlyMiddleware(),
+				middleware.NoBufferMiddleware(),
+				taskHandler.Stream)
+		}
+
+		// 存储完整性巡检（仅管理员可访问）
+		integrity := authorized.Group("/integrity")
+		integrity.Use(middleware.AdminMiddleware())
+		{
+			integrity.GET("/report", integrityHandler.Report)
+			integrity.GET("/issues", integrityHandler.Issues)
+			integrity.POST("/scrub", integrityHandler.Run)
+			integrity.POST("/files/:id/verify", integrityHandler.VerifyFile)
+		}
+
+		// 对账：存储桶中的对象与文件记录比较，处理差异（仅管理员）
+		reconcileRoutes := authorized.Group("/reconcile")
+		reconcileRoutes.Use(middleware.AdminMiddleware())
+		{
+			reconcileRoutes.POST("", reconcileHandler.Run)
+			reconcileRoutes.GET("/diffs", reconcileHandler.Diffs)
+			reconcileRoutes.POST("/diffs/resolve", reconcileHandler.ResolveAll)
+			reconcileRoutes.POST("/diffs/:id/resolve", reconcileHandler.Resolve)
+		}
+
+		// 存储桶事件处理记录（仅管理员）
+		objectEvents := authorized.Group("/events")
+		objectEvents.Use(middleware.AdminMiddleware())
+		{
+			objectEvents.GET("", objectEventHandler.List)
+		}
+
+		// 摘要计算任务管理（仅管理员可访问）
+		hashJobs := authorized.Group("/hash-jobs")
+		hashJobs.Use(middleware.AdminMiddleware())
+		{
+			hashJobs.GET("", hashJobHandler.List)
+			hashJobs.POST("/:id/retry", hashJobHandler.Retry)
+		}
 
 		// OSS配置管理（仅管理员可访问）
 		configs := authorized.Group("/oss/configs")
@@ -165,6 +384,11 @@ func SetupRouter(storageFactory oss.StorageFactory, md5Calculator *function.MD5C
 			regionBuckets.GET("/regions", regionBucketHandler.GetRegionList)
 			regionBuckets.GET("/buckets", regionBucketHandler.GetBucketList)
 			regionBuckets.GET("/user-accessible", regionBucketHandler.GetUserAccessibleBuckets)
+
+			// 上传内容策略（仅管理员可修改）
+			regionBuckets.GET("/:id/policy", middleware.AdminMiddleware(), regionBucketHandler.GetPolicy)
+			regionBuckets.PUT("/:id/policy", middleware.AdminMiddleware(), regionBucketHandler.UpdatePolicy)
+			regionBuckets.DELETE("/:id/policy", middleware.AdminMiddleware(), regionBucketHandler.DeletePolicy)
 		}
 
 		// 角色存储桶访问权限管理
@@ -200,6 +424,9 @@ func SetupRouter(storageFactory oss.StorageFactory, md5Calculator *function.MD5C
 			// GET /{bucket}?path=/dir → list directory (maps to PROPFIND)
 			// 目录列表现在支持分页：?offset=0&limit=100
 			webdavProxyAPI.GET("/:bucket", webdavProxyHandler.ListDirectory)
+
+			// GET /{bucket}/archive?path=/dir → download folder as zip
+			webdavProxyAPI.GET("/:bucket/archive", webdavProxyHandler.DownloadArchive)
 			
 			// POST /{bucket}/file → upload file (maps to PUT)
 			webdavProxyAPI.POST("/:bucket/file", webdavProxyHandler.UploadFile)
@@ -0,0 +1,284 @@
+// Package archive 解压 zip、tar、tar.gz 归档，对条目路径做穿越检查并限制解压大小以防止压缩炸弹
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/myysophia/ossmanager/internal/security"
+)
+
+// Format 归档格式
+type Format string
+
+const (
+	FormatZip   Format = "zip"
+	FormatTar   Format = "tar"
+	FormatTarGz Format = "tar.gz"
+)
+
+var (
+	// ErrUnsupportedFormat 无法识别的归档格式
+	ErrUnsupportedFormat = errors.New("不支持的归档格式，仅支持 zip、tar、tar.gz")
+	// ErrLimitExceeded 解压结果超过限制
+	ErrLimitExceeded = errors.New("归档解压超过限制")
+	// ErrUnsafePath 条目路径不安全
+	ErrUnsafePath = errors.New("归档条目路径不安全")
+)
+
+// ratioFloor 解压后小于该大小的条目不检查压缩比，避免误伤高度重复的小文件
+const ratioFloor = 1024 * 1024
+
+// Limits 解压限制，字段为 0 时使用 DefaultLimits 中的值
+type Limits struct {
+	MaxEntries   int   // 最多解压的文件数
+	MaxTotalSize int64 // 解压后的总大小上限（字节）
+	MaxEntrySize int64 // 单个文件解压后的大小上限（字节）
+	MaxRatio     int64 // 解压后与压缩后大小的最大比值
+}
+
+// DefaultLimits 默认解压限制
+var DefaultLimits = Limits{
+	MaxEntries:   10000,
+	MaxTotalSize: 10 * 1024 * 1024 * 1024,
+	MaxEntrySize: 5 * 1024 * 1024 * 1024,
+	MaxRatio:     200,
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxEntries <= 0 {
+		l.MaxEntries = DefaultLimits.MaxEntries
+	}
+	if l.MaxTotalSize <= 0 {
+		l.MaxTotalSize = DefaultLimits.MaxTotalSize
+	}
+	if l.MaxEntrySize <= 0 {
+		l.MaxEntrySize = DefaultLimits.MaxEntrySize
+	}
+	if l.MaxRatio <= 0 {
+		l.MaxRatio = DefaultLimits.MaxRatio
+	}
+	return l
+}
+
+// Entry 归档中的一个普通文件
+type Entry struct {
+	Path string // 经过清理的相对路径，使用 / 分隔
+	Size int64  // 归档头中声明的大小，仅供参考，实际读取量受 Limits 约束
+}
+
+// EntryFunc 处理一个条目，r 在函数返回后失效
+type EntryFunc func(entry Entry, r io.Reader) error
+
+// Stats 解压统计
+type Stats struct {
+	Entries int   // 已处理的文件数
+	Skipped int   // 跳过的目录、链接等非普通文件数
+	Bytes   int64 // 解压后的总字节数
+}
+
+// DetectFormat 根据文件名判断归档格式
+func DetectFormat(filename string) (Format, error) {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(name, ".tar"):
+		return FormatTar, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// EntryPath 清理条目路径（复用 security.SanitizePath），拒绝清理后仍指向上级目录或为空的路径
+func EntryPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	cleaned := strings.TrimLeft(security.SanitizePath(name), "/")
+	cleaned = path.Clean(cleaned)
+	if cleaned == "." || cleaned == "" || cleaned == ".." || strings.HasPrefix(cleaned, "../") ||
+		strings.ContainsAny(cleaned, "\x00:") {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+	return cleaned, nil
+}
+
+// extractor 在各格式之间共享的限制检查
+type extractor struct {
+	limits Limits
+	stats  Stats
+}
+
+// entry 检查条目数，并返回受单文件、总大小和压缩比限制的 reader
+// compressed 为条目压缩后的大小，未知时传 0
+func (e *extractor) entry(r io.Reader, compressed int64) (*guardReader, error) {
+	if e.stats.Entries >= e.limits.MaxEntries {
+		return nil, fmt.Errorf("%w: 文件数超过 %d", ErrLimitExceeded, e.limits.MaxEntries)
+	}
+	e.stats.Entries++
+
+	limit := e.limits.MaxEntrySize
+	if remaining := e.limits.MaxTotalSize - e.stats.Bytes; remaining < limit {
+		limit = remaining
+	}
+	if compressed > 0 {
+		byRatio := compressed * e.limits.MaxRatio
+		if byRatio < ratioFloor {
+			byRatio = ratioFloor
+		}
+		if byRatio < limit {
+			limit = byRatio
+		}
+	}
+	return &guardReader{r: r, remaining: limit}, nil
+}
+
+// done 累计条目实际解压的字节数
+func (e *extractor) done(g *guardReader) {
+	e.stats.Bytes += g.read
+}
+
+// guardReader 读取超过限制时返回 ErrLimitExceeded
+type guardReader struct {
+	r         io.Reader
+	remaining int64
+	read      int64
+}
+
+func (g *guardReader) Read(p []byte) (int, error) {
+	if g.remaining < 0 {
+		return 0, ErrLimitExceeded
+	}
+	if int64(len(p)) > g.remaining+1 {
+		p = p[:g.remaining+1]
+	}
+	n, err := g.r.Read(p)
+	g.remaining -= int64(n)
+	if g.remaining < 0 {
+		n += int(g.remaining)
+		g.read += int64(n)
+		return n, fmt.Errorf("%w: 单个文件或总大小超过限制", ErrLimitExceeded)
+	}
+	g.read += int64(n)
+	return n, err
+}
+
+// ExtractZip 依次处理 zip 中的普通文件
+func ExtractZip(r io.ReaderAt, size int64, limits Limits, fn EntryFunc) (Stats, error) {
+	e := &extractor{limits: limits.withDefaults()}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return e.stats, fmt.Errorf("读取 zip 文件失败: %w", err)
+	}
+	if len(zr.File) > e.limits.MaxEntries*2 {
+		return e.stats, fmt.Errorf("%w: 条目数 %d 过多", ErrLimitExceeded, len(zr.File))
+	}
+
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			e.stats.Skipped++
+			continue
+		}
+		name, err := EntryPath(f.Name)
+		if err != nil {
+			return e.stats, err
+		}
+		if err := e.zipEntry(f, name, fn); err != nil {
+			return e.stats, err
+		}
+	}
+	return e.stats, nil
+}
+
+func (e *extractor) zipEntry(f *zip.File, name string, fn EntryFunc) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("打开条目 %s 失败: %w", name, err)
+	}
+	defer rc.Close()
+
+	g, err := e.entry(rc, int64(f.CompressedSize64))
+	if err != nil {
+		return err
+	}
+	err = fn(Entry{Path: name, Size: int64(f.UncompressedSize64)}, g)
+	e.done(g)
+	return err
+}
+
+// ExtractTar 依次处理 tar 或 tar.gz 流中的普通文件
+func ExtractTar(r io.Reader, gzipped bool, limits Limits, fn EntryFunc) (Stats, error) {
+	e := &extractor{limits: limits.withDefaults()}
+	raw := &countReader{r: r}
+	var src io.Reader = raw
+	if gzipped {
+		gz, err := gzip.NewReader(raw)
+		if err != nil {
+			return e.stats, fmt.Errorf("读取 gzip 文件失败: %w", err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return e.stats, nil
+		}
+		if err != nil {
+			return e.stats, fmt.Errorf("读取 tar 文件失败: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			e.stats.Skipped++
+			continue
+		}
+		name, err := EntryPath(hdr.Name)
+		if err != nil {
+			return e.stats, err
+		}
+
+		g, err := e.entry(tr, 0)
+		if err != nil {
+			return e.stats, err
+		}
+		err = fn(Entry{Path: name, Size: hdr.Size}, g)
+		e.done(g)
+		if err != nil {
+			return e.stats, err
+		}
+
+		// 流式格式无法预知条目的压缩大小，按整体压缩比检查
+		if gzipped && e.stats.Bytes > ratioFloor && e.stats.Bytes > raw.n*e.limits.MaxRatio {
+			return e.stats, fmt.Errorf("%w: 压缩比超过 %d", ErrLimitExceeded, e.limits.MaxRatio)
+		}
+	}
+}
+
+// Extract 按格式解压，zip 需要随机访问，tar 可以流式读取
+func Extract(format Format, r io.ReaderAt, size int64, limits Limits, fn EntryFunc) (Stats, error) {
+	switch format {
+	case FormatZip:
+		return ExtractZip(r, size, limits, fn)
+	case FormatTar, FormatTarGz:
+		return ExtractTar(io.NewSectionReader(r, 0, size), format == FormatTarGz, limits, fn)
+	}
+	return Stats{}, ErrUnsupportedFormat
+}
+
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
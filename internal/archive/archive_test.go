@@ -0,0 +1,188 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type file struct {
+	name    string
+	content string
+	symlink bool
+}
+
+func buildZip(t *testing.T, files []file) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, f.content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTarGz(t *testing.T, files []file) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content)), Typeflag: tar.TypeReg}
+		if f.symlink {
+			hdr = &tar.Header{Name: f.name, Linkname: f.content, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if !f.symlink {
+			io.WriteString(tw, f.content)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gw.Close()
+	return buf.Bytes()
+}
+
+func collect(out map[string]string) EntryFunc {
+	return func(entry Entry, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		out[entry.Path] = string(data)
+		return nil
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]Format{
+		"site.zip":      FormatZip,
+		"DATA.TAR.GZ":   FormatTarGz,
+		"data.tgz":      FormatTarGz,
+		"backup.tar":    FormatTar,
+		"notes.txt":     "",
+		"archive.gz":    "",
+		"dir/x.zip.txt": "",
+	}
+	for name, want := range tests {
+		got, err := DetectFormat(name)
+		if got != want || (want == "") != errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("DetectFormat(%q) = %q, %v", name, got, err)
+		}
+	}
+}
+
+func TestEntryPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"index.html", "index.html", false},
+		{"./assets/app.js", "assets/app.js", false},
+		{"/etc/passwd", "etc/passwd", false},
+		{"../../etc/passwd", "etc/passwd", false},
+		{"a/../../b", "b", false},
+		{"a\\..\\..\\b.txt", "b.txt", false},
+		{"..", "", true},
+		{"./", "", true},
+		{"C:/windows/system32", "", true},
+	}
+	for _, tt := range tests {
+		got, err := EntryPath(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("EntryPath(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("EntryPath(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if strings.Contains(got, "..") {
+			t.Errorf("EntryPath(%q) = %q escapes the target", tt.name, got)
+		}
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	data := buildZip(t, []file{
+		{name: "site/index.html", content: "<html>"},
+		{name: "site/", content: ""},
+		{name: "../evil.sh", content: "rm -rf"},
+	})
+	out := map[string]string{}
+	stats, err := Extract(FormatZip, bytes.NewReader(data), int64(len(data)), Limits{}, collect(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["site/index.html"] != "<html>" || out["evil.sh"] != "rm -rf" || len(out) != 2 {
+		t.Errorf("extracted = %v", out)
+	}
+	if stats.Entries != 2 || stats.Skipped != 1 || stats.Bytes != int64(len("<html>rm -rf")) {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestExtractTarGz(t *testing.T) {
+	data := buildTarGz(t, []file{
+		{name: "data/a.csv", content: "1,2,3"},
+		{name: "data/link", content: "/etc/passwd", symlink: true},
+		{name: "data/b.csv", content: "4,5,6"},
+	})
+	out := map[string]string{}
+	stats, err := Extract(FormatTarGz, bytes.NewReader(data), int64(len(data)), Limits{}, collect(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out["data/a.csv"] != "1,2,3" || out["data/b.csv"] != "4,5,6" {
+		t.Errorf("extracted = %v", out)
+	}
+	if stats.Skipped != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	bomb := file{name: "zeros.bin", content: strings.Repeat("\x00", 4*1024*1024)}
+
+	tests := []struct {
+		name   string
+		format Format
+		files  []file
+		limits Limits
+	}{
+		{"zip ratio", FormatZip, []file{bomb}, Limits{MaxRatio: 10}},
+		{"tar.gz ratio", FormatTarGz, []file{bomb, {name: "x", content: "x"}}, Limits{MaxRatio: 10}},
+		{"entry size", FormatZip, []file{{name: "a", content: "0123456789"}}, Limits{MaxEntrySize: 5}},
+		{"total size", FormatTarGz, []file{{name: "a", content: "01234"}, {name: "b", content: "56789"}}, Limits{MaxTotalSize: 8}},
+		{"entries", FormatZip, []file{{name: "a"}, {name: "b"}, {name: "c"}}, Limits{MaxEntries: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data []byte
+			if tt.format == FormatZip {
+				data = buildZip(t, tt.files)
+			} else {
+				data = buildTarGz(t, tt.files)
+			}
+			_, err := Extract(tt.format, bytes.NewReader(data), int64(len(data)), tt.limits, collect(map[string]string{}))
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("Extract() error = %v, want ErrLimitExceeded", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ZipStream 将文件逐个写入 zip 流，内容直接从 reader 复制，不在内存中缓存整个文件
+// 文件超过 4GB 或条目数超过 65535 时由 archive/zip 自动写入 zip64 扩展
+type ZipStream struct {
+	zw    *zip.Writer
+	names map[string]bool
+}
+
+// NewZipStream 创建写入 w 的 zip 流
+func NewZipStream(w io.Writer) *ZipStream {
+	return &ZipStream{zw: zip.NewWriter(w), names: make(map[string]bool)}
+}
+
+// Add 写入一个文件，同名文件自动重命名为 name (1).ext 的形式
+func (z *ZipStream) Add(name string, modified time.Time, r io.Reader) error {
+	name = z.uniqueName(strings.TrimLeft(name, "/"))
+	w, err := z.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Close 写入中央目录，未调用时生成的 zip 不完整
+func (z *ZipStream) Close() error {
+	return z.zw.Close()
+}
+
+func (z *ZipStream) uniqueName(name string) string {
+	if !z.names[name] {
+		z.names[name] = true
+		return name
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !z.names[candidate] {
+			z.names[candidate] = true
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZipStream(t *testing.T) {
+	var buf bytes.Buffer
+	zs := NewZipStream(&buf)
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, f := range []struct{ name, content string }{
+		{"/docs/readme.md", "# readme"},
+		{"docs/readme.md", "duplicate"},
+		{"docs/readme.md", "again"},
+		{"bin/app", strings.Repeat("x", 100000)},
+	} {
+		if err := zs.Add(f.name, modified, strings.NewReader(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got[f.Name] = string(data)
+		if !f.Modified.Equal(modified) {
+			t.Errorf("%s modified = %v", f.Name, f.Modified)
+		}
+	}
+	want := map[string]string{
+		"docs/readme.md":     "# readme",
+		"docs/readme (1).md": "duplicate",
+		"docs/readme (2).md": "again",
+		"bin/app":            strings.Repeat("x", 100000),
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s = %.20q, want %.20q", name, got[name], content)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("entries = %d, want %d", len(got), len(want))
+	}
+}
+
+func TestZipStreamManyEntries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes more than 65535 entries")
+	}
+	// 条目数超过 16 位上限时需要 zip64 结束记录
+	const n = 70000
+	var buf bytes.Buffer
+	zs := NewZipStream(&buf)
+	for i := 0; i < n; i++ {
+		if err := zs.Add(fmt.Sprintf("f%d", i), time.Time{}, strings.NewReader("")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != n {
+		t.Errorf("entries = %d, want %d", len(zr.File), n)
+	}
+}
@@ -0,0 +1,173 @@
+// Package checksum 校验客户端通过 Content-MD5 / X-Checksum-Sha256 头声明的上传内容校验和
+package checksum
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/myysophia/ossmanager/internal/hashing"
+)
+
+const (
+	// HeaderContentMD5 RFC 1864 定义的内容 MD5，base64 编码
+	HeaderContentMD5 = "Content-MD5"
+	// HeaderSHA256 内容的 SHA-256，支持十六进制或 base64 编码
+	HeaderSHA256 = "X-Checksum-Sha256"
+)
+
+// ErrMismatch 实际内容与声明的校验和不一致
+var ErrMismatch = errors.New("checksum mismatch")
+
+// MismatchError 校验和不一致的详细信息
+type MismatchError struct {
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s 校验失败：期望 %s，实际 %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// Is 使 errors.Is(err, ErrMismatch) 成立
+func (e *MismatchError) Is(target error) bool {
+	return target == ErrMismatch
+}
+
+// Expected 客户端声明的校验和
+type Expected struct {
+	MD5    []byte
+	SHA256 []byte
+}
+
+// FromHeader 解析请求头中的校验和，两个头都未提供时返回 nil
+func FromHeader(h http.Header) (*Expected, error) {
+	return Parse(h.Get(HeaderContentMD5), h.Get(HeaderSHA256))
+}
+
+// Parse 解析十六进制或 base64 编码的 MD5 和 SHA-256，两者都为空时返回 nil
+func Parse(md5Value, sha256Value string) (*Expected, error) {
+	md5Value = strings.TrimSpace(md5Value)
+	sha256Value = strings.TrimSpace(sha256Value)
+	if md5Value == "" && sha256Value == "" {
+		return nil, nil
+	}
+
+	expected := &Expected{}
+	var err error
+	if md5Value != "" {
+		if expected.MD5, err = decode(md5Value, md5.Size); err != nil {
+			return nil, fmt.Errorf("无效的 %s 头: %w", HeaderContentMD5, err)
+		}
+	}
+	if sha256Value != "" {
+		if expected.SHA256, err = decode(sha256Value, sha256.Size); err != nil {
+			return nil, fmt.Errorf("无效的 %s 头: %w", HeaderSHA256, err)
+		}
+	}
+	return expected, nil
+}
+
+// decode 解析十六进制或 base64 编码的摘要，并校验长度
+func decode(value string, size int) ([]byte, error) {
+	if len(value) == hex.EncodedLen(size) {
+		if digest, err := hex.DecodeString(value); err == nil {
+			return digest, nil
+		}
+	}
+	digest, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("摘要必须是十六进制或 base64 编码")
+	}
+	if len(digest) != size {
+		return nil, fmt.Errorf("摘要长度应为 %d 字节", size)
+	}
+	return digest, nil
+}
+
+// Verifier 在数据流经时计算配置的摘要，声明了 SHA-256 时同时计算 SHA-256
+type Verifier struct {
+	expected *Expected
+	hashes   *hashing.Set
+}
+
+// NewVerifier 创建校验器，expected 为 nil 时只计算摘要，Verify 总是通过
+func NewVerifier(expected *Expected) *Verifier {
+	algs := hashing.Configured()
+	if expected != nil && expected.SHA256 != nil && !slices.Contains(algs, hashing.SHA256) {
+		algs = append(algs, hashing.SHA256)
+	}
+	return &Verifier{expected: expected, hashes: hashing.NewSet(algs...)}
+}
+
+// Expected 返回声明的校验和，未声明时为 nil
+func (v *Verifier) Expected() *Expected {
+	return v.expected
+}
+
+// Write 将数据计入摘要
+func (v *Verifier) Write(p []byte) (int, error) {
+	return v.hashes.Write(p)
+}
+
+// Reader 返回读取 r 的同时计算摘要的 reader
+func (v *Verifier) Reader(r io.Reader) io.Reader {
+	return io.TeeReader(r, v)
+}
+
+// MD5 已写入数据的 MD5（十六进制）
+func (v *Verifier) MD5() string {
+	return v.hashes.Sums()[hashing.MD5]
+}
+
+// Sums 已写入数据的所有摘要，键为算法名，可直接交给 hashing.Save 保存
+func (v *Verifier) Sums() map[string]string {
+	return v.hashes.Sums()
+}
+
+// Verify 比较已写入数据的摘要与声明的校验和
+func (v *Verifier) Verify() error {
+	if v.expected == nil {
+		return nil
+	}
+	sums := v.hashes.Sums()
+	if v.expected.MD5 != nil {
+		if expected := hex.EncodeToString(v.expected.MD5); sums[hashing.MD5] != expected {
+			return &MismatchError{Algorithm: "MD5", Expected: expected, Actual: sums[hashing.MD5]}
+		}
+	}
+	if v.expected.SHA256 != nil {
+		if expected := hex.EncodeToString(v.expected.SHA256); sums[hashing.SHA256] != expected {
+			return &MismatchError{Algorithm: "SHA-256", Expected: expected, Actual: sums[hashing.SHA256]}
+		}
+	}
+	return nil
+}
+
+// PartMD5 计算分片的 Content-MD5 头（base64），由存储端在接收分片时校验
+func PartMD5(data []byte) string {
+	sum := md5.Sum(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+type contextKey struct{}
+
+// NewContext 返回携带声明校验和的 context，供 WebDAV 文件系统在写入时读取
+func NewContext(ctx context.Context, expected *Expected) context.Context {
+	return context.WithValue(ctx, contextKey{}, expected)
+}
+
+// FromContext 获取 context 中声明的校验和
+func FromContext(ctx context.Context) *Expected {
+	expected, _ := ctx.Value(contextKey{}).(*Expected)
+	return expected
+}
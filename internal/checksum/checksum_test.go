@@ -0,0 +1,149 @@
+package checksum
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const content = "hello, checksum"
+
+func md5Base64(s string) string {
+	sum := md5.Sum([]byte(s))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFromHeader(t *testing.T) {
+	md5Sum := md5.Sum([]byte(content))
+
+	tests := []struct {
+		name    string
+		md5     string
+		sha256  string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "none", wantNil: true},
+		{name: "md5 base64", md5: md5Base64(content)},
+		{name: "md5 hex", md5: hex.EncodeToString(md5Sum[:])},
+		{name: "sha256 hex", sha256: sha256Hex(content)},
+		{name: "both", md5: md5Base64(content), sha256: sha256Hex(content)},
+		{name: "md5 wrong length", md5: base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+		{name: "sha256 garbage", sha256: "not a digest!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.md5 != "" {
+				header.Set(HeaderContentMD5, tt.md5)
+			}
+			if tt.sha256 != "" {
+				header.Set(HeaderSHA256, tt.sha256)
+			}
+
+			expected, err := FromHeader(header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (expected == nil) != tt.wantNil {
+				t.Fatalf("FromHeader() = %+v, wantNil %v", expected, tt.wantNil)
+			}
+			if expected == nil {
+				return
+			}
+			if tt.md5 != "" && !strings.EqualFold(hex.EncodeToString(expected.MD5), hex.EncodeToString(md5Sum[:])) {
+				t.Errorf("MD5 = %x, want %x", expected.MD5, md5Sum)
+			}
+		})
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderContentMD5, md5Base64(content))
+	header.Set(HeaderSHA256, sha256Hex(content))
+	expected, err := FromHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewVerifier(expected)
+	if _, err := io.Copy(io.Discard, v.Reader(strings.NewReader(content))); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	sum := md5.Sum([]byte(content))
+	if v.MD5() != hex.EncodeToString(sum[:]) {
+		t.Errorf("MD5() = %s", v.MD5())
+	}
+
+	corrupted := NewVerifier(expected)
+	corrupted.Write([]byte(content + "x"))
+	err = corrupted.Verify()
+	if !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify(corrupted) error = %v, want ErrMismatch", err)
+	}
+
+	sha256Only := NewVerifier(&Expected{SHA256: expected.SHA256})
+	sha256Only.Write([]byte("other"))
+	if err := sha256Only.Verify(); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify(sha256 mismatch) error = %v, want ErrMismatch", err)
+	}
+
+	if err := NewVerifier(nil).Verify(); err != nil {
+		t.Errorf("Verify() without expected checksum error = %v", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Error("FromContext(empty) != nil")
+	}
+	expected := &Expected{MD5: make([]byte, md5.Size)}
+	if FromContext(NewContext(context.Background(), expected)) != expected {
+		t.Error("FromContext() did not return the stored checksum")
+	}
+	if FromContext(NewContext(context.Background(), nil)) != nil {
+		t.Error("FromContext(nil) != nil")
+	}
+}
+
+func TestPartMD5(t *testing.T) {
+	if got := PartMD5([]byte(content)); got != md5Base64(content) {
+		t.Errorf("PartMD5() = %s, want %s", got, md5Base64(content))
+	}
+}
+
+func TestParse(t *testing.T) {
+	md5Sum := md5.Sum([]byte(content))
+	expected, err := Parse(hex.EncodeToString(md5Sum[:]), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NewVerifier(expected).Expected() != expected || string(expected.MD5) != string(md5Sum[:]) || expected.SHA256 != nil {
+		t.Errorf("Parse() = %+v", expected)
+	}
+	if expected, err := Parse(" ", ""); expected != nil || err != nil {
+		t.Errorf("Parse(empty) = %+v, %v", expected, err)
+	}
+	if _, err := Parse("", "abc"); err == nil {
+		t.Error("Parse(invalid sha256) error = nil")
+	}
+}
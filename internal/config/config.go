@@ -16,6 +16,16 @@ type Config struct {
 	Database DatabaseConfig
 	Log      LogConfig
 	OSS      OSSConfig
+	Janitor  JanitorConfig
+	Scan     ScanConfig
+	Import   ImportConfig
+	Archive  ArchiveConfig
+	Throttle ThrottleConfig
+	Hash     HashConfig
+	Scrub    ScrubConfig
+	Tasks    TasksConfig
+	Trash    TrashConfig
+	Events   EventsConfig
 }
 
 type AppConfig struct {
@@ -30,6 +40,120 @@ type AppConfig struct {
 	MaxFileSize      int64  `mapstructure:"max_file_size"`
 	Workers          int    `mapstructure:"workers"`           // MD5计算的工作协程数量
 	ChunkConcurrency int    `mapstructure:"chunk_concurrency"` // 分片上传并发量
+	TusPartSize      int64  `mapstructure:"tus_part_size"`     // tus 上传写入存储的分片大小（字节）
+	TusExpireHours   int    `mapstructure:"tus_expire_hours"`  // tus 未完成上传的保留时间（小时）
+	Progress         ProgressConfig
+}
+
+// ProgressConfig 上传进度存储配置
+type ProgressConfig struct {
+	Store       string `mapstructure:"store"`        // memory（默认，单实例）或 postgres（多实例共享）
+	TTL         int    `mapstructure:"ttl"`          // 进行中任务无更新后的保留时间（秒）
+	FinishedTTL int    `mapstructure:"finished_ttl"` // 已完成/失败任务的保留时间（秒）
+}
+
+// JanitorConfig 未完成分片上传清理配置
+type JanitorConfig struct {
+	Enabled  bool `mapstructure:"enabled"`
+	Interval int  `mapstructure:"interval"` // 扫描间隔（分钟）
+	MaxAge   int  `mapstructure:"max_age"`  // 超过该时长（小时）的未完成分片上传将被中止
+	DryRun   bool `mapstructure:"dry_run"`  // 仅记录日志，不实际中止
+}
+
+// ScanConfig 病毒扫描配置
+type ScanConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	Address          string `mapstructure:"address"`           // clamd 地址，如 tcp://127.0.0.1:3310 或 unix:///var/run/clamav/clamd.ctl
+	Timeout          int    `mapstructure:"timeout"`           // 单次扫描超时（秒）
+	Workers          int    `mapstructure:"workers"`           // 并发扫描数
+	Interval         int    `mapstructure:"interval"`          // 轮询待扫描文件的间隔（秒）
+	Action           string `mapstructure:"action"`            // 发现病毒后的处理：quarantine（隔离）或 delete（删除）
+	QuarantinePrefix string `mapstructure:"quarantine_prefix"` // 隔离时将对象移动到该前缀下，为空则保留原位置
+	BlockUnscanned   bool   `mapstructure:"block_unscanned"`   // 扫描完成前拒绝生成下载链接
+	MaxScanSize      int64  `mapstructure:"max_scan_size"`     // 超过该大小（字节）的文件不扫描，记为 SKIPPED；应不大于 clamd 的 StreamMaxLength，0 表示不限制
+	AllowSkipped     bool   `mapstructure:"allow_skipped"`     // block_unscanned 开启时仍允许下载因超过大小上限未扫描的文件
+}
+
+// ImportConfig 从 URL 导入文件的配置
+type ImportConfig struct {
+	Enabled         bool     `mapstructure:"enabled"`
+	MaxSize         int64    `mapstructure:"max_size"`         // 单个文件大小上限（字节），为 0 时使用 app.max_file_size
+	Timeout         int      `mapstructure:"timeout"`          // 单次导入的总超时（秒）
+	AllowPrivate    bool     `mapstructure:"allow_private"`    // 允许访问内网、回环等私有地址
+	AllowedNetworks []string `mapstructure:"allowed_networks"` // 允许访问的私有网段（CIDR），例如内网构建服务器
+}
+
+// ArchiveConfig 解压归档文件的限制，为 0 时使用内置默认值
+type ArchiveConfig struct {
+	MaxEntries   int   `mapstructure:"max_entries"`    // 最多解压的文件数
+	MaxTotalSize int64 `mapstructure:"max_total_size"` // 解压后的总大小上限（字节）
+	MaxEntrySize int64 `mapstructure:"max_entry_size"` // 单个文件解压后的大小上限（字节）
+	MaxRatio     int64 `mapstructure:"max_ratio"`      // 解压后与压缩后大小的最大比值，用于识别压缩炸弹
+}
+
+// ThrottleConfig 上传/下载带宽限速配置，限速值单位为字节/秒，0 表示不限制
+// 用户、角色和 WebDAV Token 的单独限速保存在数据库中，未配置时使用这里的默认值
+type ThrottleConfig struct {
+	Enabled      bool  `mapstructure:"enabled"`
+	UploadRate   int64 `mapstructure:"upload_rate"`   // 默认每个用户的上传限速
+	DownloadRate int64 `mapstructure:"download_rate"` // 默认每个用户的下载限速
+	CacheTTL     int   `mapstructure:"cache_ttl"`     // 限速规则缓存时间（秒），修改后最多延迟该时间生效
+}
+
+// HashConfig 文件摘要计算配置
+// 计算任务保存在 hash_jobs 表中，多个实例共同领取执行
+type HashConfig struct {
+	Algorithms   []string `mapstructure:"algorithms"`    // 计算的算法：md5、sha1、sha256、crc64ecma，MD5 总是计算；为空时计算全部
+	MaxAttempts  int      `mapstructure:"max_attempts"`  // 任务最多执行次数，超过后标记为 DEAD
+	LeaseTimeout int      `mapstructure:"lease_timeout"` // 领取任务的租约时长（秒），执行期间自动续约，实例失联超过该时长后任务可被其他实例领取
+	PollInterval int      `mapstructure:"poll_interval"` // 空闲时轮询新任务的间隔（秒）
+	RetryBackoff int      `mapstructure:"retry_backoff"` // 首次重试的等待时间（秒），之后每次翻倍，最长 1 小时
+}
+
+// ScrubConfig 存储对象完整性巡检配置
+// 每轮按上次检查时间从旧到新检查一批文件的大小、ETag 和摘要，多轮下来覆盖所有文件
+type ScrubConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	Interval       int     `mapstructure:"interval"`         // 巡检间隔（分钟）
+	BatchSize      int     `mapstructure:"batch_size"`       // 每轮检查的文件数
+	Workers        int     `mapstructure:"workers"`          // 并发检查数
+	ContentSample  float64 `mapstructure:"content_sample"`   // 每轮下载对象重新计算摘要的文件比例（0-1），其余文件只检查元数据
+	MaxContentSize int64   `mapstructure:"max_content_size"` // 超过该大小（字节）的文件不下载校验内容，0 表示不限制
+	AlertWebhook   string  `mapstructure:"alert_webhook"`    // 发现新问题时以 JSON POST 通知的地址，为空则只记录日志
+}
+
+// TasksConfig 后台任务配置
+// 任务保存在 tasks 表中，多个实例共同领取执行
+type TasksConfig struct {
+	Workers       int `mapstructure:"workers"`        // 每个实例同时执行的任务数
+	MaxAttempts   int `mapstructure:"max_attempts"`   // 执行实例失联后任务最多执行的次数，任务自身返回的错误不重试
+	LeaseTimeout  int `mapstructure:"lease_timeout"`  // 领取任务的租约时长（秒），执行期间自动续约
+	PollInterval  int `mapstructure:"poll_interval"`  // 空闲时轮询新任务的间隔（秒）
+	RetentionDays int `mapstructure:"retention_days"` // 已结束任务的保留天数
+}
+
+// TrashConfig 回收站配置
+// 启用后删除文件时将对象移动到所在存储桶的回收站前缀下，超过保留天数后彻底删除
+type TrashConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	Prefix        string `mapstructure:"prefix"`         // 回收站对象键前缀，默认 .trash/
+	RetentionDays int    `mapstructure:"retention_days"` // 文件在回收站中保留的天数
+	Interval      int    `mapstructure:"interval"`       // 清理过期文件的间隔（分钟）
+	BatchSize     int    `mapstructure:"batch_size"`     // 每轮最多彻底删除的文件数
+}
+
+// EventsConfig 存储桶对象事件接入配置
+// 存储服务的对象事件通过 webhook 或消息队列送达，外部写入存储桶的对象近实时地同步到文件记录
+type EventsConfig struct {
+	Enabled         bool              `mapstructure:"enabled"`
+	Token           string            `mapstructure:"token"`            // webhook 鉴权令牌，通过 Authorization: Bearer、X-Event-Token 头或 token 参数传递
+	UploaderID      uint              `mapstructure:"uploader_id"`      // 外部写入的对象记在该用户名下，默认 1（初始管理员）
+	Delay           int               `mapstructure:"delay"`            // 收到事件后延迟处理的时间（秒），等待本系统的上传流程写入记录
+	PollInterval    int               `mapstructure:"poll_interval"`    // 轮询待处理事件的间隔（秒）
+	BatchSize       int               `mapstructure:"batch_size"`       // 每轮最多处理的事件数
+	RetentionDays   int               `mapstructure:"retention_days"`   // 已处理事件的保留天数
+	Consumer        string            `mapstructure:"consumer"`         // 从消息队列拉取事件的消费者名称，为空时只接收 webhook
+	ConsumerOptions map[string]string `mapstructure:"consumer_options"` // 传给消费者的参数，如队列地址
 }
 
 type JWTConfig struct {
@@ -199,6 +323,7 @@ func LoadConfigWithEnv(configPath string, env string) (*Config, error) {
 	v.BindEnv("database.dbname", "DB_NAME")
 	v.BindEnv("database.sslmode", "DB_SSLMODE")
 	v.BindEnv("jwt.secret_key", "JWT_SECRET_KEY")
+	v.BindEnv("events.token", "EVENTS_TOKEN")
 
 	// 读取基本配置
 	if err := v.ReadInConfig(); err != nil {
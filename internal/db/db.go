@@ -69,6 +69,21 @@ func autoMigrate() error {
 		&models.OSSFile{},
 		&models.OSSConfig{},
 		&models.AuditLog{},
+		&models.TusUpload{},
+		&models.UploadProgress{},
+		&models.StorageQuota{},
+		&models.UploadPolicy{},
+		&models.BandwidthLimit{},
+		&models.FileHash{},
+		&models.HashJob{},
+		&models.IntegrityIssue{},
+		&models.Task{},
+		&models.FileTag{},
+		&models.Share{},
+		&models.ShareAccessLog{},
+		&models.UploadRequest{},
+		&models.ReconcileDiff{},
+		&models.ObjectEvent{},
 	)
 }
 
@@ -0,0 +1,22 @@
+package models
+
+// 带宽限速作用范围
+const (
+	BandwidthScopeUser  = "USER"  // 针对单个用户
+	BandwidthScopeRole  = "ROLE"  // 针对角色中的每个用户
+	BandwidthScopeToken = "TOKEN" // 针对单个 WebDAV Token
+)
+
+// BandwidthLimit 带宽限速模型，限速值单位为字节/秒，0 表示不限制
+type BandwidthLimit struct {
+	Model
+	ScopeType    string `gorm:"size:20;not null;index:idx_bandwidth_limits_scope" json:"scope_type"` // USER, ROLE, TOKEN
+	ScopeID      uint   `gorm:"not null;index:idx_bandwidth_limits_scope" json:"scope_id"`           // 用户ID、角色ID或 WebDAV Token ID
+	UploadRate   int64  `gorm:"not null;default:0" json:"upload_rate"`
+	DownloadRate int64  `gorm:"not null;default:0" json:"download_rate"`
+}
+
+// TableName 指定表名
+func (BandwidthLimit) TableName() string {
+	return "bandwidth_limits"
+}
@@ -0,0 +1,15 @@
+package models
+
+// FileHash 文件摘要，每个文件每种算法一条记录
+// MD5 同时保存在 OSSFile.MD5 中以兼容旧接口；CRC64-ECMA 以十进制表示，与阿里云返回的值一致
+type FileHash struct {
+	Model
+	FileID    uint   `gorm:"not null;uniqueIndex:idx_file_hashes_file_algorithm" json:"file_id"`
+	Algorithm string `gorm:"size:20;not null;uniqueIndex:idx_file_hashes_file_algorithm;index:idx_file_hashes_value" json:"algorithm"` // md5, sha1, sha256, crc64ecma
+	Value     string `gorm:"size:128;not null;index:idx_file_hashes_value" json:"value"`
+}
+
+// TableName 指定表名
+func (FileHash) TableName() string {
+	return "file_hashes"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// FileTag 文件标签，标签名统一为小写
+type FileTag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	FileID    uint      `gorm:"not null;uniqueIndex:idx_file_tags_file_name" json:"file_id"`
+	Name      string    `gorm:"size:50;not null;uniqueIndex:idx_file_tags_file_name;index" json:"name"`
+}
+
+// TableName 指定表名
+func (FileTag) TableName() string {
+	return "file_tags"
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// 摘要计算任务状态常量
+const (
+	HashJobStatusPending = "PENDING" // 等待执行（包括等待重试）
+	HashJobStatusRunning = "RUNNING" // 已被某个实例领取
+	HashJobStatusDone    = "DONE"    // 已完成
+	HashJobStatusDead    = "DEAD"    // 重试次数用尽，需要人工处理
+)
+
+// HashJob 摘要计算任务，每个文件最多一条
+// 任务不做软删除；由各实例通过 SELECT ... FOR UPDATE SKIP LOCKED 领取，领取后在 LeasedUntil 之前由该实例独占
+type HashJob struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FileID      uint       `gorm:"not null;uniqueIndex" json:"file_id"`
+	Status      string     `gorm:"size:20;not null;default:PENDING;index:idx_hash_jobs_status_run_at" json:"status"`
+	RunAt       time.Time  `gorm:"not null;index:idx_hash_jobs_status_run_at" json:"run_at"` // 最早可执行时间，用于重试退避
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	LeaseOwner  string     `gorm:"size:100" json:"lease_owner,omitempty"`
+	LeasedUntil *time.Time `json:"leased_until,omitempty"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+}
+
+// TableName 指定表名
+func (HashJob) TableName() string {
+	return "hash_jobs"
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// 完整性问题类型常量
+const (
+	IntegrityIssueMissing      = "MISSING"       // 对象不存在
+	IntegrityIssueSizeMismatch = "SIZE_MISMATCH" // 对象大小与记录不符
+	IntegrityIssueETagMismatch = "ETAG_MISMATCH" // 对象 ETag 发生变化且无法通过摘要确认内容未变
+	IntegrityIssueHashMismatch = "HASH_MISMATCH" // 对象摘要与记录不符
+)
+
+// IntegrityIssue 完整性巡检发现的问题，文件恢复正常后记录解决时间
+type IntegrityIssue struct {
+	Model
+	FileID     uint       `gorm:"not null;index" json:"file_id"`
+	Bucket     string     `gorm:"size:100;not null" json:"bucket"`
+	ObjectKey  string     `gorm:"size:255;not null" json:"object_key"`
+	Kind       string     `gorm:"size:20;not null" json:"kind"`
+	Expected   string     `gorm:"size:255" json:"expected,omitempty"`
+	Actual     string     `gorm:"size:255" json:"actual,omitempty"`
+	DetectedAt time.Time  `gorm:"not null" json:"detected_at"`
+	ResolvedAt *time.Time `gorm:"index" json:"resolved_at,omitempty"`
+}
+
+// TableName 指定表名
+func (IntegrityIssue) TableName() string {
+	return "integrity_issues"
+}
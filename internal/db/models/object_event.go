@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// 存储桶对象事件类型
+const (
+	ObjectEventCreated = "CREATED" // 对象被创建或覆盖
+	ObjectEventRemoved = "REMOVED" // 对象被删除
+)
+
+// 存储桶对象事件的处理状态
+const (
+	ObjectEventStatusPending = "PENDING" // 等待处理
+	ObjectEventStatusDone    = "DONE"    // 已同步到文件记录
+	ObjectEventStatusIgnored = "IGNORED" // 无需处理：未管理的存储桶、回收站对象、已有更新的事件等
+	ObjectEventStatusFailed  = "FAILED"  // 处理失败，需要通过对账修正
+)
+
+// ObjectEvent 从存储服务收到的对象事件，延迟到 RunAt 之后处理，用于把外部写入同步到文件记录
+// 事件不做软删除，处理后保留一段时间以便排查
+type ObjectEvent struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Source      string     `gorm:"size:20;not null" json:"source"` // aliyun 或 s3
+	EventName   string     `gorm:"size:100" json:"event_name"`     // 存储服务原始的事件名称
+	Type        string     `gorm:"size:20;not null" json:"type"`   // CREATED 或 REMOVED
+	Bucket      string     `gorm:"size:100;not null;index:idx_object_events_bucket_key" json:"bucket"`
+	ObjectKey   string     `gorm:"type:text;not null;index:idx_object_events_bucket_key" json:"object_key"`
+	Size        int64      `json:"size"`
+	ETag        string     `gorm:"column:etag;size:100" json:"etag,omitempty"`
+	EventTime   time.Time  `gorm:"not null" json:"event_time"`
+	Status      string     `gorm:"size:20;not null;default:PENDING;index:idx_object_events_status_run_at" json:"status"`
+	RunAt       time.Time  `gorm:"not null;index:idx_object_events_status_run_at" json:"run_at"`
+	FileID      uint       `json:"file_id,omitempty"` // 创建、更新或删除的文件记录
+	Result      string     `gorm:"type:text" json:"result,omitempty"`
+	ProcessedAt *time.Time `gorm:"index" json:"processed_at,omitempty"`
+}
+
+// TableName 指定表名
+func (ObjectEvent) TableName() string {
+	return "object_events"
+}
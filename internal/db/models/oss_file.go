@@ -10,24 +10,53 @@ const (
 	MD5StatusFailed      = "FAILED"      // 计算失败
 )
 
+// 病毒扫描状态常量，空字符串表示未启用扫描时上传的文件
+const (
+	ScanStatusPending  = "PENDING"  // 待扫描
+	ScanStatusScanning = "SCANNING" // 扫描中
+	ScanStatusClean    = "CLEAN"    // 未发现威胁
+	ScanStatusInfected = "INFECTED" // 发现病毒
+	ScanStatusFailed   = "FAILED"   // 扫描失败
+	ScanStatusSkipped  = "SKIPPED"  // 超过扫描大小上限，未扫描
+)
+
+// 文件状态常量
+const (
+	FileStatusQuarantined = "QUARANTINED" // 因感染病毒被隔离
+	FileStatusCorrupt     = "CORRUPT"     // 完整性巡检发现对象大小或内容与记录不符
+	FileStatusMissing     = "MISSING"     // 完整性巡检发现存储端对象已不存在
+	FileStatusDeleted     = "DELETED"     // 已移入回收站，对象保存在存储桶的回收站前缀下
+)
+
+// LiveFileStatuses 记录仍代表对象键处当前文件的状态；覆盖上传留下的 REPLACED 历史记录和回收站中的文件不在其中
+var LiveFileStatuses = []string{"ACTIVE", FileStatusQuarantined, FileStatusCorrupt, FileStatusMissing}
+
 // OSSFile OSS 文件模型
 type OSSFile struct {
 	Model
-	Filename         string    `gorm:"size:255;not null" json:"filename"`
-	OriginalFilename string    `gorm:"size:255;not null" json:"original_filename"`
-	FileSize         int64     `gorm:"not null" json:"file_size"`
-	MD5              string    `gorm:"size:32" json:"md5"`
-	MD5Status        string    `gorm:"size:20;default:'PENDING'" json:"md5_status"` // PENDING, CALCULATING, COMPLETED, FAILED
-	StorageType      string    `gorm:"size:20;not null" json:"storage_type"`        // ALIYUN_OSS, AWS_S3, CLOUDFLARE_R2
-	Bucket           string    `gorm:"size:100;not null" json:"bucket"`
-	ObjectKey        string    `gorm:"size:255;not null" json:"object_key"`
-	DownloadURL      string    `gorm:"type:text" json:"download_url,omitempty"`
-	ExpiresAt        time.Time `json:"expires_at,omitempty"`
-	UploaderID       uint      `gorm:"not null" json:"uploader_id"`
-	Uploader         *User     `json:"uploader,omitempty"`
-	UploadIP         string    `gorm:"size:50" json:"upload_ip"`
-	Status           string    `gorm:"size:20;default:ACTIVE" json:"status"` // ACTIVE, DELETED
-	ConfigID         uint      `gorm:"not null" json:"config_id"`            // 存储配置ID
+	Filename         string     `gorm:"size:255;not null" json:"filename"`
+	OriginalFilename string     `gorm:"size:255;not null" json:"original_filename"`
+	FileSize         int64      `gorm:"not null" json:"file_size"`
+	MD5              string     `gorm:"size:32" json:"md5"`
+	MD5Status        string     `gorm:"size:20;default:'PENDING'" json:"md5_status"` // PENDING, CALCULATING, COMPLETED, FAILED
+	StorageType      string     `gorm:"size:20;not null" json:"storage_type"`        // ALIYUN_OSS, AWS_S3, CLOUDFLARE_R2
+	Bucket           string     `gorm:"size:100;not null" json:"bucket"`
+	ObjectKey        string     `gorm:"size:255;not null" json:"object_key"`
+	DownloadURL      string     `gorm:"type:text" json:"download_url,omitempty"`
+	ExpiresAt        time.Time  `json:"expires_at,omitempty"`
+	UploaderID       uint       `gorm:"not null" json:"uploader_id"`
+	Uploader         *User      `json:"uploader,omitempty"`
+	UploadIP         string     `gorm:"size:50" json:"upload_ip"`
+	Status           string     `gorm:"size:20;default:ACTIVE" json:"status"`  // ACTIVE, DELETED
+	ConfigID         uint       `gorm:"not null" json:"config_id"`             // 存储配置ID
+	ScanStatus       string     `gorm:"size:20;index" json:"scan_status"`      // PENDING, CLEAN, INFECTED, FAILED
+	ScanResult       string     `gorm:"size:255" json:"scan_result,omitempty"` // 命中的病毒特征或失败原因
+	ScannedAt        *time.Time `json:"scanned_at,omitempty"`
+	ETag             string     `gorm:"column:etag;size:100" json:"etag,omitempty"` // 完整性巡检首次检查时记录的存储端 ETag
+	VerifiedAt       *time.Time `gorm:"index" json:"verified_at,omitempty"`         // 最近一次完整性巡检时间
+	OriginalKey      string     `gorm:"size:255" json:"original_key,omitempty"`     // 移入回收站前的对象键，恢复时移回该位置
+	TrashedAt        *time.Time `gorm:"index" json:"trashed_at,omitempty"`          // 移入回收站的时间
+	TrashedBy        uint       `json:"trashed_by,omitempty"`                       // 删除文件的用户ID
 }
 
 // TableName 指定表名
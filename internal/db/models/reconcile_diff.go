@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// 对账差异类型
+const (
+	ReconcileDiffUnrecorded   = "UNRECORDED"     // 存储桶中有对象但没有文件记录
+	ReconcileDiffMissing      = "MISSING_OBJECT" // 有文件记录但存储桶中没有对象
+	ReconcileDiffSizeMismatch = "SIZE_MISMATCH"  // 对象大小与记录不符
+	ReconcileDiffETagMismatch = "ETAG_MISMATCH"  // 对象 ETag 与记录不符
+)
+
+// 对账差异的处理方式
+const (
+	ReconcileActionImport = "IMPORT" // 为没有记录的对象创建文件记录
+	ReconcileActionRelink = "RELINK" // 让记录重新指向对象：按对象更新大小和 ETag，或指向另一个对象键
+	ReconcileActionDelete = "DELETE" // 删除没有记录的对象或没有对象的记录
+)
+
+// ReconcileDiff 对账任务发现的差异，由管理员导入、重新关联或删除
+type ReconcileDiff struct {
+	Model
+	TaskID       uint       `gorm:"not null;index" json:"task_id"`
+	Bucket       string     `gorm:"size:100;not null" json:"bucket"`
+	ObjectKey    string     `gorm:"type:text;not null" json:"object_key"`
+	Kind         string     `gorm:"size:20;not null" json:"kind"`
+	FileID       uint       `gorm:"index" json:"file_id,omitempty"` // 相关的文件记录，导入后为新记录
+	ObjectSize   int64      `json:"object_size"`
+	RecordSize   int64      `json:"record_size"`
+	ObjectETag   string     `gorm:"column:object_etag;size:100" json:"object_etag,omitempty"`
+	RecordETag   string     `gorm:"column:record_etag;size:100" json:"record_etag,omitempty"`
+	LastModified *time.Time `json:"last_modified,omitempty"` // 对象的最后修改时间
+	Resolution   string     `gorm:"size:20" json:"resolution,omitempty"`
+	ResolvedBy   uint       `json:"resolved_by,omitempty"`
+	ResolvedAt   *time.Time `gorm:"index" json:"resolved_at,omitempty"`
+}
+
+// TableName 指定表名
+func (ReconcileDiff) TableName() string {
+	return "reconcile_diffs"
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Share 文件或目录的公开分享链接，通过 /s/:slug 匿名访问
+// FileID 不为 0 时分享单个文件，否则分享 Bucket 中 Prefix 下的所有文件
+type Share struct {
+	Model
+	Slug         string     `gorm:"size:32;not null;uniqueIndex" json:"slug"`
+	OwnerID      uint       `gorm:"not null;index" json:"owner_id"`
+	FileID       uint       `gorm:"index" json:"file_id,omitempty"`
+	Bucket       string     `gorm:"size:100;not null" json:"bucket"`
+	Prefix       string     `gorm:"size:255" json:"prefix,omitempty"`
+	Name         string     `gorm:"size:255" json:"name"` // 展示给访问者的名称：文件名或目录名
+	PasswordHash string     `gorm:"size:100" json:"-"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxDownloads int        `gorm:"not null;default:0" json:"max_downloads"` // 0 表示不限制
+	Downloads    int        `gorm:"not null;default:0" json:"downloads"`
+	PreviewOnly  bool       `gorm:"not null;default:false" json:"preview_only"` // 只允许在线预览，不提供下载
+	LastAccessAt *time.Time `json:"last_access_at,omitempty"`
+}
+
+// TableName 指定表名
+func (Share) TableName() string {
+	return "shares"
+}
+
+// HasPassword 是否设置了访问密码
+func (s *Share) HasPassword() bool {
+	return s.PasswordHash != ""
+}
+
+// 分享访问动作
+const (
+	ShareActionView     = "VIEW"     // 查看分享信息或目录列表
+	ShareActionDownload = "DOWNLOAD" // 下载文件
+	ShareActionPreview  = "PREVIEW"  // 在线预览文件
+)
+
+// ShareAccessLog 分享链接访问记录，拒绝的访问同样记录
+type ShareAccessLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	ShareID   uint      `gorm:"not null;index" json:"share_id"`
+	FileID    uint      `json:"file_id,omitempty"`
+	Action    string    `gorm:"size:20;not null" json:"action"`
+	Allowed   bool      `gorm:"not null" json:"allowed"`
+	Reason    string    `gorm:"size:100" json:"reason,omitempty"` // 拒绝原因
+	IP        string    `gorm:"size:50" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+}
+
+// TableName 指定表名
+func (ShareAccessLog) TableName() string {
+	return "share_access_logs"
+}
@@ -0,0 +1,24 @@
+package models
+
+// 配额作用范围
+const (
+	QuotaScopeUser   = "USER"   // 针对单个用户
+	QuotaScopeRole   = "ROLE"   // 针对角色中的每个用户
+	QuotaScopeBucket = "BUCKET" // 针对存储桶的总用量
+)
+
+// StorageQuota 存储配额模型
+// BucketName 为空时表示跨所有存储桶的总配额；MaxBytes/MaxFiles 为 0 表示不限制
+type StorageQuota struct {
+	Model
+	ScopeType  string `gorm:"size:20;not null;index:idx_storage_quotas_scope" json:"scope_type"` // USER, ROLE, BUCKET
+	ScopeID    uint   `gorm:"not null;default:0;index:idx_storage_quotas_scope" json:"scope_id"` // 用户ID或角色ID，BUCKET 范围为 0
+	BucketName string `gorm:"size:255;not null;default:''" json:"bucket_name"`
+	MaxBytes   int64  `gorm:"not null;default:0" json:"max_bytes"`
+	MaxFiles   int64  `gorm:"not null;default:0" json:"max_files"`
+}
+
+// TableName 指定表名
+func (StorageQuota) TableName() string {
+	return "storage_quotas"
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// 后台任务状态常量
+const (
+	TaskStatusPending   = "PENDING"   // 等待执行
+	TaskStatusRunning   = "RUNNING"   // 已被某个实例领取
+	TaskStatusSucceeded = "SUCCEEDED" // 执行成功
+	TaskStatusFailed    = "FAILED"    // 执行失败
+	TaskStatusCanceled  = "CANCELED"  // 已取消
+)
+
+// Task 后台任务，用于执行删除目录、批量计算摘要等耗时操作
+// 任务不做软删除；由各实例通过 SELECT ... FOR UPDATE SKIP LOCKED 领取，领取后在 LeasedUntil 之前由该实例独占
+type Task struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Type            string     `gorm:"size:50;not null;index" json:"type"`
+	Status          string     `gorm:"size:20;not null;default:PENDING;index" json:"status"`
+	UserID          uint       `gorm:"not null;index" json:"user_id"`     // 提交任务的用户
+	Params          string     `gorm:"type:text" json:"params"`           // 任务参数（JSON）
+	Result          string     `gorm:"type:text" json:"result,omitempty"` // 执行结果（JSON）
+	Error           string     `gorm:"type:text" json:"error,omitempty"`
+	Message         string     `gorm:"size:500" json:"message,omitempty"` // 当前进度说明
+	Done            int64      `gorm:"not null;default:0" json:"done"`
+	Total           int64      `gorm:"not null;default:0" json:"total"` // 0 表示总量未知
+	Attempts        int        `gorm:"not null;default:0" json:"attempts"`
+	CancelRequested bool       `gorm:"not null;default:false" json:"cancel_requested"`
+	LeaseOwner      string     `gorm:"size:100" json:"-"`
+	LeasedUntil     *time.Time `json:"-"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+// TableName 指定表名
+func (Task) TableName() string {
+	return "tasks"
+}
+
+// Finished 任务是否已结束
+func (t *Task) Finished() bool {
+	return t.Status == TaskStatusSucceeded || t.Status == TaskStatusFailed || t.Status == TaskStatusCanceled
+}
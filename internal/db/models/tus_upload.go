@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// tus 上传状态常量
+const (
+	TusStatusUploading  = "UPLOADING"  // 上传中
+	TusStatusCompleted  = "COMPLETED"  // 已完成
+	TusStatusTerminated = "TERMINATED" // 已终止
+)
+
+// TusUpload tus 断点续传上传记录
+// 每条记录对应一个存储端的分片上传，PATCH 数据在本地暂存满一个分片后写入存储
+type TusUpload struct {
+	Model
+	UploadKey         string    `gorm:"size:64;not null;uniqueIndex" json:"upload_key"` // tus 上传标识（URL 中的 id）
+	UserID            uint      `gorm:"not null;index" json:"user_id"`
+	ConfigID          uint      `gorm:"not null" json:"config_id"`
+	RegionCode        string    `gorm:"size:50;not null" json:"region_code"`
+	BucketName        string    `gorm:"size:100;not null" json:"bucket_name"`
+	ObjectKey         string    `gorm:"size:255;not null" json:"object_key"`
+	OriginalFilename  string    `gorm:"size:255;not null" json:"original_filename"`
+	UploadLength      int64     `gorm:"not null" json:"upload_length"`
+	UploadOffset      int64     `gorm:"not null;default:0" json:"upload_offset"`
+	PartSize          int64     `gorm:"not null" json:"part_size"`
+	MultipartUploadID string    `gorm:"size:255" json:"multipart_upload_id"`
+	Parts             string    `gorm:"type:text" json:"-"`    // 已上传分片（JSON）
+	Metadata          string    `gorm:"type:text" json:"metadata"` // 原始 Upload-Metadata 头
+	UploadIP          string    `gorm:"size:50" json:"upload_ip"`
+	Status            string    `gorm:"size:20;default:UPLOADING;index" json:"status"` // UPLOADING, COMPLETED, TERMINATED
+	FileID            uint      `json:"file_id,omitempty"`                             // 完成后关联的文件记录
+	ExpiresAt         time.Time `gorm:"index" json:"expires_at"`
+}
+
+// IsExpired 检查上传是否已过期
+func (t *TusUpload) IsExpired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+// TableName 指定表名
+func (TusUpload) TableName() string {
+	return "tus_uploads"
+}
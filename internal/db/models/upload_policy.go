@@ -0,0 +1,17 @@
+package models
+
+// UploadPolicy 存储桶上传内容策略，关联到地域-桶映射
+// 列表类字段均为逗号分隔，为空表示不限制；MaxFileSize 为 0 表示不限制
+type UploadPolicy struct {
+	Model
+	RegionBucketMappingID uint   `gorm:"not null;uniqueIndex" json:"region_bucket_mapping_id"`
+	AllowedExtensions     string `gorm:"type:text" json:"allowed_extensions"`     // 允许的扩展名，如 ".pdf,.docx"
+	AllowedMIMETypes      string `gorm:"type:text" json:"allowed_mime_types"`     // 允许的 MIME 类型（按文件头检测），支持 "image/*"
+	MaxFileSize           int64  `gorm:"not null;default:0" json:"max_file_size"` // 单个文件最大字节数
+	ForbiddenPatterns     string `gorm:"type:text" json:"forbidden_patterns"`     // 禁止的文件名通配符，如 "*.exe,~$*"
+}
+
+// TableName 指定表名
+func (UploadPolicy) TableName() string {
+	return "upload_policies"
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// UploadProgress 上传进度记录，用于多实例间共享上传进度
+type UploadProgress struct {
+	Model
+	TaskID    string    `gorm:"size:64;not null;uniqueIndex" json:"task_id"`
+	Status    string    `gorm:"size:20;not null" json:"status"` // uploading, completed, failed
+	Data      string    `gorm:"type:jsonb;not null" json:"data"` // 序列化的进度信息
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// TableName 指定表名
+func (UploadProgress) TableName() string {
+	return "upload_progresses"
+}
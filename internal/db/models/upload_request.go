@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// UploadRequest 上传请求链接，没有账号的外部人员通过 /u/:slug 向 Bucket 的 Prefix 目录上传文件
+// 上传的文件记在链接所有者名下；列表类字段均为逗号分隔，为空表示不限制
+type UploadRequest struct {
+	Model
+	Slug              string     `gorm:"size:32;not null;uniqueIndex" json:"slug"`
+	OwnerID           uint       `gorm:"not null;index" json:"owner_id"`
+	Bucket            string     `gorm:"size:100;not null" json:"bucket"`
+	Prefix            string     `gorm:"size:255;not null" json:"prefix"`
+	Name              string     `gorm:"size:255" json:"name"`         // 展示给上传者的标题
+	Description       string     `gorm:"type:text" json:"description"` // 展示给上传者的说明
+	PasswordHash      string     `gorm:"size:100" json:"-"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	AllowedExtensions string     `gorm:"type:text" json:"allowed_extensions"`      // 允许的扩展名，如 ".pdf,.docx"
+	AllowedMIMETypes  string     `gorm:"type:text" json:"allowed_mime_types"`      // 允许的 MIME 类型（按文件头检测），支持 "image/*"
+	MaxTotalSize      int64      `gorm:"not null;default:0" json:"max_total_size"` // 所有上传文件的总字节数上限，0 表示不限制
+	UploadedSize      int64      `gorm:"not null;default:0" json:"uploaded_size"`
+	FileCount         int        `gorm:"not null;default:0" json:"file_count"`
+	LastUploadAt      *time.Time `json:"last_upload_at,omitempty"`
+}
+
+// TableName 指定表名
+func (UploadRequest) TableName() string {
+	return "upload_requests"
+}
+
+// HasPassword 是否设置了访问密码
+func (r *UploadRequest) HasPassword() bool {
+	return r.PasswordHash != ""
+}
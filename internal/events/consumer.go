@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Message 从消息队列收到的一条消息
+type Message struct {
+	ID     string
+	Handle string // 确认消息时使用的句柄，如 SQS 的 ReceiptHandle、MNS 的 ReceiptHandle
+	Body   []byte
+}
+
+// Consumer 从消息队列拉取事件消息，例如订阅了存储桶事件的 MNS 或 SQS 队列
+// 实现通过 RegisterConsumer 注册，按配置中的 events.consumer 选用
+type Consumer interface {
+	// Receive 拉取一批消息，没有消息时可以阻塞等待（长轮询），ctx 结束时应尽快返回
+	Receive(ctx context.Context) ([]Message, error)
+	// Ack 确认消息已保存，消息队列不再投递
+	Ack(ctx context.Context, msg Message) error
+	// Close 释放连接等资源
+	Close() error
+}
+
+// ConsumerFactory 按配置中的 events.consumer_options 创建消费者
+type ConsumerFactory func(options map[string]string) (Consumer, error)
+
+var (
+	consumersMu sync.RWMutex
+	consumers   = make(map[string]ConsumerFactory)
+)
+
+// RegisterConsumer 注册消费者，通常在实现所在包的 init 中调用
+func RegisterConsumer(name string, factory ConsumerFactory) {
+	consumersMu.Lock()
+	defer consumersMu.Unlock()
+	if _, ok := consumers[name]; ok {
+		panic(fmt.Sprintf("事件消费者 %s 重复注册", name))
+	}
+	consumers[name] = factory
+}
+
+// NewConsumer 创建已注册的消费者
+func NewConsumer(name string, options map[string]string) (Consumer, error) {
+	consumersMu.RLock()
+	factory, ok := consumers[name]
+	consumersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未注册的事件消费者: %s，可用: %v", name, consumerNames())
+	}
+	return factory(options)
+}
+
+func consumerNames() []string {
+	consumersMu.RLock()
+	defer consumersMu.RUnlock()
+	names := make([]string, 0, len(consumers))
+	for name := range consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Consume 持续从消费者拉取消息并保存其中的事件，直到 ctx 结束
+// 消息中的事件保存成功后才确认，保存失败的消息由消息队列重新投递；无法解析的消息记录日志后确认丢弃
+func (in *Ingestor) Consume(ctx context.Context, c Consumer) {
+	for ctx.Err() == nil {
+		msgs, err := c.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warn("拉取事件消息失败", zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(in.pollInterval()):
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			events, err := Parse(msg.Body)
+			if err != nil {
+				logger.Warn("无法解析事件消息，已丢弃", zap.String("message_id", msg.ID), zap.Error(err))
+			} else if err := in.Enqueue(events); err != nil {
+				logger.Error("保存事件失败，等待消息重新投递", zap.String("message_id", msg.ID), zap.Error(err))
+				continue
+			}
+			if err := c.Ack(ctx, msg); err != nil {
+				logger.Warn("确认事件消息失败", zap.String("message_id", msg.ID), zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package events
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/trash"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const aliyunPayload = `{"events":[
+	{"eventName":"ObjectCreated:PutObject","eventTime":"2026-03-08T08:20:11.000Z",
+	 "oss":{"bucket":{"name":"docs"},"object":{"key":"a/b.txt","size":12,"eTag":"ABC"}}},
+	{"eventName":"ObjectModified:UpdateObjectMeta","oss":{"bucket":{"name":"docs"},"object":{"key":"a/b.txt"}}},
+	{"eventName":"ObjectRemoved:DeleteObject","eventTime":"2026-03-08T08:21:00Z",
+	 "oss":{"bucket":{"name":"docs"},"object":{"key":"c.txt"}}}
+]}`
+
+const s3Payload = `{"Records":[
+	{"eventName":"ObjectCreated:Put","eventTime":"2026-03-08T08:20:11.123Z",
+	 "s3":{"bucket":{"name":"media"},"object":{"key":"dir/my+file%281%29.txt","size":5,"eTag":"d41d8"}}}
+]}`
+
+func TestParseAliyun(t *testing.T) {
+	events, err := Parse([]byte(aliyunPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("len = %d", len(events))
+	}
+	created := events[0]
+	if created.Source != SourceAliyun || created.Type != models.ObjectEventCreated || created.Bucket != "docs" ||
+		created.ObjectKey != "a/b.txt" || created.Size != 12 || created.ETag != "ABC" {
+		t.Errorf("created = %+v", created)
+	}
+	if !created.EventTime.Equal(time.Date(2026, 3, 8, 8, 20, 11, 0, time.UTC)) {
+		t.Errorf("event time = %v", created.EventTime)
+	}
+	if events[1].Type != models.ObjectEventRemoved || events[1].ObjectKey != "c.txt" {
+		t.Errorf("removed = %+v", events[1])
+	}
+}
+
+func TestParseS3(t *testing.T) {
+	events, err := Parse([]byte(s3Payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Source != SourceS3 || events[0].ObjectKey != "dir/my file(1).txt" {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestParseEnvelopes(t *testing.T) {
+	quote := func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(aliyunPayload))
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"SNS", `{"Type":"Notification","Message":` + quote(s3Payload) + `}`, 1},
+		{"SQS 消息", `{"MessageId":"1","Body":` + quote(s3Payload) + `}`, 1},
+		{"SQS 消息经过 SNS", `{"Body":` + quote(`{"Type":"Notification","Message":`+quote(s3Payload)+`}`) + `}`, 1},
+		{"SQS 批量", `{"Messages":[{"Body":` + quote(s3Payload) + `},{"Body":` + quote(aliyunPayload) + `}]}`, 3},
+		{"MNS 队列 XML", `<?xml version="1.0"?><Message><MessageBody>` + encoded + `</MessageBody></Message>`, 2},
+		{"MNS 主题 JSON", `{"TopicName":"oss","Message":` + quote(encoded) + `}`, 2},
+		{"Base64", encoded, 2},
+		{"数组", `[` + s3Payload + `,` + aliyunPayload + `]`, 3},
+		{"S3 测试事件", `{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"media"}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := Parse([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != tt.want {
+				t.Errorf("len = %d, want %d", len(events), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse([]byte(`{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.example.com/confirm"}`))
+	if !errors.Is(err, ErrConfirmation) {
+		t.Errorf("confirmation: err = %v", err)
+	}
+	for _, body := range []string{"", `{"foo":1}`, "not base64!", `{"Message":"{\"Message\":\"{\\\"x\\\":1}\"}"}`} {
+		if _, err := Parse([]byte(body)); !errors.Is(err, ErrUnsupportedPayload) {
+			t.Errorf("Parse(%q) err = %v", body, err)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	in := NewIngestor(nil, config.EventsConfig{Token: "secret"}, nil, "")
+	if !in.Authorize("secret") || in.Authorize("Secret") || in.Authorize("") {
+		t.Error("Authorize with token")
+	}
+	if NewIngestor(nil, config.EventsConfig{}, nil, "").Authorize("") {
+		t.Error("Authorize without token = true")
+	}
+}
+
+func TestNewIngestorDefaults(t *testing.T) {
+	in := NewIngestor(nil, config.EventsConfig{}, nil, "/uploads")
+	if in.cfg.UploaderID != defaultUploaderID || in.cfg.Delay != defaultDelay || in.cfg.BatchSize != defaultBatchSize ||
+		in.cfg.PollInterval != defaultPollInterval || in.cfg.RetentionDays != defaultRetentionDays {
+		t.Errorf("cfg = %+v", in.cfg)
+	}
+	if in.awsUploadDir != "uploads/" {
+		t.Errorf("awsUploadDir = %q", in.awsUploadDir)
+	}
+	if in.Enabled() {
+		t.Error("Enabled() = true")
+	}
+}
+
+func TestSameETag(t *testing.T) {
+	if !sameETag(`"ABC"`, "abc") || sameETag("abc", "abd") {
+		t.Error("sameETag")
+	}
+}
+
+func newTestIngestor(t *testing.T, bin *trash.Bin, awsUploadDir string) (*Ingestor, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewIngestor(db, config.EventsConfig{Enabled: true}, bin, awsUploadDir), mock
+}
+
+func expectMappings(mock sqlmock.Sqlmock, count int) {
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "region_bucket_mapping"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+}
+
+func TestApplyIgnored(t *testing.T) {
+	bin := trash.NewBin(nil, nil, config.TrashConfig{Enabled: true})
+
+	in, mock := newTestIngestor(t, bin, "")
+	expectMappings(mock, 0)
+	status, _, err := in.apply(in.db, &models.ObjectEvent{Bucket: "other", ObjectKey: "a.txt", Type: models.ObjectEventCreated})
+	if err != nil || status != models.ObjectEventStatusIgnored {
+		t.Errorf("unmanaged bucket: status = %s, err = %v", status, err)
+	}
+
+	expectMappings(mock, 1)
+	status, _, err = in.apply(in.db, &models.ObjectEvent{Bucket: "docs", ObjectKey: ".trash/1/a.txt", Type: models.ObjectEventCreated})
+	if err != nil || status != models.ObjectEventStatusIgnored {
+		t.Errorf("trash object: status = %s, err = %v", status, err)
+	}
+
+	expectMappings(mock, 1)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "object_events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	status, result, err := in.apply(in.db, &models.ObjectEvent{ID: 1, Bucket: "docs", ObjectKey: "a.txt", Type: models.ObjectEventRemoved})
+	if err != nil || status != models.ObjectEventStatusIgnored || result != "已有更新的事件" {
+		t.Errorf("superseded: status = %s, result = %s, err = %v", status, result, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApplyOutsideUploadDir(t *testing.T) {
+	in, mock := newTestIngestor(t, nil, "uploads")
+	expectMappings(mock, 1)
+	status, _, err := in.apply(in.db, &models.ObjectEvent{Source: SourceS3, Bucket: "media", ObjectKey: "other/a.txt"})
+	if err != nil || status != models.ObjectEventStatusIgnored {
+		t.Errorf("status = %s, err = %v", status, err)
+	}
+}
+
+func TestApplyRemoved(t *testing.T) {
+	in, mock := newTestIngestor(t, nil, "uploads")
+	expectMappings(mock, 1)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "object_events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "oss_files" WHERE \(bucket = \$1 AND object_key = \$2`).
+		WithArgs("media", "a.txt", "ACTIVE", models.FileStatusQuarantined, models.FileStatusCorrupt, models.FileStatusMissing, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "bucket", "object_key"}).AddRow(7, "media", "a.txt"))
+	mock.ExpectExec(`UPDATE "oss_files" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	event := &models.ObjectEvent{Source: SourceS3, Bucket: "media", ObjectKey: "uploads/a.txt", Type: models.ObjectEventRemoved, EventTime: time.Now()}
+	status, _, err := in.apply(in.db, event)
+	if err != nil || status != models.ObjectEventStatusDone || event.FileID != 7 {
+		t.Errorf("status = %s, file_id = %d, err = %v", status, event.FileID, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,396 @@
+package events
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"github.com/myysophia/ossmanager/internal/trash"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultUploaderID    = 1 // 初始管理员
+	defaultDelay         = 30
+	defaultPollInterval  = 5
+	defaultBatchSize     = 100
+	defaultRetentionDays = 7
+	purgeInterval        = time.Hour
+	maxResultLength      = 1000
+)
+
+// trackedStatuses 存储端应当有对象的文件状态，事件只同步这些记录；回收站中的文件由回收站管理
+var trackedStatuses = []string{"ACTIVE", models.FileStatusQuarantined, models.FileStatusCorrupt, models.FileStatusMissing}
+
+var defaultIngestor *Ingestor
+
+// SetDefault 设置默认的事件接入器
+func SetDefault(in *Ingestor) {
+	defaultIngestor = in
+}
+
+// Default 返回默认的事件接入器，未设置时返回 nil
+func Default() *Ingestor {
+	return defaultIngestor
+}
+
+// Ingestor 事件接入器
+// 收到的事件先保存到 object_events 表，延迟 delay 秒后处理：本系统上传的文件在事件处理前已写入记录，不会重复创建；
+// 多个实例通过 SELECT ... FOR UPDATE SKIP LOCKED 分批领取，同一对象较早的事件在有更新的事件时被忽略
+type Ingestor struct {
+	db           *gorm.DB
+	cfg          config.EventsConfig
+	bin          *trash.Bin // 回收站前缀下的对象由回收站管理，忽略其事件
+	awsUploadDir string     // S3 事件中的对象键包含上传目录，文件记录中的对象键不包含
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+// NewIngestor 创建事件接入器
+func NewIngestor(db *gorm.DB, cfg config.EventsConfig, bin *trash.Bin, awsUploadDir string) *Ingestor {
+	if cfg.UploaderID == 0 {
+		cfg.UploaderID = defaultUploaderID
+	}
+	if cfg.Delay <= 0 {
+		cfg.Delay = defaultDelay
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.RetentionDays <= 0 {
+		cfg.RetentionDays = defaultRetentionDays
+	}
+	if awsUploadDir = strings.Trim(awsUploadDir, "/"); awsUploadDir != "" {
+		awsUploadDir += "/"
+	}
+	return &Ingestor{
+		db:           db,
+		cfg:          cfg,
+		bin:          bin,
+		awsUploadDir: awsUploadDir,
+	}
+}
+
+// Enabled 是否启用事件接入
+func (in *Ingestor) Enabled() bool {
+	return in != nil && in.cfg.Enabled
+}
+
+// Authorize 校验 webhook 请求携带的令牌，未配置令牌时拒绝所有请求
+func (in *Ingestor) Authorize(token string) bool {
+	if in.cfg.Token == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(in.cfg.Token)) == 1
+}
+
+func (in *Ingestor) pollInterval() time.Duration {
+	return time.Duration(in.cfg.PollInterval) * time.Second
+}
+
+// Enqueue 保存事件，等待延迟处理
+func (in *Ingestor) Enqueue(events []models.ObjectEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	runAt := time.Now().Add(time.Duration(in.cfg.Delay) * time.Second)
+	for i := range events {
+		events[i].Status = models.ObjectEventStatusPending
+		events[i].RunAt = runAt
+	}
+	if err := in.db.Create(&events).Error; err != nil {
+		return fmt.Errorf("保存事件失败: %w", err)
+	}
+	return nil
+}
+
+// Start 启动后台处理；配置了消费者时同时从消息队列拉取事件。未启用时不做任何事
+func (in *Ingestor) Start() {
+	if !in.Enabled() {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	in.cancel = cancel
+
+	in.wg.Add(1)
+	go func() {
+		defer in.wg.Done()
+		ticker := time.NewTicker(in.pollInterval())
+		defer ticker.Stop()
+
+		var lastPurge time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				in.drain()
+				if time.Since(lastPurge) >= purgeInterval {
+					in.purge()
+					lastPurge = time.Now()
+				}
+			}
+		}
+	}()
+
+	if in.cfg.Consumer != "" {
+		consumer, err := NewConsumer(in.cfg.Consumer, in.cfg.ConsumerOptions)
+		if err != nil {
+			logger.Error("创建事件消费者失败，只接收 webhook 事件", zap.String("consumer", in.cfg.Consumer), zap.Error(err))
+		} else {
+			in.wg.Add(1)
+			go func() {
+				defer in.wg.Done()
+				defer consumer.Close()
+				in.Consume(ctx, consumer)
+			}()
+		}
+	}
+
+	logger.Info("存储桶事件接入已启动",
+		zap.Int("delay_seconds", in.cfg.Delay),
+		zap.Int("poll_interval_seconds", in.cfg.PollInterval),
+		zap.String("consumer", in.cfg.Consumer),
+	)
+}
+
+// Stop 停止后台处理
+func (in *Ingestor) Stop() {
+	if in.cancel != nil {
+		in.cancel()
+	}
+	in.wg.Wait()
+}
+
+// drain 连续处理到没有到期的事件
+func (in *Ingestor) drain() {
+	for {
+		n, err := in.RunOnce()
+		if err != nil {
+			logger.Error("处理存储桶事件失败", zap.Error(err))
+			return
+		}
+		if n < in.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// purge 删除超过保留期的已处理事件
+func (in *Ingestor) purge() {
+	cutoff := time.Now().AddDate(0, 0, -in.cfg.RetentionDays)
+	result := in.db.Where("processed_at < ?", cutoff).Delete(&models.ObjectEvent{})
+	if result.Error != nil {
+		logger.Warn("清理已处理的存储桶事件失败", zap.Error(result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		logger.Info("已清理过期的存储桶事件", zap.Int64("count", result.RowsAffected))
+	}
+}
+
+// RunOnce 领取并处理一批到期的事件，返回处理的事件数
+// 单个事件处理失败时标记为 FAILED 并继续，由对账修正
+func (in *Ingestor) RunOnce() (int, error) {
+	var processed int
+	err := in.db.Transaction(func(tx *gorm.DB) error {
+		var events []models.ObjectEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", models.ObjectEventStatusPending, time.Now()).
+			Order("event_time, id").Limit(in.cfg.BatchSize).Find(&events).Error; err != nil {
+			return err
+		}
+
+		for i := range events {
+			event := &events[i]
+			var status, result string
+			err := tx.Transaction(func(stx *gorm.DB) error {
+				var err error
+				status, result, err = in.apply(stx, event)
+				return err
+			})
+			if err != nil {
+				logger.Warn("处理存储桶事件失败",
+					zap.Uint("event_id", event.ID),
+					zap.String("bucket", event.Bucket),
+					zap.String("object_key", event.ObjectKey),
+					zap.Error(err))
+				status, result = models.ObjectEventStatusFailed, err.Error()
+			}
+			result = utils.Truncate(result, maxResultLength)
+			if err := tx.Model(event).Updates(map[string]interface{}{
+				"status":       status,
+				"result":       result,
+				"file_id":      event.FileID,
+				"processed_at": time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+			processed++
+		}
+		return nil
+	})
+	return processed, err
+}
+
+// apply 处理一个事件，返回处理后的状态和说明
+func (in *Ingestor) apply(tx *gorm.DB, event *models.ObjectEvent) (string, string, error) {
+	var mappings int64
+	if err := tx.Model(&models.RegionBucketMapping{}).Where("bucket_name = ?", event.Bucket).Count(&mappings).Error; err != nil {
+		return "", "", err
+	}
+	if mappings == 0 {
+		return models.ObjectEventStatusIgnored, "未管理的存储桶", nil
+	}
+
+	key := event.ObjectKey
+	if event.Source == SourceS3 && in.awsUploadDir != "" {
+		if !strings.HasPrefix(key, in.awsUploadDir) {
+			return models.ObjectEventStatusIgnored, "对象不在上传目录中", nil
+		}
+		key = strings.TrimPrefix(key, in.awsUploadDir)
+	}
+	if in.bin != nil && in.bin.Contains(key) {
+		return models.ObjectEventStatusIgnored, "回收站中的对象", nil
+	}
+
+	var newer int64
+	if err := tx.Model(&models.ObjectEvent{}).
+		Where("bucket = ? AND object_key = ?", event.Bucket, event.ObjectKey).
+		Where("event_time > ? OR (event_time = ? AND id > ?)", event.EventTime, event.EventTime, event.ID).
+		Count(&newer).Error; err != nil {
+		return "", "", err
+	}
+	if newer > 0 {
+		return models.ObjectEventStatusIgnored, "已有更新的事件", nil
+	}
+
+	if event.Type == models.ObjectEventRemoved {
+		return in.removed(tx, event, key)
+	}
+	if strings.HasSuffix(key, "/") && event.Size == 0 {
+		return models.ObjectEventStatusIgnored, "目录占位对象", nil
+	}
+	return in.created(tx, event, key)
+}
+
+// created 对象被创建或覆盖：没有记录时创建记录，内容变化时按对象更新大小和 ETag 并清除摘要
+func (in *Ingestor) created(tx *gorm.DB, event *models.ObjectEvent, key string) (string, string, error) {
+	var file models.OSSFile
+	err := tx.Where("bucket = ? AND object_key = ? AND status IN ?", event.Bucket, key, trackedStatuses).
+		Order("id").First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return in.create(tx, event, key)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	event.FileID = file.ID
+
+	updates := map[string]interface{}{}
+	changed := file.FileSize != event.Size || (file.ETag != "" && event.ETag != "" && !sameETag(file.ETag, event.ETag))
+	switch {
+	case changed:
+		updates["file_size"] = event.Size
+		updates["etag"] = event.ETag
+		updates["md5"] = ""
+		updates["md5_status"] = models.MD5StatusPending
+		updates["verified_at"] = nil
+		if file.Status == models.FileStatusCorrupt || file.Status == models.FileStatusMissing {
+			updates["status"] = "ACTIVE"
+		}
+	case file.Status == models.FileStatusMissing:
+		updates["status"] = "ACTIVE"
+	}
+	if !changed && file.ETag == "" && event.ETag != "" {
+		updates["etag"] = event.ETag
+	}
+	if len(updates) == 0 {
+		return models.ObjectEventStatusDone, "记录已是最新", nil
+	}
+
+	if err := tx.Model(&file).Updates(updates).Error; err != nil {
+		return "", "", fmt.Errorf("更新文件记录失败: %w", err)
+	}
+	if changed {
+		if err := hashing.Replace(tx, file.ID, nil); err != nil {
+			return "", "", err
+		}
+		return models.ObjectEventStatusDone, "对象内容已变化，更新文件记录", nil
+	}
+	return models.ObjectEventStatusDone, "更新文件记录", nil
+}
+
+// create 为外部写入的对象创建文件记录，记在配置的用户名下
+func (in *Ingestor) create(tx *gorm.DB, event *models.ObjectEvent, key string) (string, string, error) {
+	var ossConfig models.OSSConfig
+	if err := tx.Where("is_default = ?", true).First(&ossConfig).Error; err != nil {
+		return "", "", fmt.Errorf("获取默认存储配置失败: %w", err)
+	}
+	expireTime := ossConfig.URLExpireTime
+	if expireTime <= 0 {
+		expireTime = 24 * 3600 // 默认24小时
+	}
+	file := models.OSSFile{
+		ConfigID:         ossConfig.ID,
+		Filename:         key,
+		OriginalFilename: path.Base(key),
+		FileSize:         event.Size,
+		StorageType:      ossConfig.StorageType,
+		Bucket:           event.Bucket,
+		ObjectKey:        key,
+		UploaderID:       in.cfg.UploaderID,
+		ExpiresAt:        time.Now().Add(time.Duration(expireTime) * time.Second),
+		Status:           "ACTIVE",
+		ScanStatus:       scan.InitialStatus(),
+		ETag:             event.ETag,
+	}
+	if err := tx.Create(&file).Error; err != nil {
+		return "", "", fmt.Errorf("创建文件记录失败: %w", err)
+	}
+	if file.ScanStatus == models.ScanStatusPending {
+		scan.Notify()
+	}
+	event.FileID = file.ID
+	return models.ObjectEventStatusDone, "创建文件记录", nil
+}
+
+// removed 对象被删除：删除事件发生前已存在的记录，之后重新上传产生的记录保持不变
+func (in *Ingestor) removed(tx *gorm.DB, event *models.ObjectEvent, key string) (string, string, error) {
+	var files []models.OSSFile
+	if err := tx.Where("bucket = ? AND object_key = ? AND status IN ? AND created_at <= ?",
+		event.Bucket, key, trackedStatuses, event.EventTime).Find(&files).Error; err != nil {
+		return "", "", err
+	}
+	if len(files) == 0 {
+		return models.ObjectEventStatusIgnored, "没有对应的文件记录", nil
+	}
+	for i := range files {
+		if err := tx.Delete(&files[i]).Error; err != nil {
+			return "", "", fmt.Errorf("删除文件记录失败: %w", err)
+		}
+	}
+	event.FileID = files[0].ID
+	return models.ObjectEventStatusDone, fmt.Sprintf("删除 %d 条文件记录", len(files)), nil
+}
+
+// sameETag 比较 ETag，忽略引号和大小写
+func sameETag(a, b string) bool {
+	return strings.EqualFold(strings.Trim(a, `"`), strings.Trim(b, `"`))
+}
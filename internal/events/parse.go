@@ -0,0 +1,231 @@
+// Package events 接入存储服务的对象事件通知：解析阿里云 OSS、S3 事件及 MNS/SQS/SNS 等消息队列的封装，
+// 事件保存后延迟处理，创建、更新或删除对应的文件记录，使外部写入存储桶的对象近实时地出现在系统中
+package events
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/function"
+)
+
+// 事件来源
+const (
+	SourceAliyun = "aliyun"
+	SourceS3     = "s3"
+)
+
+// maxEnvelopeDepth 消息队列封装的最大嵌套层数
+const maxEnvelopeDepth = 4
+
+var (
+	// ErrUnsupportedPayload 无法识别的事件格式
+	ErrUnsupportedPayload = errors.New("无法识别的事件格式")
+	// ErrConfirmation 主题订阅确认请求，需要访问其中的地址完成订阅
+	ErrConfirmation = errors.New("收到订阅确认请求")
+)
+
+// s3Event S3 事件通知
+type s3Event struct {
+	Records []struct {
+		EventName string `json:"eventName"`
+		EventTime string `json:"eventTime"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"`
+				Size int64  `json:"size"`
+				ETag string `json:"eTag"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// payload 识别请求体的格式：阿里云事件有 events，S3 事件有 Records，其余为消息队列的封装
+// SNS/MNS 主题推送的事件在 Message 中，SQS 消息在 Body 中，MNS 队列消息在 MessageBody 中，
+// SQS ReceiveMessage 的响应在 Messages 中
+type payload struct {
+	Events       json.RawMessage   `json:"events"`
+	Records      json.RawMessage   `json:"Records"`
+	Event        string            `json:"Event"` // S3 配置通知时发送的 s3:TestEvent
+	Type         string            `json:"Type"`  // SNS 消息类型
+	SubscribeURL string            `json:"SubscribeURL"`
+	Message      *string           `json:"Message"`
+	Body         *string           `json:"Body"`
+	MessageBody  *string           `json:"MessageBody"`
+	Messages     []json.RawMessage `json:"Messages"`
+}
+
+// xmlPayload MNS 的 XML 消息，队列消息在 MessageBody 中，主题推送在 Message 中
+type xmlPayload struct {
+	Message     string `xml:"Message"`
+	MessageBody string `xml:"MessageBody"`
+}
+
+// Parse 解析事件通知的请求体或消息队列中的消息，返回其中对象创建和删除的事件
+// 其他事件（如修改元数据、归档解冻）和 S3 测试事件被忽略
+func Parse(body []byte) ([]models.ObjectEvent, error) {
+	return parse(body, 0)
+}
+
+func parse(body []byte, depth int) ([]models.ObjectEvent, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || depth > maxEnvelopeDepth {
+		return nil, ErrUnsupportedPayload
+	}
+
+	switch body[0] {
+	case '{':
+		return parseJSON(body, depth)
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedPayload, err)
+		}
+		return parseAll(items, depth)
+	case '<':
+		var p xmlPayload
+		if err := xml.Unmarshal(body, &p); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedPayload, err)
+		}
+		if p.MessageBody != "" {
+			return parse([]byte(p.MessageBody), depth+1)
+		}
+		if p.Message != "" {
+			return parse([]byte(p.Message), depth+1)
+		}
+		return nil, ErrUnsupportedPayload
+	default:
+		// MNS 默认以 Base64 编码消息内容
+		decoded, err := base64.StdEncoding.DecodeString(string(body))
+		if err != nil {
+			return nil, ErrUnsupportedPayload
+		}
+		return parse(decoded, depth+1)
+	}
+}
+
+func parseJSON(body []byte, depth int) ([]models.ObjectEvent, error) {
+	var p payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedPayload, err)
+	}
+
+	switch {
+	case p.Events != nil:
+		var event function.OSSEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedPayload, err)
+		}
+		return fromAliyun(event), nil
+	case p.Records != nil:
+		var event s3Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedPayload, err)
+		}
+		return fromS3(event), nil
+	case p.Event == "s3:TestEvent":
+		return nil, nil
+	case p.Type == "SubscriptionConfirmation" || p.Type == "UnsubscribeConfirmation":
+		return nil, fmt.Errorf("%w: %s", ErrConfirmation, p.SubscribeURL)
+	case p.Message != nil:
+		return parse([]byte(*p.Message), depth+1)
+	case p.Body != nil:
+		return parse([]byte(*p.Body), depth+1)
+	case p.MessageBody != nil:
+		return parse([]byte(*p.MessageBody), depth+1)
+	case p.Messages != nil:
+		return parseAll(p.Messages, depth)
+	}
+	return nil, ErrUnsupportedPayload
+}
+
+func parseAll(items []json.RawMessage, depth int) ([]models.ObjectEvent, error) {
+	var events []models.ObjectEvent
+	for _, item := range items {
+		parsed, err := parse(item, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, parsed...)
+	}
+	return events, nil
+}
+
+func fromAliyun(event function.OSSEvent) []models.ObjectEvent {
+	var events []models.ObjectEvent
+	for _, e := range event.Events {
+		eventType := typeOf(e.EventName)
+		if eventType == "" {
+			continue
+		}
+		events = append(events, models.ObjectEvent{
+			Source:    SourceAliyun,
+			EventName: e.EventName,
+			Type:      eventType,
+			Bucket:    e.OSS.Bucket.Name,
+			ObjectKey: e.OSS.Object.Key,
+			Size:      e.OSS.Object.Size,
+			ETag:      e.OSS.Object.ETag,
+			EventTime: parseTime(e.EventTime),
+		})
+	}
+	return events
+}
+
+func fromS3(event s3Event) []models.ObjectEvent {
+	var events []models.ObjectEvent
+	for _, r := range event.Records {
+		eventType := typeOf(r.EventName)
+		if eventType == "" {
+			continue
+		}
+		// S3 事件中的对象键经过 URL 编码，空格编码为 +
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			key = r.S3.Object.Key
+		}
+		events = append(events, models.ObjectEvent{
+			Source:    SourceS3,
+			EventName: r.EventName,
+			Type:      eventType,
+			Bucket:    r.S3.Bucket.Name,
+			ObjectKey: key,
+			Size:      r.S3.Object.Size,
+			ETag:      r.S3.Object.ETag,
+			EventTime: parseTime(r.EventTime),
+		})
+	}
+	return events
+}
+
+// typeOf 按事件名称判断事件类型，阿里云为 ObjectCreated:PutObject，S3 为 ObjectCreated:Put 或 s3:ObjectCreated:Put
+func typeOf(eventName string) string {
+	name := strings.TrimPrefix(eventName, "s3:")
+	switch {
+	case strings.HasPrefix(name, "ObjectCreated:"):
+		return models.ObjectEventCreated
+	case strings.HasPrefix(name, "ObjectRemoved:"):
+		return models.ObjectEventRemoved
+	}
+	return ""
+}
+
+// parseTime 解析事件时间，格式不正确时使用当前时间
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
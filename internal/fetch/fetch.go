@@ -0,0 +1,246 @@
+// Package fetch 从外部 URL 拉取内容，在建立连接时校验目标地址以防止 SSRF
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxRedirects   = 5
+	responseHeaderTimeout = 30 * time.Second
+)
+
+var (
+	// ErrForbiddenAddress 目标地址属于被禁止访问的网段
+	ErrForbiddenAddress = errors.New("fetch: destination address is not allowed")
+	// ErrTooLarge 远程内容超过大小限制
+	ErrTooLarge = errors.New("fetch: content exceeds size limit")
+)
+
+// 默认禁止访问的网段：IsPrivate/IsLoopback 等标准库判断之外的保留地址
+var reservedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",       // 本网络
+	"100.64.0.0/10",   // 运营商级 NAT
+	"192.0.0.0/24",    // IETF 协议分配
+	"192.0.2.0/24",    // 文档示例
+	"198.18.0.0/15",   // 基准测试
+	"198.51.100.0/24", // 文档示例
+	"203.0.113.0/24",  // 文档示例
+	"240.0.0.0/4",     // 保留
+	"64:ff9b::/96",    // NAT64，可映射到任意 IPv4 地址
+	"2001:db8::/32",   // 文档示例
+)
+
+// Options 客户端配置
+type Options struct {
+	AllowPrivate    bool     // 允许访问私有、回环和保留地址
+	AllowedNetworks []string // 即使属于私有网段也允许访问的网段（CIDR）
+	MaxRedirects    int
+}
+
+// Client 只能访问公网地址（或显式放行网段）的 HTTP 客户端
+type Client struct {
+	http         *http.Client
+	allowPrivate bool
+	allowed      []*net.IPNet
+}
+
+// NewClient 创建客户端，AllowedNetworks 格式错误时返回错误
+func NewClient(opts Options) (*Client, error) {
+	c := &Client{allowPrivate: opts.AllowPrivate}
+	for _, cidr := range opts.AllowedNetworks {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("fetch: invalid allowed network %q: %w", cidr, err)
+		}
+		c.allowed = append(c.allowed, network)
+	}
+
+	maxRedirects := opts.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+
+	// 在 Control 中校验的是 DNS 解析后实际连接的地址，可以防止 DNS 重绑定；
+	// 不使用环境变量中的代理，否则校验的会是代理地址
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   c.control,
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	c.http = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("fetch: stopped after %d redirects", maxRedirects)
+			}
+			return checkScheme(req.URL)
+		},
+	}
+	return c, nil
+}
+
+// control 在建立连接前校验目标 IP
+func (c *Client) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	if !c.Allowed(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// Allowed 判断是否允许连接 ip
+func (c *Client) Allowed(ip net.IP) bool {
+	if c.allowPrivate || !IsPrivate(ip) {
+		return true
+	}
+	for _, network := range c.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPrivate 判断 ip 是否属于私有、回环、链路本地、组播或其他保留地址
+func IsPrivate(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get 请求 rawURL，只接受 http/https 和 2xx 响应，调用方负责关闭响应体
+func (c *Client) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: invalid url: %w", err)
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+	if u.User != nil {
+		return nil, errors.New("fetch: credentials in url are not allowed")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "ossmanager-import/1.0")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch: unexpected status %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("fetch: unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("fetch: missing host")
+	}
+	return nil
+}
+
+// Filename 从 Content-Disposition 或最终请求的 URL 路径推断文件名，无法推断时返回空字符串
+func Filename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := cleanName(params["filename"]); name != "" {
+			return name
+		}
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		return cleanName(resp.Request.URL.Path)
+	}
+	return ""
+}
+
+// cleanName 只保留路径的最后一段
+func cleanName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// LimitReader 读取超过 n 字节时返回 ErrTooLarge，而不是像 io.LimitReader 那样静默截断
+func LimitReader(r io.Reader, n int64) io.Reader {
+	return &limitReader{r: r, remaining: n}
+}
+
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+	// 多读一个字节用于判断是否超出限制
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrTooLarge
+	}
+	return n, err
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
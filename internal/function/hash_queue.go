@@ -0,0 +1,206 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/lease"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultMaxAttempts  = 5
+	defaultLeaseTimeout = 300 // 秒
+	defaultPollInterval = 5   // 秒
+	defaultRetryBackoff = 30  // 秒
+	maxRetryBackoff     = time.Hour
+	maxErrorLength      = 1000
+)
+
+// ErrJobRunning 任务正在执行，不能重试
+var ErrJobRunning = errors.New("任务正在执行中")
+
+// claimSQL 领取一个可执行的任务：等待中且已到执行时间，或租约已过期（领取它的实例崩溃或失联）
+// SKIP LOCKED 使多个实例并发领取时互不阻塞，也不会领到同一个任务
+const claimSQL = `
+UPDATE hash_jobs
+SET status = ?, lease_owner = ?, leased_until = ?, attempts = attempts + 1, updated_at = ?
+WHERE id = (
+	SELECT id FROM hash_jobs
+	WHERE (status = ? AND run_at <= ?) OR (status = ? AND leased_until < ?)
+	ORDER BY run_at, id
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *`
+
+// adoptSQL 为处于计算中但没有任务的文件补建任务，例如升级前内存队列中丢失的任务
+const adoptSQL = `
+INSERT INTO hash_jobs (created_at, updated_at, file_id, status, run_at, attempts)
+SELECT ?, ?, id, ?, ?, 0 FROM oss_files
+WHERE md5_status = ? AND deleted_at IS NULL
+ON CONFLICT (file_id) DO NOTHING`
+
+// hashQueue 基于 hash_jobs 表的持久化任务队列
+// 所有状态变更都带上 lease_owner 条件，租约已被其他实例接手时本实例的写入不会生效
+type hashQueue struct {
+	lease        lease.Lease
+	maxAttempts  int
+	pollInterval time.Duration
+	retryBackoff time.Duration
+}
+
+func newHashQueue(cfg config.HashConfig) *hashQueue {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.LeaseTimeout <= 0 {
+		cfg.LeaseTimeout = defaultLeaseTimeout
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	return &hashQueue{
+		lease:        lease.New(time.Duration(cfg.LeaseTimeout)*time.Second, models.HashJobStatusRunning),
+		maxAttempts:  cfg.MaxAttempts,
+		pollInterval: time.Duration(cfg.PollInterval) * time.Second,
+		retryBackoff: time.Duration(cfg.RetryBackoff) * time.Second,
+	}
+}
+
+// enqueue 为文件创建任务；已有任务时重置为立即执行，正在执行的任务保持不变
+func (q *hashQueue) enqueue(db *gorm.DB, fileID uint) error {
+	now := time.Now()
+	job := models.HashJob{
+		CreatedAt: now,
+		UpdatedAt: now,
+		FileID:    fileID,
+		Status:    models.HashJobStatusPending,
+		RunAt:     now,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "file_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"status":       models.HashJobStatusPending,
+			"run_at":       now,
+			"attempts":     0,
+			"last_error":   "",
+			"lease_owner":  "",
+			"leased_until": nil,
+			"updated_at":   now,
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Neq{Column: clause.Column{Table: "hash_jobs", Name: "status"}, Value: models.HashJobStatusRunning},
+		}},
+	}).Create(&job).Error
+}
+
+// adoptOrphans 为计算中但没有任务的文件补建任务，返回补建数量
+func (q *hashQueue) adoptOrphans(db *gorm.DB) (int64, error) {
+	now := time.Now()
+	result := db.Exec(adoptSQL, now, now, models.HashJobStatusPending, now, models.MD5StatusCalculating)
+	return result.RowsAffected, result.Error
+}
+
+// claim 领取一个任务，没有可执行的任务时返回 nil
+func (q *hashQueue) claim(db *gorm.DB) (*models.HashJob, error) {
+	now := time.Now()
+	var job models.HashJob
+	result := db.Raw(claimSQL,
+		models.HashJobStatusRunning, q.lease.Owner, q.lease.Until(), now,
+		models.HashJobStatusPending, now, models.HashJobStatusRunning, now,
+	).Scan(&job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// owned 限定为本实例仍持有租约的任务
+func (q *hashQueue) owned(db *gorm.DB, job *models.HashJob) *gorm.DB {
+	return q.lease.Owned(db, &models.HashJob{}, job.ID)
+}
+
+// keepAlive 每隔三分之一租约时长续约一次，直到 ctx 结束；续约失败说明租约已丢失，调用 lost
+func (q *hashQueue) keepAlive(ctx context.Context, db *gorm.DB, job *models.HashJob, lost context.CancelFunc) {
+	ticker := time.NewTicker(q.lease.Timeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			held, err := q.lease.Renew(db, &models.HashJob{}, job.ID)
+			if err != nil {
+				logger.Warn("摘要任务续约失败", zap.Uint("job_id", job.ID), zap.Error(err))
+				continue
+			}
+			if !held {
+				lost()
+				return
+			}
+		}
+	}
+}
+
+// complete 标记任务完成
+func (q *hashQueue) complete(db *gorm.DB, job *models.HashJob) {
+	if err := q.owned(db, job).Updates(lease.Released(map[string]interface{}{
+		"status":     models.HashJobStatusDone,
+		"last_error": "",
+	})).Error; err != nil {
+		logger.Error("更新摘要任务状态失败", zap.Uint("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// release 放弃租约并立即交还任务，本次执行不计入次数
+func (q *hashQueue) release(db *gorm.DB, job *models.HashJob) {
+	if err := q.owned(db, job).Updates(lease.Released(map[string]interface{}{
+		"status":   models.HashJobStatusPending,
+		"attempts": gorm.Expr("attempts - 1"),
+		"run_at":   time.Now(),
+	})).Error; err != nil {
+		logger.Error("释放摘要任务失败", zap.Uint("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// fail 记录失败原因；未超过次数上限时安排重试，否则进入 DEAD 状态，返回是否进入 DEAD 状态
+func (q *hashQueue) fail(db *gorm.DB, job *models.HashJob, cause error) (bool, error) {
+	reason := utils.Truncate(cause.Error(), maxErrorLength)
+	dead := job.Attempts >= q.maxAttempts
+
+	updates := lease.Released(map[string]interface{}{
+		"status":     models.HashJobStatusDead,
+		"last_error": reason,
+	})
+	if !dead {
+		updates["status"] = models.HashJobStatusPending
+		updates["run_at"] = time.Now().Add(backoff(q.retryBackoff, job.Attempts))
+	}
+	return dead, q.owned(db, job).Updates(updates).Error
+}
+
+// backoff 第 attempt 次失败后的等待时间：base * 2^(attempt-1)，最长 maxRetryBackoff
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return min(d, maxRetryBackoff)
+}
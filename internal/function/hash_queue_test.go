@@ -0,0 +1,41 @@
+package function
+
+import (
+	"testing"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/config"
+)
+
+func TestBackoff(t *testing.T) {
+	base := 30 * time.Second
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour}, // 64 分钟超过上限
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(base, tt.attempt); got != tt.want {
+			t.Errorf("backoff(%v, %d) = %v, want %v", base, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestNewHashQueueDefaults(t *testing.T) {
+	q := newHashQueue(config.HashConfig{})
+	if q.maxAttempts != defaultMaxAttempts ||
+		q.lease.Timeout != defaultLeaseTimeout*time.Second ||
+		q.pollInterval != defaultPollInterval*time.Second ||
+		q.retryBackoff != defaultRetryBackoff*time.Second {
+		t.Errorf("newHashQueue() defaults = %+v", q)
+	}
+	if q.lease.Owner == "" || q.lease.Owner == newHashQueue(config.HashConfig{}).lease.Owner {
+		t.Errorf("lease owner %q should be non-empty and unique per queue", q.lease.Owner)
+	}
+}
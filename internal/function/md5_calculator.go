@@ -14,9 +14,11 @@ import (
 	"github.com/myysophia/ossmanager/internal/config"
 	"github.com/myysophia/ossmanager/internal/db"
 	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
 	"github.com/myysophia/ossmanager/internal/logger"
 	ossService "github.com/myysophia/ossmanager/internal/oss"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // OSSEvent 阿里云OSS事件结构
@@ -51,26 +53,37 @@ type MD5CalculationRequest struct {
 	FileID     uint   `json:"file_id"`
 }
 
-// MD5Calculator MD5计算器
+// MD5Calculator 文件摘要计算器
+// 下载对象并一次性计算 MD5 及配置的其他算法（SHA-1、SHA-256、CRC64-ECMA），结果保存到 file_hashes 表
+// 计算任务保存在 hash_jobs 表中，重启后不会丢失，多个实例可以同时执行
 type MD5Calculator struct {
 	storageFactory *ossService.DefaultStorageFactory
-	calculateChan  chan *models.OSSFile
 	workers        int
+	algorithms     []string
+	queue          *hashQueue
+	wakeCh         chan struct{}
 	wg             sync.WaitGroup
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
 
-// NewMD5Calculator 创建MD5计算器
-func NewMD5Calculator(storageFactory *ossService.DefaultStorageFactory, workers int) *MD5Calculator {
+// NewMD5Calculator 创建摘要计算器，cfg.Algorithms 为空时计算所有支持的算法
+func NewMD5Calculator(storageFactory *ossService.DefaultStorageFactory, workers int, cfg config.HashConfig) *MD5Calculator {
 	if workers <= 0 {
 		workers = 3 // 默认3个工作协程
 	}
+	algs, err := hashing.ParseAlgorithms(cfg.Algorithms)
+	if err != nil {
+		logger.Warn("摘要算法配置无效，计算所有支持的算法", zap.Strings("algorithms", cfg.Algorithms), zap.Error(err))
+		algs = hashing.All
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	calculator := &MD5Calculator{
 		storageFactory: storageFactory,
-		calculateChan:  make(chan *models.OSSFile, 100),
 		workers:        workers,
+		algorithms:     algs,
+		queue:          newHashQueue(cfg),
+		wakeCh:         make(chan struct{}, 1),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
@@ -78,156 +91,270 @@ func NewMD5Calculator(storageFactory *ossService.DefaultStorageFactory, workers
 	return calculator
 }
 
-// Start 启动MD5计算器
+// Algorithms 返回计算的摘要算法
+func (c *MD5Calculator) Algorithms() []string {
+	return c.algorithms
+}
+
+// Start 启动摘要计算器
 func (c *MD5Calculator) Start() {
+	if n, err := c.queue.adoptOrphans(db.GetDB()); err != nil {
+		logger.Warn("恢复计算中文件的摘要任务失败", zap.Error(err))
+	} else if n > 0 {
+		logger.Info("已为计算中但没有任务的文件创建摘要任务", zap.Int64("count", n))
+	}
+
 	for i := 0; i < c.workers; i++ {
 		c.wg.Add(1)
 		go c.worker(i)
 	}
-	logger.Info("MD5计算器已启动", zap.Int("workers", c.workers))
+	logger.Info("摘要计算器已启动",
+		zap.Int("workers", c.workers),
+		zap.Strings("algorithms", c.algorithms),
+		zap.String("owner", c.queue.lease.Owner),
+	)
 }
 
-// Stop 停止MD5计算器
+// Stop 停止摘要计算器，正在执行的任务会被释放，由其他实例或下次启动后继续执行
 func (c *MD5Calculator) Stop() {
 	c.cancel()
-	close(c.calculateChan)
 	c.wg.Wait()
-	logger.Info("MD5计算器已停止")
+	logger.Info("摘要计算器已停止")
+}
+
+// Notify 唤醒空闲的工作协程立即领取任务
+func (c *MD5Calculator) Notify() {
+	select {
+	case c.wakeCh <- struct{}{}:
+	default:
+	}
 }
 
-// TriggerCalculation 触发MD5计算
+// TriggerCalculation 触发摘要计算，所有配置的算法都已计算时直接返回
 func (c *MD5Calculator) TriggerCalculation(file *models.OSSFile) error {
-	// 检查文件是否已有MD5
+	sums, err := hashing.Load(db.GetDB(), file.ID)
+	if err != nil {
+		return fmt.Errorf("获取文件摘要失败: %w", err)
+	}
 	if file.MD5 != "" {
+		sums[hashing.MD5] = file.MD5
+	}
+	if len(hashing.Missing(sums, c.algorithms)) == 0 {
 		return nil
 	}
 
-	// 更新文件状态为计算中
-	fileUpdate := &models.OSSFile{
-		MD5Status: models.MD5StatusCalculating,
-	}
-	if err := db.GetDB().Model(&models.OSSFile{}).Where("id = ?", file.ID).Updates(fileUpdate).Error; err != nil {
-		logger.Error("更新文件MD5状态失败", zap.Uint("id", file.ID), zap.Error(err))
+	err = db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OSSFile{}).Where("id = ?", file.ID).
+			Update("md5_status", models.MD5StatusCalculating).Error; err != nil {
+			return fmt.Errorf("更新文件MD5状态失败: %w", err)
+		}
+		return c.queue.enqueue(tx, file.ID)
+	})
+	if err != nil {
 		return err
 	}
+	c.Notify()
+	return nil
+}
+
+// RetryJob 重新执行摘要任务，用于人工处理 DEAD 状态的任务
+func (c *MD5Calculator) RetryJob(jobID uint) (*models.HashJob, error) {
+	var job models.HashJob
+	if err := db.GetDB().First(&job, jobID).Error; err != nil {
+		return nil, err
+	}
+	if job.Status == models.HashJobStatusRunning {
+		return nil, ErrJobRunning
+	}
 
-	// 将文件放入计算队列
-	select {
-	case c.calculateChan <- file:
-		return nil
-	case <-time.After(5 * time.Second):
-		// 超时处理
-		fileUpdate := &models.OSSFile{
-			MD5Status: models.MD5StatusFailed,
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OSSFile{}).Where("id = ?", job.FileID).
+			Update("md5_status", models.MD5StatusCalculating).Error; err != nil {
+			return err
 		}
-		db.GetDB().Model(&models.OSSFile{}).Where("id = ?", file.ID).Updates(fileUpdate)
-		return errors.New("MD5计算队列已满，请稍后重试")
+		return c.queue.enqueue(tx, job.FileID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.Notify()
+
+	if err := db.GetDB().First(&job, jobID).Error; err != nil {
+		return nil, err
 	}
+	return &job, nil
 }
 
-// worker MD5计算工作协程
+// worker 摘要计算工作协程，持续领取任务直到队列为空，然后等待轮询或唤醒
 func (c *MD5Calculator) worker(id int) {
 	defer c.wg.Done()
-	logger.Info("MD5计算工作协程启动", zap.Int("worker_id", id))
+	logger.Info("摘要计算工作协程启动", zap.Int("worker_id", id))
+
+	ticker := time.NewTicker(c.queue.pollInterval)
+	defer ticker.Stop()
 
 	for {
+		for c.ctx.Err() == nil && c.runNext() {
+		}
 		select {
 		case <-c.ctx.Done():
-			logger.Info("MD5计算工作协程停止", zap.Int("worker_id", id))
+			logger.Info("摘要计算工作协程停止", zap.Int("worker_id", id))
 			return
-		case file, ok := <-c.calculateChan:
-			if !ok {
-				logger.Info("MD5计算工作协程停止", zap.Int("worker_id", id))
-				return
-			}
-
-			// 计算文件MD5
-			c.calculateFileMD5(file)
+		case <-ticker.C:
+		case <-c.wakeCh:
 		}
 	}
 }
 
-// calculateFileMD5 计算文件MD5
-func (c *MD5Calculator) calculateFileMD5(file *models.OSSFile) {
-	logger.Info("开始计算文件MD5", zap.Uint("id", file.ID), zap.String("object_key", file.ObjectKey))
+// runNext 领取并执行一个任务，没有可执行的任务时返回 false
+func (c *MD5Calculator) runNext() bool {
+	job, err := c.queue.claim(db.GetDB())
+	if err != nil {
+		logger.Error("领取摘要任务失败", zap.Error(err))
+		return false
+	}
+	if job == nil {
+		return false
+	}
+	c.runJob(job)
+	return true
+}
 
-	// 更新状态为计算中
-	updateStatus := func(status string, md5 string) {
-		fileUpdate := &models.OSSFile{
-			MD5Status: status,
-			MD5:       md5,
-		}
-		if err := db.GetDB().Model(&models.OSSFile{}).Where("id = ?", file.ID).Updates(fileUpdate).Error; err != nil {
-			logger.Error("更新文件MD5状态失败", zap.Uint("id", file.ID), zap.Error(err))
+// runJob 执行任务；执行期间定期续约，租约丢失时放弃本次结果
+func (c *MD5Calculator) runJob(job *models.HashJob) {
+	database := db.GetDB()
+
+	var file models.OSSFile
+	if err := database.First(&file, job.FileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Info("文件已删除，跳过摘要任务", zap.Uint("job_id", job.ID), zap.Uint("file_id", job.FileID))
+			c.queue.complete(database, job)
+			return
 		}
+		c.fail(job, fmt.Errorf("获取文件信息失败: %w", err))
+		return
 	}
 
-	// 获取配置
-	storage, err := c.storageFactory.GetStorageService(file.StorageType)
-	if err != nil {
-		logger.Error("获取存储提供商失败", zap.String("storage_type", file.StorageType), zap.Error(err))
-		updateStatus(models.MD5StatusFailed, "")
+	// 租约多次过期（例如执行中实例崩溃）同样计入执行次数
+	if job.Attempts > c.queue.maxAttempts {
+		c.fail(job, fmt.Errorf("执行次数超过上限 %d，最近一次错误: %s", c.queue.maxAttempts, job.LastError))
 		return
 	}
 
-	// 下载文件并计算MD5
-	reader, err := storage.GetObject(file.ObjectKey)
-	if err != nil {
-		logger.Error("下载文件失败", zap.String("object_key", file.ObjectKey), zap.Error(err))
-		updateStatus(models.MD5StatusFailed, "")
-		return
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+	go c.queue.keepAlive(ctx, database, job, cancel)
+
+	logger.Info("开始计算文件摘要",
+		zap.Uint("job_id", job.ID),
+		zap.Uint("id", file.ID),
+		zap.String("object_key", file.ObjectKey),
+		zap.Int("attempt", job.Attempts))
+
+	sums, err := c.computeHashes(ctx, &file)
+	if err == nil {
+		err = hashing.Save(database, file.ID, sums)
 	}
-	defer reader.Close()
 
-	// 计算MD5
-	hash := md5.New()
-	if _, err := io.Copy(hash, reader); err != nil {
-		logger.Error("计算MD5失败", zap.String("object_key", file.ObjectKey), zap.Error(err))
-		updateStatus(models.MD5StatusFailed, "")
+	switch {
+	case c.ctx.Err() != nil:
+		// 实例正在停止，释放任务由其他实例接手，不计入执行次数
+		c.queue.release(database, job)
+		return
+	case ctx.Err() != nil:
+		logger.Warn("摘要任务租约已丢失，放弃本次结果", zap.Uint("job_id", job.ID), zap.Uint("file_id", file.ID))
+		return
+	case err != nil:
+		c.fail(job, err)
 		return
 	}
 
-	// 转换为十六进制字符串
-	md5Str := hex.EncodeToString(hash.Sum(nil))
-	logger.Info("文件MD5计算完成",
+	c.queue.complete(database, job)
+	logger.Info("文件摘要计算完成",
 		zap.Uint("id", file.ID),
 		zap.String("object_key", file.ObjectKey),
-		zap.String("md5", md5Str))
-
-	// 更新MD5
-	updateStatus(models.MD5StatusCompleted, md5Str)
+		zap.Any("hashes", sums))
 }
 
-// CalculateMD5Sync 同步计算OSS文件的MD5
-func (c *MD5Calculator) CalculateMD5Sync(file *models.OSSFile) error {
-	logger.Info("开始同步计算文件MD5", zap.Uint("file_id", file.ID))
+// fail 记录任务失败：未超过次数上限时按退避时间重试，否则进入 DEAD 状态并将文件 MD5 状态标记为 FAILED
+func (c *MD5Calculator) fail(job *models.HashJob, cause error) {
+	database := db.GetDB()
+	dead, err := c.queue.fail(database, job, cause)
+	if err != nil {
+		logger.Error("更新摘要任务状态失败", zap.Uint("job_id", job.ID), zap.Error(err))
+		return
+	}
+	if !dead {
+		logger.Warn("计算文件摘要失败，稍后重试",
+			zap.Uint("job_id", job.ID),
+			zap.Uint("file_id", job.FileID),
+			zap.Int("attempt", job.Attempts),
+			zap.Error(cause))
+		return
+	}
 
-	// 获取存储提供商
+	logger.Error("计算文件摘要失败，重试次数已用尽",
+		zap.Uint("job_id", job.ID),
+		zap.Uint("file_id", job.FileID),
+		zap.Int("attempts", job.Attempts),
+		zap.Error(cause))
+	if err := database.Model(&models.OSSFile{}).Where("id = ?", job.FileID).
+		Update("md5_status", models.MD5StatusFailed).Error; err != nil {
+		logger.Error("更新文件MD5状态失败", zap.Uint("id", job.FileID), zap.Error(err))
+	}
+}
+
+// computeHashes 下载对象并计算所有配置的摘要，ctx 取消时中断读取
+func (c *MD5Calculator) computeHashes(ctx context.Context, file *models.OSSFile) (map[string]string, error) {
 	storage, err := c.storageFactory.GetStorageService(file.StorageType)
 	if err != nil {
-		return fmt.Errorf("获取存储提供商失败: %w", err)
+		return nil, fmt.Errorf("获取存储提供商失败: %w", err)
 	}
 
-	// 下载文件并计算MD5
-	reader, err := storage.GetObject(file.ObjectKey)
+	reader, err := openObject(storage, file)
 	if err != nil {
-		return fmt.Errorf("获取文件内容失败: %w", err)
+		return nil, fmt.Errorf("下载文件失败: %w", err)
 	}
 	defer reader.Close()
+	stop := context.AfterFunc(ctx, func() { reader.Close() })
+	defer stop()
 
-	// 计算MD5
-	hash := md5.New()
-	if _, err := io.Copy(hash, reader); err != nil {
-		return fmt.Errorf("计算MD5时发生错误: %w", err)
+	set := hashing.NewSet(c.algorithms...)
+	if _, err := io.Copy(set, reader); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("读取文件内容失败: %w", err)
 	}
+	return set.Sums(), nil
+}
 
-	md5Value := hex.EncodeToString(hash.Sum(nil))
-	logger.Info("文件MD5计算完成",
+// openObject 从文件记录所在的存储桶读取对象，存储桶未配置区域映射时使用默认区域
+func openObject(storage ossService.StorageService, file *models.OSSFile) (io.ReadCloser, error) {
+	if file.Bucket == "" {
+		return storage.GetObject(file.ObjectKey)
+	}
+	var mapping models.RegionBucketMapping
+	regionCode := ""
+	if err := db.GetDB().Where("bucket_name = ?", file.Bucket).First(&mapping).Error; err == nil {
+		regionCode = mapping.RegionCode
+	}
+	return storage.GetObjectFromBucket(file.ObjectKey, regionCode, file.Bucket)
+}
+
+// CalculateMD5Sync 同步计算OSS文件的摘要
+func (c *MD5Calculator) CalculateMD5Sync(file *models.OSSFile) error {
+	logger.Info("开始同步计算文件摘要", zap.Uint("file_id", file.ID))
+
+	sums, err := c.computeHashes(context.Background(), file)
+	if err != nil {
+		return err
+	}
+	logger.Info("文件摘要计算完成",
 		zap.Uint("file_id", file.ID),
-		zap.String("md5", md5Value))
+		zap.Any("hashes", sums))
 
-	// 更新数据库中的MD5值
-	return c.UpdateFileMD5(file.ID, md5Value)
+	return hashing.Save(db.GetDB(), file.ID, sums)
 }
 
 // UpdateFileMD5 更新数据库中文件的MD5值
@@ -0,0 +1,170 @@
+// Package hashing 计算并保存文件的多种摘要（MD5、SHA-1、SHA-256、CRC64-ECMA）
+package hashing
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/myysophia/ossmanager/internal/config"
+)
+
+// 支持的摘要算法
+const (
+	MD5    = "md5"
+	SHA1   = "sha1"
+	SHA256 = "sha256"
+	// CRC64 CRC-64/ECMA-182，与阿里云 OSS 返回的 x-oss-hash-crc64ecma 一致，以十进制无符号整数表示
+	CRC64 = "crc64ecma"
+)
+
+// All 所有支持的算法
+var All = []string{MD5, SHA1, SHA256, CRC64}
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// aliases 算法名的常见写法
+var aliases = map[string]string{
+	"md5":        MD5,
+	"sha1":       SHA1,
+	"sha-1":      SHA1,
+	"sha256":     SHA256,
+	"sha-256":    SHA256,
+	"crc64":      CRC64,
+	"crc64ecma":  CRC64,
+	"crc64-ecma": CRC64,
+}
+
+// ParseAlgorithm 解析算法名，大小写和连字符不敏感
+func ParseAlgorithm(name string) (string, error) {
+	if alg, ok := aliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return alg, nil
+	}
+	return "", fmt.Errorf("不支持的摘要算法: %s", name)
+}
+
+// ParseAlgorithms 解析配置的算法列表并去重，MD5 总是包含在内；为空时返回所有算法
+func ParseAlgorithms(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return append([]string(nil), All...), nil
+	}
+	algs := []string{MD5}
+	seen := map[string]bool{MD5: true}
+	for _, name := range names {
+		alg, err := ParseAlgorithm(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs, nil
+}
+
+// Configured 返回配置的摘要算法，配置未加载或无效时返回所有算法
+func Configured() []string {
+	var names []string
+	if cfg := config.GetConfig(); cfg != nil {
+		names = cfg.Hash.Algorithms
+	}
+	algs, err := ParseAlgorithms(names)
+	if err != nil {
+		return append([]string(nil), All...)
+	}
+	return algs
+}
+
+// NormalizeValue 将用户输入的摘要值转换为存储格式：十六进制摘要转为小写，CRC64 转为十进制
+// CRC64 也接受 0x 前缀的十六进制
+func NormalizeValue(alg, value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if alg == CRC64 {
+		var (
+			n   uint64
+			err error
+		)
+		if strings.HasPrefix(value, "0x") {
+			n, err = strconv.ParseUint(value[2:], 16, 64)
+		} else {
+			n, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return "", fmt.Errorf("无效的 CRC64 值: %s", value)
+		}
+		return strconv.FormatUint(n, 10), nil
+	}
+
+	size := map[string]int{MD5: md5.Size, SHA1: sha1.Size, SHA256: sha256.Size}[alg]
+	if len(value) != hex.EncodedLen(size) {
+		return "", fmt.Errorf("%s 摘要应为 %d 位十六进制", alg, hex.EncodedLen(size))
+	}
+	if _, err := hex.DecodeString(value); err != nil {
+		return "", fmt.Errorf("%s 摘要应为十六进制", alg)
+	}
+	return value, nil
+}
+
+func newHash(alg string) hash.Hash {
+	switch alg {
+	case SHA1:
+		return sha1.New()
+	case SHA256:
+		return sha256.New()
+	case CRC64:
+		return crc64.New(crc64Table)
+	default:
+		return md5.New()
+	}
+}
+
+// Set 同时计算多种摘要，写入的数据只读取一遍
+type Set struct {
+	algs   []string
+	hashes []hash.Hash
+	w      io.Writer
+}
+
+// NewSet 创建计算指定算法的摘要集合
+func NewSet(algs ...string) *Set {
+	s := &Set{algs: algs}
+	writers := make([]io.Writer, len(algs))
+	for i, alg := range algs {
+		h := newHash(alg)
+		s.hashes = append(s.hashes, h)
+		writers[i] = h
+	}
+	s.w = io.MultiWriter(writers...)
+	return s
+}
+
+// Write 将数据计入所有摘要
+func (s *Set) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// Reader 返回读取 r 的同时计算摘要的 reader
+func (s *Set) Reader(r io.Reader) io.Reader {
+	return io.TeeReader(r, s)
+}
+
+// Sums 返回已写入数据的摘要，键为算法名
+func (s *Set) Sums() map[string]string {
+	sums := make(map[string]string, len(s.algs))
+	for i, alg := range s.algs {
+		if alg == CRC64 {
+			sums[alg] = strconv.FormatUint(s.hashes[i].(hash.Hash64).Sum64(), 10)
+			continue
+		}
+		sums[alg] = hex.EncodeToString(s.hashes[i].Sum(nil))
+	}
+	return sums
+}
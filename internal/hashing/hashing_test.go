@@ -0,0 +1,58 @@
+package hashing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetSums(t *testing.T) {
+	s := NewSet(All...)
+	if _, err := s.Write([]byte("123456789")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	want := map[string]string{
+		MD5:    "25f9e794323b453885f5181f1b624d0b",
+		SHA1:   "f7c3bc1d808e04732adf679965ccc34ca7ae3441",
+		SHA256: "15e2b0d3c33891ebb0f1ef609ec419420c20e320ce94c65fbc8c3312448eb225",
+		CRC64:  "11051210869376104954",
+	}
+	if got := s.Sums(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Sums() = %v, want %v", got, want)
+	}
+}
+
+func TestParseAlgorithms(t *testing.T) {
+	got, err := ParseAlgorithms([]string{"SHA-256", "crc64", "sha256"})
+	if err != nil {
+		t.Fatalf("ParseAlgorithms() error = %v", err)
+	}
+	if want := []string{MD5, SHA256, CRC64}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAlgorithms() = %v, want %v", got, want)
+	}
+	if got, _ := ParseAlgorithms(nil); !reflect.DeepEqual(got, All) {
+		t.Errorf("ParseAlgorithms(nil) = %v, want %v", got, All)
+	}
+	if _, err := ParseAlgorithms([]string{"sha512"}); err == nil {
+		t.Error("ParseAlgorithms(sha512) should fail")
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		alg, in, want string
+		wantErr       bool
+	}{
+		{MD5, " 25F9E794323B453885F5181F1B624D0B ", "25f9e794323b453885f5181f1b624d0b", false},
+		{MD5, "25f9e794", "", true},
+		{SHA1, "zzc3bc1d808e04732adf679965ccc34ca7ae3441", "", true},
+		{CRC64, "0x995DC9BBDF1939FA", "11051210869376104954", false},
+		{CRC64, "11051210869376104954", "11051210869376104954", false},
+		{CRC64, "-1", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeValue(tt.alg, tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeValue(%s, %q) = %q, %v; want %q, err=%v", tt.alg, tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
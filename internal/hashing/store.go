@@ -0,0 +1,86 @@
+package hashing
+
+import (
+	"sort"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Save 保存文件的摘要，已存在的算法覆盖旧值；包含 MD5 时同步更新 OSSFile 的 MD5 字段
+func Save(db *gorm.DB, fileID uint, sums map[string]string) error {
+	if len(sums) == 0 {
+		return nil
+	}
+	algs := make([]string, 0, len(sums))
+	for alg := range sums {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+
+	records := make([]models.FileHash, 0, len(algs))
+	for _, alg := range algs {
+		records = append(records, models.FileHash{FileID: fileID, Algorithm: alg, Value: sums[alg]})
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "file_id"}, {Name: "algorithm"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at", "deleted_at"}),
+		}).Create(&records).Error; err != nil {
+			return err
+		}
+		if md5, ok := sums[MD5]; ok {
+			return tx.Model(&models.OSSFile{}).Where("id = ?", fileID).Updates(map[string]interface{}{
+				"md5":        md5,
+				"md5_status": models.MD5StatusCompleted,
+			}).Error
+		}
+		return nil
+	})
+}
+
+// Replace 用新的摘要替换文件的所有摘要，用于文件内容被覆盖的情况
+func Replace(db *gorm.DB, fileID uint, sums map[string]string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("file_id = ?", fileID).Delete(&models.FileHash{}).Error; err != nil {
+			return err
+		}
+		return Save(tx, fileID, sums)
+	})
+}
+
+// Load 读取文件的所有摘要，键为算法名
+// 早于摘要表写入的文件只有 OSSFile.MD5，调用方需要自行补充
+func Load(db *gorm.DB, fileID uint) (map[string]string, error) {
+	var records []models.FileHash
+	if err := db.Where("file_id = ?", fileID).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string, len(records))
+	for _, r := range records {
+		sums[r.Algorithm] = r.Value
+	}
+	return sums, nil
+}
+
+// Copy 将来源文件的摘要复制给内容相同的新文件，用于去重复制
+func Copy(db *gorm.DB, srcFileID, dstFileID uint) error {
+	sums, err := Load(db, srcFileID)
+	if err != nil {
+		return err
+	}
+	return Save(db, dstFileID, sums)
+}
+
+// Missing 返回 algs 中文件尚未计算的算法
+func Missing(sums map[string]string, algs []string) []string {
+	var missing []string
+	for _, alg := range algs {
+		if sums[alg] == "" {
+			missing = append(missing, alg)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,293 @@
+// Package janitor 清理存储端遗留的资源，例如中断后未完成的分片上传
+package janitor
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/tus"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultInterval = 60 // 分钟
+	defaultMaxAge   = 72 // 小时
+)
+
+// AbandonedUpload 某个存储桶中未完成的分片上传
+type AbandonedUpload struct {
+	oss.MultipartUploadInfo
+	RegionCode string `json:"region_code"`
+	BucketName string `json:"bucket_name"`
+	AgeSeconds int64  `json:"age_seconds"`
+	Active     bool   `json:"active"` // 被进行中的 tus 上传引用
+}
+
+// MultipartJanitor 分片上传清理器
+// 定期扫描所有已映射的存储桶，中止超过保留时间的未完成分片上传
+type MultipartJanitor struct {
+	storageFactory oss.StorageFactory
+	db             *gorm.DB
+	cfg            config.JanitorConfig
+	stopCh         chan struct{}
+	stopOnce       sync.Once
+	wg             sync.WaitGroup
+}
+
+// NewMultipartJanitor 创建分片上传清理器
+func NewMultipartJanitor(storageFactory oss.StorageFactory, db *gorm.DB, cfg config.JanitorConfig) *MultipartJanitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaultMaxAge
+	}
+	return &MultipartJanitor{
+		storageFactory: storageFactory,
+		db:             db,
+		cfg:            cfg,
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// MaxAge 返回策略中的最大保留时间
+func (j *MultipartJanitor) MaxAge() time.Duration {
+	return time.Duration(j.cfg.MaxAge) * time.Hour
+}
+
+// Start 启动定时清理，未启用时不做任何事
+func (j *MultipartJanitor) Start() {
+	if !j.cfg.Enabled {
+		return
+	}
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(time.Duration(j.cfg.Interval) * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-j.stopCh:
+				return
+			case <-ticker.C:
+				j.RunOnce()
+			}
+		}
+	}()
+
+	logger.Info("分片上传清理器已启动",
+		zap.Int("interval_minutes", j.cfg.Interval),
+		zap.Int("max_age_hours", j.cfg.MaxAge),
+		zap.Bool("dry_run", j.cfg.DryRun),
+	)
+}
+
+// Stop 停止定时清理
+func (j *MultipartJanitor) Stop() {
+	j.stopOnce.Do(func() { close(j.stopCh) })
+	j.wg.Wait()
+}
+
+// RunOnce 按策略执行一次清理
+func (j *MultipartJanitor) RunOnce() {
+	if j.cfg.DryRun {
+		uploads, err := j.List("", "", j.MaxAge())
+		if err != nil {
+			logger.Error("扫描未完成分片上传失败", zap.Error(err))
+			return
+		}
+		expired := SelectExpired(uploads, j.MaxAge())
+		logger.Info("分片上传清理（演练模式）",
+			zap.Int("expired", len(expired)),
+			zap.Int64("bytes", TotalSize(expired)),
+		)
+		return
+	}
+
+	aborted, err := j.AbortOlderThan("", "", j.MaxAge())
+	if err != nil {
+		logger.Error("清理未完成分片上传失败", zap.Error(err))
+		return
+	}
+	logger.Info("分片上传清理完成",
+		zap.Int("aborted", len(aborted)),
+		zap.Int64("bytes", TotalSize(aborted)),
+	)
+}
+
+// List 列出未完成的分片上传，regionCode/bucketName 为空时扫描所有已映射的存储桶
+// minAge 大于 0 时只返回存在时间不少于 minAge 的上传
+func (j *MultipartJanitor) List(regionCode, bucketName string, minAge time.Duration) ([]AbandonedUpload, error) {
+	storage, err := j.storage()
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := j.buckets(regionCode, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := j.activeUploadIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var result []AbandonedUpload
+	for _, b := range buckets {
+		uploads, err := storage.ListMultipartUploadsInBucket(b.RegionCode, b.BucketName)
+		if err != nil {
+			logger.Warn("列出未完成分片上传失败",
+				zap.String("region_code", b.RegionCode),
+				zap.String("bucket_name", b.BucketName),
+				zap.Error(err))
+			continue
+		}
+
+		for _, u := range uploads {
+			age := now.Sub(u.Initiated)
+			if minAge > 0 && age < minAge {
+				continue
+			}
+			_, isActive := active[u.UploadID]
+			result = append(result, AbandonedUpload{
+				MultipartUploadInfo: u,
+				RegionCode:          b.RegionCode,
+				BucketName:          b.BucketName,
+				AgeSeconds:          int64(age.Seconds()),
+				Active:              isActive,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// Abort 中止指定的分片上传，并终止引用它的 tus 上传、删除其本地暂存文件
+func (j *MultipartJanitor) Abort(regionCode, bucketName, objectKey, uploadID string) error {
+	storage, err := j.storage()
+	if err != nil {
+		return err
+	}
+
+	if err := storage.AbortMultipartUploadToBucket(uploadID, objectKey, regionCode, bucketName); err != nil {
+		return err
+	}
+
+	var terminated []models.TusUpload
+	if err := j.db.Model(&terminated).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "upload_key"}}}).
+		Where("multipart_upload_id = ? AND status = ?", uploadID, models.TusStatusUploading).
+		Update("status", models.TusStatusTerminated).Error; err != nil {
+		logger.Warn("更新tus上传状态失败", zap.String("upload_id", uploadID), zap.Error(err))
+	}
+	for _, u := range terminated {
+		if err := os.Remove(tus.PendingPath(u.UploadKey)); err != nil && !os.IsNotExist(err) {
+			logger.Warn("删除tus暂存文件失败", zap.String("upload_key", u.UploadKey), zap.Error(err))
+		}
+	}
+
+	logger.Info("已中止未完成分片上传",
+		zap.String("region_code", regionCode),
+		zap.String("bucket_name", bucketName),
+		zap.String("object_key", objectKey),
+		zap.String("upload_id", uploadID),
+	)
+	return nil
+}
+
+// AbortOlderThan 中止存在时间超过 age 的分片上传，进行中的 tus 上传不受影响
+func (j *MultipartJanitor) AbortOlderThan(regionCode, bucketName string, age time.Duration) ([]AbandonedUpload, error) {
+	uploads, err := j.List(regionCode, bucketName, age)
+	if err != nil {
+		return nil, err
+	}
+
+	var aborted []AbandonedUpload
+	for _, u := range SelectExpired(uploads, age) {
+		if err := j.Abort(u.RegionCode, u.BucketName, u.Key, u.UploadID); err != nil {
+			logger.Warn("中止分片上传失败",
+				zap.String("bucket_name", u.BucketName),
+				zap.String("object_key", u.Key),
+				zap.String("upload_id", u.UploadID),
+				zap.Error(err))
+			continue
+		}
+		aborted = append(aborted, u)
+	}
+	return aborted, nil
+}
+
+// SelectExpired 按策略筛选需要中止的上传：存在时间不少于 maxAge 且未被进行中的 tus 上传引用
+func SelectExpired(uploads []AbandonedUpload, maxAge time.Duration) []AbandonedUpload {
+	var expired []AbandonedUpload
+	for _, u := range uploads {
+		if u.Active || time.Duration(u.AgeSeconds)*time.Second < maxAge {
+			continue
+		}
+		expired = append(expired, u)
+	}
+	return expired
+}
+
+// TotalSize 统计分片占用的总字节数
+func TotalSize(uploads []AbandonedUpload) int64 {
+	var total int64
+	for _, u := range uploads {
+		total += u.Size
+	}
+	return total
+}
+
+// storage 获取默认存储配置对应的存储服务
+func (j *MultipartJanitor) storage() (oss.StorageService, error) {
+	var ossConfig models.OSSConfig
+	if err := j.db.Where("is_default = ?", true).First(&ossConfig).Error; err != nil {
+		return nil, fmt.Errorf("获取默认存储配置失败: %w", err)
+	}
+	return j.storageFactory.GetStorageService(ossConfig.StorageType)
+}
+
+// buckets 获取需要扫描的存储桶
+func (j *MultipartJanitor) buckets(regionCode, bucketName string) ([]models.RegionBucketMapping, error) {
+	query := j.db.Model(&models.RegionBucketMapping{}).Distinct("region_code", "bucket_name")
+	if regionCode != "" {
+		query = query.Where("region_code = ?", regionCode)
+	}
+	if bucketName != "" {
+		query = query.Where("bucket_name = ?", bucketName)
+	}
+
+	var buckets []models.RegionBucketMapping
+	if err := query.Find(&buckets).Error; err != nil {
+		return nil, fmt.Errorf("获取存储桶列表失败: %w", err)
+	}
+	return buckets, nil
+}
+
+// activeUploadIDs 返回进行中且未过期的 tus 上传所使用的分片上传ID
+func (j *MultipartJanitor) activeUploadIDs() (map[string]struct{}, error) {
+	var ids []string
+	if err := j.db.Model(&models.TusUpload{}).
+		Where("status = ? AND expires_at > ? AND multipart_upload_id <> ''", models.TusStatusUploading, time.Now()).
+		Pluck("multipart_upload_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("获取进行中的tus上传失败: %w", err)
+	}
+
+	active := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		active[id] = struct{}{}
+	}
+	return active, nil
+}
@@ -0,0 +1,123 @@
+package janitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/tus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// fakeStorage 只实现清理器用到的方法
+type fakeStorage struct {
+	oss.StorageService
+	uploads map[string][]oss.MultipartUploadInfo
+	aborted []string
+}
+
+func (f *fakeStorage) ListMultipartUploadsInBucket(regionCode, bucketName string) ([]oss.MultipartUploadInfo, error) {
+	return f.uploads[bucketName], nil
+}
+
+func (f *fakeStorage) AbortMultipartUploadToBucket(uploadID, objectKey, regionCode, bucketName string) error {
+	f.aborted = append(f.aborted, uploadID)
+	return nil
+}
+
+type fakeFactory struct {
+	storage *fakeStorage
+}
+
+func (f *fakeFactory) GetStorageService(string) (oss.StorageService, error)  { return f.storage, nil }
+func (f *fakeFactory) GetDefaultStorageService() (oss.StorageService, error) { return f.storage, nil }
+func (f *fakeFactory) ClearCache()                                           {}
+
+func TestSelectExpired(t *testing.T) {
+	uploads := []AbandonedUpload{
+		{MultipartUploadInfo: oss.MultipartUploadInfo{UploadID: "old", Size: 10}, AgeSeconds: 7200},
+		{MultipartUploadInfo: oss.MultipartUploadInfo{UploadID: "young", Size: 20}, AgeSeconds: 60},
+		{MultipartUploadInfo: oss.MultipartUploadInfo{UploadID: "active", Size: 30}, AgeSeconds: 7200, Active: true},
+	}
+
+	expired := SelectExpired(uploads, time.Hour)
+	if len(expired) != 1 || expired[0].UploadID != "old" {
+		t.Fatalf("SelectExpired() = %+v, want only 'old'", expired)
+	}
+	if got := TotalSize(uploads); got != 60 {
+		t.Errorf("TotalSize() = %d, want 60", got)
+	}
+}
+
+func TestAbortOlderThanSkipsActiveTusUploads(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	storage := &fakeStorage{uploads: map[string][]oss.MultipartUploadInfo{
+		"docs": {
+			{Key: "a.bin", UploadID: "stale", Initiated: now.Add(-100 * time.Hour), Size: 5 << 20},
+			{Key: "b.bin", UploadID: "tus-active", Initiated: now.Add(-100 * time.Hour)},
+			{Key: "c.bin", UploadID: "fresh", Initiated: now.Add(-time.Hour)},
+		},
+	}}
+
+	mock.ExpectQuery(`SELECT \* FROM "oss_configs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "storage_type"}).AddRow(1, oss.StorageTypeAliyunOSS))
+	mock.ExpectQuery(`SELECT DISTINCT "region_code","bucket_name" FROM "region_bucket_mapping"`).
+		WillReturnRows(sqlmock.NewRows([]string{"region_code", "bucket_name"}).AddRow("cn-hangzhou", "docs"))
+	mock.ExpectQuery(`SELECT "multipart_upload_id" FROM "tus_uploads"`).
+		WillReturnRows(sqlmock.NewRows([]string{"multipart_upload_id"}).AddRow("tus-active"))
+	mock.ExpectQuery(`SELECT \* FROM "oss_configs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "storage_type"}).AddRow(1, oss.StorageTypeAliyunOSS))
+	mock.ExpectQuery(`UPDATE "tus_uploads" .* RETURNING "upload_key"`).
+		WillReturnRows(sqlmock.NewRows([]string{"upload_key"}).AddRow("expired"))
+
+	// 未配置 upload_temp_dir 时暂存文件位于工作目录下，切换到临时目录
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	pending := tus.PendingPath("expired")
+	if err := os.MkdirAll(filepath.Dir(pending), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pending, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	j := NewMultipartJanitor(&fakeFactory{storage: storage}, db, config.JanitorConfig{MaxAge: 72})
+	aborted, err := j.AbortOlderThan("", "", j.MaxAge())
+	if err != nil {
+		t.Fatalf("AbortOlderThan() error = %v", err)
+	}
+
+	if len(aborted) != 1 || aborted[0].UploadID != "stale" {
+		t.Fatalf("aborted = %+v, want only 'stale'", aborted)
+	}
+	if len(storage.aborted) != 1 || storage.aborted[0] != "stale" {
+		t.Errorf("storage aborted %v, want [stale]", storage.aborted)
+	}
+	if _, err := os.Stat(pending); !os.IsNotExist(err) {
+		t.Errorf("pending file of terminated tus upload still exists: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,60 @@
+// Package lease 数据库任务表共用的租约
+// 实例领取任务时写入 lease_owner 和 leased_until，执行期间定期续约；
+// 所有状态变更都带上 lease_owner 条件，租约过期被其他实例接手后本实例的写入不会生效
+package lease
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Lease 本实例在一张任务表上的租约
+type Lease struct {
+	Owner   string        // 本实例的租约标识
+	Timeout time.Duration // 租约时长
+	Running string        // 任务表中执行中的状态
+}
+
+// New 创建租约，每次调用生成新的租约标识
+func New(timeout time.Duration, running string) Lease {
+	return Lease{Owner: NewOwner(), Timeout: timeout, Running: running}
+}
+
+// NewOwner 生成本实例的租约标识：主机名-进程号-随机串
+func NewOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Until 从现在起续约后的到期时间
+func (l Lease) Until() time.Time {
+	return time.Now().Add(l.Timeout)
+}
+
+// Owned 限定为本实例仍持有租约的任务，model 为任务表的模型
+func (l Lease) Owned(db *gorm.DB, model interface{}, id uint) *gorm.DB {
+	return db.Model(model).Where("id = ? AND status = ? AND lease_owner = ?", id, l.Running, l.Owner)
+}
+
+// Renew 续约，返回 false 表示租约已丢失
+func (l Lease) Renew(db *gorm.DB, model interface{}, id uint) (bool, error) {
+	result := l.Owned(db, model, id).Update("leased_until", l.Until())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Released 在 updates 中加入清空租约的字段，用于结束或交还任务
+func Released(updates map[string]interface{}) map[string]interface{} {
+	updates["lease_owner"] = ""
+	updates["leased_until"] = nil
+	return updates
+}
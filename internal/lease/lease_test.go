@@ -0,0 +1,54 @@
+package lease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type job struct {
+	ID          uint
+	Status      string
+	LeaseOwner  string
+	LeasedUntil *time.Time
+}
+
+func TestNewOwnerUnique(t *testing.T) {
+	if a, b := NewOwner(), NewOwner(); a == "" || a == b {
+		t.Errorf("NewOwner() = %q, %q; want non-empty and unique", a, b)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(time.Minute, "RUNNING")
+	mock.ExpectExec(`UPDATE "jobs" SET "leased_until"=\$1 WHERE id = \$2 AND status = \$3 AND lease_owner = \$4`).
+		WithArgs(sqlmock.AnyArg(), 1, "RUNNING", l.Owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "jobs" SET "leased_until"`).
+		WithArgs(sqlmock.AnyArg(), 2, "RUNNING", l.Owner).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if held, err := l.Renew(db, &job{}, 1); err != nil || !held {
+		t.Errorf("Renew(1) = %v, %v; want true", held, err)
+	}
+	// 租约已被其他实例接手
+	if held, err := l.Renew(db, &job{}, 2); err != nil || held {
+		t.Errorf("Renew(2) = %v, %v; want false", held, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
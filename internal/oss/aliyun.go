@@ -1,8 +1,10 @@
 package oss
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"path"
 	"sort"
@@ -290,8 +292,106 @@ func (s *AliyunOSSService) ListUploadedPartsToBucket(objectKey string, uploadID
 	return uploadedParts, nil
 }
 
+// ListMultipartUploadsInBucket 列出指定存储桶中所有未完成的分片上传
+func (s *AliyunOSSService) ListMultipartUploadsInBucket(regionCode string, bucketName string) ([]MultipartUploadInfo, error) {
+	endpoint := s.getEndpoint(regionCode)
+	client, err := oss.New(endpoint, s.config.AccessKeyID, s.config.AccessKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("创建OSS客户端失败: %w", err)
+	}
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("获取存储桶失败: %w", err)
+	}
+
+	var uploads []MultipartUploadInfo
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		result, err := bucket.ListMultipartUploads(oss.KeyMarker(keyMarker), oss.UploadIDMarker(uploadIDMarker), oss.MaxUploads(1000))
+		if err != nil {
+			return nil, fmt.Errorf("列出未完成分片上传失败: %w", err)
+		}
+
+		for _, u := range result.Uploads {
+			info := MultipartUploadInfo{Key: u.Key, UploadID: u.UploadID, Initiated: u.Initiated}
+
+			// 统计已上传分片占用的空间
+			partMarker := 0
+			for {
+				parts, err := bucket.ListUploadedParts(oss.InitiateMultipartUploadResult{
+					Key:      u.Key,
+					UploadID: u.UploadID,
+				}, oss.PartNumberMarker(partMarker))
+				if err != nil {
+					logger.Warn("获取分片信息失败",
+						zap.String("bucketName", bucketName),
+						zap.String("objectKey", u.Key),
+						zap.String("uploadID", u.UploadID),
+						zap.Error(err))
+					break
+				}
+				for _, part := range parts.UploadedParts {
+					info.Parts++
+					info.Size += int64(part.Size)
+				}
+				if !parts.IsTruncated {
+					break
+				}
+				partMarker, _ = strconv.Atoi(parts.NextPartNumberMarker)
+			}
+
+			uploads = append(uploads, info)
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	}
+
+	return uploads, nil
+}
+
+// WalkObjectsInBucket 按对象键的字节序逐页遍历指定存储桶中前缀下的所有对象
+func (s *AliyunOSSService) WalkObjectsInBucket(regionCode string, bucketName string, prefix string, fn func(ObjectInfo) error) error {
+	endpoint := s.getEndpoint(regionCode)
+	client, err := oss.New(endpoint, s.config.AccessKeyID, s.config.AccessKeySecret)
+	if err != nil {
+		return fmt.Errorf("创建OSS客户端失败: %w", err)
+	}
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return fmt.Errorf("获取存储桶失败: %w", err)
+	}
+
+	marker := ""
+	for {
+		result, err := bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker), oss.MaxKeys(1000))
+		if err != nil {
+			return fmt.Errorf("列出对象失败: %w", err)
+		}
+
+		for _, obj := range result.Objects {
+			if err := fn(ObjectInfo{
+				Key:          obj.Key,
+				Size:         obj.Size,
+				LastModified: obj.LastModified,
+				ETag:         strings.Trim(obj.ETag, "\""),
+				ContentType:  obj.Type,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			return nil
+		}
+		marker = result.NextMarker
+	}
+}
+
 // GeneratePartUploadURL 生成单个分片上传的预签名URL
-func (s *AliyunOSSService) GeneratePartUploadURL(objectKey string, uploadID string, partNumber int, regionCode string, bucketName string) (string, error) {
+func (s *AliyunOSSService) GeneratePartUploadURL(objectKey string, uploadID string, partNumber int, contentMD5 string, regionCode string, bucketName string) (string, error) {
 	endpoint := s.getEndpoint(regionCode)
 	client, err := oss.New(endpoint, s.config.AccessKeyID, s.config.AccessKeySecret)
 	if err != nil {
@@ -307,6 +407,9 @@ func (s *AliyunOSSService) GeneratePartUploadURL(objectKey string, uploadID stri
 		oss.AddParam("partNumber", strconv.Itoa(partNumber)),
 		oss.ContentType("application/octet-stream"),
 	}
+	if contentMD5 != "" {
+		options = append(options, oss.ContentMD5(contentMD5))
+	}
 
 	url, err := bucket.SignURL(objectKey, oss.HTTPPut, 3600, options...)
 	if err != nil {
@@ -390,6 +493,65 @@ func (s *AliyunOSSService) GetObject(objectKey string) (io.ReadCloser, error) {
 	return body, nil
 }
 
+// GetObjectFromBucket 获取指定存储桶中的对象内容
+func (s *AliyunOSSService) GetObjectFromBucket(objectKey string, regionCode string, bucketName string) (io.ReadCloser, error) {
+	client, err := oss.New(s.getEndpoint(regionCode), s.config.AccessKeyID, s.config.AccessKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("创建OSS客户端失败: %w", err)
+	}
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("获取存储桶失败: %w", err)
+	}
+
+	body, err := bucket.GetObject(objectKey)
+	if err != nil {
+		logger.Error("获取阿里云OSS对象失败",
+			zap.String("bucketName", bucketName),
+			zap.String("objectKey", objectKey),
+			zap.Error(err))
+		return nil, fmt.Errorf("获取阿里云OSS对象失败: %w", err)
+	}
+	return body, nil
+}
+
+// StatObjectInBucket 获取指定存储桶中对象的元数据，包括 OSS 计算的 CRC64
+func (s *AliyunOSSService) StatObjectInBucket(objectKey string, regionCode string, bucketName string) (*ObjectInfo, error) {
+	client, err := oss.New(s.getEndpoint(regionCode), s.config.AccessKeyID, s.config.AccessKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("创建OSS客户端失败: %w", err)
+	}
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("获取存储桶失败: %w", err)
+	}
+
+	props, err := bucket.GetObjectDetailedMeta(objectKey)
+	if err != nil {
+		var srvErr oss.ServiceError
+		if errors.As(err, &srvErr) && srvErr.StatusCode == http.StatusNotFound {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("获取阿里云OSS对象信息失败: %w", err)
+	}
+
+	size, err := strconv.ParseInt(props.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("解析阿里云OSS对象大小失败: %w", err)
+	}
+	info := &ObjectInfo{
+		Key:         objectKey,
+		Size:        size,
+		ETag:        strings.Trim(props.Get("ETag"), `"`),
+		ContentType: props.Get("Content-Type"),
+		CRC64:       props.Get(oss.HTTPHeaderOssCRC64),
+	}
+	if t, err := http.ParseTime(props.Get("Last-Modified")); err == nil {
+		info.LastModified = t
+	}
+	return info, nil
+}
+
 // TriggerMD5Calculation 触发计算MD5值
 func (s *AliyunOSSService) TriggerMD5Calculation(objectKey string, fileID uint) error {
 	logger.Info("触发阿里云OSS对象MD5计算",
@@ -804,11 +966,8 @@ func (s *AliyunOSSService) CopyObject(srcBucket, srcKey, dstBucket, dstKey strin
 		return fmt.Errorf("获取目标存储桶失败: %w", err)
 	}
 
-	// 构建源对象路径
-	srcObjectPath := fmt.Sprintf("/%s/%s", srcBucket, srcKey)
-
-	// 复制对象
-	_, err = dstOssBucket.CopyObject(srcObjectPath, dstKey)
+	// 复制对象（服务端复制，不经过本服务）
+	_, err = dstOssBucket.CopyObjectFrom(srcBucket, srcKey, dstKey)
 	if err != nil {
 		return fmt.Errorf("复制对象失败: %w", err)
 	}
@@ -2,6 +2,7 @@ package oss
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path"
@@ -285,6 +286,38 @@ func (s *AWSS3Service) GetObject(objectKey string) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
+// GetObjectFromBucket 获取指定存储桶中的对象内容
+// AWS S3 目前不支持指定存储桶，从默认存储桶读取
+func (s *AWSS3Service) GetObjectFromBucket(objectKey string, regionCode string, bucketName string) (io.ReadCloser, error) {
+	return s.GetObject(objectKey)
+}
+
+// StatObjectInBucket 获取对象的元数据
+// AWS S3 目前不支持指定存储桶，从默认存储桶读取
+func (s *AWSS3Service) StatObjectInBucket(objectKey string, regionCode string, bucketName string) (*ObjectInfo, error) {
+	fullObjectKey := s.getObjectKey(objectKey)
+	result, err := s.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fullObjectKey),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("获取AWS S3对象信息失败: %w", err)
+	}
+
+	return &ObjectInfo{
+		Key:          objectKey,
+		Size:         aws.ToInt64(result.ContentLength),
+		LastModified: aws.ToTime(result.LastModified),
+		ETag:         strings.Trim(aws.ToString(result.ETag), `"`),
+		ContentType:  aws.ToString(result.ContentType),
+	}, nil
+}
+
 // TriggerMD5Calculation 触发计算MD5值
 func (s *AWSS3Service) TriggerMD5Calculation(objectKey string, fileID uint) error {
 	logger.Info("触发AWS S3对象MD5计算",
@@ -396,8 +429,92 @@ func (s *AWSS3Service) ListUploadedPartsToBucket(objectKey string, uploadID stri
 	return nil, fmt.Errorf("AWS S3暂不支持列出已上传分片")
 }
 
+// ListMultipartUploadsInBucket 列出未完成的分片上传
+func (s *AWSS3Service) ListMultipartUploadsInBucket(regionCode string, bucketName string) ([]MultipartUploadInfo, error) {
+	// AWS S3 目前不支持指定存储桶，列出默认存储桶上传目录下的分片上传
+	ctx := context.Background()
+	input := &s3.ListMultipartUploadsInput{Bucket: aws.String(s.bucketName)}
+	if s.uploadDir != "" {
+		input.Prefix = aws.String(strings.TrimSuffix(s.uploadDir, "/") + "/")
+	}
+
+	var uploads []MultipartUploadInfo
+	paginator := s3.NewListMultipartUploadsPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("列出AWS S3未完成分片上传失败: %w", err)
+		}
+
+		for _, u := range page.Uploads {
+			fullKey := aws.ToString(u.Key)
+			info := MultipartUploadInfo{
+				// 返回相对上传目录的键，与其他方法的 objectKey 参数保持一致
+				Key:       strings.TrimPrefix(strings.TrimPrefix(fullKey, s.uploadDir), "/"),
+				UploadID:  aws.ToString(u.UploadId),
+				Initiated: aws.ToTime(u.Initiated),
+			}
+
+			parts := s3.NewListPartsPaginator(s.client, &s3.ListPartsInput{
+				Bucket:   aws.String(s.bucketName),
+				Key:      u.Key,
+				UploadId: u.UploadId,
+			})
+			for parts.HasMorePages() {
+				partPage, err := parts.NextPage(ctx)
+				if err != nil {
+					logger.Warn("获取AWS S3分片信息失败",
+						zap.String("objectKey", fullKey),
+						zap.String("uploadID", info.UploadID),
+						zap.Error(err))
+					break
+				}
+				for _, part := range partPage.Parts {
+					info.Parts++
+					info.Size += aws.ToInt64(part.Size)
+				}
+			}
+
+			uploads = append(uploads, info)
+		}
+	}
+
+	return uploads, nil
+}
+
+// WalkObjectsInBucket 按对象键的字节序逐页遍历上传目录中前缀下的所有对象
+func (s *AWSS3Service) WalkObjectsInBucket(regionCode string, bucketName string, prefix string, fn func(ObjectInfo) error) error {
+	// AWS S3 目前不支持指定存储桶，遍历默认存储桶的上传目录，返回相对上传目录的键
+	dir := ""
+	if s.uploadDir != "" {
+		dir = strings.TrimSuffix(s.uploadDir, "/") + "/"
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(dir + prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return fmt.Errorf("列出AWS S3对象失败: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			if err := fn(ObjectInfo{
+				Key:          strings.TrimPrefix(aws.ToString(obj.Key), dir),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+				ETag:         strings.Trim(aws.ToString(obj.ETag), "\""),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // GeneratePartUploadURL 生成单个分片上传的预签名URL
-func (s *AWSS3Service) GeneratePartUploadURL(objectKey string, uploadID string, partNumber int, regionCode string, bucketName string) (string, error) {
+func (s *AWSS3Service) GeneratePartUploadURL(objectKey string, uploadID string, partNumber int, contentMD5 string, regionCode string, bucketName string) (string, error) {
 	return "", fmt.Errorf("AWS S3暂不支持生成分片上传URL")
 }
 
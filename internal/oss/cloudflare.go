@@ -2,9 +2,11 @@ package oss
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -298,6 +300,32 @@ func (s *CloudflareR2Service) GetObject(objectKey string) (io.ReadCloser, error)
 	return resp.Body, nil
 }
 
+// StatObjectInBucket 获取对象的元数据
+// CloudFlare R2 目前不支持指定存储桶，从默认存储桶读取
+func (s *CloudflareR2Service) StatObjectInBucket(objectKey string, regionCode string, bucketName string) (*ObjectInfo, error) {
+	fullObjectKey := s.getObjectKey(objectKey)
+	result, err := s.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fullObjectKey),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("获取R2对象信息失败: %w", err)
+	}
+
+	return &ObjectInfo{
+		Key:          objectKey,
+		Size:         aws.ToInt64(result.ContentLength),
+		LastModified: aws.ToTime(result.LastModified),
+		ETag:         strings.Trim(aws.ToString(result.ETag), `"`),
+		ContentType:  aws.ToString(result.ContentType),
+	}, nil
+}
+
 // TriggerMD5Calculation 触发计算MD5值
 func (s *CloudflareR2Service) TriggerMD5Calculation(objectKey string, fileID uint) error {
 	logger.Info("触发CloudFlare R2对象MD5计算",
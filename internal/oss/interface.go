@@ -1,6 +1,7 @@
 package oss
 
 import (
+	"errors"
 	"io"
 	"time"
 )
@@ -12,6 +13,9 @@ const (
 	StorageTypeR2        = "CLOUDFLARE_R2"
 )
 
+// ErrObjectNotFound 对象不存在
+var ErrObjectNotFound = errors.New("对象不存在")
+
 // Part 分片信息
 type Part struct {
 	PartNumber int    `json:"part_number"`
@@ -25,6 +29,16 @@ type ObjectInfo struct {
 	LastModified time.Time `json:"last_modified"`
 	ETag         string    `json:"etag"`
 	ContentType  string    `json:"content_type"`
+	CRC64        string    `json:"crc64,omitempty"` // 存储端返回的 CRC64-ECMA（十进制），仅阿里云 OSS 提供
+}
+
+// MultipartUploadInfo 未完成的分片上传信息
+type MultipartUploadInfo struct {
+	Key       string    `json:"key"`
+	UploadID  string    `json:"upload_id"`
+	Initiated time.Time `json:"initiated"`
+	Parts     int       `json:"parts"` // 已上传分片数
+	Size      int64     `json:"size"`  // 已上传分片占用的字节数
 }
 
 // StorageService 存储服务接口
@@ -73,8 +87,20 @@ type StorageService interface {
 	// 返回：已上传的分片信息列表, 错误
 	ListUploadedPartsToBucket(objectKey string, uploadID string, regionCode string, bucketName string) ([]Part, error)
 
+	// ListMultipartUploadsInBucket 列出指定存储桶中所有未完成的分片上传
+	// regionCode, bucketName: 指定的地域和存储桶
+	// 返回：未完成分片上传列表（含已上传分片数和字节数）, 错误
+	ListMultipartUploadsInBucket(regionCode string, bucketName string) ([]MultipartUploadInfo, error)
+
+	// WalkObjectsInBucket 按对象键的字节序逐页遍历指定存储桶中前缀下的所有对象
+	// regionCode, bucketName: 指定的地域和存储桶
+	// prefix: 前缀，为空时遍历整个存储桶
+	// fn: 对每个对象调用，返回错误时停止遍历并返回该错误
+	WalkObjectsInBucket(regionCode string, bucketName string, prefix string, fn func(ObjectInfo) error) error
+
 	// GeneratePartUploadURL 生成单个分片上传的预签名URL
-	GeneratePartUploadURL(objectKey string, uploadID string, partNumber int, regionCode string, bucketName string) (string, error)
+	// contentMD5 不为空时签入 Content-MD5（base64），上传时必须携带相同的头，存储端会校验分片内容
+	GeneratePartUploadURL(objectKey string, uploadID string, partNumber int, contentMD5 string, regionCode string, bucketName string) (string, error)
 
 	// GenerateDownloadURL 生成下载URL
 	// objectKey: 对象键
@@ -102,6 +128,18 @@ type StorageService interface {
 	// 返回：对象内容读取器, 错误
 	GetObject(objectKey string) (io.ReadCloser, error)
 
+	// StatObjectInBucket 获取指定存储桶中对象的元数据
+	// objectKey: 对象键
+	// regionCode, bucketName: 指定的地域和存储桶
+	// 返回：对象信息, 错误（对象不存在时为 ErrObjectNotFound）
+	StatObjectInBucket(objectKey string, regionCode string, bucketName string) (*ObjectInfo, error)
+
+	// GetObjectFromBucket 获取指定存储桶中的对象内容
+	// objectKey: 对象键
+	// regionCode, bucketName: 指定的地域和存储桶
+	// 返回：对象内容读取器, 错误
+	GetObjectFromBucket(objectKey string, regionCode string, bucketName string) (io.ReadCloser, error)
+
 	// TriggerMD5Calculation 触发计算MD5值
 	// objectKey: 对象键
 	// fileID: 文件ID
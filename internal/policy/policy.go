@@ -0,0 +1,216 @@
+// Package policy 按存储桶校验上传内容：扩展名、文件头检测出的 MIME 类型、文件大小和文件名
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+)
+
+// SniffLen 检测文件类型需要读取的字节数
+const SniffLen = 512
+
+// ErrPolicyViolation 上传内容不符合策略
+var ErrPolicyViolation = errors.New("upload policy violation")
+
+// ViolationError 违反策略的具体原因
+type ViolationError struct {
+	Reason string
+}
+
+func (e *ViolationError) Error() string {
+	return e.Reason
+}
+
+// Is 使 errors.Is(err, ErrPolicyViolation) 成立
+func (e *ViolationError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+func violation(format string, args ...interface{}) error {
+	return &ViolationError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Policy 解析后的上传策略，nil 表示不做任何限制
+type Policy struct {
+	Extensions        []string // 小写，带点
+	MIMETypes         []string // 小写，支持 "type/*"
+	MaxFileSize       int64
+	ForbiddenPatterns []string // 小写的通配符
+}
+
+// New 根据存储桶策略和全局大小限制构建策略，两者都为空时返回 nil
+func New(p *models.UploadPolicy, globalMaxSize int64) *Policy {
+	result := &Policy{MaxFileSize: globalMaxSize}
+	if p != nil {
+		for _, ext := range splitList(p.AllowedExtensions) {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			result.Extensions = append(result.Extensions, ext)
+		}
+		result.MIMETypes = splitList(p.AllowedMIMETypes)
+		result.ForbiddenPatterns = splitList(p.ForbiddenPatterns)
+		if p.MaxFileSize > 0 && (result.MaxFileSize <= 0 || p.MaxFileSize < result.MaxFileSize) {
+			result.MaxFileSize = p.MaxFileSize
+		}
+	}
+
+	if result.MaxFileSize <= 0 && len(result.Extensions) == 0 &&
+		len(result.MIMETypes) == 0 && len(result.ForbiddenPatterns) == 0 {
+		return nil
+	}
+	return result
+}
+
+// Validate 校验策略配置是否合法（通配符语法）
+func Validate(p *models.UploadPolicy) error {
+	for _, pattern := range splitList(p.ForbiddenPatterns) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("无效的文件名通配符 %q", pattern)
+		}
+	}
+	for _, t := range splitList(p.AllowedMIMETypes) {
+		if !strings.Contains(t, "/") {
+			return fmt.Errorf("无效的 MIME 类型 %q", t)
+		}
+	}
+	if p.MaxFileSize < 0 {
+		return errors.New("max_file_size 不能为负数")
+	}
+	return nil
+}
+
+// CheckFile 校验文件名和大小，size 小于 0 表示大小未知，跳过大小检查
+func (p *Policy) CheckFile(filename string, size int64) error {
+	if p == nil {
+		return nil
+	}
+
+	name := strings.ToLower(path.Base(filepath.ToSlash(filename)))
+	for _, pattern := range p.ForbiddenPatterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return violation("文件名 %s 被策略禁止（匹配 %s）", path.Base(filename), pattern)
+		}
+	}
+
+	if len(p.Extensions) > 0 {
+		ext := strings.ToLower(path.Ext(name))
+		if !contains(p.Extensions, ext) {
+			return violation("不允许上传扩展名为 %q 的文件，允许的扩展名：%s", ext, strings.Join(p.Extensions, ", "))
+		}
+	}
+
+	if p.MaxFileSize > 0 && size > p.MaxFileSize {
+		return violation("文件大小 %d 字节超过限制 %d 字节", size, p.MaxFileSize)
+	}
+	return nil
+}
+
+// NeedsContent 是否需要检测文件内容
+func (p *Policy) NeedsContent() bool {
+	return p != nil && len(p.MIMETypes) > 0
+}
+
+// CheckContent 根据文件头检测 MIME 类型并校验，不信任文件名和客户端声明的类型
+// 空文件没有可检测的内容，直接放行
+func (p *Policy) CheckContent(filename string, head []byte) error {
+	if !p.NeedsContent() || len(head) == 0 {
+		return nil
+	}
+
+	detected := DetectContentType(filename, head)
+	for _, allowed := range p.MIMETypes {
+		if matchMIME(allowed, detected) {
+			return nil
+		}
+	}
+	return violation("不允许上传类型为 %s 的文件", detected)
+}
+
+// Peek 读取 r 的前 SniffLen 字节，返回这些字节和可以从头读取完整内容的 reader
+func Peek(r io.Reader) ([]byte, io.Reader, error) {
+	head := make([]byte, SniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+	head = head[:n]
+	return head, io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// 标准库无法识别的常见类型
+var magicTypes = []struct {
+	prefix   []byte
+	mimeType string
+}{
+	{[]byte("MZ"), "application/x-msdownload"},
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), "application/x-ole-storage"},
+	{[]byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{[]byte("#!"), "text/x-shellscript"},
+}
+
+// ooxmlTypes Office Open XML 文档本质上是 zip，确认是 zip 后按扩展名细分
+var ooxmlTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// DetectContentType 根据文件头检测 MIME 类型（不含参数）
+func DetectContentType(filename string, head []byte) string {
+	for _, m := range magicTypes {
+		if bytes.HasPrefix(head, m.prefix) {
+			return m.mimeType
+		}
+	}
+
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if detected == "application/zip" {
+		if t, ok := ooxmlTypes[strings.ToLower(path.Ext(filename))]; ok {
+			return t
+		}
+	}
+	return detected
+}
+
+// matchMIME 支持 "type/*" 通配
+func matchMIME(allowed, detected string) bool {
+	if strings.HasSuffix(allowed, "/*") {
+		return strings.HasPrefix(detected, strings.TrimSuffix(allowed, "*"))
+	}
+	return allowed == detected
+}
+
+// splitList 解析逗号或换行分隔的列表，统一转为小写
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
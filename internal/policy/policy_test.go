@@ -0,0 +1,111 @@
+package policy
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+)
+
+func TestCheckFile(t *testing.T) {
+	p := New(&models.UploadPolicy{
+		AllowedExtensions: "pdf, .DOCX",
+		MaxFileSize:       1000,
+		ForbiddenPatterns: "~$*,*.tmp.pdf",
+	}, 2000)
+
+	tests := []struct {
+		name    string
+		file    string
+		size    int64
+		wantErr bool
+	}{
+		{"allowed", "report.pdf", 100, false},
+		{"extension case insensitive", "docs/Report.DocX", 100, false},
+		{"unknown size", "report.pdf", -1, false},
+		{"extension not allowed", "setup.exe", 100, true},
+		{"no extension", "Makefile", 100, true},
+		{"bucket limit is stricter than global", "report.pdf", 1500, true},
+		{"forbidden pattern", "~$report.docx", 100, true},
+		{"forbidden suffix", "draft.tmp.pdf", 100, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.CheckFile(tt.file, tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckFile(%q, %d) error = %v, wantErr %v", tt.file, tt.size, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrPolicyViolation) {
+				t.Errorf("error %v is not ErrPolicyViolation", err)
+			}
+		})
+	}
+}
+
+func TestCheckContent(t *testing.T) {
+	p := New(&models.UploadPolicy{
+		AllowedMIMETypes: "application/pdf,image/*,application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	}, 0)
+
+	tests := []struct {
+		name    string
+		file    string
+		head    string
+		wantErr bool
+	}{
+		{"pdf", "a.pdf", "%PDF-1.7\n", false},
+		{"png wildcard", "a.png", "\x89PNG\r\n\x1a\n", false},
+		{"docx is zip", "a.docx", "PK\x03\x04", false},
+		{"zip renamed", "a.zip", "PK\x03\x04", true},
+		{"exe renamed to pdf", "a.pdf", "MZ\x90\x00", true},
+		{"elf", "a.pdf", "\x7fELF", true},
+		{"plain text", "a.pdf", "hello world", true},
+		{"empty file", "a.pdf", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.CheckContent(tt.file, []byte(tt.head))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckContent(%q) error = %v, wantErr %v", tt.head, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNilPolicy(t *testing.T) {
+	p := New(nil, 0)
+	if p != nil {
+		t.Fatalf("New(nil, 0) = %+v, want nil", p)
+	}
+	if err := p.CheckFile("a.exe", 1<<40); err != nil {
+		t.Errorf("nil policy CheckFile() error = %v", err)
+	}
+	if p.NeedsContent() {
+		t.Error("nil policy NeedsContent() = true")
+	}
+}
+
+func TestPeek(t *testing.T) {
+	content := strings.Repeat("x", SniffLen+10)
+	head, r, err := Peek(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(head) != SniffLen {
+		t.Errorf("len(head) = %d, want %d", len(head), SniffLen)
+	}
+	all, _ := io.ReadAll(r)
+	if string(all) != content {
+		t.Error("Peek() reader does not return the full content")
+	}
+
+	head, r, err = Peek(strings.NewReader("short"))
+	if err != nil || string(head) != "short" {
+		t.Fatalf("Peek(short) = %q, %v", head, err)
+	}
+	if all, _ := io.ReadAll(r); string(all) != "short" {
+		t.Errorf("Peek(short) reader = %q", all)
+	}
+}
@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"gorm.io/gorm"
+)
+
+// Service 读取存储桶上传策略
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建上传策略服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Load 获取存储桶的上传策略（叠加全局 max_file_size），regionCode 为空时只按存储桶名称匹配
+// 没有任何限制时返回 nil
+func (s *Service) Load(regionCode, bucketName string) (*Policy, error) {
+	var globalMaxSize int64
+	if cfg := config.GetConfig(); cfg != nil {
+		globalMaxSize = cfg.App.MaxFileSize
+	}
+
+	query := s.db.Model(&models.UploadPolicy{}).
+		Joins("JOIN region_bucket_mapping ON region_bucket_mapping.id = upload_policies.region_bucket_mapping_id AND region_bucket_mapping.deleted_at IS NULL").
+		Where("region_bucket_mapping.bucket_name = ?", bucketName)
+	if regionCode != "" {
+		query = query.Where("region_bucket_mapping.region_code = ?", regionCode)
+	}
+
+	var p models.UploadPolicy
+	if err := query.Order("upload_policies.id").First(&p).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return New(nil, globalMaxSize), nil
+		}
+		return nil, fmt.Errorf("获取上传策略失败: %w", err)
+	}
+	return New(&p, globalMaxSize), nil
+}
@@ -0,0 +1,272 @@
+// Package quota 计算并校验用户、角色和存储桶的存储配额
+package quota
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"gorm.io/gorm"
+)
+
+// ErrQuotaExceeded 超出配额
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Usage 已用容量
+type Usage struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// Limit 对某个用户生效的一条配额及其当前用量
+type Limit struct {
+	QuotaID    uint   `json:"quota_id"`
+	ScopeType  string `json:"scope_type"`
+	ScopeID    uint   `json:"scope_id"`
+	BucketName string `json:"bucket_name"`
+	MaxBytes   int64  `json:"max_bytes"`
+	MaxFiles   int64  `json:"max_files"`
+	Usage      Usage  `json:"usage"`
+}
+
+// AvailableBytes 剩余可用字节数，不限制时返回 -1
+func (l Limit) AvailableBytes() int64 {
+	if l.MaxBytes <= 0 {
+		return -1
+	}
+	if l.Usage.Bytes >= l.MaxBytes {
+		return 0
+	}
+	return l.MaxBytes - l.Usage.Bytes
+}
+
+// ExceededError 超出配额的详细信息
+type ExceededError struct {
+	Limit     Limit
+	Resource  string // bytes 或 files
+	Requested int64
+}
+
+func (e *ExceededError) Error() string {
+	scope := map[string]string{
+		models.QuotaScopeUser:   "用户",
+		models.QuotaScopeRole:   "角色",
+		models.QuotaScopeBucket: "存储桶",
+	}[e.Limit.ScopeType]
+	if e.Resource == "files" {
+		return fmt.Sprintf("超出%s文件数配额：已有 %d 个，上限 %d 个", scope, e.Limit.Usage.Files, e.Limit.MaxFiles)
+	}
+	return fmt.Sprintf("超出%s存储空间配额：已用 %d 字节，本次 %d 字节，上限 %d 字节",
+		scope, e.Limit.Usage.Bytes, e.Requested, e.Limit.MaxBytes)
+}
+
+// Is 使 errors.Is(err, ErrQuotaExceeded) 成立
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Report 用户在某个存储桶上的配额使用情况
+type Report struct {
+	UserID         uint    `json:"user_id"`
+	BucketName     string  `json:"bucket_name,omitempty"`
+	Usage          Usage   `json:"usage"`           // 用户在该范围内的用量
+	Limits         []Limit `json:"limits"`          // 生效的配额
+	AvailableBytes int64   `json:"available_bytes"` // 剩余可用字节数，-1 表示不限制
+}
+
+// Service 配额服务
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建配额服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Check 校验用户向存储桶上传 size 字节的新文件是否超出配额
+func (s *Service) Check(userID uint, bucketName string, size int64) error {
+	limits, err := s.EffectiveLimits(userID, bucketName)
+	if err != nil {
+		return err
+	}
+	return checkLimits(limits, size)
+}
+
+// checkLimits 依次检查每条配额，返回第一条被超出的配额
+func checkLimits(limits []Limit, size int64) error {
+	for _, l := range limits {
+		if l.MaxBytes > 0 && l.Usage.Bytes+size > l.MaxBytes {
+			return &ExceededError{Limit: l, Resource: "bytes", Requested: size}
+		}
+		if l.MaxFiles > 0 && l.Usage.Files+1 > l.MaxFiles {
+			return &ExceededError{Limit: l, Resource: "files", Requested: 1}
+		}
+	}
+	return nil
+}
+
+// Report 返回用户在存储桶上的配额使用情况，bucketName 为空时只统计全局配额
+func (s *Service) Report(userID uint, bucketName string) (*Report, error) {
+	limits, err := s.EffectiveLimits(userID, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.usage(userID, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		UserID:         userID,
+		BucketName:     bucketName,
+		Usage:          usage,
+		Limits:         limits,
+		AvailableBytes: minAvailable(limits),
+	}, nil
+}
+
+// EffectiveLimits 计算对用户生效的配额：
+//   - 按"指定存储桶"和"所有存储桶"两个层级分别取值，用户配额优先于角色配额；
+//   - 用户属于多个角色时取最宽松的角色配额；
+//   - 存储桶配额限制所有用户在该桶中的总用量。
+func (s *Service) EffectiveLimits(userID uint, bucketName string) ([]Limit, error) {
+	var roleIDs []uint
+	if err := s.db.Table("user_roles").Where("user_id = ?", userID).Pluck("role_id", &roleIDs).Error; err != nil {
+		return nil, fmt.Errorf("获取用户角色失败: %w", err)
+	}
+
+	buckets := []string{""}
+	if bucketName != "" {
+		buckets = append(buckets, bucketName)
+	}
+
+	query := s.db.Where("bucket_name IN ?", buckets).
+		Where(s.db.Where("scope_type = ? AND scope_id = ?", models.QuotaScopeUser, userID).
+			Or("scope_type = ? AND scope_id IN ?", models.QuotaScopeRole, append(roleIDs, 0)).
+			Or("scope_type = ? AND bucket_name <> ''", models.QuotaScopeBucket))
+
+	var quotas []models.StorageQuota
+	if err := query.Find(&quotas).Error; err != nil {
+		return nil, fmt.Errorf("获取配额失败: %w", err)
+	}
+
+	limits := resolve(quotas)
+	for i := range limits {
+		var (
+			usage Usage
+			err   error
+		)
+		if limits[i].ScopeType == models.QuotaScopeBucket {
+			usage, err = s.bucketUsage(limits[i].BucketName)
+		} else {
+			usage, err = s.usage(userID, limits[i].BucketName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		limits[i].Usage = usage
+	}
+	return limits, nil
+}
+
+// resolve 从候选配额中选出生效的配额（不含用量）
+func resolve(quotas []models.StorageQuota) []Limit {
+	var limits []Limit
+
+	// 用户/角色配额按存储桶层级分组
+	levels := map[string][]models.StorageQuota{}
+	var order []string
+	for _, q := range quotas {
+		if q.ScopeType == models.QuotaScopeBucket {
+			limits = append(limits, toLimit(q))
+			continue
+		}
+		if _, ok := levels[q.BucketName]; !ok {
+			order = append(order, q.BucketName)
+		}
+		levels[q.BucketName] = append(levels[q.BucketName], q)
+	}
+
+	for _, bucket := range order {
+		var userQuota *models.StorageQuota
+		var roleQuotas []models.StorageQuota
+		for i, q := range levels[bucket] {
+			if q.ScopeType == models.QuotaScopeUser {
+				userQuota = &levels[bucket][i]
+			} else {
+				roleQuotas = append(roleQuotas, q)
+			}
+		}
+
+		if userQuota != nil {
+			limits = append(limits, toLimit(*userQuota))
+			continue
+		}
+		if len(roleQuotas) > 0 {
+			limits = append(limits, loosest(roleQuotas))
+		}
+	}
+
+	return limits
+}
+
+// loosest 合并多个角色配额，取每项最宽松的值（0 表示不限制）
+func loosest(quotas []models.StorageQuota) Limit {
+	l := toLimit(quotas[0])
+	for _, q := range quotas[1:] {
+		if l.MaxBytes != 0 && (q.MaxBytes == 0 || q.MaxBytes > l.MaxBytes) {
+			l.MaxBytes = q.MaxBytes
+			l.QuotaID, l.ScopeID = q.ID, q.ScopeID
+		}
+		if l.MaxFiles != 0 && (q.MaxFiles == 0 || q.MaxFiles > l.MaxFiles) {
+			l.MaxFiles = q.MaxFiles
+		}
+	}
+	return l
+}
+
+func toLimit(q models.StorageQuota) Limit {
+	return Limit{
+		QuotaID:    q.ID,
+		ScopeType:  q.ScopeType,
+		ScopeID:    q.ScopeID,
+		BucketName: q.BucketName,
+		MaxBytes:   q.MaxBytes,
+		MaxFiles:   q.MaxFiles,
+	}
+}
+
+// minAvailable 返回所有配额中最小的剩余字节数，不限制时返回 -1
+func minAvailable(limits []Limit) int64 {
+	available := int64(-1)
+	for _, l := range limits {
+		if a := l.AvailableBytes(); a >= 0 && (available < 0 || a < available) {
+			available = a
+		}
+	}
+	return available
+}
+
+// usage 统计用户的用量，bucketName 为空时统计所有存储桶
+func (s *Service) usage(userID uint, bucketName string) (Usage, error) {
+	query := s.db.Model(&models.OSSFile{}).Where("uploader_id = ? AND status = ?", userID, "ACTIVE")
+	if bucketName != "" {
+		query = query.Where("bucket = ?", bucketName)
+	}
+	return scanUsage(query)
+}
+
+// bucketUsage 统计存储桶的总用量
+func (s *Service) bucketUsage(bucketName string) (Usage, error) {
+	return scanUsage(s.db.Model(&models.OSSFile{}).Where("bucket = ? AND status = ?", bucketName, "ACTIVE"))
+}
+
+func scanUsage(query *gorm.DB) (Usage, error) {
+	var usage Usage
+	if err := query.Select("COALESCE(SUM(file_size), 0) AS bytes, COUNT(*) AS files").Scan(&usage).Error; err != nil {
+		return usage, fmt.Errorf("统计用量失败: %w", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,69 @@
+package quota
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+)
+
+func TestResolve(t *testing.T) {
+	quotas := []models.StorageQuota{
+		{ScopeType: models.QuotaScopeRole, ScopeID: 1, MaxBytes: 100, MaxFiles: 10},
+		{ScopeType: models.QuotaScopeRole, ScopeID: 2, MaxBytes: 500, MaxFiles: 0},
+		{ScopeType: models.QuotaScopeRole, ScopeID: 1, BucketName: "docs", MaxBytes: 50},
+		{ScopeType: models.QuotaScopeUser, ScopeID: 7, BucketName: "docs", MaxBytes: 80},
+		{ScopeType: models.QuotaScopeBucket, BucketName: "docs", MaxFiles: 1000},
+	}
+
+	limits := resolve(quotas)
+	if len(limits) != 3 {
+		t.Fatalf("resolve() returned %d limits, want 3: %+v", len(limits), limits)
+	}
+
+	byScope := map[string]Limit{}
+	for _, l := range limits {
+		byScope[l.ScopeType+"/"+l.BucketName] = l
+	}
+
+	// 多个角色取最宽松的值
+	if l := byScope["ROLE/"]; l.MaxBytes != 500 || l.MaxFiles != 0 {
+		t.Errorf("global role limit = %+v, want max_bytes=500 max_files=0", l)
+	}
+	// 同一层级用户配额优先于角色配额
+	if l := byScope["USER/docs"]; l.MaxBytes != 80 {
+		t.Errorf("bucket user limit = %+v, want max_bytes=80", l)
+	}
+	if l := byScope["BUCKET/docs"]; l.MaxFiles != 1000 {
+		t.Errorf("bucket limit = %+v, want max_files=1000", l)
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	limits := []Limit{
+		{ScopeType: models.QuotaScopeUser, MaxBytes: 100, Usage: Usage{Bytes: 60, Files: 1}},
+		{ScopeType: models.QuotaScopeBucket, BucketName: "docs", MaxFiles: 2, Usage: Usage{Files: 1}},
+	}
+
+	if err := checkLimits(limits, 40); err != nil {
+		t.Errorf("checkLimits(40) error = %v, want nil", err)
+	}
+
+	err := checkLimits(limits, 41)
+	var exceeded *ExceededError
+	if !errors.Is(err, ErrQuotaExceeded) || !errors.As(err, &exceeded) || exceeded.Resource != "bytes" {
+		t.Errorf("checkLimits(41) error = %v, want bytes quota exceeded", err)
+	}
+
+	limits[1].Usage.Files = 2
+	if err := checkLimits(limits, 1); !errors.As(err, &exceeded) || exceeded.Resource != "files" {
+		t.Errorf("checkLimits() error = %v, want files quota exceeded", err)
+	}
+
+	if got := minAvailable(limits); got != 40 {
+		t.Errorf("minAvailable() = %d, want 40", got)
+	}
+	if got := minAvailable(nil); got != -1 {
+		t.Errorf("minAvailable(nil) = %d, want -1", got)
+	}
+}
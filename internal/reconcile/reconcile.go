@@ -0,0 +1,300 @@
+// Package reconcile 对账：遍历存储桶中的真实对象并与文件记录逐一比较，
+// 找出没有记录的对象、没有对象的记录以及大小或 ETag 不一致的文件，由管理员导入、重新关联或删除
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/search"
+	"github.com/myysophia/ossmanager/internal/tasks"
+	"github.com/myysophia/ossmanager/internal/trash"
+	"gorm.io/gorm"
+)
+
+const (
+	recordBatchSize = 1000 // 每批读取的文件记录数
+	diffBatchSize   = 100  // 每批写入的差异数
+)
+
+// trackedStatuses 认为存储端应当有对象的文件状态；回收站中的文件另外按 trashed_at 判断
+var trackedStatuses = []string{"ACTIVE", models.FileStatusQuarantined, models.FileStatusCorrupt, models.FileStatusMissing}
+
+// Params 对账范围
+type Params struct {
+	RegionCode string `json:"region_code"`
+	BucketName string `json:"bucket_name"`
+	Prefix     string `json:"prefix"` // 为空时对账整个存储桶
+}
+
+// Summary 一次对账的汇总
+type Summary struct {
+	Objects      int `json:"objects"`
+	Records      int `json:"records"`
+	Matched      int `json:"matched"`
+	Unrecorded   int `json:"unrecorded"`
+	Missing      int `json:"missing"`
+	SizeMismatch int `json:"size_mismatch"`
+	ETagMismatch int `json:"etag_mismatch"`
+}
+
+// Differences 发现的差异总数
+func (s *Summary) Differences() int {
+	return s.Unrecorded + s.Missing + s.SizeMismatch + s.ETagMismatch
+}
+
+// Reconciler 对账器
+type Reconciler struct {
+	storageFactory oss.StorageFactory
+	db             *gorm.DB
+	bin            *trash.Bin // 删除没有记录的对象时先移入回收站，未启用时直接删除
+}
+
+// NewReconciler 创建对账器
+func NewReconciler(storageFactory oss.StorageFactory, db *gorm.DB, bin *trash.Bin) *Reconciler {
+	return &Reconciler{
+		storageFactory: storageFactory,
+		db:             db,
+		bin:            bin,
+	}
+}
+
+// Run 对账并保存差异，差异按任务ID记录；任务重复执行时先清除上次执行留下的差异
+func (r *Reconciler) Run(ctx context.Context, taskID uint, p Params, progress *tasks.Progress) (*Summary, error) {
+	ossConfig, err := r.defaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	storage, err := r.storageFactory.GetStorageService(ossConfig.StorageType)
+	if err != nil {
+		return nil, fmt.Errorf("获取存储服务失败: %w", err)
+	}
+	if err := r.db.Unscoped().Where("task_id = ?", taskID).Delete(&models.ReconcileDiff{}).Error; err != nil {
+		return nil, fmt.Errorf("清除上次对账结果失败: %w", err)
+	}
+
+	var total int64
+	if err := r.recordQuery(p).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("统计文件记录失败: %w", err)
+	}
+	progress.SetTotal(total)
+
+	w := &writer{db: r.db, taskID: taskID, bucket: p.BucketName}
+	m := &merger{records: r.records(p), emit: w.add}
+	err = storage.WalkObjectsInBucket(p.RegionCode, p.BucketName, p.Prefix, func(obj oss.ObjectInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		before := m.summary.Records
+		if err := m.object(obj); err != nil {
+			return err
+		}
+		progress.Add(int64(m.summary.Records - before))
+		progress.SetMessage(obj.Key)
+		return nil
+	})
+	if err == nil {
+		err = m.finish()
+	}
+	if flushErr := w.flush(); err == nil {
+		err = flushErr
+	}
+	progress.SetMessage("")
+	return &m.summary, err
+}
+
+// recordQuery 对账范围内认为存储端应当有对象的文件记录
+func (r *Reconciler) recordQuery(p Params) *gorm.DB {
+	query := r.db.Model(&models.OSSFile{}).
+		Where("bucket = ?", p.BucketName).
+		Where("(status IN ? OR (status = ? AND trashed_at IS NOT NULL))", trackedStatuses, models.FileStatusDeleted)
+	if p.Prefix != "" {
+		query = query.Where("object_key LIKE ?", search.EscapeLike(p.Prefix)+"%")
+	}
+	return query
+}
+
+// records 按对象键的字节序逐批读取文件记录，与存储端列出对象的顺序一致
+func (r *Reconciler) records(p Params) func() (*models.OSSFile, error) {
+	query := r.recordQuery(p).Session(&gorm.Session{})
+	var batch []models.OSSFile
+	var last *models.OSSFile
+	done := false
+	return func() (*models.OSSFile, error) {
+		if len(batch) == 0 && !done {
+			q := query
+			if last != nil {
+				q = q.Where(`(object_key COLLATE "C", id) > (?, ?)`, last.ObjectKey, last.ID)
+			}
+			var next []models.OSSFile
+			if err := q.Order(`object_key COLLATE "C", id`).Limit(recordBatchSize).Find(&next).Error; err != nil {
+				return nil, fmt.Errorf("获取文件记录失败: %w", err)
+			}
+			done = len(next) < recordBatchSize
+			batch = next
+		}
+		if len(batch) == 0 {
+			return nil, nil
+		}
+		last = &batch[0]
+		batch = batch[1:]
+		return last, nil
+	}
+}
+
+// defaultConfig 获取默认存储配置，对账和导入都使用它对应的存储服务
+func (r *Reconciler) defaultConfig() (*models.OSSConfig, error) {
+	var ossConfig models.OSSConfig
+	if err := r.db.Where("is_default = ?", true).First(&ossConfig).Error; err != nil {
+		return nil, fmt.Errorf("获取默认存储配置失败: %w", err)
+	}
+	return &ossConfig, nil
+}
+
+// merger 合并按对象键排序的对象和文件记录，逐一比较
+type merger struct {
+	records func() (*models.OSSFile, error) // 返回下一条记录，没有更多记录时返回 nil
+	head    *models.OSSFile
+	emit    func(models.ReconcileDiff) error
+	summary Summary
+}
+
+// peek 返回下一条尚未比较的记录
+func (m *merger) peek() (*models.OSSFile, error) {
+	if m.head == nil && m.records != nil {
+		file, err := m.records()
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			m.records = nil
+		}
+		m.head = file
+	}
+	return m.head, nil
+}
+
+// object 比较一个对象：对象键更小的记录都没有对象，对象键相同的记录比较大小和 ETag
+func (m *merger) object(obj oss.ObjectInfo) error {
+	m.summary.Objects++
+	matched := false
+	for {
+		file, err := m.peek()
+		if err != nil {
+			return err
+		}
+		if file == nil || file.ObjectKey > obj.Key {
+			break
+		}
+		m.head = nil
+		m.summary.Records++
+
+		if file.ObjectKey < obj.Key {
+			if err := m.missing(file); err != nil {
+				return err
+			}
+			continue
+		}
+		matched = true
+		if err := m.compare(file, obj); err != nil {
+			return err
+		}
+	}
+
+	// 目录占位对象没有文件记录
+	if matched || (strings.HasSuffix(obj.Key, "/") && obj.Size == 0) {
+		return nil
+	}
+	m.summary.Unrecorded++
+	return m.emit(objectDiff(models.ReconcileDiffUnrecorded, obj))
+}
+
+// finish 所有对象比较完后，剩余的记录都没有对象
+func (m *merger) finish() error {
+	for {
+		file, err := m.peek()
+		if err != nil || file == nil {
+			return err
+		}
+		m.head = nil
+		m.summary.Records++
+		if err := m.missing(file); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *merger) missing(file *models.OSSFile) error {
+	m.summary.Missing++
+	return m.emit(models.ReconcileDiff{
+		ObjectKey:  file.ObjectKey,
+		Kind:       models.ReconcileDiffMissing,
+		FileID:     file.ID,
+		RecordSize: file.FileSize,
+		RecordETag: file.ETag,
+	})
+}
+
+// compare 比较对象和记录的大小，大小一致且记录中有 ETag 时比较 ETag
+func (m *merger) compare(file *models.OSSFile, obj oss.ObjectInfo) error {
+	var kind string
+	switch {
+	case file.FileSize != obj.Size:
+		kind = models.ReconcileDiffSizeMismatch
+		m.summary.SizeMismatch++
+	case file.ETag != "" && !strings.EqualFold(file.ETag, obj.ETag):
+		kind = models.ReconcileDiffETagMismatch
+		m.summary.ETagMismatch++
+	default:
+		m.summary.Matched++
+		return nil
+	}
+	diff := objectDiff(kind, obj)
+	diff.FileID, diff.RecordSize, diff.RecordETag = file.ID, file.FileSize, file.ETag
+	return m.emit(diff)
+}
+
+func objectDiff(kind string, obj oss.ObjectInfo) models.ReconcileDiff {
+	diff := models.ReconcileDiff{
+		ObjectKey:  obj.Key,
+		Kind:       kind,
+		ObjectSize: obj.Size,
+		ObjectETag: obj.ETag,
+	}
+	if !obj.LastModified.IsZero() {
+		lastModified := obj.LastModified
+		diff.LastModified = &lastModified
+	}
+	return diff
+}
+
+// writer 批量保存差异
+type writer struct {
+	db      *gorm.DB
+	taskID  uint
+	bucket  string
+	pending []models.ReconcileDiff
+}
+
+func (w *writer) add(diff models.ReconcileDiff) error {
+	diff.TaskID, diff.Bucket = w.taskID, w.bucket
+	w.pending = append(w.pending, diff)
+	if len(w.pending) >= diffBatchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *writer) flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	if err := w.db.Create(&w.pending).Error; err != nil {
+		return fmt.Errorf("保存对账差异失败: %w", err)
+	}
+	w.pending = nil
+	return nil
+}
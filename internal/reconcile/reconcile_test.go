@@ -0,0 +1,161 @@
+package reconcile
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/oss"
+)
+
+// sliceRecords 按顺序返回给定的记录
+func sliceRecords(files ...models.OSSFile) func() (*models.OSSFile, error) {
+	return func() (*models.OSSFile, error) {
+		if len(files) == 0 {
+			return nil, nil
+		}
+		file := &files[0]
+		files = files[1:]
+		return file, nil
+	}
+}
+
+func runMerge(t *testing.T, records []models.OSSFile, objects []oss.ObjectInfo) ([]models.ReconcileDiff, Summary) {
+	t.Helper()
+	var diffs []models.ReconcileDiff
+	m := &merger{
+		records: sliceRecords(records...),
+		emit: func(diff models.ReconcileDiff) error {
+			diffs = append(diffs, diff)
+			return nil
+		},
+	}
+	for _, obj := range objects {
+		if err := m.object(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.finish(); err != nil {
+		t.Fatal(err)
+	}
+	return diffs, m.summary
+}
+
+func TestMerge(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := []models.OSSFile{
+		{Model: models.Model{ID: 1}, ObjectKey: "a.txt", FileSize: 10, ETag: `"AAA"`},
+		{Model: models.Model{ID: 2}, ObjectKey: "b.txt", FileSize: 20},
+		{Model: models.Model{ID: 3}, ObjectKey: "c.txt", FileSize: 30, ETag: "ccc"},
+		{Model: models.Model{ID: 4}, ObjectKey: "d.txt", FileSize: 40, ETag: "ddd"},
+		{Model: models.Model{ID: 5}, ObjectKey: "z.txt", FileSize: 50},
+	}
+	objects := []oss.ObjectInfo{
+		{Key: "a.txt", Size: 10, ETag: `"aaa"`},
+		{Key: "b.txt", Size: 20, ETag: "bbb"},
+		{Key: "c.txt", Size: 31, ETag: "ccc"},
+		{Key: "d.txt", Size: 40, ETag: "xxx"},
+		{Key: "dir/", Size: 0},
+		{Key: "e.txt", Size: 60, ETag: "eee", LastModified: modified},
+	}
+
+	diffs, summary := runMerge(t, records, objects)
+
+	want := Summary{Objects: 6, Records: 5, Matched: 2, Unrecorded: 1, Missing: 1, SizeMismatch: 1, ETagMismatch: 1}
+	if summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+	if summary.Differences() != 4 {
+		t.Errorf("Differences() = %d", summary.Differences())
+	}
+
+	kinds := map[string]models.ReconcileDiff{}
+	for _, diff := range diffs {
+		kinds[diff.Kind] = diff
+	}
+	if d := kinds[models.ReconcileDiffSizeMismatch]; d.FileID != 3 || d.ObjectSize != 31 || d.RecordSize != 30 {
+		t.Errorf("size mismatch = %+v", d)
+	}
+	if d := kinds[models.ReconcileDiffETagMismatch]; d.FileID != 4 || d.ObjectETag != "xxx" || d.RecordETag != "ddd" {
+		t.Errorf("etag mismatch = %+v", d)
+	}
+	if d := kinds[models.ReconcileDiffUnrecorded]; d.ObjectKey != "e.txt" || d.FileID != 0 ||
+		d.LastModified == nil || !d.LastModified.Equal(modified) {
+		t.Errorf("unrecorded = %+v", d)
+	}
+	if d := kinds[models.ReconcileDiffMissing]; d.ObjectKey != "z.txt" || d.FileID != 5 || d.RecordSize != 50 {
+		t.Errorf("missing = %+v", d)
+	}
+}
+
+func TestMergeMissingBeforeObject(t *testing.T) {
+	records := []models.OSSFile{
+		{Model: models.Model{ID: 1}, ObjectKey: "a.txt"},
+		{Model: models.Model{ID: 2}, ObjectKey: "b.txt", FileSize: 5},
+		{Model: models.Model{ID: 3}, ObjectKey: "b.txt", FileSize: 5},
+	}
+	diffs, summary := runMerge(t, records, []oss.ObjectInfo{{Key: "b.txt", Size: 5}})
+
+	// 同一对象的多条记录都与对象比较
+	want := Summary{Objects: 1, Records: 3, Matched: 2, Missing: 1}
+	if summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+	if len(diffs) != 1 || diffs[0].Kind != models.ReconcileDiffMissing || diffs[0].FileID != 1 {
+		t.Errorf("diffs = %+v", diffs)
+	}
+}
+
+func TestMergeNoRecords(t *testing.T) {
+	diffs, summary := runMerge(t, nil, []oss.ObjectInfo{{Key: "a/", Size: 0}, {Key: "a/b", Size: 1}})
+	if summary.Unrecorded != 1 || len(diffs) != 1 || diffs[0].ObjectKey != "a/b" {
+		t.Errorf("summary = %+v, diffs = %+v", summary, diffs)
+	}
+}
+
+func TestMergeRecordsError(t *testing.T) {
+	wantErr := errors.New("boom")
+	m := &merger{
+		records: func() (*models.OSSFile, error) { return nil, wantErr },
+		emit:    func(models.ReconcileDiff) error { return nil },
+	}
+	if err := m.object(oss.ObjectInfo{Key: "a"}); !errors.Is(err, wantErr) {
+		t.Errorf("object() = %v", err)
+	}
+}
+
+func TestSupported(t *testing.T) {
+	tests := []struct {
+		kind, action string
+		want         bool
+	}{
+		{models.ReconcileDiffUnrecorded, models.ReconcileActionImport, true},
+		{models.ReconcileDiffUnrecorded, models.ReconcileActionDelete, true},
+		{models.ReconcileDiffUnrecorded, models.ReconcileActionRelink, false},
+		{models.ReconcileDiffMissing, models.ReconcileActionRelink, true},
+		{models.ReconcileDiffMissing, models.ReconcileActionDelete, true},
+		{models.ReconcileDiffMissing, models.ReconcileActionImport, false},
+		{models.ReconcileDiffSizeMismatch, models.ReconcileActionRelink, true},
+		{models.ReconcileDiffETagMismatch, models.ReconcileActionDelete, false},
+		{"OTHER", models.ReconcileActionRelink, false},
+	}
+	for _, tt := range tests {
+		if got := Supported(tt.kind, tt.action); got != tt.want {
+			t.Errorf("Supported(%q, %q) = %v, want %v", tt.kind, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestResolveRejectsBeforeStorage(t *testing.T) {
+	r := NewReconciler(nil, nil, nil)
+	now := time.Now()
+	if err := r.Resolve(&models.ReconcileDiff{Kind: models.ReconcileDiffUnrecorded, ResolvedAt: &now},
+		models.ReconcileActionImport, 1, ""); !errors.Is(err, ErrResolved) {
+		t.Errorf("resolved: err = %v", err)
+	}
+	if err := r.Resolve(&models.ReconcileDiff{Kind: models.ReconcileDiffSizeMismatch},
+		models.ReconcileActionDelete, 1, ""); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("unsupported: err = %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrResolved 差异已经处理过
+	ErrResolved = errors.New("差异已处理")
+	// ErrStale 存储端或记录在对账之后发生了变化，需要重新对账
+	ErrStale = errors.New("对象或记录已发生变化，请重新对账")
+	// ErrUnsupported 该类差异不支持这种处理方式
+	ErrUnsupported = errors.New("该差异不支持这种处理方式")
+	// ErrTargetRequired 重新关联没有对象的记录时需要指定对象键
+	ErrTargetRequired = errors.New("请指定要关联的对象键")
+	// ErrConflict 对象键已有其他文件记录
+	ErrConflict = errors.New("该对象已有文件记录")
+)
+
+// Resolve 按 action 处理一条差异，userID 为处理人
+//   - IMPORT：为没有记录的对象创建文件记录，记在处理人名下
+//   - RELINK：大小或 ETag 不一致时按对象更新记录；没有对象的记录改为指向 objectKey 处已有的对象
+//   - DELETE：删除没有记录的对象（启用回收站时移入回收站）或没有对象的记录
+func (r *Reconciler) Resolve(diff *models.ReconcileDiff, action string, userID uint, objectKey string) error {
+	if diff.ResolvedAt != nil {
+		return ErrResolved
+	}
+	if !Supported(diff.Kind, action) {
+		return ErrUnsupported
+	}
+	ossConfig, err := r.defaultConfig()
+	if err != nil {
+		return err
+	}
+	storage, err := r.storageFactory.GetStorageService(ossConfig.StorageType)
+	if err != nil {
+		return fmt.Errorf("获取存储服务失败: %w", err)
+	}
+	regionCode := r.regionOf(diff.Bucket)
+
+	switch {
+	case diff.Kind == models.ReconcileDiffUnrecorded && action == models.ReconcileActionImport:
+		_, err = r.importObject(storage, ossConfig, regionCode, diff, userID)
+	case diff.Kind == models.ReconcileDiffUnrecorded:
+		err = r.deleteObject(storage, ossConfig, regionCode, diff, userID)
+	case diff.Kind == models.ReconcileDiffMissing && action == models.ReconcileActionRelink:
+		err = r.relink(storage, regionCode, diff, objectKey)
+	case diff.Kind == models.ReconcileDiffMissing:
+		err = r.deleteRecord(storage, regionCode, diff)
+	default:
+		// 大小或 ETag 不一致：按对象更新记录
+		err = r.relink(storage, regionCode, diff, diff.ObjectKey)
+	}
+	if err != nil {
+		return err
+	}
+	return r.markResolved(diff, action, userID)
+}
+
+// Supported 该类差异是否支持这种处理方式
+func Supported(kind, action string) bool {
+	switch kind {
+	case models.ReconcileDiffUnrecorded:
+		return action == models.ReconcileActionImport || action == models.ReconcileActionDelete
+	case models.ReconcileDiffMissing:
+		return action == models.ReconcileActionRelink || action == models.ReconcileActionDelete
+	case models.ReconcileDiffSizeMismatch, models.ReconcileDiffETagMismatch:
+		return action == models.ReconcileActionRelink
+	}
+	return false
+}
+
+// importObject 为没有记录的对象创建文件记录
+func (r *Reconciler) importObject(storage oss.StorageService, ossConfig *models.OSSConfig, regionCode string, diff *models.ReconcileDiff, userID uint) (*models.OSSFile, error) {
+	if err := r.checkUnrecorded(diff.Bucket, diff.ObjectKey, 0); err != nil {
+		return nil, err
+	}
+	obj, err := storage.StatObjectInBucket(diff.ObjectKey, regionCode, diff.Bucket)
+	if errors.Is(err, oss.ErrObjectNotFound) {
+		return nil, ErrStale
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取对象信息失败: %w", err)
+	}
+
+	expireTime := ossConfig.URLExpireTime
+	if expireTime <= 0 {
+		expireTime = 24 * 3600 // 默认24小时
+	}
+	file := models.OSSFile{
+		ConfigID:         ossConfig.ID,
+		Filename:         diff.ObjectKey,
+		OriginalFilename: path.Base(diff.ObjectKey),
+		FileSize:         obj.Size,
+		StorageType:      ossConfig.StorageType,
+		Bucket:           diff.Bucket,
+		ObjectKey:        diff.ObjectKey,
+		UploaderID:       userID,
+		ExpiresAt:        time.Now().Add(time.Duration(expireTime) * time.Second),
+		Status:           "ACTIVE",
+		ScanStatus:       scan.InitialStatus(),
+		ETag:             obj.ETag,
+	}
+	if err := r.db.Create(&file).Error; err != nil {
+		return nil, fmt.Errorf("创建文件记录失败: %w", err)
+	}
+	if file.ScanStatus == models.ScanStatusPending {
+		scan.Notify()
+	}
+	diff.FileID = file.ID
+	return &file, nil
+}
+
+// deleteObject 删除没有记录的对象；启用回收站时先为对象创建记录再移入回收站，可以恢复
+func (r *Reconciler) deleteObject(storage oss.StorageService, ossConfig *models.OSSConfig, regionCode string, diff *models.ReconcileDiff, userID uint) error {
+	if r.bin.Enabled() {
+		file, err := r.importObject(storage, ossConfig, regionCode, diff, userID)
+		if err != nil {
+			return err
+		}
+		return r.bin.Move(file, userID)
+	}
+
+	if err := r.checkUnrecorded(diff.Bucket, diff.ObjectKey, 0); err != nil {
+		return err
+	}
+	if err := storage.DeleteObjectFromBucket(diff.ObjectKey, regionCode, diff.Bucket); err != nil && !errors.Is(err, oss.ErrObjectNotFound) {
+		return fmt.Errorf("删除对象失败: %w", err)
+	}
+	return nil
+}
+
+// relink 让差异中的记录指向 objectKey 处的对象，按对象更新大小和 ETag
+// 对象内容可能已经变化，清除摘要等待重新计算，并交由完整性巡检重新检查
+func (r *Reconciler) relink(storage oss.StorageService, regionCode string, diff *models.ReconcileDiff, objectKey string) error {
+	if objectKey == "" {
+		return ErrTargetRequired
+	}
+	file, err := r.diffFile(diff)
+	if err != nil {
+		return err
+	}
+	if objectKey != file.ObjectKey {
+		if err := r.checkUnrecorded(file.Bucket, objectKey, file.ID); err != nil {
+			return err
+		}
+	}
+	obj, err := storage.StatObjectInBucket(objectKey, regionCode, file.Bucket)
+	if err != nil {
+		return fmt.Errorf("获取对象信息失败: %w", err)
+	}
+
+	updates := map[string]interface{}{
+		"object_key":  objectKey,
+		"filename":    objectKey,
+		"file_size":   obj.Size,
+		"etag":        obj.ETag,
+		"md5":         "",
+		"md5_status":  models.MD5StatusPending,
+		"verified_at": nil,
+	}
+	if file.Status == models.FileStatusCorrupt || file.Status == models.FileStatusMissing {
+		updates["status"] = "ACTIVE"
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(file).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新文件记录失败: %w", err)
+		}
+		return hashing.Replace(tx, file.ID, nil)
+	})
+}
+
+// deleteRecord 删除没有对象的记录及其标签
+func (r *Reconciler) deleteRecord(storage oss.StorageService, regionCode string, diff *models.ReconcileDiff) error {
+	file, err := r.diffFile(diff)
+	if err != nil {
+		return err
+	}
+	if _, err := storage.StatObjectInBucket(file.ObjectKey, regionCode, file.Bucket); err == nil {
+		return ErrStale
+	} else if !errors.Is(err, oss.ErrObjectNotFound) {
+		return fmt.Errorf("获取对象信息失败: %w", err)
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", file.ID).Delete(&models.FileTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(file).Error
+	})
+}
+
+// diffFile 读取差异中的记录，记录已删除或已指向其他对象时返回 ErrStale
+func (r *Reconciler) diffFile(diff *models.ReconcileDiff) (*models.OSSFile, error) {
+	var file models.OSSFile
+	err := r.db.First(&file, diff.FileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStale
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file.Bucket != diff.Bucket || file.ObjectKey != diff.ObjectKey {
+		return nil, ErrStale
+	}
+	return &file, nil
+}
+
+// checkUnrecorded 检查对象键没有 exceptID 以外的文件记录
+func (r *Reconciler) checkUnrecorded(bucket, objectKey string, exceptID uint) error {
+	var count int64
+	if err := r.db.Model(&models.OSSFile{}).
+		Where("bucket = ? AND object_key = ? AND id <> ?", bucket, objectKey, exceptID).
+		Where("(status IN ? OR (status = ? AND trashed_at IS NOT NULL))", trackedStatuses, models.FileStatusDeleted).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// markResolved 记录差异的处理方式
+func (r *Reconciler) markResolved(diff *models.ReconcileDiff, action string, userID uint) error {
+	now := time.Now()
+	if err := r.db.Model(diff).Updates(map[string]interface{}{
+		"resolution":  action,
+		"resolved_by": userID,
+		"resolved_at": now,
+		"file_id":     diff.FileID,
+	}).Error; err != nil {
+		return err
+	}
+	diff.Resolution, diff.ResolvedBy, diff.ResolvedAt = action, userID, &now
+	return nil
+}
+
+// regionOf 返回存储桶所属区域，未映射时返回空字符串
+func (r *Reconciler) regionOf(bucket string) string {
+	var mapping models.RegionBucketMapping
+	r.db.Where("bucket_name = ?", bucket).First(&mapping)
+	return mapping.RegionCode
+}
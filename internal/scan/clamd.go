@@ -0,0 +1,153 @@
+// Package scan 通过 ClamAV 守护进程（clamd）扫描上传的文件
+package scan
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultChunkSize = 64 * 1024
+	defaultTimeout   = 60 * time.Second
+)
+
+var (
+	// ErrUnavailable 无法连接 clamd
+	ErrUnavailable = errors.New("clamd: unavailable")
+	// ErrSizeLimitExceeded 文件超过 clamd 的 StreamMaxLength 限制
+	ErrSizeLimitExceeded = errors.New("clamd: INSTREAM size limit exceeded")
+)
+
+// Result 扫描结果
+type Result struct {
+	Infected  bool
+	Signature string // 命中的病毒特征名
+}
+
+// Client clamd 客户端，每次请求使用一个新连接
+type Client struct {
+	network   string
+	address   string
+	timeout   time.Duration
+	chunkSize int
+}
+
+// NewClient 创建 clamd 客户端
+// address 支持 "tcp://host:3310"、"unix:///var/run/clamav/clamd.ctl"、"host:3310" 或以 "/" 开头的套接字路径
+func NewClient(address string, timeout time.Duration) *Client {
+	network, addr := "tcp", address
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		addr = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "/"):
+		network = "unix"
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Client{network: network, address: addr, timeout: timeout, chunkSize: defaultChunkSize}
+}
+
+// Ping 检查 clamd 是否可用
+func (c *Client) Ping() error {
+	reply, err := c.command("zPING\x00", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd: unexpected PING reply %q", reply)
+	}
+	return nil
+}
+
+// Scan 通过 INSTREAM 命令扫描 r 的内容
+func (c *Client) Scan(r io.Reader) (*Result, error) {
+	reply, err := c.command("zINSTREAM\x00", r)
+	if err != nil {
+		return nil, err
+	}
+	return parseReply(reply)
+}
+
+// command 发送命令并读取以 NUL 结尾的回复，body 不为空时按 INSTREAM 格式分块发送
+func (c *Client) command(cmd string, body io.Reader) (string, error) {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, cmd); err != nil {
+		return "", fmt.Errorf("clamd: send command: %w", err)
+	}
+
+	var streamErr error
+	if body != nil {
+		streamErr = c.stream(conn, body)
+	}
+
+	// clamd 超过 StreamMaxLength 时会先回复错误再关闭连接，因此发送失败时仍尝试读取回复
+	_ = conn.SetReadDeadline(time.Now().Add(c.timeout))
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	if reply != "" {
+		return reply, nil
+	}
+	if streamErr != nil {
+		return "", streamErr
+	}
+	return "", fmt.Errorf("clamd: read reply: %w", err)
+}
+
+// stream 按 <4 字节大端长度><数据> 分块发送，以长度为 0 的块结束
+func (c *Client) stream(conn net.Conn, body io.Reader) error {
+	buf := make([]byte, c.chunkSize)
+	size := make([]byte, 4)
+	for {
+		_ = conn.SetWriteDeadline(time.Now().Add(c.timeout))
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(append(size, buf[:n]...)); err != nil {
+				return fmt.Errorf("clamd: send data: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("read object: %w", readErr)
+		}
+	}
+
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return fmt.Errorf("clamd: send terminator: %w", err)
+	}
+	return nil
+}
+
+// parseReply 解析 "stream: OK" / "stream: <signature> FOUND" / "<message> ERROR"
+func parseReply(reply string) (*Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.Contains(reply, "size limit exceeded"):
+		return nil, ErrSizeLimitExceeded
+	case strings.HasSuffix(reply, "ERROR"):
+		return nil, fmt.Errorf("clamd: %s", reply)
+	default:
+		return nil, fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+}
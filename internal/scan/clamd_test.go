@@ -0,0 +1,160 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd 模拟 clamd 的 PING 和 INSTREAM 命令，内容包含 EICAR 测试串时报告感染
+func fakeClamd(t *testing.T, ln net.Listener) {
+	t.Helper()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handleFakeClamd(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+}
+
+func handleFakeClamd(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch cmd {
+	case "zPING\x00":
+		io.WriteString(conn, "PONG\x00")
+	case "zINSTREAM\x00":
+		var data bytes.Buffer
+		size := make([]byte, 4)
+		for {
+			if _, err := io.ReadFull(r, size); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size)
+			if n == 0 {
+				break
+			}
+			if _, err := io.CopyN(&data, r, int64(n)); err != nil {
+				return
+			}
+		}
+		if bytes.Contains(data.Bytes(), []byte(eicar)) {
+			io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+		} else {
+			io.WriteString(conn, "stream: OK\x00")
+		}
+	default:
+		io.WriteString(conn, "UNKNOWN COMMAND\x00")
+	}
+}
+
+func TestClientScan(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeClamd(t, ln)
+
+	client := NewClient("tcp://"+ln.Addr().String(), 5*time.Second)
+	client.chunkSize = 16 // 强制分多块发送
+
+	if err := client.Ping(); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	result, err := client.Scan(strings.NewReader("hello world, nothing to see here"))
+	if err != nil {
+		t.Fatalf("Scan(clean) error = %v", err)
+	}
+	if result.Infected {
+		t.Errorf("Scan(clean) = %+v, want clean", result)
+	}
+
+	result, err = client.Scan(strings.NewReader("prefix " + eicar + " suffix"))
+	if err != nil {
+		t.Fatalf("Scan(eicar) error = %v", err)
+	}
+	if !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Errorf("Scan(eicar) = %+v, want Eicar-Test-Signature", result)
+	}
+}
+
+func TestClientUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "clamd.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix socket not supported: %v", err)
+	}
+	fakeClamd(t, ln)
+
+	for _, address := range []string{"unix://" + sock, sock} {
+		if err := NewClient(address, time.Second).Ping(); err != nil {
+			t.Errorf("Ping(%s) error = %v", address, err)
+		}
+	}
+}
+
+func TestClientUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	_, err = NewClient(address, time.Second).Scan(strings.NewReader("data"))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Scan() error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply     string
+		infected  bool
+		signature string
+		wantErr   error
+	}{
+		{reply: "stream: OK"},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND", infected: true, signature: "Win.Test.EICAR_HDB-1"},
+		{reply: "INSTREAM size limit exceeded. ERROR", wantErr: ErrSizeLimitExceeded},
+		{reply: "stream: Can't allocate memory ERROR", wantErr: errors.New("")},
+		{reply: "garbage", wantErr: errors.New("")},
+	}
+	for _, tt := range tests {
+		result, err := parseReply(tt.reply)
+		if tt.wantErr != nil {
+			if err == nil {
+				t.Errorf("parseReply(%q) error = nil, want error", tt.reply)
+			} else if tt.wantErr == ErrSizeLimitExceeded && !errors.Is(err, ErrSizeLimitExceeded) {
+				t.Errorf("parseReply(%q) error = %v, want ErrSizeLimitExceeded", tt.reply, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseReply(%q) error = %v", tt.reply, err)
+			continue
+		}
+		if result.Infected != tt.infected || result.Signature != tt.signature {
+			t.Errorf("parseReply(%q) = %+v", tt.reply, result)
+		}
+	}
+}
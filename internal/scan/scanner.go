@@ -0,0 +1,378 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWorkers  = 2
+	defaultInterval = 10 // 秒
+	batchSize       = 50
+
+	maxScanResultLength = 255 // scan_result 列的长度
+	maxObjectKeyLength  = 255 // object_key 列的长度
+
+	// ActionQuarantine 隔离感染文件
+	ActionQuarantine = "quarantine"
+	// ActionDelete 删除感染文件
+	ActionDelete = "delete"
+)
+
+var (
+	// ErrInfected 文件感染病毒
+	ErrInfected = errors.New("文件未通过病毒扫描，已被隔离")
+	// ErrNotScanned 文件尚未完成扫描
+	ErrNotScanned = errors.New("文件正在进行安全扫描，请稍后再试")
+	// ErrSkipped 文件超过扫描大小上限未经扫描，且配置不允许下载
+	ErrSkipped = errors.New("文件超过安全扫描的大小上限，未经扫描，不允许下载")
+)
+
+var defaultScanner *Scanner
+
+// SetDefault 设置默认扫描器，上传完成后通过 Notify 唤醒
+func SetDefault(s *Scanner) {
+	defaultScanner = s
+}
+
+// Notify 唤醒默认扫描器立即处理待扫描文件
+func Notify() {
+	if defaultScanner != nil {
+		defaultScanner.Notify()
+	}
+}
+
+// Rescan 将文件重新标记为待扫描并唤醒默认扫描器
+func Rescan(db *gorm.DB, fileID uint) error {
+	result := db.Model(&models.OSSFile{}).Where("id = ?", fileID).
+		Updates(map[string]interface{}{"scan_status": models.ScanStatusPending, "scan_result": ""})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	Notify()
+	return nil
+}
+
+// InitialStatus 新上传文件的扫描状态，未启用扫描时为空
+func InitialStatus() string {
+	if cfg := config.GetConfig(); cfg != nil && cfg.Scan.Enabled {
+		return models.ScanStatusPending
+	}
+	return ""
+}
+
+// CheckDownload 检查文件是否允许下载
+func CheckDownload(file *models.OSSFile) error {
+	if file.ScanStatus == models.ScanStatusInfected || file.Status == models.FileStatusQuarantined {
+		return ErrInfected
+	}
+	cfg := config.GetConfig()
+	if cfg == nil || !cfg.Scan.BlockUnscanned {
+		return nil
+	}
+	switch file.ScanStatus {
+	case models.ScanStatusPending, models.ScanStatusScanning, models.ScanStatusFailed:
+		return ErrNotScanned
+	case models.ScanStatusSkipped:
+		if !cfg.Scan.AllowSkipped {
+			return ErrSkipped
+		}
+	}
+	return nil
+}
+
+// Scanner 扫描器：轮询待扫描的文件，从存储端读取内容交给 clamd 扫描并记录结果
+type Scanner struct {
+	client         *Client
+	storageFactory oss.StorageFactory
+	db             *gorm.DB
+	cfg            config.ScanConfig
+	wakeCh         chan struct{}
+	stopCh         chan struct{}
+	stopOnce       sync.Once
+	wg             sync.WaitGroup
+}
+
+// NewScanner 创建扫描器
+func NewScanner(storageFactory oss.StorageFactory, db *gorm.DB, cfg config.ScanConfig) *Scanner {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Action == "" {
+		cfg.Action = ActionQuarantine
+	}
+	if cfg.QuarantinePrefix = strings.TrimLeft(cfg.QuarantinePrefix, "/"); cfg.QuarantinePrefix != "" && !strings.HasSuffix(cfg.QuarantinePrefix, "/") {
+		cfg.QuarantinePrefix += "/"
+	}
+	return &Scanner{
+		client:         NewClient(cfg.Address, time.Duration(cfg.Timeout)*time.Second),
+		storageFactory: storageFactory,
+		db:             db,
+		cfg:            cfg,
+		wakeCh:         make(chan struct{}, 1),
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// Start 启动扫描循环，未启用时不做任何事
+func (s *Scanner) Start() {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	if err := s.client.Ping(); err != nil {
+		logger.Warn("clamd 当前不可用，将在后台重试", zap.String("address", s.cfg.Address), zap.Error(err))
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Duration(s.cfg.Interval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			s.RunOnce()
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+			case <-s.wakeCh:
+			}
+		}
+	}()
+
+	logger.Info("病毒扫描器已启动",
+		zap.String("address", s.cfg.Address),
+		zap.Int("workers", s.cfg.Workers),
+		zap.String("action", s.cfg.Action),
+	)
+}
+
+// Stop 停止扫描
+func (s *Scanner) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	s.wg.Wait()
+}
+
+// Notify 唤醒扫描循环
+func (s *Scanner) Notify() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// RunOnce 处理一批待扫描文件，直到没有待扫描文件或 clamd 不可用
+func (s *Scanner) RunOnce() {
+	s.recoverStale()
+
+	for {
+		var files []models.OSSFile
+		if err := s.db.Where("scan_status = ?", models.ScanStatusPending).
+			Order("id").Limit(batchSize).Find(&files).Error; err != nil {
+			logger.Error("获取待扫描文件失败", zap.Error(err))
+			return
+		}
+		if len(files) == 0 {
+			return
+		}
+
+		var (
+			wg          sync.WaitGroup
+			mu          sync.Mutex
+			unavailable bool
+		)
+		sem := make(chan struct{}, s.cfg.Workers)
+		for i := range files {
+			if !s.claim(&files[i]) {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(file *models.OSSFile) {
+				defer func() { <-sem; wg.Done() }()
+				if err := s.ScanFile(file); errors.Is(err, ErrUnavailable) {
+					mu.Lock()
+					unavailable = true
+					mu.Unlock()
+				}
+			}(&files[i])
+		}
+		wg.Wait()
+
+		if unavailable || len(files) < batchSize {
+			return
+		}
+		select {
+		case <-s.stopCh:
+			return
+		default:
+		}
+	}
+}
+
+// claim 将文件标记为扫描中，多实例部署时只有一个实例能领取成功
+func (s *Scanner) claim(file *models.OSSFile) bool {
+	result := s.db.Model(&models.OSSFile{}).
+		Where("id = ? AND scan_status = ?", file.ID, models.ScanStatusPending).
+		Update("scan_status", models.ScanStatusScanning)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// recoverStale 将长时间停留在扫描中的文件（例如实例崩溃）重新标记为待扫描
+func (s *Scanner) recoverStale() {
+	timeout := s.client.timeout * 10
+	s.db.Model(&models.OSSFile{}).
+		Where("scan_status = ? AND updated_at < ?", models.ScanStatusScanning, time.Now().Add(-timeout)).
+		Update("scan_status", models.ScanStatusPending)
+}
+
+// ScanFile 扫描单个文件并按策略处理结果
+// clamd 不可用时文件恢复为待扫描并返回 ErrUnavailable
+func (s *Scanner) ScanFile(file *models.OSSFile) error {
+	if s.cfg.MaxScanSize > 0 && file.FileSize > s.cfg.MaxScanSize {
+		return s.skip(file, fmt.Sprintf("文件大小 %d 字节超过扫描上限 %d 字节", file.FileSize, s.cfg.MaxScanSize))
+	}
+
+	regionCode, storage, err := s.storageFor(file)
+	if err != nil {
+		return s.fail(file, err)
+	}
+
+	body, err := storage.GetObjectFromBucket(file.ObjectKey, regionCode, file.Bucket)
+	if err != nil {
+		return s.fail(file, fmt.Errorf("读取对象失败: %w", err))
+	}
+	result, err := s.client.Scan(body)
+	body.Close()
+
+	if errors.Is(err, ErrUnavailable) {
+		s.db.Model(file).Update("scan_status", models.ScanStatusPending)
+		logger.Warn("clamd 不可用，稍后重试", zap.Uint("file_id", file.ID), zap.Error(err))
+		return err
+	}
+	if errors.Is(err, ErrSizeLimitExceeded) {
+		// 记录的大小可能与对象不一致，或 max_scan_size 大于 clamd 的 StreamMaxLength
+		return s.skip(file, "文件超过 clamd 的 StreamMaxLength 限制")
+	}
+	if err != nil {
+		return s.fail(file, err)
+	}
+
+	now := time.Now()
+	if !result.Infected {
+		return s.db.Model(file).Updates(map[string]interface{}{
+			"scan_status": models.ScanStatusClean,
+			"scan_result": "",
+			"scanned_at":  now,
+		}).Error
+	}
+
+	logger.Warn("发现感染文件",
+		zap.Uint("file_id", file.ID),
+		zap.String("bucket", file.Bucket),
+		zap.String("object_key", file.ObjectKey),
+		zap.String("signature", result.Signature),
+		zap.String("action", s.cfg.Action),
+	)
+
+	updates := map[string]interface{}{
+		"scan_status": models.ScanStatusInfected,
+		"scan_result": utils.Truncate(result.Signature, maxScanResultLength),
+		"scanned_at":  now,
+		"status":      models.FileStatusQuarantined,
+	}
+	if s.cfg.Action == ActionDelete {
+		if err := storage.DeleteObjectFromBucket(file.ObjectKey, regionCode, file.Bucket); err != nil {
+			logger.Error("删除感染文件失败", zap.Uint("file_id", file.ID), zap.Error(err))
+		} else {
+			updates["status"] = "DELETED"
+		}
+	} else if key, ok := s.quarantine(storage, file, regionCode); ok {
+		updates["object_key"] = key
+	}
+	return s.db.Model(file).Updates(updates).Error
+}
+
+// quarantine 将感染对象移动到隔离前缀下，未配置前缀或移动失败时保留原位置
+func (s *Scanner) quarantine(storage oss.StorageService, file *models.OSSFile, regionCode string) (string, bool) {
+	prefix := s.cfg.QuarantinePrefix
+	if prefix == "" || strings.HasPrefix(file.ObjectKey, prefix) {
+		return "", false
+	}
+
+	key := prefix + file.ObjectKey
+	if utf8.RuneCountInString(key) > maxObjectKeyLength {
+		logger.Warn("隔离后的对象键超过长度限制，保留原位置", zap.Uint("file_id", file.ID), zap.String("object_key", file.ObjectKey))
+		return "", false
+	}
+	if err := storage.CopyObject(file.Bucket, file.ObjectKey, file.Bucket, key); err != nil {
+		logger.Warn("移动感染文件到隔离区失败，保留原位置", zap.Uint("file_id", file.ID), zap.Error(err))
+		return "", false
+	}
+	if err := storage.DeleteObjectFromBucket(file.ObjectKey, regionCode, file.Bucket); err != nil {
+		logger.Warn("删除感染文件原对象失败", zap.Uint("file_id", file.ID), zap.Error(err))
+	}
+	return key, true
+}
+
+// skip 记录文件因超过大小上限未扫描，是否允许下载由 allow_skipped 决定
+func (s *Scanner) skip(file *models.OSSFile, reason string) error {
+	logger.Info("文件超过扫描大小上限，跳过扫描", zap.Uint("file_id", file.ID), zap.Int64("size", file.FileSize))
+	return s.db.Model(file).Updates(map[string]interface{}{
+		"scan_status": models.ScanStatusSkipped,
+		"scan_result": reason,
+		"scanned_at":  time.Now(),
+	}).Error
+}
+
+// fail 记录扫描失败，状态写入失败时一并返回，避免调用方以为文件已退出扫描队列
+func (s *Scanner) fail(file *models.OSSFile, err error) error {
+	logger.Error("扫描文件失败", zap.Uint("file_id", file.ID), zap.String("object_key", file.ObjectKey), zap.Error(err))
+	if dbErr := s.db.Model(file).Updates(map[string]interface{}{
+		"scan_status": models.ScanStatusFailed,
+		"scan_result": utils.Truncate(err.Error(), maxScanResultLength),
+		"scanned_at":  time.Now(),
+	}).Error; dbErr != nil {
+		logger.Error("记录扫描失败状态失败", zap.Uint("file_id", file.ID), zap.Error(dbErr))
+		return errors.Join(err, dbErr)
+	}
+	return err
+}
+
+// storageFor 获取文件所在存储桶的地域和存储服务
+func (s *Scanner) storageFor(file *models.OSSFile) (string, oss.StorageService, error) {
+	var mapping models.RegionBucketMapping
+	if err := s.db.Where("bucket_name = ?", file.Bucket).First(&mapping).Error; err != nil {
+		return "", nil, fmt.Errorf("获取存储桶地域失败: %w", err)
+	}
+
+	var ossConfig models.OSSConfig
+	if err := s.db.First(&ossConfig, file.ConfigID).Error; err != nil {
+		return "", nil, fmt.Errorf("获取存储配置失败: %w", err)
+	}
+
+	storage, err := s.storageFactory.GetStorageService(ossConfig.StorageType)
+	if err != nil {
+		return "", nil, err
+	}
+	return mapping.RegionCode, storage, nil
+}
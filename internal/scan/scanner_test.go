@@ -0,0 +1,92 @@
+package scan
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestScanFileSkipsOversizedFile(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec(`UPDATE "oss_files" SET`).
+		WithArgs(sqlmock.AnyArg(), models.ScanStatusSkipped, sqlmock.AnyArg(), sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// 存储工厂为 nil：超过上限的文件不应读取对象
+	s := NewScanner(nil, db, config.ScanConfig{MaxScanSize: 1024})
+	file := &models.OSSFile{FileSize: 2048}
+	file.ID = 7
+	if err := s.ScanFile(file); err != nil {
+		t.Fatalf("ScanFile() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFailTruncatesReasonByRune(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cause := errors.New(strings.Repeat("读", 300))
+	mock.ExpectExec(`UPDATE "oss_files" SET`).
+		WithArgs(strings.Repeat("读", maxScanResultLength), models.ScanStatusFailed, sqlmock.AnyArg(), sqlmock.AnyArg(), 7).
+		WillReturnError(errors.New("connection reset"))
+
+	s := NewScanner(nil, db, config.ScanConfig{})
+	file := &models.OSSFile{}
+	file.ID = 7
+	// 状态写入失败时返回的错误同时包含扫描错误和数据库错误
+	err = s.fail(file, cause)
+	if !errors.Is(err, cause) || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("fail() error = %v, want both scan and database errors", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewScannerNormalizesQuarantinePrefix(t *testing.T) {
+	tests := map[string]string{
+		"":             "",
+		"quarantine":   "quarantine/",
+		"/quarantine/": "quarantine/",
+	}
+	for prefix, want := range tests {
+		s := NewScanner(nil, nil, config.ScanConfig{QuarantinePrefix: prefix})
+		if s.cfg.QuarantinePrefix != want {
+			t.Errorf("QuarantinePrefix(%q) = %q, want %q", prefix, s.cfg.QuarantinePrefix, want)
+		}
+	}
+}
+
+func TestQuarantineSkipsOverlongKey(t *testing.T) {
+	s := NewScanner(nil, nil, config.ScanConfig{QuarantinePrefix: "quarantine"})
+	file := &models.OSSFile{ObjectKey: strings.Repeat("a", maxObjectKeyLength-5)}
+	// 存储为 nil：超过长度时不应复制对象
+	if key, ok := s.quarantine(nil, file, "cn-hangzhou"); ok {
+		t.Errorf("quarantine() = %q, true; want original location kept", key)
+	}
+}
@@ -0,0 +1,71 @@
+package scrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"go.uber.org/zap"
+)
+
+// maxAlertIssues 单次告警最多携带的问题数，其余只体现在汇总数量中
+const maxAlertIssues = 100
+
+// alertPayload 告警 Webhook 的请求体
+type alertPayload struct {
+	Event  string                  `json:"event"`
+	Run    *Run                    `json:"run"`
+	Issues []models.IntegrityIssue `json:"issues"`
+}
+
+// alerter 通过 Webhook 发送完整性告警
+type alerter struct {
+	webhook string
+	client  *http.Client
+}
+
+func newAlerter(webhook string) *alerter {
+	return &alerter{
+		webhook: webhook,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// alert 发送告警，未配置 Webhook 时只记录日志；发送失败不影响巡检
+func (a *alerter) alert(run *Run, issues []models.IntegrityIssue) {
+	logger.Error("完整性巡检发现新问题",
+		zap.Int("new_issues", len(issues)),
+		zap.Int("missing", run.Missing),
+		zap.Int("corrupt", run.Corrupt),
+	)
+	if a.webhook == "" {
+		return
+	}
+
+	if len(issues) > maxAlertIssues {
+		issues = issues[:maxAlertIssues]
+	}
+	if err := a.post(alertPayload{Event: "integrity.issues", Run: run, Issues: issues}); err != nil {
+		logger.Error("发送完整性告警失败", zap.String("webhook", a.webhook), zap.Error(err))
+	}
+}
+
+func (a *alerter) post(payload alertPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Post(a.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,480 @@
+// Package scrub 定期巡检存储对象的完整性，发现存储端被删除或被修改的文件
+package scrub
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultInterval  = 60 // 分钟
+	defaultBatchSize = 1000
+	defaultWorkers   = 4
+)
+
+// ErrUnavailable 文件在完整性巡检中被标记为缺失或损坏
+var ErrUnavailable = errors.New("文件在存储端已丢失或已损坏，暂时无法下载")
+
+var defaultScrubber *Scrubber
+
+// SetDefault 设置默认巡检器，管理接口通过它查询巡检状态和触发巡检
+func SetDefault(s *Scrubber) {
+	defaultScrubber = s
+}
+
+// Default 返回默认巡检器，未设置时返回 nil
+func Default() *Scrubber {
+	return defaultScrubber
+}
+
+// CheckDownload 检查文件是否因完整性问题不允许下载
+func CheckDownload(file *models.OSSFile) error {
+	if file.Status == models.FileStatusCorrupt || file.Status == models.FileStatusMissing {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// checkedStatuses 参与巡检的文件状态，已标记的文件继续巡检，恢复后重新标记为 ACTIVE
+var checkedStatuses = []string{"ACTIVE", models.FileStatusCorrupt, models.FileStatusMissing}
+
+// finding 单项检查发现的问题
+type finding struct {
+	Kind     string
+	Expected string
+	Actual   string
+}
+
+// Result 单个文件的检查结果
+type Result struct {
+	FileID        uint                    `json:"file_id"`
+	Status        string                  `json:"status"`
+	Restored      bool                    `json:"restored"`          // 之前标记为缺失或损坏，本次检查正常
+	ContentVerify bool                    `json:"content_verified"`  // 是否下载对象校验了摘要
+	Skipped       bool                    `json:"skipped,omitempty"` // 检查期间文件被移入回收站等，不再参与巡检，结果未保存
+	Issues        []models.IntegrityIssue `json:"issues,omitempty"`  // 本次新发现的问题
+}
+
+// Run 一轮巡检的汇总
+type Run struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Checked    int       `json:"checked"`
+	Verified   int       `json:"content_verified"`
+	Missing    int       `json:"missing"`
+	Corrupt    int       `json:"corrupt"`
+	Restored   int       `json:"restored"`
+	Errors     int       `json:"errors"`
+	NewIssues  int       `json:"new_issues"`
+}
+
+// Scrubber 完整性巡检器
+// 对比数据库记录与存储端对象的大小、ETag 和已保存的摘要，将存储端已删除的文件标记为 MISSING，内容不符的标记为 CORRUPT
+type Scrubber struct {
+	storageFactory oss.StorageFactory
+	db             *gorm.DB
+	cfg            config.ScrubConfig
+	alerter        *alerter
+	wakeCh         chan struct{}
+	stopCh         chan struct{}
+	stopOnce       sync.Once
+	wg             sync.WaitGroup
+
+	mu      sync.Mutex
+	running bool
+	lastRun *Run
+}
+
+// NewScrubber 创建完整性巡检器
+func NewScrubber(storageFactory oss.StorageFactory, db *gorm.DB, cfg config.ScrubConfig) *Scrubber {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	return &Scrubber{
+		storageFactory: storageFactory,
+		db:             db,
+		cfg:            cfg,
+		alerter:        newAlerter(cfg.AlertWebhook),
+		wakeCh:         make(chan struct{}, 1),
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// Start 启动定时巡检，未启用时不做任何事
+func (s *Scrubber) Start() {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Duration(s.cfg.Interval) * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+			case <-s.wakeCh:
+			}
+			s.RunOnce()
+		}
+	}()
+
+	logger.Info("完整性巡检器已启动",
+		zap.Int("interval_minutes", s.cfg.Interval),
+		zap.Int("batch_size", s.cfg.BatchSize),
+		zap.Float64("content_sample", s.cfg.ContentSample),
+	)
+}
+
+// Stop 停止定时巡检
+func (s *Scrubber) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	s.wg.Wait()
+}
+
+// Trigger 唤醒巡检循环立即执行一轮，未启用定时巡检时在后台执行
+func (s *Scrubber) Trigger() {
+	if !s.cfg.Enabled {
+		go s.RunOnce()
+		return
+	}
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// LastRun 返回本实例最近一轮巡检的汇总，以及当前是否正在巡检
+func (s *Scrubber) LastRun() (*Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastRun, s.running
+}
+
+// RunOnce 检查一批最久未检查的文件，同一时间本实例只执行一轮
+func (s *Scrubber) RunOnce() *Run {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	run := &Run{StartedAt: time.Now()}
+	defer func() {
+		run.FinishedAt = time.Now()
+		s.mu.Lock()
+		s.running = false
+		s.lastRun = run
+		s.mu.Unlock()
+	}()
+
+	var files []models.OSSFile
+	if err := s.db.Where("status IN ?", checkedStatuses).
+		Order("verified_at ASC NULLS FIRST, id").Limit(s.cfg.BatchSize).Find(&files).Error; err != nil {
+		logger.Error("获取待巡检文件失败", zap.Error(err))
+		return run
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		issues []models.IntegrityIssue
+	)
+	sem := make(chan struct{}, s.cfg.Workers)
+	for i := range files {
+		select {
+		case <-s.stopCh:
+			wg.Wait()
+			return run
+		default:
+		}
+		if !s.claim(&files[i]) {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(file *models.OSSFile) {
+			defer func() { <-sem; wg.Done() }()
+			result, err := s.Verify(file, rand.Float64() < s.cfg.ContentSample)
+
+			mu.Lock()
+			defer mu.Unlock()
+			run.Checked++
+			if err != nil {
+				run.Errors++
+				logger.Warn("巡检文件失败", zap.Uint("file_id", file.ID), zap.Error(err))
+				return
+			}
+			if result.ContentVerify {
+				run.Verified++
+			}
+			switch result.Status {
+			case models.FileStatusMissing:
+				run.Missing++
+			case models.FileStatusCorrupt:
+				run.Corrupt++
+			}
+			if result.Restored {
+				run.Restored++
+			}
+			issues = append(issues, result.Issues...)
+		}(&files[i])
+	}
+	wg.Wait()
+
+	run.NewIssues = len(issues)
+	logger.Info("完整性巡检完成",
+		zap.Int("checked", run.Checked),
+		zap.Int("missing", run.Missing),
+		zap.Int("corrupt", run.Corrupt),
+		zap.Int("restored", run.Restored),
+		zap.Int("errors", run.Errors),
+		zap.Int("new_issues", run.NewIssues),
+	)
+	if len(issues) > 0 {
+		s.alerter.alert(run, issues)
+	}
+	return run
+}
+
+// claim 记录检查时间，多实例部署时只有一个实例能领取同一个文件
+func (s *Scrubber) claim(file *models.OSSFile) bool {
+	now := time.Now()
+	query := s.db.Model(&models.OSSFile{}).Where("id = ?", file.ID)
+	if file.VerifiedAt == nil {
+		query = query.Where("verified_at IS NULL")
+	} else {
+		query = query.Where("verified_at = ?", *file.VerifiedAt)
+	}
+	result := query.UpdateColumn("verified_at", now)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// Verify 检查单个文件并记录结果；verifyContent 为 true 时下载对象重新计算摘要
+// 存储端暂时不可用等无法判断的错误直接返回，不改变文件状态
+func (s *Scrubber) Verify(file *models.OSSFile, verifyContent bool) (*Result, error) {
+	regionCode, storage, err := s.storageFor(file)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := storage.StatObjectInBucket(file.ObjectKey, regionCode, file.Bucket)
+	if errors.Is(err, oss.ErrObjectNotFound) {
+		return s.record(file, nil, false, []finding{{Kind: models.IntegrityIssueMissing}})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取对象信息失败: %w", err)
+	}
+
+	sums, err := hashing.Load(s.db, file.ID)
+	if err != nil {
+		return nil, fmt.Errorf("获取文件摘要失败: %w", err)
+	}
+	if file.MD5 != "" {
+		sums[hashing.MD5] = file.MD5
+	}
+
+	findings := compare(file, info, sums)
+	verified := false
+
+	// ETag 变化不一定意味着内容变化（例如对象被重新复制），能下载校验时以摘要为准
+	etagChanged := len(findings) == 1 && findings[0].Kind == models.IntegrityIssueETagMismatch
+	canVerify := len(sums) > 0 && (s.cfg.MaxContentSize <= 0 || info.Size <= s.cfg.MaxContentSize)
+	if canVerify && (etagChanged || (verifyContent && len(findings) == 0)) {
+		findings, err = s.verifyContent(storage, regionCode, file, sums)
+		if err != nil {
+			return nil, err
+		}
+		verified = true
+	}
+
+	return s.record(file, info, verified, findings)
+}
+
+// verifyContent 下载对象并与已保存的摘要对比
+func (s *Scrubber) verifyContent(storage oss.StorageService, regionCode string, file *models.OSSFile, sums map[string]string) ([]finding, error) {
+	body, err := storage.GetObjectFromBucket(file.ObjectKey, regionCode, file.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("读取对象失败: %w", err)
+	}
+	defer body.Close()
+
+	algs := make([]string, 0, len(sums))
+	for alg := range sums {
+		algs = append(algs, alg)
+	}
+	set := hashing.NewSet(algs...)
+	if _, err := io.Copy(set, body); err != nil {
+		return nil, fmt.Errorf("读取对象内容失败: %w", err)
+	}
+	return compareHashes(sums, set.Sums()), nil
+}
+
+// record 更新文件状态并保存新发现的问题，文件恢复正常时将未解决的问题标记为已解决
+func (s *Scrubber) record(file *models.OSSFile, info *oss.ObjectInfo, verified bool, findings []finding) (*Result, error) {
+	now := time.Now()
+	result := &Result{FileID: file.ID, Status: statusFor(findings), ContentVerify: verified}
+	result.Restored = result.Status == "ACTIVE" && file.Status != "ACTIVE"
+
+	updates := map[string]interface{}{"status": result.Status, "verified_at": now}
+	// 首次检查时记录 ETag；内容校验通过说明 ETag 变化不影响内容，更新为新值
+	if info != nil && len(findings) == 0 && (file.ETag == "" || verified) {
+		updates["etag"] = info.ETag
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 检查期间文件可能被移入回收站（对象键已变化），这时对象不存在不代表文件缺失，不能覆盖其状态
+		updated := tx.Model(&models.OSSFile{}).Where("id = ? AND status IN ?", file.ID, checkedStatuses).UpdateColumns(updates)
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			result.Skipped = true
+			return nil
+		}
+		if len(findings) == 0 {
+			return tx.Model(&models.IntegrityIssue{}).
+				Where("file_id = ? AND resolved_at IS NULL", file.ID).
+				Update("resolved_at", now).Error
+		}
+
+		for _, f := range findings {
+			var count int64
+			if err := tx.Model(&models.IntegrityIssue{}).
+				Where("file_id = ? AND kind = ? AND resolved_at IS NULL", file.ID, f.Kind).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			issue := models.IntegrityIssue{
+				FileID:     file.ID,
+				Bucket:     file.Bucket,
+				ObjectKey:  file.ObjectKey,
+				Kind:       f.Kind,
+				Expected:   f.Expected,
+				Actual:     f.Actual,
+				DetectedAt: now,
+			}
+			if err := tx.Create(&issue).Error; err != nil {
+				return err
+			}
+			result.Issues = append(result.Issues, issue)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存巡检结果失败: %w", err)
+	}
+	if result.Skipped {
+		logger.Info("文件已不再参与巡检，忽略本次结果", zap.Uint("file_id", file.ID))
+		return &Result{FileID: file.ID, Skipped: true}, nil
+	}
+
+	if result.Restored {
+		logger.Info("文件已恢复正常", zap.Uint("file_id", file.ID), zap.String("previous_status", file.Status))
+	}
+	for _, issue := range result.Issues {
+		logger.Error("发现文件完整性问题",
+			zap.Uint("file_id", file.ID),
+			zap.String("bucket", file.Bucket),
+			zap.String("object_key", file.ObjectKey),
+			zap.String("kind", issue.Kind),
+			zap.String("expected", issue.Expected),
+			zap.String("actual", issue.Actual),
+		)
+	}
+	return result, nil
+}
+
+// storageFor 获取文件所在存储桶的地域和存储服务，存储桶未配置区域映射时使用默认区域
+func (s *Scrubber) storageFor(file *models.OSSFile) (string, oss.StorageService, error) {
+	regionCode := ""
+	var mapping models.RegionBucketMapping
+	if err := s.db.Where("bucket_name = ?", file.Bucket).First(&mapping).Error; err == nil {
+		regionCode = mapping.RegionCode
+	}
+
+	storage, err := s.storageFactory.GetStorageService(file.StorageType)
+	if err != nil {
+		return "", nil, fmt.Errorf("获取存储服务失败: %w", err)
+	}
+	return regionCode, storage, nil
+}
+
+// compare 对比文件记录与存储端元数据：大小、ETag（已记录时）以及存储端提供的 CRC64
+func compare(file *models.OSSFile, info *oss.ObjectInfo, sums map[string]string) []finding {
+	if info.Size != file.FileSize {
+		return []finding{{
+			Kind:     models.IntegrityIssueSizeMismatch,
+			Expected: fmt.Sprint(file.FileSize),
+			Actual:   fmt.Sprint(info.Size),
+		}}
+	}
+	if info.CRC64 != "" && sums[hashing.CRC64] != "" && info.CRC64 != sums[hashing.CRC64] {
+		return []finding{{
+			Kind:     models.IntegrityIssueHashMismatch,
+			Expected: hashing.CRC64 + ":" + sums[hashing.CRC64],
+			Actual:   hashing.CRC64 + ":" + info.CRC64,
+		}}
+	}
+	if file.ETag != "" && info.ETag != file.ETag {
+		return []finding{{
+			Kind:     models.IntegrityIssueETagMismatch,
+			Expected: file.ETag,
+			Actual:   info.ETag,
+		}}
+	}
+	return nil
+}
+
+// compareHashes 对比已保存的摘要与重新计算的摘要
+func compareHashes(want, got map[string]string) []finding {
+	for _, alg := range hashing.All {
+		if want[alg] != "" && got[alg] != "" && want[alg] != got[alg] {
+			return []finding{{
+				Kind:     models.IntegrityIssueHashMismatch,
+				Expected: alg + ":" + want[alg],
+				Actual:   alg + ":" + got[alg],
+			}}
+		}
+	}
+	return nil
+}
+
+// statusFor 根据检查发现的问题确定文件状态
+func statusFor(findings []finding) string {
+	if len(findings) == 0 {
+		return "ACTIVE"
+	}
+	if findings[0].Kind == models.IntegrityIssueMissing {
+		return models.FileStatusMissing
+	}
+	return models.FileStatusCorrupt
+}
@@ -0,0 +1,101 @@
+package scrub
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestCompare(t *testing.T) {
+	file := &models.OSSFile{FileSize: 100, ETag: "abc"}
+	sums := map[string]string{hashing.CRC64: "42"}
+
+	tests := []struct {
+		name string
+		info oss.ObjectInfo
+		want string
+	}{
+		{"ok", oss.ObjectInfo{Size: 100, ETag: "abc", CRC64: "42"}, ""},
+		{"size", oss.ObjectInfo{Size: 99, ETag: "abc"}, models.IntegrityIssueSizeMismatch},
+		{"crc64", oss.ObjectInfo{Size: 100, ETag: "abc", CRC64: "43"}, models.IntegrityIssueHashMismatch},
+		{"etag", oss.ObjectInfo{Size: 100, ETag: "def"}, models.IntegrityIssueETagMismatch},
+	}
+	for _, tt := range tests {
+		findings := compare(file, &tt.info, sums)
+		got := ""
+		if len(findings) > 0 {
+			got = findings[0].Kind
+		}
+		if got != tt.want {
+			t.Errorf("%s: compare() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// 尚未记录 ETag 时不比较
+	if findings := compare(&models.OSSFile{FileSize: 1}, &oss.ObjectInfo{Size: 1, ETag: "x"}, nil); len(findings) != 0 {
+		t.Errorf("compare() without recorded etag = %v, want none", findings)
+	}
+}
+
+func TestCompareHashes(t *testing.T) {
+	want := map[string]string{hashing.MD5: "aa", hashing.SHA256: "bb"}
+	if findings := compareHashes(want, map[string]string{hashing.MD5: "aa", hashing.SHA256: "bb"}); len(findings) != 0 {
+		t.Errorf("compareHashes() matching = %v, want none", findings)
+	}
+	findings := compareHashes(want, map[string]string{hashing.MD5: "aa", hashing.SHA256: "cc"})
+	if len(findings) != 1 || findings[0].Expected != "sha256:bb" || findings[0].Actual != "sha256:cc" {
+		t.Errorf("compareHashes() mismatch = %v", findings)
+	}
+}
+
+func TestStatusFor(t *testing.T) {
+	if got := statusFor(nil); got != "ACTIVE" {
+		t.Errorf("statusFor(nil) = %q, want ACTIVE", got)
+	}
+	if got := statusFor([]finding{{Kind: models.IntegrityIssueMissing}}); got != models.FileStatusMissing {
+		t.Errorf("statusFor(missing) = %q", got)
+	}
+	if got := statusFor([]finding{{Kind: models.IntegrityIssueSizeMismatch}}); got != models.FileStatusCorrupt {
+		t.Errorf("statusFor(size) = %q", got)
+	}
+	if CheckDownload(&models.OSSFile{Status: models.FileStatusMissing}) == nil {
+		t.Error("CheckDownload() should reject missing files")
+	}
+}
+
+func TestRecordSkipsTrashedFile(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewScrubber(nil, db, config.ScrubConfig{})
+
+	// 检查期间文件被移入回收站，状态已不在巡检范围内，不能标记为缺失或保存问题
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "oss_files" SET .* WHERE \(id = \$\d+ AND status IN \(\$\d+,\$\d+,\$\d+\)\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	file := &models.OSSFile{Model: models.Model{ID: 7}, Status: "ACTIVE"}
+	result, err := s.record(file, nil, false, []finding{{Kind: models.IntegrityIssueMissing}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Skipped || result.Status != "" || len(result.Issues) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,160 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"gorm.io/gorm"
+)
+
+// 分页大小
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// 排序字段
+const (
+	SortCreatedAt = "created_at"
+	SortSize      = "file_size"
+	SortName      = "original_filename"
+)
+
+// ErrInvalidCursor 游标无法解析或与当前排序方式不一致
+var ErrInvalidCursor = errors.New("无效的分页游标")
+
+// Page 排序和游标分页参数
+type Page struct {
+	Sort   string
+	Desc   bool
+	Limit  int
+	Cursor *Cursor // 为空时从第一条开始
+
+	value any // 游标中的排序值，已转换为对应列的类型
+}
+
+// Cursor 上一页最后一条记录的排序值和ID，编码后返回给客户端
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// ParsePage 从查询参数解析 sort（created_at、file_size、original_filename）、order（asc、desc）、limit 和 cursor，
+// 默认按上传时间倒序
+func ParsePage(values url.Values) (*Page, error) {
+	p := &Page{Sort: values.Get("sort"), Desc: true, Limit: DefaultLimit}
+	switch p.Sort {
+	case "":
+		p.Sort = SortCreatedAt
+	case SortCreatedAt, SortSize, SortName:
+	default:
+		return nil, fmt.Errorf("不支持的排序字段: %s", p.Sort)
+	}
+
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		p.Desc = false
+	default:
+		return nil, fmt.Errorf("order 应为 asc 或 desc")
+	}
+
+	if s := values.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("limit 应为正整数")
+		}
+		p.Limit = min(limit, MaxLimit)
+	}
+
+	if s := values.Get("cursor"); s != "" {
+		cursor, err := DecodeCursor(s)
+		if err != nil || cursor.Sort != p.Sort {
+			return nil, ErrInvalidCursor
+		}
+		p.Cursor = cursor
+		if p.value, err = p.cursorValue(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// DecodeCursor 解析 base64url 编码的游标
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// Encode 将游标编码为 base64url 字符串
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Apply 加入游标条件、排序和 limit+1 的行数限制，多取的一行用于判断是否还有下一页
+func (p *Page) Apply(db *gorm.DB) *gorm.DB {
+	column := "oss_files." + p.Sort
+	op, dir := ">", "ASC"
+	if p.Desc {
+		op, dir = "<", "DESC"
+	}
+
+	if p.Cursor != nil {
+		db = db.Where(fmt.Sprintf("(%s, oss_files.id) %s (?, ?)", column, op), p.value, p.Cursor.ID)
+	}
+	return db.Order(fmt.Sprintf("%s %s, oss_files.id %s", column, dir, dir)).Limit(p.Limit + 1)
+}
+
+// cursorValue 将游标中的排序值转换为对应列的类型
+func (p *Page) cursorValue() (any, error) {
+	switch p.Sort {
+	case SortCreatedAt:
+		t, err := time.Parse(time.RFC3339Nano, p.Cursor.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return t, nil
+	case SortSize:
+		n, err := strconv.ParseInt(p.Cursor.Value, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return n, nil
+	default:
+		return p.Cursor.Value, nil
+	}
+}
+
+// Next 截断按 Apply 查询到的文件，还有下一页时返回下一页的游标
+func (p *Page) Next(files []models.OSSFile) ([]models.OSSFile, string) {
+	if len(files) <= p.Limit {
+		return files, ""
+	}
+	files = files[:p.Limit]
+	last := files[len(files)-1]
+	cursor := &Cursor{Sort: p.Sort, ID: last.ID}
+	switch p.Sort {
+	case SortCreatedAt:
+		cursor.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	case SortSize:
+		cursor.Value = strconv.FormatInt(last.FileSize, 10)
+	default:
+		cursor.Value = last.OriginalFilename
+	}
+	return files, cursor.Encode()
+}
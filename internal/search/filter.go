@@ -0,0 +1,212 @@
+// Package search 按文件名、路径、大小、上传时间、上传者、标签和摘要等条件查询文件目录，结果按游标分页
+package search
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
+	"gorm.io/gorm"
+)
+
+// dateLayout 只有日期的时间参数格式，按本地时区解析
+const dateLayout = "2006-01-02"
+
+// Filter 文件搜索条件，零值表示不限制
+type Filter struct {
+	Name           string    // 文件名子串，包含 * 或 ? 时按通配符匹配整个文件名
+	Prefix         string    // 对象键前缀（目录）
+	Bucket         string    // 存储桶
+	MinSize        int64     // 最小字节数（含）
+	MaxSize        int64     // 最大字节数（含），0 表示不限制
+	UploadedAfter  time.Time // 上传时间下限（含）
+	UploadedBefore time.Time // 上传时间上限（不含）
+	UploaderID     uint      // 上传者ID
+	Uploader       string    // 上传者用户名
+	MD5Status      string
+	Status         string   // 文件状态，默认 ACTIVE
+	Tags           []string // 同时带有所有这些标签
+	HashAlgorithm  string
+	Hash           string // 摘要值，已转换为存储格式
+}
+
+// ParseFilter 从查询参数解析搜索条件
+//
+// 支持的参数：name、prefix、bucket、min_size、max_size、uploaded_after、uploaded_before（RFC3339 或 YYYY-MM-DD）、
+// uploader（用户ID或用户名）、md5_status、status、tag（可重复）、hash 与 hash_algorithm（默认 md5）
+func ParseFilter(values url.Values) (*Filter, error) {
+	f := &Filter{
+		Name:      strings.TrimSpace(values.Get("name")),
+		Prefix:    strings.TrimLeft(values.Get("prefix"), "/"),
+		Bucket:    values.Get("bucket"),
+		MD5Status: strings.ToUpper(values.Get("md5_status")),
+		Status:    strings.ToUpper(values.Get("status")),
+	}
+	if f.Status == "" {
+		f.Status = "ACTIVE"
+	}
+
+	var err error
+	if f.MinSize, err = parseSize(values, "min_size"); err != nil {
+		return nil, err
+	}
+	if f.MaxSize, err = parseSize(values, "max_size"); err != nil {
+		return nil, err
+	}
+	if f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return nil, fmt.Errorf("min_size 不能大于 max_size")
+	}
+	if f.UploadedAfter, err = parseTime(values, "uploaded_after"); err != nil {
+		return nil, err
+	}
+	if f.UploadedBefore, err = parseTime(values, "uploaded_before"); err != nil {
+		return nil, err
+	}
+
+	if uploader := strings.TrimSpace(values.Get("uploader")); uploader != "" {
+		if id, err := strconv.ParseUint(uploader, 10, 32); err == nil {
+			f.UploaderID = uint(id)
+		} else {
+			f.Uploader = uploader
+		}
+	}
+
+	for _, tag := range values["tag"] {
+		if tag = NormalizeTag(tag); tag != "" {
+			f.Tags = append(f.Tags, tag)
+		}
+	}
+
+	if hash := values.Get("hash"); hash != "" {
+		alg := values.Get("hash_algorithm")
+		if alg == "" {
+			alg = hashing.MD5
+		}
+		if f.HashAlgorithm, err = hashing.ParseAlgorithm(alg); err != nil {
+			return nil, err
+		}
+		if f.Hash, err = hashing.NormalizeValue(f.HashAlgorithm, hash); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// NormalizeTag 标签不区分大小写，去掉首尾空白后转为小写
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+func parseSize(values url.Values, key string) (int64, error) {
+	s := values.Get(key)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s 应为非负整数", key)
+	}
+	return n, nil
+}
+
+func parseTime(values url.Values, key string) (time.Time, error) {
+	s := values.Get(key)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(dateLayout, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s 应为 RFC3339 时间或 YYYY-MM-DD 日期", key)
+}
+
+// Apply 将搜索条件加入 oss_files 查询
+func (f *Filter) Apply(db *gorm.DB) *gorm.DB {
+	db = db.Where("oss_files.status = ?", f.Status)
+	if f.Name != "" {
+		if IsGlob(f.Name) {
+			db = db.Where("oss_files.original_filename ILIKE ?", GlobToLike(f.Name))
+		} else {
+			db = db.Where("oss_files.original_filename ILIKE ?", "%"+EscapeLike(f.Name)+"%")
+		}
+	}
+	if f.Prefix != "" {
+		db = db.Where("oss_files.object_key LIKE ?", EscapeLike(f.Prefix)+"%")
+	}
+	if f.Bucket != "" {
+		db = db.Where("oss_files.bucket = ?", f.Bucket)
+	}
+	if f.MinSize > 0 {
+		db = db.Where("oss_files.file_size >= ?", f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		db = db.Where("oss_files.file_size <= ?", f.MaxSize)
+	}
+	if !f.UploadedAfter.IsZero() {
+		db = db.Where("oss_files.created_at >= ?", f.UploadedAfter)
+	}
+	if !f.UploadedBefore.IsZero() {
+		db = db.Where("oss_files.created_at < ?", f.UploadedBefore)
+	}
+	if f.UploaderID != 0 {
+		db = db.Where("oss_files.uploader_id = ?", f.UploaderID)
+	}
+	if f.Uploader != "" {
+		db = db.Where("oss_files.uploader_id IN (?)",
+			db.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Select("id").Where("username = ?", f.Uploader))
+	}
+	if f.MD5Status != "" {
+		db = db.Where("oss_files.md5_status = ?", f.MD5Status)
+	}
+	for _, tag := range f.Tags {
+		db = db.Where("oss_files.id IN (?)",
+			db.Session(&gorm.Session{NewDB: true}).Model(&models.FileTag{}).Select("file_id").Where("name = ?", tag))
+	}
+	if f.Hash != "" {
+		matched := db.Session(&gorm.Session{NewDB: true}).Model(&models.FileHash{}).Select("file_id").
+			Where("algorithm = ? AND value = ?", f.HashAlgorithm, f.Hash)
+		if f.HashAlgorithm == hashing.MD5 {
+			// 早于摘要表写入的文件只在 oss_files.md5 中有记录
+			db = db.Where("(oss_files.md5 = ? OR oss_files.id IN (?))", f.Hash, matched)
+		} else {
+			db = db.Where("oss_files.id IN (?)", matched)
+		}
+	}
+	return db
+}
+
+// IsGlob 是否包含通配符 * 或 ?
+func IsGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
+
+// GlobToLike 将通配符模式转换为 LIKE 模式：* 匹配任意字符串，? 匹配单个字符
+func GlobToLike(pattern string) string {
+	var b strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		case '%', '_', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// EscapeLike 转义 LIKE 模式中的通配符
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,148 @@
+package search
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
+)
+
+func TestParseFilter(t *testing.T) {
+	values := url.Values{
+		"name":            {" *.PDF "},
+		"prefix":          {"/docs/"},
+		"min_size":        {"10"},
+		"max_size":        {"100"},
+		"uploaded_after":  {"2025-01-02"},
+		"uploaded_before": {"2025-01-09T00:00:00Z"},
+		"uploader":        {"bob"},
+		"md5_status":      {"completed"},
+		"tag":             {" Report ", "", "q1"},
+		"hash":            {"D41D8CD98F00B204E9800998ECF8427E"},
+	}
+	f, err := ParseFilter(values)
+	if err != nil {
+		t.Fatalf("ParseFilter() error = %v", err)
+	}
+	if f.Name != "*.PDF" || f.Prefix != "docs/" || f.MinSize != 10 || f.MaxSize != 100 {
+		t.Errorf("f = %+v", f)
+	}
+	if want := time.Date(2025, 1, 2, 0, 0, 0, 0, time.Local); !f.UploadedAfter.Equal(want) {
+		t.Errorf("UploadedAfter = %v, want %v", f.UploadedAfter, want)
+	}
+	if f.Uploader != "bob" || f.UploaderID != 0 || f.MD5Status != "COMPLETED" || f.Status != "ACTIVE" {
+		t.Errorf("f = %+v", f)
+	}
+	if len(f.Tags) != 2 || f.Tags[0] != "report" || f.Tags[1] != "q1" {
+		t.Errorf("Tags = %v", f.Tags)
+	}
+	if f.HashAlgorithm != hashing.MD5 || f.Hash != "d41d8cd98f00b204e9800998ecf8427e" {
+		t.Errorf("hash = %s:%s", f.HashAlgorithm, f.Hash)
+	}
+
+	if f, err := ParseFilter(url.Values{"uploader": {"42"}}); err != nil || f.UploaderID != 42 || f.Uploader != "" {
+		t.Errorf("ParseFilter(uploader=42) = %+v, %v", f, err)
+	}
+
+	for _, bad := range []url.Values{
+		{"min_size": {"-1"}},
+		{"min_size": {"10"}, "max_size": {"5"}},
+		{"uploaded_after": {"yesterday"}},
+		{"hash": {"abc"}, "hash_algorithm": {"md4"}},
+		{"hash": {"xyz"}},
+	} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("ParseFilter(%v) error = nil", bad)
+		}
+	}
+}
+
+func TestGlobToLike(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"*.pdf", "%.pdf"},
+		{"report-202?.xlsx", "report-202_.xlsx"},
+		{"100%_*", `100\%\_%`},
+	}
+	for _, tt := range tests {
+		if !IsGlob(tt.pattern) {
+			t.Errorf("IsGlob(%q) = false", tt.pattern)
+		}
+		if got := GlobToLike(tt.pattern); got != tt.want {
+			t.Errorf("GlobToLike(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+	if IsGlob("report.pdf") {
+		t.Error("IsGlob(report.pdf) = true")
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	p, err := ParsePage(url.Values{})
+	if err != nil || p.Sort != SortCreatedAt || !p.Desc || p.Limit != DefaultLimit || p.Cursor != nil {
+		t.Errorf("ParsePage() = %+v, %v", p, err)
+	}
+
+	p, err = ParsePage(url.Values{"sort": {"file_size"}, "order": {"asc"}, "limit": {"1000"}})
+	if err != nil || p.Sort != SortSize || p.Desc || p.Limit != MaxLimit {
+		t.Errorf("ParsePage() = %+v, %v", p, err)
+	}
+
+	for _, bad := range []url.Values{
+		{"sort": {"id; drop table"}},
+		{"order": {"up"}},
+		{"limit": {"0"}},
+		{"cursor": {"!!"}},
+		{"sort": {"file_size"}, "cursor": {(&Cursor{Sort: SortCreatedAt, Value: "2025-01-01T00:00:00Z", ID: 1}).Encode()}},
+		{"sort": {"file_size"}, "cursor": {(&Cursor{Sort: SortSize, Value: "abc", ID: 1}).Encode()}},
+	} {
+		if _, err := ParsePage(bad); err == nil {
+			t.Errorf("ParsePage(%v) error = nil", bad)
+		}
+	}
+	if _, err := ParsePage(url.Values{"cursor": {"e30"}}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ParsePage(empty cursor) error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestPageNext(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC)
+	files := make([]models.OSSFile, 3)
+	for i := range files {
+		files[i].ID = uint(i + 1)
+		files[i].CreatedAt = created.Add(-time.Duration(i) * time.Hour)
+		files[i].FileSize = int64(i * 10)
+	}
+
+	p := &Page{Sort: SortCreatedAt, Desc: true, Limit: 3}
+	if got, next := p.Next(files); len(got) != 3 || next != "" {
+		t.Errorf("Next() = %d files, cursor %q", len(got), next)
+	}
+
+	p.Limit = 2
+	got, next := p.Next(files)
+	if len(got) != 2 || next == "" {
+		t.Fatalf("Next() = %d files, cursor %q", len(got), next)
+	}
+
+	// 游标可以被下一页的请求解析，且保留纳秒精度
+	p2, err := ParsePage(url.Values{"cursor": {next}})
+	if err != nil {
+		t.Fatalf("ParsePage(next) error = %v", err)
+	}
+	if p2.Cursor.ID != 2 || !p2.value.(time.Time).Equal(files[1].CreatedAt) {
+		t.Errorf("cursor = %+v, value = %v", p2.Cursor, p2.value)
+	}
+
+	p = &Page{Sort: SortSize, Limit: 1}
+	if _, next := p.Next(files); next == "" {
+		t.Error("Next(file_size) cursor is empty")
+	} else if c, _ := DecodeCursor(next); c.Value != "0" || c.ID != 1 {
+		t.Errorf("cursor = %+v", c)
+	}
+}
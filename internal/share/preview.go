@@ -0,0 +1,33 @@
+package share
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// inlineTypes 可以在浏览器中直接预览的类型，这些类型不会执行脚本；HTML、SVG 等其余类型一律作为附件下载
+var inlineTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"image/bmp":       true,
+	"image/avif":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// PreviewType 按文件名返回预览时的 Content-Type 以及能否 inline 输出
+// 预览与前端页面同源，不在允许列表中的类型以 application/octet-stream 作为附件输出，避免上传的 HTML/SVG 在本站执行脚本
+func PreviewType(filename string) (string, bool) {
+	contentType := mime.TypeByExtension(strings.ToLower(path.Ext(filename)))
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !inlineTypes[mediaType] {
+		return "application/octet-stream", false
+	}
+	if mediaType == "text/plain" {
+		return "text/plain; charset=utf-8", true
+	}
+	return mediaType, true
+}
@@ -0,0 +1,114 @@
+// Package share 实现文件和目录的公开分享链接：随机短链、访问密码、有效期、下载次数限制和访问记录；
+// 以及供外部人员上传文件的上传请求链接
+package share
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/utils"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// slugBytes 短链随机字节数，base64url 编码后为 16 个字符
+const slugBytes = 12
+
+var (
+	ErrExpired          = errors.New("分享链接已过期")
+	ErrLimitReached     = errors.New("分享链接的下载次数已用完")
+	ErrPasswordRequired = errors.New("需要访问密码")
+	ErrWrongPassword    = errors.New("访问密码错误")
+	ErrPreviewOnly      = errors.New("该分享只允许在线预览")
+	ErrNotShared        = errors.New("文件不在分享范围内")
+)
+
+// NewSlug 生成随机短链
+func NewSlug() (string, error) {
+	b := make([]byte, slugBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashPassword 计算访问密码的哈希，密码为空时返回空字符串表示不需要密码
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword 按 HashPassword 返回的哈希校验访问密码，哈希为空表示不需要密码
+func CheckPassword(hash, password string) error {
+	if hash == "" {
+		return nil
+	}
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// Check 检查分享是否过期或下载次数已用完
+func Check(s *models.Share, now time.Time) error {
+	if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+		return ErrExpired
+	}
+	if s.MaxDownloads > 0 && s.Downloads >= s.MaxDownloads {
+		return ErrLimitReached
+	}
+	return nil
+}
+
+// Contains 文件是否在分享范围内：单文件分享比较文件ID，目录分享比较存储桶和前缀
+func Contains(s *models.Share, file *models.OSSFile) bool {
+	if s.FileID != 0 {
+		return file.ID == s.FileID
+	}
+	return file.Bucket == s.Bucket && strings.HasPrefix(file.ObjectKey, s.Prefix)
+}
+
+// Consume 占用一次下载次数，并发访问时不会超过上限
+func Consume(db *gorm.DB, s *models.Share) error {
+	result := db.Model(&models.Share{}).
+		Where("id = ? AND (max_downloads = 0 OR downloads < max_downloads)", s.ID).
+		Updates(map[string]interface{}{
+			"downloads":      gorm.Expr("downloads + 1"),
+			"last_access_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLimitReached
+	}
+	s.Downloads++
+	return nil
+}
+
+// Log 记录一次访问，err 不为空时记录为拒绝访问；写入失败只记录日志
+func Log(db *gorm.DB, entry models.ShareAccessLog, err error) {
+	entry.Allowed = err == nil
+	if err != nil {
+		entry.Reason = utils.Truncate(err.Error(), 100)
+	}
+	entry.UserAgent = utils.Truncate(entry.UserAgent, 255)
+	if err := db.Create(&entry).Error; err != nil {
+		logger.Warn("记录分享访问失败", zap.Uint("share_id", entry.ShareID), zap.Error(err))
+	}
+}
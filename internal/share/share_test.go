@@ -0,0 +1,232 @@
+package share
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestNewSlug(t *testing.T) {
+	a, err := NewSlug()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSlug()
+	if len(a) != 16 || a == b || strings.ContainsAny(a, "+/=") {
+		t.Errorf("NewSlug() = %q, %q", a, b)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	if err := CheckPassword("", ""); err != nil {
+		t.Errorf("CheckPassword(no password) error = %v", err)
+	}
+
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPassword(hash, "secret"); err != nil {
+		t.Errorf("CheckPassword(correct) error = %v", err)
+	}
+	if err := CheckPassword(hash, ""); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("CheckPassword(empty) error = %v, want ErrPasswordRequired", err)
+	}
+	if err := CheckPassword(hash, "wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("CheckPassword(wrong) error = %v, want ErrWrongPassword", err)
+	}
+
+	if hash, err := HashPassword(""); err != nil || hash != "" {
+		t.Errorf("HashPassword(\"\") = %q, %v", hash, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	tests := []struct {
+		share models.Share
+		want  error
+	}{
+		{models.Share{}, nil},
+		{models.Share{ExpiresAt: &future, MaxDownloads: 2, Downloads: 1}, nil},
+		{models.Share{ExpiresAt: &past}, ErrExpired},
+		{models.Share{MaxDownloads: 2, Downloads: 2}, ErrLimitReached},
+	}
+	for i, tt := range tests {
+		if err := Check(&tt.share, now); !errors.Is(err, tt.want) {
+			t.Errorf("#%d Check() error = %v, want %v", i, err, tt.want)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	fileShare := &models.Share{FileID: 3, Bucket: "docs"}
+	folderShare := &models.Share{Bucket: "docs", Prefix: "reports/"}
+	tests := []struct {
+		share *models.Share
+		file  models.OSSFile
+		want  bool
+	}{
+		{fileShare, models.OSSFile{Model: models.Model{ID: 3}, Bucket: "docs", ObjectKey: "x"}, true},
+		{fileShare, models.OSSFile{Model: models.Model{ID: 4}, Bucket: "docs", ObjectKey: "x"}, false},
+		{folderShare, models.OSSFile{Bucket: "docs", ObjectKey: "reports/2025/q1.pdf"}, true},
+		{folderShare, models.OSSFile{Bucket: "docs", ObjectKey: "reports-old/q1.pdf"}, false},
+		{folderShare, models.OSSFile{Bucket: "other", ObjectKey: "reports/q1.pdf"}, false},
+	}
+	for i, tt := range tests {
+		if got := Contains(tt.share, &tt.file); got != tt.want {
+			t.Errorf("#%d Contains() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestConsume(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &models.Share{Model: models.Model{ID: 1}, MaxDownloads: 1}
+	mock.ExpectExec(`UPDATE "shares" SET "downloads"=downloads \+ 1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := Consume(db, s); err != nil || s.Downloads != 1 {
+		t.Errorf("Consume() = %v, downloads = %d", err, s.Downloads)
+	}
+
+	mock.ExpectExec(`UPDATE "shares"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := Consume(db, s); !errors.Is(err, ErrLimitReached) {
+		t.Errorf("Consume() error = %v, want ErrLimitReached", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckUpload(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	tests := []struct {
+		req  models.UploadRequest
+		size int64
+		want error
+	}{
+		{models.UploadRequest{}, 1 << 30, nil},
+		{models.UploadRequest{MaxTotalSize: 100, UploadedSize: 40}, 60, nil},
+		{models.UploadRequest{MaxTotalSize: 100, UploadedSize: 40}, 61, ErrSizeExceeded},
+		{models.UploadRequest{ExpiresAt: &past}, 0, ErrRequestExpired},
+	}
+	for i, tt := range tests {
+		if err := CheckUpload(&tt.req, now, tt.size); !errors.Is(err, tt.want) {
+			t.Errorf("#%d CheckUpload() error = %v, want %v", i, err, tt.want)
+		}
+	}
+
+	if got := Remaining(&models.UploadRequest{}); got != -1 {
+		t.Errorf("Remaining(unlimited) = %d, want -1", got)
+	}
+	if got := Remaining(&models.UploadRequest{MaxTotalSize: 100, UploadedSize: 120}); got != 0 {
+		t.Errorf("Remaining(over) = %d, want 0", got)
+	}
+}
+
+func TestUploadKey(t *testing.T) {
+	r := &models.UploadRequest{Prefix: "inbox/acme/"}
+	tests := []struct {
+		filename string
+		want     string
+		wantErr  bool
+	}{
+		{"report.pdf", "inbox/acme/report.pdf", false},
+		{"../../etc/passwd", "inbox/acme/passwd", false},
+		{`C:\Users\me\scan.png`, "inbox/acme/scan.png", false},
+		{"", "", true},
+		{"..", "", true},
+		{"what?.txt", "", true},
+	}
+	for _, tt := range tests {
+		got, err := UploadKey(r, tt.filename)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("UploadKey(%q) = %q, %v", tt.filename, got, err)
+		}
+	}
+}
+
+func TestUploadPolicy(t *testing.T) {
+	if p := UploadPolicy(&models.UploadRequest{}); p != nil {
+		t.Errorf("UploadPolicy(no limits) = %+v, want nil", p)
+	}
+	p := UploadPolicy(&models.UploadRequest{AllowedExtensions: "pdf, .docx"})
+	if err := p.CheckFile("contract.PDF", 10); err != nil {
+		t.Errorf("CheckFile(pdf) error = %v", err)
+	}
+	if err := p.CheckFile("setup.exe", 10); err == nil {
+		t.Error("CheckFile(exe) error = nil, want violation")
+	}
+}
+
+func TestReserve(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &models.UploadRequest{Model: models.Model{ID: 1}, MaxTotalSize: 100}
+	mock.ExpectExec(`UPDATE "upload_requests" SET .*uploaded_size \+`).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := Reserve(db, r, 60); err != nil || r.UploadedSize != 60 || r.FileCount != 1 {
+		t.Errorf("Reserve() = %v, uploaded = %d, count = %d", err, r.UploadedSize, r.FileCount)
+	}
+
+	mock.ExpectExec(`UPDATE "upload_requests"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := Reserve(db, r, 60); !errors.Is(err, ErrSizeExceeded) {
+		t.Errorf("Reserve() error = %v, want ErrSizeExceeded", err)
+	}
+
+	mock.ExpectExec(`UPDATE "upload_requests" SET .*GREATEST`).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := Release(db, r, 60); err != nil || r.UploadedSize != 0 || r.FileCount != 0 {
+		t.Errorf("Release() = %v, uploaded = %d, count = %d", err, r.UploadedSize, r.FileCount)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPreviewType(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+		inline   bool
+	}{
+		{"a.PNG", "image/png", true},
+		{"a.jpg", "image/jpeg", true},
+		{"a.pdf", "application/pdf", true},
+		{"a.txt", "text/plain; charset=utf-8", true},
+		{"a.html", "application/octet-stream", false},
+		{"a.htm", "application/octet-stream", false},
+		{"a.svg", "application/octet-stream", false},
+		{"a.xml", "application/octet-stream", false},
+		{"a.js", "application/octet-stream", false},
+		{"noext", "application/octet-stream", false},
+	}
+	for _, tt := range tests {
+		got, inline := PreviewType(tt.filename)
+		if got != tt.want || inline != tt.inline {
+			t.Errorf("PreviewType(%q) = %q, %v, want %q, %v", tt.filename, got, inline, tt.want, tt.inline)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package share
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/policy"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRequestExpired = errors.New("上传链接已过期")
+	ErrSizeExceeded   = errors.New("上传链接的剩余容量不足")
+	ErrInvalidName    = errors.New("无效的文件名")
+)
+
+// CheckUpload 检查上传请求是否过期，以及剩余容量能否容纳 size 字节
+func CheckUpload(r *models.UploadRequest, now time.Time, size int64) error {
+	if r.ExpiresAt != nil && !now.Before(*r.ExpiresAt) {
+		return ErrRequestExpired
+	}
+	if r.MaxTotalSize > 0 && r.UploadedSize+size > r.MaxTotalSize {
+		return ErrSizeExceeded
+	}
+	return nil
+}
+
+// Remaining 返回上传请求的剩余容量，-1 表示不限制
+func Remaining(r *models.UploadRequest) int64 {
+	if r.MaxTotalSize <= 0 {
+		return -1
+	}
+	return max(r.MaxTotalSize-r.UploadedSize, 0)
+}
+
+// UploadPolicy 返回上传请求限定的文件类型策略，没有限制时返回 nil
+// 存储桶自身的上传策略另外校验，两者都需要满足
+func UploadPolicy(r *models.UploadRequest) *policy.Policy {
+	return policy.New(&models.UploadPolicy{
+		AllowedExtensions: r.AllowedExtensions,
+		AllowedMIMETypes:  r.AllowedMIMETypes,
+	}, 0)
+}
+
+// UploadKey 返回上传文件的对象键：上传请求目录下的文件名，不允许包含路径
+func UploadKey(r *models.UploadRequest, filename string) (string, error) {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if name == "." || name == "/" || name == ".." || strings.ContainsAny(name, "<>:\"|?*") {
+		return "", ErrInvalidName
+	}
+	return r.Prefix + name, nil
+}
+
+// Reserve 占用上传请求的容量并计数，并发上传时总大小不会超过上限；上传失败时应调用 Release 归还
+func Reserve(db *gorm.DB, r *models.UploadRequest, size int64) error {
+	result := db.Model(&models.UploadRequest{}).
+		Where("id = ? AND (max_total_size = 0 OR uploaded_size + ? <= max_total_size)", r.ID, size).
+		Updates(map[string]interface{}{
+			"uploaded_size":  gorm.Expr("uploaded_size + ?", size),
+			"file_count":     gorm.Expr("file_count + 1"),
+			"last_upload_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSizeExceeded
+	}
+	r.UploadedSize += size
+	r.FileCount++
+	return nil
+}
+
+// Release 归还 Reserve 占用的容量
+func Release(db *gorm.DB, r *models.UploadRequest, size int64) error {
+	if err := db.Model(&models.UploadRequest{}).Where("id = ?", r.ID).Updates(map[string]interface{}{
+		"uploaded_size": gorm.Expr("GREATEST(uploaded_size - ?, 0)", size),
+		"file_count":    gorm.Expr("GREATEST(file_count - 1, 0)"),
+	}).Error; err != nil {
+		return err
+	}
+	r.UploadedSize = max(r.UploadedSize-size, 0)
+	r.FileCount = max(r.FileCount-1, 0)
+	return nil
+}
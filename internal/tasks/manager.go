@@ -0,0 +1,577 @@
+// Package tasks 持久化的后台任务框架
+// 删除目录、批量计算摘要等耗时操作提交为任务后立即返回，由后台工作协程执行，进度和结果保存在 tasks 表中
+// 多个实例共同领取任务，执行实例失联后任务由其他实例重新执行，因此任务处理函数需要可以重复执行
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/lease"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWorkers       = 2
+	defaultMaxAttempts   = 3
+	defaultLeaseTimeout  = 60 // 秒
+	defaultPollInterval  = 2  // 秒
+	defaultRetentionDays = 7
+	heartbeatInterval    = time.Second
+	cleanupInterval      = time.Hour
+)
+
+var (
+	// ErrUnknownType 没有注册该类型的任务处理函数
+	ErrUnknownType = errors.New("不支持的任务类型")
+	// ErrFinished 任务已结束，不能取消
+	ErrFinished = errors.New("任务已结束")
+	// ErrCanceled 任务被用户取消，作为任务 context 的取消原因
+	ErrCanceled = errors.New("任务已取消")
+
+	errLeaseLost = errors.New("任务租约已丢失")
+)
+
+// claimSQL 领取一个本实例能执行的任务：等待中，或租约已过期（领取它的实例崩溃或失联）
+// SKIP LOCKED 使多个实例并发领取时互不阻塞，也不会领到同一个任务
+const claimSQL = `
+UPDATE tasks
+SET status = ?, lease_owner = ?, leased_until = ?, attempts = attempts + 1, started_at = COALESCE(started_at, ?), updated_at = ?
+WHERE id = (
+	SELECT id FROM tasks
+	WHERE type IN ? AND (status = ? OR (status = ? AND leased_until < ?))
+	ORDER BY id
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *`
+
+// heartbeatSQL 保存进度并续约，同时读取其他实例写入的取消请求
+const heartbeatSQL = `
+UPDATE tasks
+SET done = ?, total = ?, message = ?, leased_until = ?, updated_at = ?
+WHERE id = ? AND status = ? AND lease_owner = ?
+RETURNING cancel_requested`
+
+// Handler 执行一种类型的任务，返回值序列化为 JSON 保存为任务结果
+// ctx 在任务被取消、租约丢失或实例停止时取消，处理函数应及时返回
+type Handler func(ctx context.Context, task *models.Task, progress *Progress) (any, error)
+
+var defaultManager *Manager
+
+// SetDefault 设置默认任务管理器
+func SetDefault(m *Manager) {
+	defaultManager = m
+}
+
+// Default 返回默认任务管理器，未设置时返回 nil
+func Default() *Manager {
+	return defaultManager
+}
+
+// Decode 将任务参数解析到 v
+func Decode(task *models.Task, v any) error {
+	if err := json.Unmarshal([]byte(task.Params), v); err != nil {
+		return fmt.Errorf("解析任务参数失败: %w", err)
+	}
+	return nil
+}
+
+// Manager 后台任务管理器
+// 任务保存在 tasks 表中，所有状态变更都带上 lease_owner 条件，租约已被其他实例接手时本实例的写入不会生效
+type Manager struct {
+	db           *gorm.DB
+	cfg          config.TasksConfig
+	lease        lease.Lease
+	pollInterval time.Duration
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	running  map[uint]context.CancelCauseFunc
+	watchers map[uint]map[chan struct{}]struct{}
+
+	wakeCh chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager 创建任务管理器，注册任务类型后调用 Start 开始执行
+func NewManager(db *gorm.DB, cfg config.TasksConfig) *Manager {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.LeaseTimeout <= 0 {
+		cfg.LeaseTimeout = defaultLeaseTimeout
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.RetentionDays <= 0 {
+		cfg.RetentionDays = defaultRetentionDays
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		db:           db,
+		cfg:          cfg,
+		lease:        lease.New(time.Duration(cfg.LeaseTimeout)*time.Second, models.TaskStatusRunning),
+		pollInterval: time.Duration(cfg.PollInterval) * time.Second,
+		handlers:     make(map[string]Handler),
+		running:      make(map[uint]context.CancelCauseFunc),
+		watchers:     make(map[uint]map[chan struct{}]struct{}),
+		wakeCh:       make(chan struct{}, 1),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Register 注册任务类型的处理函数，本实例只领取已注册类型的任务
+func (m *Manager) Register(taskType string, handler Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[taskType] = handler
+}
+
+// Start 启动工作协程和过期任务清理
+func (m *Manager) Start() {
+	for i := 0; i < m.cfg.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker(i)
+	}
+	m.wg.Add(1)
+	go m.cleaner()
+	logger.Info("后台任务管理器已启动",
+		zap.Int("workers", m.cfg.Workers),
+		zap.Strings("types", m.types()),
+		zap.String("owner", m.lease.Owner))
+}
+
+// Stop 停止任务管理器，正在执行的任务会被释放，由其他实例或下次启动后重新执行
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+	logger.Info("后台任务管理器已停止")
+}
+
+// Notify 唤醒空闲的工作协程立即领取任务
+func (m *Manager) Notify() {
+	select {
+	case m.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Submit 提交任务，params 序列化为 JSON 保存为任务参数
+func (m *Manager) Submit(userID uint, taskType string, params any) (*models.Task, error) {
+	if m.handler(taskType) == nil {
+		return nil, ErrUnknownType
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("序列化任务参数失败: %w", err)
+	}
+
+	task := &models.Task{
+		Type:   taskType,
+		Status: models.TaskStatusPending,
+		UserID: userID,
+		Params: string(data),
+	}
+	if err := m.db.Create(task).Error; err != nil {
+		return nil, fmt.Errorf("保存任务失败: %w", err)
+	}
+	m.Notify()
+	logger.Info("已提交后台任务", zap.Uint("task_id", task.ID), zap.String("type", taskType), zap.Uint("user_id", userID))
+	return task, nil
+}
+
+// Get 获取任务
+func (m *Manager) Get(id uint) (*models.Task, error) {
+	var task models.Task
+	if err := m.db.First(&task, id).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Cancel 取消任务：等待中的任务直接标记为已取消，执行中的任务记录取消请求，由执行实例在下次心跳时中断
+func (m *Manager) Cancel(id uint) (*models.Task, error) {
+	result := m.db.Model(&models.Task{}).
+		Where("id = ? AND status = ?", id, models.TaskStatusPending).
+		Updates(map[string]interface{}{
+			"status":           models.TaskStatusCanceled,
+			"cancel_requested": true,
+			"error":            ErrCanceled.Error(),
+			"finished_at":      time.Now(),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	updated := result.RowsAffected > 0
+
+	if !updated {
+		result = m.db.Model(&models.Task{}).
+			Where("id = ? AND status = ?", id, models.TaskStatusRunning).
+			Update("cancel_requested", true)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		updated = result.RowsAffected > 0
+		if updated {
+			m.mu.Lock()
+			if cancel, ok := m.running[id]; ok {
+				cancel(ErrCanceled)
+			}
+			m.mu.Unlock()
+		}
+	}
+	m.publish(id)
+
+	task, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !updated && task.Finished() {
+		return task, ErrFinished
+	}
+	return task, nil
+}
+
+// Watch 在任务状态或进度变化时发送任务快照，任务结束或 ctx 取消后关闭通道
+// 本实例执行的任务更新后立即发送，其他实例执行的任务按心跳间隔轮询
+func (m *Manager) Watch(ctx context.Context, id uint) <-chan models.Task {
+	out := make(chan models.Task)
+	notify := m.subscribe(id)
+	go func() {
+		defer close(out)
+		defer m.unsubscribe(id, notify)
+
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		var last *models.Task
+		for {
+			task, err := m.Get(id)
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				return
+			case err != nil:
+				logger.Warn("获取后台任务失败", zap.Uint("task_id", id), zap.Error(err))
+			case last == nil || changed(last, task):
+				select {
+				case out <- *task:
+				case <-ctx.Done():
+					return
+				}
+				last = task
+				if task.Finished() {
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-notify:
+			case <-ticker.C:
+			}
+		}
+	}()
+	return out
+}
+
+// changed 任务状态或进度是否有变化
+func changed(prev, cur *models.Task) bool {
+	return prev.Status != cur.Status ||
+		prev.Done != cur.Done ||
+		prev.Total != cur.Total ||
+		prev.Message != cur.Message ||
+		prev.CancelRequested != cur.CancelRequested
+}
+
+func (m *Manager) subscribe(id uint) chan struct{} {
+	ch := make(chan struct{}, 1)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.watchers[id] == nil {
+		m.watchers[id] = make(map[chan struct{}]struct{})
+	}
+	m.watchers[id][ch] = struct{}{}
+	return ch
+}
+
+func (m *Manager) unsubscribe(id uint, ch chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.watchers[id], ch)
+	if len(m.watchers[id]) == 0 {
+		delete(m.watchers, id)
+	}
+}
+
+// publish 通知本实例中关注该任务的订阅者
+func (m *Manager) publish(id uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.watchers[id] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *Manager) handler(taskType string) Handler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.handlers[taskType]
+}
+
+// types 返回已注册的任务类型
+func (m *Manager) types() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	types := make([]string, 0, len(m.handlers))
+	for t := range m.handlers {
+		types = append(types, t)
+	}
+	return types
+}
+
+// worker 工作协程，持续领取任务直到没有可执行的任务，然后等待轮询或唤醒
+func (m *Manager) worker(id int) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for m.ctx.Err() == nil && m.runNext() {
+		}
+		select {
+		case <-m.ctx.Done():
+			logger.Info("后台任务工作协程停止", zap.Int("worker_id", id))
+			return
+		case <-ticker.C:
+		case <-m.wakeCh:
+		}
+	}
+}
+
+// runNext 领取并执行一个任务，没有可执行的任务时返回 false
+func (m *Manager) runNext() bool {
+	types := m.types()
+	if len(types) == 0 {
+		return false
+	}
+
+	now := time.Now()
+	var task models.Task
+	result := m.db.Raw(claimSQL,
+		models.TaskStatusRunning, m.lease.Owner, m.lease.Until(), now, now,
+		types, models.TaskStatusPending, models.TaskStatusRunning, now,
+	).Scan(&task)
+	if result.Error != nil {
+		logger.Error("领取后台任务失败", zap.Error(result.Error))
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+	m.run(&task)
+	return true
+}
+
+// run 执行任务；执行期间每秒保存进度并续约，租约丢失时放弃本次结果
+func (m *Manager) run(task *models.Task) {
+	progress := &Progress{}
+	if task.CancelRequested {
+		m.finish(task, models.TaskStatusCanceled, nil, ErrCanceled, progress)
+		return
+	}
+	// 租约多次过期（例如执行中实例崩溃）说明任务可能导致实例崩溃，不再重新执行
+	if task.Attempts > m.cfg.MaxAttempts {
+		m.finish(task, models.TaskStatusFailed, nil,
+			fmt.Errorf("执行次数超过上限 %d，执行实例多次失联", m.cfg.MaxAttempts), progress)
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(m.ctx)
+	defer cancel(nil)
+	m.mu.Lock()
+	m.running[task.ID] = cancel
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, task.ID)
+		m.mu.Unlock()
+	}()
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		m.heartbeat(ctx, task, progress, cancel)
+	}()
+
+	logger.Info("开始执行后台任务",
+		zap.Uint("task_id", task.ID),
+		zap.String("type", task.Type),
+		zap.Int("attempt", task.Attempts))
+
+	result, err := call(m.handler(task.Type), ctx, task, progress)
+	cause := context.Cause(ctx)
+	cancel(nil)
+	<-heartbeatDone
+
+	switch {
+	case errors.Is(cause, errLeaseLost):
+		logger.Warn("后台任务租约已丢失，放弃本次结果", zap.Uint("task_id", task.ID))
+	case err == nil:
+		m.finish(task, models.TaskStatusSucceeded, result, nil, progress)
+	case m.ctx.Err() != nil:
+		// 实例正在停止，释放任务由其他实例接手，不计入执行次数
+		m.release(task)
+	case errors.Is(cause, ErrCanceled):
+		m.finish(task, models.TaskStatusCanceled, result, ErrCanceled, progress)
+	default:
+		m.finish(task, models.TaskStatusFailed, result, err, progress)
+	}
+}
+
+// call 调用任务处理函数，处理函数 panic 时转换为错误
+func call(handler Handler, ctx context.Context, task *models.Task, progress *Progress) (result any, err error) {
+	if handler == nil {
+		return nil, ErrUnknownType
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务执行异常: %v", r)
+		}
+	}()
+	return handler(ctx, task, progress)
+}
+
+// heartbeat 每秒保存一次进度并续约，直到 ctx 结束
+// 续约失败说明租约已被其他实例接手，以 errLeaseLost 取消任务；读到取消请求时以 ErrCanceled 取消任务
+func (m *Manager) heartbeat(ctx context.Context, task *models.Task, progress *Progress, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		done, total, message := progress.snapshot()
+		now := time.Now()
+		var state struct{ CancelRequested bool }
+		result := m.db.Raw(heartbeatSQL,
+			done, total, message, m.lease.Until(), now,
+			task.ID, m.lease.Running, m.lease.Owner,
+		).Scan(&state)
+		if result.Error != nil {
+			logger.Warn("保存后台任务进度失败", zap.Uint("task_id", task.ID), zap.Error(result.Error))
+			continue
+		}
+		if result.RowsAffected == 0 {
+			cancel(errLeaseLost)
+			return
+		}
+		m.publish(task.ID)
+		if state.CancelRequested {
+			cancel(ErrCanceled)
+		}
+	}
+}
+
+// owned 限定为本实例仍持有租约的任务
+func (m *Manager) owned(task *models.Task) *gorm.DB {
+	return m.lease.Owned(m.db, &models.Task{}, task.ID)
+}
+
+// finish 保存任务的最终状态、进度和结果
+func (m *Manager) finish(task *models.Task, status string, result any, cause error, progress *Progress) {
+	done, total, message := progress.snapshot()
+	updates := lease.Released(map[string]interface{}{
+		"status":      status,
+		"done":        done,
+		"total":       total,
+		"message":     message,
+		"finished_at": time.Now(),
+	})
+	if result != nil {
+		if data, err := json.Marshal(result); err != nil {
+			logger.Warn("序列化后台任务结果失败", zap.Uint("task_id", task.ID), zap.Error(err))
+		} else {
+			updates["result"] = string(data)
+		}
+	}
+	if cause != nil {
+		updates["error"] = cause.Error()
+	}
+	if err := m.owned(task).Updates(updates).Error; err != nil {
+		logger.Error("更新后台任务状态失败", zap.Uint("task_id", task.ID), zap.Error(err))
+		return
+	}
+	m.publish(task.ID)
+
+	if status == models.TaskStatusFailed {
+		logger.Error("后台任务执行失败", zap.Uint("task_id", task.ID), zap.String("type", task.Type), zap.Error(cause))
+		return
+	}
+	logger.Info("后台任务已结束", zap.Uint("task_id", task.ID), zap.String("type", task.Type), zap.String("status", status))
+}
+
+// release 放弃租约并立即交还任务，本次执行不计入次数
+func (m *Manager) release(task *models.Task) {
+	if err := m.owned(task).Updates(lease.Released(map[string]interface{}{
+		"status":   models.TaskStatusPending,
+		"attempts": gorm.Expr("attempts - 1"),
+	})).Error; err != nil {
+		logger.Error("释放后台任务失败", zap.Uint("task_id", task.ID), zap.Error(err))
+	}
+}
+
+// cleaner 定期删除超过保留天数的已结束任务
+func (m *Manager) cleaner() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		m.cleanup()
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) cleanup() {
+	cutoff := time.Now().AddDate(0, 0, -m.cfg.RetentionDays)
+	result := m.db.Where("status IN ? AND finished_at < ?",
+		[]string{models.TaskStatusSucceeded, models.TaskStatusFailed, models.TaskStatusCanceled}, cutoff).
+		Delete(&models.Task{})
+	if result.Error != nil {
+		logger.Warn("清理过期后台任务失败", zap.Error(result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		logger.Info("已清理过期后台任务", zap.Int64("count", result.RowsAffected))
+	}
+}
@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+)
+
+func TestNewManagerDefaults(t *testing.T) {
+	m := NewManager(nil, config.TasksConfig{})
+	if m.cfg.Workers != defaultWorkers || m.cfg.MaxAttempts != defaultMaxAttempts || m.cfg.RetentionDays != defaultRetentionDays {
+		t.Errorf("cfg = %+v", m.cfg)
+	}
+	if m.lease.Timeout != defaultLeaseTimeout*time.Second || m.pollInterval != defaultPollInterval*time.Second {
+		t.Errorf("lease.Timeout = %v, pollInterval = %v", m.lease.Timeout, m.pollInterval)
+	}
+	if m.lease.Owner == "" {
+		t.Error("owner is empty")
+	}
+}
+
+func TestSubmitUnknownType(t *testing.T) {
+	m := NewManager(nil, config.TasksConfig{})
+	if _, err := m.Submit(1, "unknown", nil); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Submit() error = %v, want ErrUnknownType", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	m := NewManager(nil, config.TasksConfig{})
+	m.Register("noop", func(ctx context.Context, task *models.Task, progress *Progress) (any, error) {
+		return nil, nil
+	})
+	if m.handler("noop") == nil {
+		t.Error("handler(noop) = nil")
+	}
+	if types := m.types(); len(types) != 1 || types[0] != "noop" {
+		t.Errorf("types() = %v", types)
+	}
+}
+
+func TestCall(t *testing.T) {
+	task := &models.Task{Params: `{"prefix":"a/"}`}
+	result, err := call(func(ctx context.Context, task *models.Task, progress *Progress) (any, error) {
+		var params struct{ Prefix string }
+		if err := Decode(task, &params); err != nil {
+			return nil, err
+		}
+		progress.SetTotal(2)
+		progress.Add(1)
+		return params.Prefix, nil
+	}, context.Background(), task, &Progress{})
+	if err != nil || result != "a/" {
+		t.Errorf("call() = %v, %v", result, err)
+	}
+
+	_, err = call(func(ctx context.Context, task *models.Task, progress *Progress) (any, error) {
+		panic("boom")
+	}, context.Background(), task, &Progress{})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("call(panic) error = %v", err)
+	}
+
+	if _, err := call(nil, context.Background(), task, &Progress{}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("call(nil) error = %v, want ErrUnknownType", err)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	p := &Progress{}
+	p.SetTotal(10)
+	p.Add(3)
+	p.Add(2)
+	p.SetMessage(strings.Repeat("删", maxMessageLength+100))
+	done, total, message := p.snapshot()
+	if done != 5 || total != 10 {
+		t.Errorf("snapshot() = %d/%d", done, total)
+	}
+	if message != strings.Repeat("删", maxMessageLength) {
+		t.Errorf("message length = %d runes, want %d", utf8.RuneCountInString(message), maxMessageLength)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	base := models.Task{Status: models.TaskStatusRunning, Done: 1, Total: 10, Message: "a"}
+	if changed(&base, &base) {
+		t.Error("changed(same) = true")
+	}
+	for _, modify := range []func(*models.Task){
+		func(t *models.Task) { t.Status = models.TaskStatusSucceeded },
+		func(t *models.Task) { t.Done = 2 },
+		func(t *models.Task) { t.Total = 11 },
+		func(t *models.Task) { t.Message = "b" },
+		func(t *models.Task) { t.CancelRequested = true },
+	} {
+		cur := base
+		modify(&cur)
+		if !changed(&base, &cur) {
+			t.Errorf("changed(%+v) = false", cur)
+		}
+	}
+	cur := base
+	cur.UpdatedAt = time.Now()
+	if changed(&base, &cur) {
+		t.Error("changed() = true when only updated_at differs")
+	}
+}
+
+func TestFinished(t *testing.T) {
+	for status, want := range map[string]bool{
+		models.TaskStatusPending:   false,
+		models.TaskStatusRunning:   false,
+		models.TaskStatusSucceeded: true,
+		models.TaskStatusFailed:    true,
+		models.TaskStatusCanceled:  true,
+	} {
+		task := models.Task{Status: status}
+		if task.Finished() != want {
+			t.Errorf("Finished(%s) = %v, want %v", status, !want, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package tasks
+
+import (
+	"sync"
+
+	"github.com/myysophia/ossmanager/internal/utils"
+)
+
+const maxMessageLength = 500
+
+// Progress 任务执行进度，由任务处理函数更新，执行实例每秒保存一次
+type Progress struct {
+	mu      sync.Mutex
+	done    int64
+	total   int64
+	message string
+}
+
+// SetTotal 设置任务总量，未知时保持为 0
+func (p *Progress) SetTotal(total int64) {
+	p.mu.Lock()
+	p.total = total
+	p.mu.Unlock()
+}
+
+// Add 增加已完成的数量
+func (p *Progress) Add(n int64) {
+	p.mu.Lock()
+	p.done += n
+	p.mu.Unlock()
+}
+
+// SetMessage 设置当前进度说明，超过长度限制时截断
+func (p *Progress) SetMessage(message string) {
+	p.mu.Lock()
+	p.message = utils.Truncate(message, maxMessageLength)
+	p.mu.Unlock()
+}
+
+// snapshot 返回当前进度
+func (p *Progress) snapshot() (done, total int64, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done, p.total, p.message
+}
@@ -118,8 +118,29 @@ func (m *MockStorageService) ListUploadedPartsToBucket(objectKey string, uploadI
 	return args.Get(0).([]oss.Part), args.Error(1)
 }
 
-func (m *MockStorageService) GeneratePartUploadURL(objectKey string, uploadID string, partNumber int, regionCode string, bucketName string) (string, error) {
-	args := m.Called(objectKey, uploadID, partNumber, regionCode, bucketName)
+func (m *MockStorageService) ListMultipartUploadsInBucket(regionCode string, bucketName string) ([]oss.MultipartUploadInfo, error) {
+	args := m.Called(regionCode, bucketName)
+	return args.Get(0).([]oss.MultipartUploadInfo), args.Error(1)
+}
+
+func (m *MockStorageService) WalkObjectsInBucket(regionCode string, bucketName string, prefix string, fn func(oss.ObjectInfo) error) error {
+	args := m.Called(regionCode, bucketName, prefix, fn)
+	return args.Error(0)
+}
+
+func (m *MockStorageService) GetObjectFromBucket(objectKey string, regionCode string, bucketName string) (io.ReadCloser, error) {
+	args := m.Called(objectKey, regionCode, bucketName)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockStorageService) StatObjectInBucket(objectKey string, regionCode string, bucketName string) (*oss.ObjectInfo, error) {
+	args := m.Called(objectKey, regionCode, bucketName)
+	info, _ := args.Get(0).(*oss.ObjectInfo)
+	return info, args.Error(1)
+}
+
+func (m *MockStorageService) GeneratePartUploadURL(objectKey string, uploadID string, partNumber int, contentMD5 string, regionCode string, bucketName string) (string, error) {
+	args := m.Called(objectKey, uploadID, partNumber, contentMD5, regionCode, bucketName)
 	return args.String(0), args.Error(1)
 }
 
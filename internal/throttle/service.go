@@ -0,0 +1,279 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCacheTTL = 30 // 秒
+	// idleTimeout 限速器超过该时长未使用时回收
+	idleTimeout = 10 * time.Minute
+
+	directionUpload   = "upload"
+	directionDownload = "download"
+)
+
+// Rates 上传/下载限速（字节/秒），0 表示不限制
+type Rates struct {
+	Upload   int64 `json:"upload_rate"`
+	Download int64 `json:"download_rate"`
+}
+
+func (r Rates) get(direction string) int64 {
+	if direction == directionUpload {
+		return r.Upload
+	}
+	return r.Download
+}
+
+// Subject 限速对象：用户，以及通过 WebDAV Token 访问时的 Token
+type Subject struct {
+	UserID  uint
+	TokenID uint
+}
+
+// Effective 对某个限速对象生效的限速
+// 用户限速由该用户的所有连接共享；Token 限速只作用于使用该 Token 的连接，并与用户限速同时生效
+type Effective struct {
+	User  Rates  `json:"user"`
+	Token *Rates `json:"token,omitempty"`
+}
+
+type cachedRules struct {
+	effective Effective
+	expires   time.Time
+}
+
+// Service 带宽限速服务，同一用户的并发连接共享同一个令牌桶
+// 限速器保存在进程内存中，多实例部署时每个实例分别限速
+type Service struct {
+	db       *gorm.DB
+	cfg      config.ThrottleConfig
+	cacheTTL time.Duration
+
+	mu        sync.Mutex
+	rules     map[Subject]cachedRules
+	limiters  map[string]*limiter
+	lastSweep time.Time
+}
+
+var defaultService *Service
+
+// SetDefault 设置默认限速服务
+func SetDefault(s *Service) {
+	defaultService = s
+}
+
+// Default 返回默认限速服务，未设置时返回 nil（不限速）
+func Default() *Service {
+	return defaultService
+}
+
+// NewService 创建带宽限速服务
+func NewService(db *gorm.DB, cfg config.ThrottleConfig) *Service {
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &Service{
+		db:        db,
+		cfg:       cfg,
+		cacheTTL:  time.Duration(ttl) * time.Second,
+		rules:     make(map[Subject]cachedRules),
+		limiters:  make(map[string]*limiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Enabled 是否启用限速，nil 服务视为未启用
+func (s *Service) Enabled() bool {
+	return s != nil && s.cfg.Enabled
+}
+
+// UploadReader 按限速对象的上传限速包装 r
+func (s *Service) UploadReader(ctx context.Context, sub Subject, r io.Reader) io.Reader {
+	return newReader(ctx, r, s.limitersFor(sub, directionUpload))
+}
+
+// ResponseWriter 按限速对象的下载限速包装 HTTP 响应，未限速时原样返回
+func (s *Service) ResponseWriter(ctx context.Context, sub Subject, w http.ResponseWriter) http.ResponseWriter {
+	limiters := s.limitersFor(sub, directionDownload)
+	if len(limiters) == 0 {
+		return w
+	}
+	return &responseWriter{ResponseWriter: w, w: newWriter(ctx, w, limiters)}
+}
+
+// Invalidate 清空限速规则缓存，修改限速后调用使其在本实例立即生效
+func (s *Service) Invalidate() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.rules = make(map[Subject]cachedRules)
+	s.mu.Unlock()
+}
+
+// Effective 返回对限速对象生效的限速
+func (s *Service) Effective(sub Subject) (Effective, error) {
+	defaults := Rates{Upload: s.cfg.UploadRate, Download: s.cfg.DownloadRate}
+	if s.db == nil {
+		return Effective{User: defaults}, nil
+	}
+
+	var roleIDs []uint
+	if err := s.db.Table("user_roles").Where("user_id = ?", sub.UserID).Pluck("role_id", &roleIDs).Error; err != nil {
+		return Effective{}, fmt.Errorf("获取用户角色失败: %w", err)
+	}
+
+	query := s.db.Where("scope_type = ? AND scope_id = ?", models.BandwidthScopeUser, sub.UserID).
+		Or("scope_type = ? AND scope_id IN ?", models.BandwidthScopeRole, append(roleIDs, 0))
+	if sub.TokenID != 0 {
+		query = query.Or("scope_type = ? AND scope_id = ?", models.BandwidthScopeToken, sub.TokenID)
+	}
+
+	var limits []models.BandwidthLimit
+	if err := query.Find(&limits).Error; err != nil {
+		return Effective{}, fmt.Errorf("获取带宽限速失败: %w", err)
+	}
+	return resolve(limits, defaults), nil
+}
+
+// resolve 从候选限速中选出生效的限速：
+//   - 用户限速优先于角色限速，都没有时使用默认限速；
+//   - 用户属于多个角色时取最宽松的角色限速；
+//   - Token 限速单独返回。
+func resolve(limits []models.BandwidthLimit, defaults Rates) Effective {
+	var (
+		user  *Rates
+		roles []Rates
+		eff   Effective
+	)
+	for _, l := range limits {
+		rates := Rates{Upload: l.UploadRate, Download: l.DownloadRate}
+		switch l.ScopeType {
+		case models.BandwidthScopeUser:
+			user = &rates
+		case models.BandwidthScopeRole:
+			roles = append(roles, rates)
+		case models.BandwidthScopeToken:
+			eff.Token = &rates
+		}
+	}
+
+	switch {
+	case user != nil:
+		eff.User = *user
+	case len(roles) > 0:
+		eff.User = loosest(roles)
+	default:
+		eff.User = defaults
+	}
+	return eff
+}
+
+// loosest 合并多个角色限速，取每项最宽松的值（0 表示不限制）
+func loosest(rates []Rates) Rates {
+	r := rates[0]
+	for _, o := range rates[1:] {
+		if r.Upload != 0 && (o.Upload == 0 || o.Upload > r.Upload) {
+			r.Upload = o.Upload
+		}
+		if r.Download != 0 && (o.Download == 0 || o.Download > r.Download) {
+			r.Download = o.Download
+		}
+	}
+	return r
+}
+
+// limitersFor 返回限速对象在某个方向上需要经过的限速器，不限速时返回 nil
+func (s *Service) limitersFor(sub Subject, direction string) []*limiter {
+	if !s.Enabled() || sub.UserID == 0 {
+		return nil
+	}
+	eff := s.cachedEffective(sub)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked()
+
+	var limiters []*limiter
+	if eff.Token != nil {
+		if bps := eff.Token.get(direction); bps > 0 {
+			limiters = append(limiters, s.limiterLocked(fmt.Sprintf("token:%d:%s", sub.TokenID, direction), bps))
+		}
+	}
+	if bps := eff.User.get(direction); bps > 0 {
+		limiters = append(limiters, s.limiterLocked(fmt.Sprintf("user:%d:%s", sub.UserID, direction), bps))
+	}
+	return limiters
+}
+
+// cachedEffective 从缓存读取生效的限速，过期后重新查询；查询失败时使用默认限速
+func (s *Service) cachedEffective(sub Subject) Effective {
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.rules[sub]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.effective
+	}
+
+	eff, err := s.Effective(sub)
+	if err != nil {
+		logger.Warn("获取带宽限速失败，使用默认限速", zap.Uint("user_id", sub.UserID), zap.Error(err))
+		eff = Effective{User: Rates{Upload: s.cfg.UploadRate, Download: s.cfg.DownloadRate}}
+	}
+
+	s.mu.Lock()
+	s.rules[sub] = cachedRules{effective: eff, expires: now.Add(s.cacheTTL)}
+	s.mu.Unlock()
+	return eff
+}
+
+// limiterLocked 获取或创建令牌桶，限速变化时就地调整，正在进行的传输随之生效
+func (s *Service) limiterLocked(key string, bps int64) *limiter {
+	l, ok := s.limiters[key]
+	if !ok {
+		l = &limiter{Limiter: rate.NewLimiter(rate.Limit(bps), burstFor(bps))}
+		s.limiters[key] = l
+	} else if l.Limit() != rate.Limit(bps) {
+		l.SetLimit(rate.Limit(bps))
+		l.SetBurst(burstFor(bps))
+	}
+	l.touch()
+	return l
+}
+
+// sweepLocked 回收长时间未使用的限速器和过期的规则缓存
+func (s *Service) sweepLocked() {
+	now := time.Now()
+	if now.Sub(s.lastSweep) < idleTimeout {
+		return
+	}
+	s.lastSweep = now
+
+	cutoff := now.Add(-idleTimeout)
+	for key, l := range s.limiters {
+		if l.idleSince(cutoff) {
+			delete(s.limiters, key)
+		}
+	}
+	for sub, cached := range s.rules {
+		if now.After(cached.expires) {
+			delete(s.rules, sub)
+		}
+	}
+}
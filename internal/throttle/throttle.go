@@ -0,0 +1,146 @@
+// Package throttle 按用户、角色和 WebDAV Token 限制上传/下载带宽
+package throttle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// minBurst 令牌桶的最小容量，保证低限速时单次读写仍有合理的块大小
+const minBurst = 64 * 1024
+
+// burstFor 按限速计算令牌桶容量：约一秒的数据量，不小于 minBurst
+func burstFor(bytesPerSecond int64) int {
+	if bytesPerSecond < minBurst {
+		return minBurst
+	}
+	return int(bytesPerSecond)
+}
+
+// chunkSize 多个限速器中最小的令牌桶容量，单次读写不超过该大小，保证 WaitN 不会因超出容量而失败
+func chunkSize(limiters []*limiter) int {
+	size := 0
+	for _, l := range limiters {
+		if b := l.Burst(); size == 0 || b < size {
+			size = b
+		}
+	}
+	return size
+}
+
+// wait 依次等待所有限速器放行 n 字节，限速在传输过程中被调小时按新容量分批等待
+func wait(ctx context.Context, limiters []*limiter, n int) error {
+	for _, l := range limiters {
+		l.touch()
+		for remaining := n; remaining > 0; {
+			k := remaining
+			if b := l.Burst(); k > b {
+				k = b
+			}
+			if err := l.WaitN(ctx, k); err != nil {
+				return err
+			}
+			remaining -= k
+		}
+	}
+	return nil
+}
+
+// reader 读取数据后按限速等待，请求取消时返回 context 的错误
+type reader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*limiter
+}
+
+// newReader 包装 r，读取速率不超过所有 limiter 的限速
+func newReader(ctx context.Context, r io.Reader, limiters []*limiter) io.Reader {
+	if len(limiters) == 0 {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limiters: limiters}
+}
+
+func (tr *reader) Read(p []byte) (int, error) {
+	if size := chunkSize(tr.limiters); len(p) > size {
+		p = p[:size]
+	}
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		if werr := wait(tr.ctx, tr.limiters, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// writer 按限速分块写入
+type writer struct {
+	ctx      context.Context
+	w        io.Writer
+	limiters []*limiter
+}
+
+func newWriter(ctx context.Context, w io.Writer, limiters []*limiter) io.Writer {
+	if len(limiters) == 0 {
+		return w
+	}
+	return &writer{ctx: ctx, w: w, limiters: limiters}
+}
+
+func (tw *writer) Write(p []byte) (int, error) {
+	size := chunkSize(tw.limiters)
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+		if err := wait(tw.ctx, tw.limiters, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := tw.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// responseWriter 只限制响应体的写入速率，其余方法沿用原 ResponseWriter
+type responseWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	return rw.w.Write(p)
+}
+
+// Flush 透传给原 ResponseWriter，保证流式响应及时发送
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// limiter 带最近使用时间的令牌桶，长时间未使用的限速器会被回收
+type limiter struct {
+	*rate.Limiter
+	lastUsed int64 // unix 秒，原子访问
+}
+
+func (l *limiter) touch() {
+	atomic.StoreInt64(&l.lastUsed, time.Now().Unix())
+}
+
+func (l *limiter) idleSince(t time.Time) bool {
+	return atomic.LoadInt64(&l.lastUsed) < t.Unix()
+}
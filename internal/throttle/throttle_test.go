@@ -0,0 +1,93 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"golang.org/x/time/rate"
+)
+
+func newTestLimiter(bps int64) *limiter {
+	return &limiter{Limiter: rate.NewLimiter(rate.Limit(bps), burstFor(bps))}
+}
+
+func TestReaderLimitsRate(t *testing.T) {
+	const bps = 256 * 1024
+	data := bytes.Repeat([]byte("x"), bps+bps/2)
+
+	start := time.Now()
+	r := newReader(context.Background(), bytes.NewReader(data), []*limiter{newTestLimiter(bps)})
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("ReadAll() returned %d bytes, want %d", len(got), len(data))
+	}
+	// 令牌桶初始可放行一秒的数据量，剩余的一半需要约 0.5 秒
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("reading %d bytes at %d B/s took %v, want >= 400ms", len(data), bps, elapsed)
+	}
+}
+
+func TestWriterStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := newTestLimiter(minBurst)
+	var buf bytes.Buffer
+	w := newWriter(ctx, &buf, []*limiter{l})
+
+	if _, err := w.Write(make([]byte, minBurst)); err != nil {
+		t.Fatalf("first Write() error = %v", err)
+	}
+	cancel()
+	if _, err := w.Write(make([]byte, minBurst)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Write() after cancel error = %v, want context.Canceled", err)
+	}
+	if buf.Len() != minBurst {
+		t.Errorf("written %d bytes, want %d", buf.Len(), minBurst)
+	}
+}
+
+func TestNoLimitersPassThrough(t *testing.T) {
+	src := bytes.NewReader(nil)
+	if r := newReader(context.Background(), src, nil); r != src {
+		t.Error("newReader() without limiters should return the original reader")
+	}
+	var s *Service
+	if s.Enabled() || s.limitersFor(Subject{UserID: 1}, directionUpload) != nil {
+		t.Error("nil service should not throttle")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	defaults := Rates{Upload: 100, Download: 200}
+
+	if eff := resolve(nil, defaults); eff.User != defaults || eff.Token != nil {
+		t.Errorf("resolve(nil) = %+v, want defaults", eff)
+	}
+
+	limits := []models.BandwidthLimit{
+		{ScopeType: models.BandwidthScopeRole, ScopeID: 1, UploadRate: 10, DownloadRate: 50},
+		{ScopeType: models.BandwidthScopeRole, ScopeID: 2, UploadRate: 30, DownloadRate: 0},
+		{ScopeType: models.BandwidthScopeToken, ScopeID: 9, UploadRate: 5},
+	}
+	eff := resolve(limits, defaults)
+	// 多个角色取最宽松的值
+	if eff.User != (Rates{Upload: 30, Download: 0}) {
+		t.Errorf("role rates = %+v, want upload=30 download=0", eff.User)
+	}
+	if eff.Token == nil || eff.Token.Upload != 5 {
+		t.Errorf("token rates = %+v, want upload=5", eff.Token)
+	}
+
+	// 用户限速优先于角色限速
+	limits = append(limits, models.BandwidthLimit{ScopeType: models.BandwidthScopeUser, ScopeID: 7, UploadRate: 1, DownloadRate: 2})
+	if eff := resolve(limits, defaults); eff.User != (Rates{Upload: 1, Download: 2}) {
+		t.Errorf("user rates = %+v, want upload=1 download=2", eff.User)
+	}
+}
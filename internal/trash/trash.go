@@ -0,0 +1,277 @@
+// Package trash 实现文件回收站：删除的文件先移动到所在存储桶的回收站前缀下，可以恢复，超过保留期后彻底删除
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPrefix        = ".trash/"
+	defaultRetentionDays = 30
+	defaultInterval      = 60 // 分钟
+	defaultBatchSize     = 500
+)
+
+var (
+	// ErrNotTrashed 文件不在回收站中
+	ErrNotTrashed = errors.New("文件不在回收站中")
+	// ErrConflict 恢复位置已存在同名文件
+	ErrConflict = errors.New("原位置已存在同名文件")
+)
+
+var defaultBin *Bin
+
+// SetDefault 设置默认回收站，WebDAV 删除文件时通过它移入回收站
+func SetDefault(b *Bin) {
+	defaultBin = b
+}
+
+// Default 返回默认回收站，未设置时返回 nil
+func Default() *Bin {
+	return defaultBin
+}
+
+// Bin 回收站
+// 文件移入回收站时对象复制到 <prefix><文件ID>/<原对象键> 并删除原对象，记录状态改为 DELETED；
+// 定时任务彻底删除超过保留期的文件
+type Bin struct {
+	storageFactory oss.StorageFactory
+	db             *gorm.DB
+	cfg            config.TrashConfig
+	stopCh         chan struct{}
+	stopOnce       sync.Once
+	wg             sync.WaitGroup
+}
+
+// NewBin 创建回收站
+func NewBin(storageFactory oss.StorageFactory, db *gorm.DB, cfg config.TrashConfig) *Bin {
+	if cfg.Prefix = strings.TrimLeft(cfg.Prefix, "/"); cfg.Prefix == "" {
+		cfg.Prefix = defaultPrefix
+	}
+	if !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+	if cfg.RetentionDays <= 0 {
+		cfg.RetentionDays = defaultRetentionDays
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	return &Bin{
+		storageFactory: storageFactory,
+		db:             db,
+		cfg:            cfg,
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// Enabled 是否启用回收站，未启用时删除立即生效
+func (b *Bin) Enabled() bool {
+	return b != nil && b.cfg.Enabled
+}
+
+// Retention 返回文件在回收站中的保留时长
+func (b *Bin) Retention() time.Duration {
+	return time.Duration(b.cfg.RetentionDays) * 24 * time.Hour
+}
+
+// Contains 对象键是否位于回收站前缀下
+func (b *Bin) Contains(objectKey string) bool {
+	return strings.HasPrefix(objectKey, b.cfg.Prefix)
+}
+
+// Key 返回文件在回收站中的对象键，以文件ID区分同名文件
+func (b *Bin) Key(file *models.OSSFile) string {
+	return fmt.Sprintf("%s%d/%s", b.cfg.Prefix, file.ID, file.ObjectKey)
+}
+
+// Start 启动定时清理过期文件，未启用时不做任何事
+func (b *Bin) Start() {
+	if !b.Enabled() {
+		return
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(time.Duration(b.cfg.Interval) * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stopCh:
+				return
+			case <-ticker.C:
+				b.RunOnce()
+			}
+		}
+	}()
+
+	logger.Info("回收站清理已启动",
+		zap.String("prefix", b.cfg.Prefix),
+		zap.Int("retention_days", b.cfg.RetentionDays),
+		zap.Int("interval_minutes", b.cfg.Interval),
+	)
+}
+
+// Stop 停止定时清理
+func (b *Bin) Stop() {
+	b.stopOnce.Do(func() { close(b.stopCh) })
+	b.wg.Wait()
+}
+
+// RunOnce 彻底删除一批超过保留期的文件
+func (b *Bin) RunOnce() {
+	var files []models.OSSFile
+	if err := b.db.Where("status = ? AND trashed_at < ?", models.FileStatusDeleted, time.Now().Add(-b.Retention())).
+		Order("trashed_at").Limit(b.cfg.BatchSize).Find(&files).Error; err != nil {
+		logger.Error("获取回收站过期文件失败", zap.Error(err))
+		return
+	}
+
+	var purged, failed int
+	for i := range files {
+		if err := b.Purge(&files[i]); err != nil {
+			logger.Warn("彻底删除回收站文件失败",
+				zap.Uint("file_id", files[i].ID),
+				zap.String("object_key", files[i].ObjectKey),
+				zap.Error(err))
+			failed++
+			continue
+		}
+		purged++
+	}
+	if purged > 0 || failed > 0 {
+		logger.Info("回收站清理完成", zap.Int("purged", purged), zap.Int("failed", failed))
+	}
+}
+
+// Move 将文件移入回收站
+func (b *Bin) Move(file *models.OSSFile, userID uint) error {
+	if file.Status == models.FileStatusDeleted {
+		return nil
+	}
+	storage, regionCode, err := b.storage(file)
+	if err != nil {
+		return err
+	}
+
+	originalKey, trashKey := file.ObjectKey, b.Key(file)
+	if err := storage.CopyObject(file.Bucket, originalKey, file.Bucket, trashKey); err != nil {
+		return fmt.Errorf("移动对象到回收站失败: %w", err)
+	}
+
+	now := time.Now()
+	result := b.db.Model(file).Where("status <> ?", models.FileStatusDeleted).Updates(map[string]interface{}{
+		"status":       models.FileStatusDeleted,
+		"object_key":   trashKey,
+		"original_key": originalKey,
+		"trashed_at":   now,
+		"trashed_by":   userID,
+	})
+	if result.Error != nil {
+		if err := storage.DeleteObjectFromBucket(trashKey, regionCode, file.Bucket); err != nil {
+			logger.Warn("清理回收站对象失败", zap.String("object_key", trashKey), zap.Error(err))
+		}
+		return fmt.Errorf("更新文件记录失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// 同时有其他请求将文件移入了回收站，回收站中的对象键相同，不能再删除
+		return nil
+	}
+	file.Status, file.ObjectKey, file.OriginalKey, file.TrashedAt, file.TrashedBy =
+		models.FileStatusDeleted, trashKey, originalKey, &now, userID
+
+	// 记录已指向回收站中的副本，原对象删除失败只会留下多余的对象
+	if err := storage.DeleteObjectFromBucket(originalKey, regionCode, file.Bucket); err != nil && !errors.Is(err, oss.ErrObjectNotFound) {
+		return fmt.Errorf("删除原对象失败: %w", err)
+	}
+	return nil
+}
+
+// Restore 将回收站中的文件移回原位置
+func (b *Bin) Restore(file *models.OSSFile) error {
+	if file.Status != models.FileStatusDeleted || file.OriginalKey == "" {
+		return ErrNotTrashed
+	}
+
+	var count int64
+	if err := b.db.Model(&models.OSSFile{}).
+		Where("bucket = ? AND object_key = ? AND status IN ?", file.Bucket, file.OriginalKey, models.LiveFileStatuses).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrConflict
+	}
+
+	storage, regionCode, err := b.storage(file)
+	if err != nil {
+		return err
+	}
+
+	trashKey, originalKey := file.ObjectKey, file.OriginalKey
+	if err := storage.CopyObject(file.Bucket, trashKey, file.Bucket, originalKey); err != nil {
+		return fmt.Errorf("恢复对象失败: %w", err)
+	}
+
+	if err := b.db.Model(file).Updates(map[string]interface{}{
+		"status":       "ACTIVE",
+		"object_key":   originalKey,
+		"original_key": "",
+		"trashed_at":   nil,
+		"trashed_by":   0,
+	}).Error; err != nil {
+		return fmt.Errorf("更新文件记录失败: %w", err)
+	}
+	file.Status, file.ObjectKey, file.OriginalKey, file.TrashedAt, file.TrashedBy = "ACTIVE", originalKey, "", nil, 0
+
+	if err := storage.DeleteObjectFromBucket(trashKey, regionCode, file.Bucket); err != nil && !errors.Is(err, oss.ErrObjectNotFound) {
+		logger.Warn("删除回收站对象失败", zap.String("object_key", trashKey), zap.Error(err))
+	}
+	return nil
+}
+
+// Purge 彻底删除文件的对象、记录和标签，用于回收站中的文件或未启用回收站时的删除
+func (b *Bin) Purge(file *models.OSSFile) error {
+	storage, regionCode, err := b.storage(file)
+	if err != nil {
+		return err
+	}
+	if err := storage.DeleteObjectFromBucket(file.ObjectKey, regionCode, file.Bucket); err != nil && !errors.Is(err, oss.ErrObjectNotFound) {
+		return fmt.Errorf("删除对象失败: %w", err)
+	}
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", file.ID).Delete(&models.FileTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(file).Error
+	})
+}
+
+// storage 返回文件所在的存储服务和存储桶所属区域
+func (b *Bin) storage(file *models.OSSFile) (oss.StorageService, string, error) {
+	storage, err := b.storageFactory.GetStorageService(file.StorageType)
+	if err != nil {
+		return nil, "", fmt.Errorf("获取存储服务失败: %w", err)
+	}
+	var mapping models.RegionBucketMapping
+	if err := b.db.Where("bucket_name = ?", file.Bucket).First(&mapping).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", fmt.Errorf("获取存储桶区域信息失败: %w", err)
+	}
+	return storage, mapping.RegionCode, nil
+}
@@ -0,0 +1,179 @@
+package trash
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/myysophia/ossmanager/internal/config"
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/oss"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// fakeStorage 只实现回收站用到的方法
+type fakeStorage struct {
+	oss.StorageService
+	objects map[string]bool
+	copyErr error
+}
+
+func (f *fakeStorage) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) error {
+	if f.copyErr != nil {
+		return f.copyErr
+	}
+	if !f.objects[srcKey] {
+		return oss.ErrObjectNotFound
+	}
+	f.objects[dstKey] = true
+	return nil
+}
+
+func (f *fakeStorage) DeleteObjectFromBucket(objectKey, regionCode, bucketName string) error {
+	delete(f.objects, objectKey)
+	return nil
+}
+
+type fakeFactory struct {
+	storage *fakeStorage
+}
+
+func (f *fakeFactory) GetStorageService(string) (oss.StorageService, error)  { return f.storage, nil }
+func (f *fakeFactory) GetDefaultStorageService() (oss.StorageService, error) { return f.storage, nil }
+func (f *fakeFactory) ClearCache()                                           {}
+
+func newTestBin(t *testing.T, storage *fakeStorage) (*Bin, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBin(&fakeFactory{storage: storage}, db, config.TrashConfig{Enabled: true, Prefix: "/recycle"}), mock
+}
+
+func expectRegion(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "region_bucket_mapping"`).
+		WillReturnRows(sqlmock.NewRows([]string{"region_code", "bucket_name"}).AddRow("cn-hangzhou", "docs"))
+}
+
+func TestNewBinDefaults(t *testing.T) {
+	b := NewBin(nil, nil, config.TrashConfig{})
+	if b.cfg.Prefix != defaultPrefix || b.cfg.RetentionDays != defaultRetentionDays || b.cfg.Interval != defaultInterval {
+		t.Errorf("cfg = %+v", b.cfg)
+	}
+	if b.Enabled() {
+		t.Error("Enabled() = true")
+	}
+	var nilBin *Bin
+	if nilBin.Enabled() {
+		t.Error("nil Enabled() = true")
+	}
+}
+
+func TestKey(t *testing.T) {
+	b, _ := newTestBin(t, nil)
+	file := &models.OSSFile{Model: models.Model{ID: 7}, ObjectKey: "a/b.txt"}
+	if got := b.Key(file); got != "recycle/7/a/b.txt" {
+		t.Errorf("Key() = %q", got)
+	}
+	if !b.Contains("recycle/7/a/b.txt") || b.Contains("a/b.txt") {
+		t.Error("Contains() mismatch")
+	}
+}
+
+func TestMoveAndRestore(t *testing.T) {
+	storage := &fakeStorage{objects: map[string]bool{"a/b.txt": true}}
+	b, mock := newTestBin(t, storage)
+	file := &models.OSSFile{Model: models.Model{ID: 7}, Bucket: "docs", ObjectKey: "a/b.txt", Status: "ACTIVE"}
+
+	expectRegion(mock)
+	mock.ExpectExec(`UPDATE "oss_files" SET .* WHERE status <> \$\d+ AND "oss_files"."deleted_at" IS NULL AND "id" = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := b.Move(file, 3); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+	if file.Status != models.FileStatusDeleted || file.ObjectKey != "recycle/7/a/b.txt" || file.OriginalKey != "a/b.txt" || file.TrashedBy != 3 {
+		t.Errorf("file = %+v", file)
+	}
+	if storage.objects["a/b.txt"] || !storage.objects["recycle/7/a/b.txt"] {
+		t.Errorf("objects = %v", storage.objects)
+	}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "oss_files"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectRegion(mock)
+	mock.ExpectExec(`UPDATE "oss_files" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := b.Restore(file); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if file.Status != "ACTIVE" || file.ObjectKey != "a/b.txt" || file.TrashedAt != nil {
+		t.Errorf("file = %+v", file)
+	}
+	if !storage.objects["a/b.txt"] || storage.objects["recycle/7/a/b.txt"] {
+		t.Errorf("objects = %v", storage.objects)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMoveCopyFailureKeepsFile(t *testing.T) {
+	storage := &fakeStorage{objects: map[string]bool{"a.txt": true}, copyErr: errors.New("denied")}
+	b, mock := newTestBin(t, storage)
+	file := &models.OSSFile{Model: models.Model{ID: 1}, Bucket: "docs", ObjectKey: "a.txt", Status: "ACTIVE"}
+
+	expectRegion(mock)
+	if err := b.Move(file, 1); err == nil {
+		t.Fatal("Move() error = nil")
+	}
+	if file.Status != "ACTIVE" || !storage.objects["a.txt"] {
+		t.Errorf("file = %+v, objects = %v", file, storage.objects)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestoreConflict(t *testing.T) {
+	b, mock := newTestBin(t, &fakeStorage{})
+	file := &models.OSSFile{Model: models.Model{ID: 7}, Bucket: "docs", ObjectKey: "recycle/7/a.txt", OriginalKey: "a.txt", Status: models.FileStatusDeleted}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "oss_files"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	if err := b.Restore(file); !errors.Is(err, ErrConflict) {
+		t.Errorf("Restore() error = %v, want ErrConflict", err)
+	}
+
+	active := &models.OSSFile{Status: "ACTIVE"}
+	if err := b.Restore(active); !errors.Is(err, ErrNotTrashed) {
+		t.Errorf("Restore(active) error = %v, want ErrNotTrashed", err)
+	}
+}
+
+func TestRestoreIgnoresReplacedHistory(t *testing.T) {
+	storage := &fakeStorage{objects: map[string]bool{"recycle/7/a.txt": true}}
+	b, mock := newTestBin(t, storage)
+	file := &models.OSSFile{Model: models.Model{ID: 7}, Bucket: "docs", ObjectKey: "recycle/7/a.txt", OriginalKey: "a.txt", Status: models.FileStatusDeleted}
+
+	// 原位置只剩覆盖上传留下的 REPLACED 记录，冲突检查只统计当前状态的记录
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "oss_files" WHERE \(bucket = \$1 AND object_key = \$2 AND status IN \(\$3,\$4,\$5,\$6\)\)`).
+		WithArgs("docs", "a.txt", "ACTIVE", models.FileStatusQuarantined, models.FileStatusCorrupt, models.FileStatusMissing).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectRegion(mock)
+	mock.ExpectExec(`UPDATE "oss_files" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := b.Restore(file); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if file.Status != "ACTIVE" || !storage.objects["a.txt"] {
+		t.Errorf("file = %+v, objects = %v", file, storage.objects)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
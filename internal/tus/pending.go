@@ -0,0 +1,19 @@
+package tus
+
+import (
+	"path/filepath"
+
+	"github.com/myysophia/ossmanager/internal/config"
+)
+
+// defaultTempDir 未配置 app.upload_temp_dir 时的暂存目录
+const defaultTempDir = "./tmp/uploads"
+
+// PendingPath 返回上传暂存文件路径，未凑满一个分片的数据先写入该文件
+func PendingPath(uploadKey string) string {
+	dir := defaultTempDir
+	if cfg := config.GetConfig(); cfg != nil && cfg.App.UploadTempDir != "" {
+		dir = cfg.App.UploadTempDir
+	}
+	return filepath.Join(dir, "tus", uploadKey+".bin")
+}
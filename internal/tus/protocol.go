@@ -0,0 +1,143 @@
+// Package tus 实现 tus 1.0 断点续传协议中与存储无关的部分：
+// 协议头常量、Upload-Metadata 编解码以及 Upload-Checksum 校验。
+package tus
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// 协议版本与支持的扩展
+const (
+	Version           = "1.0.0"
+	Extensions        = "creation,creation-with-upload,termination,checksum,expiration"
+	ChecksumAlgorithm = "md5,sha1,sha256"
+	OffsetContentType = "application/offset+octet-stream"
+)
+
+// 协议头名称
+const (
+	HeaderResumable         = "Tus-Resumable"
+	HeaderVersion           = "Tus-Version"
+	HeaderExtension         = "Tus-Extension"
+	HeaderMaxSize           = "Tus-Max-Size"
+	HeaderChecksumAlgorithm = "Tus-Checksum-Algorithm"
+	HeaderUploadOffset      = "Upload-Offset"
+	HeaderUploadLength      = "Upload-Length"
+	HeaderUploadMetadata    = "Upload-Metadata"
+	HeaderUploadChecksum    = "Upload-Checksum"
+	HeaderUploadExpires     = "Upload-Expires"
+)
+
+// StatusChecksumMismatch 校验和不匹配时返回的状态码（checksum 扩展定义）
+const StatusChecksumMismatch = 460
+
+// ErrUnsupportedChecksum 不支持的校验算法
+var ErrUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+
+// ParseMetadata 解析 Upload-Metadata 头
+// 格式为逗号分隔的 "key base64(value)"，value 可省略
+func ParseMetadata(header string) (map[string]string, error) {
+	meta := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return meta, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			meta[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata value for key %q: %w", fields[0], err)
+			}
+			meta[fields[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("invalid metadata pair %q", strings.TrimSpace(pair))
+		}
+	}
+
+	return meta, nil
+}
+
+// EncodeMetadata 将元数据编码为 Upload-Metadata 头，键按字典序输出
+func EncodeMetadata(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if meta[k] == "" {
+			pairs = append(pairs, k)
+			continue
+		}
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(meta[k])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Checksum 一次 PATCH 请求携带的校验信息
+type Checksum struct {
+	Algorithm string
+	Expected  []byte
+	hash      hash.Hash
+}
+
+// ParseChecksum 解析 Upload-Checksum 头，格式为 "<algorithm> <base64 digest>"
+func ParseChecksum(header string) (*Checksum, error) {
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid Upload-Checksum header")
+	}
+
+	algorithm := strings.ToLower(fields[0])
+	var h hash.Hash
+	switch algorithm {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, ErrUnsupportedChecksum
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid Upload-Checksum digest: %w", err)
+	}
+
+	return &Checksum{Algorithm: algorithm, Expected: expected, hash: h}, nil
+}
+
+// Hash 返回用于计算请求体摘要的 hash.Hash
+func (c *Checksum) Hash() hash.Hash {
+	return c.hash
+}
+
+// Verify 比较已写入 Hash 的数据摘要与期望值
+func (c *Checksum) Verify() bool {
+	sum := c.hash.Sum(nil)
+	if len(sum) != len(c.Expected) {
+		return false
+	}
+	for i := range sum {
+		if sum[i] != c.Expected[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package tus
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"single", "filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==", map[string]string{"filename": "world_domination_plan.pdf"}, false},
+		{"multiple", "filename YS50eHQ=, is_confidential", map[string]string{"filename": "a.txt", "is_confidential": ""}, false},
+		{"bad base64", "filename !!!", nil, true},
+		{"too many fields", "filename YQ== YQ==", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMetadata(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMetadata(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseMetadata(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("ParseMetadata(%q)[%q] = %q, want %q", tt.header, k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeMetadataRoundTrip(t *testing.T) {
+	meta := map[string]string{"filename": "报告.pdf", "bucket_name": "docs", "flag": ""}
+	got, err := ParseMetadata(EncodeMetadata(meta))
+	if err != nil {
+		t.Fatalf("ParseMetadata() error = %v", err)
+	}
+	for k, v := range meta {
+		if got[k] != v {
+			t.Errorf("round trip [%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	data := []byte("hello tus")
+	sum := sha1.Sum(data)
+	header := "sha1 " + base64.StdEncoding.EncodeToString(sum[:])
+
+	c, err := ParseChecksum(header)
+	if err != nil {
+		t.Fatalf("ParseChecksum() error = %v", err)
+	}
+	c.Hash().Write(data)
+	if !c.Verify() {
+		t.Error("Verify() = false, want true")
+	}
+
+	c, _ = ParseChecksum(header)
+	c.Hash().Write([]byte("tampered"))
+	if c.Verify() {
+		t.Error("Verify() = true for tampered data, want false")
+	}
+
+	if _, err := ParseChecksum("crc32 AAAA"); err != ErrUnsupportedChecksum {
+		t.Errorf("ParseChecksum(crc32) error = %v, want ErrUnsupportedChecksum", err)
+	}
+	if _, err := ParseChecksum("sha1"); err == nil {
+		t.Error("ParseChecksum(missing digest) error = nil, want error")
+	}
+}
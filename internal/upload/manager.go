@@ -3,6 +3,9 @@ package upload
 import (
 	"sync"
 	"time"
+
+	"github.com/myysophia/ossmanager/internal/logger"
+	"go.uber.org/zap"
 )
 
 type ChunkInfo struct {
@@ -22,31 +25,99 @@ type Progress struct {
 	TotalChunks int         `json:"total_chunks"` // 总分片数
 	Chunks      []ChunkInfo `json:"chunks"`       // 分片信息
 	Status      string      `json:"status"`       // uploading, completed, failed
+	Error       string      `json:"error,omitempty"`
+}
+
+// Options 进度管理器配置
+type Options struct {
+	TTL          time.Duration // 进行中任务无更新后的保留时间
+	FinishedTTL  time.Duration // 已完成/失败任务的保留时间
+	SaveInterval time.Duration // 进行中任务写入 Store 的最小间隔
+	PollInterval time.Duration // 订阅其他实例任务时轮询 Store 的间隔
+}
+
+// DefaultOptions 默认配置
+var DefaultOptions = Options{
+	TTL:          24 * time.Hour,
+	FinishedTTL:  5 * time.Minute,
+	SaveInterval: time.Second,
+	PollInterval: time.Second,
 }
 
+// finishGrace 任务结束后本地保留的时间，让订阅者有时间接收最终状态
+const finishGrace = 5 * time.Second
+
 type Manager struct {
 	mu          sync.RWMutex
-	progresses  map[string]*Progress
+	store       Store
+	opts        Options
+	progresses  map[string]*Progress // 本实例正在写入的任务
+	lastSaved   map[string]time.Time
+	savers      map[string]*taskSaver
+	saveSeq     uint64
+	lastSent    map[string]time.Time // 轮询到的远端任务最后推送的更新时间
 	subscribers map[string]map[chan Progress]struct{}
+	stopCh      chan struct{}
+	stopOnce    sync.Once
 }
 
+// NewManager 创建使用进程内存储的进度管理器
 func NewManager() *Manager {
-	return &Manager{
+	return NewManagerWithStore(NewMemoryStore(), DefaultOptions)
+}
+
+// NewManagerWithStore 创建使用指定存储的进度管理器，并启动轮询和过期清理协程
+func NewManagerWithStore(store Store, opts Options) *Manager {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultOptions.TTL
+	}
+	if opts.FinishedTTL <= 0 {
+		opts.FinishedTTL = DefaultOptions.FinishedTTL
+	}
+	if opts.SaveInterval <= 0 {
+		opts.SaveInterval = DefaultOptions.SaveInterval
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultOptions.PollInterval
+	}
+
+	m := &Manager{
+		store:       store,
+		opts:        opts,
 		progresses:  make(map[string]*Progress),
+		lastSaved:   make(map[string]time.Time),
+		savers:      make(map[string]*taskSaver),
+		lastSent:    make(map[string]time.Time),
 		subscribers: make(map[string]map[chan Progress]struct{}),
+		stopCh:      make(chan struct{}),
 	}
+	go m.pollLoop()
+	go m.cleanupLoop()
+	return m
 }
 
 var DefaultManager = NewManager()
 
+// SetDefaultManager 替换默认进度管理器并关闭旧的管理器
+func SetDefaultManager(m *Manager) {
+	old := DefaultManager
+	DefaultManager = m
+	if old != nil && old != m {
+		old.Close()
+	}
+}
+
+// Close 停止后台协程
+func (m *Manager) Close() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+}
+
 func (m *Manager) Start(id string, total int64) {
 	m.StartWithChunks(id, total, false, 0)
 }
 
 func (m *Manager) StartWithChunks(id string, total int64, isChunked bool, totalChunks int) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	now := time.Now()
 	progress := &Progress{
 		Total:       total,
@@ -77,11 +148,14 @@ func (m *Manager) StartWithChunks(id string, total int64, isChunked bool, totalC
 	}
 
 	m.progresses[id] = progress
+	s := m.snapshot(id, progress, m.opts.TTL)
+	m.mu.Unlock()
+	m.persist(s)
 }
 
 func (m *Manager) Update(id string, uploaded int64) {
+	var s *pendingSave
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	if p, ok := m.progresses[id]; ok {
 		if uploaded > p.Uploaded {
 			now := time.Now()
@@ -96,19 +170,16 @@ func (m *Manager) Update(id string, uploaded int64) {
 			}
 		}
 
-		// 通知订阅者
-		for ch := range m.subscribers[id] {
-			select {
-			case ch <- *p:
-			default:
-			}
-		}
+		m.notify(id, p)
+		s = m.snapshotThrottled(id, p)
 	}
+	m.mu.Unlock()
+	m.persist(s)
 }
 
 func (m *Manager) UpdateChunk(id string, chunkNumber int, uploaded bool) {
+	var s *pendingSave
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	if p, ok := m.progresses[id]; ok && p.IsChunked {
 		if chunkNumber > 0 && chunkNumber <= len(p.Chunks) {
 			p.Chunks[chunkNumber-1].Uploaded = uploaded
@@ -133,84 +204,84 @@ func (m *Manager) UpdateChunk(id string, chunkNumber int, uploaded bool) {
 				p.Percentage = float64(totalUploaded) / float64(p.Total) * 100
 			}
 
-			// 通知订阅者
-			for ch := range m.subscribers[id] {
-				select {
-				case ch <- *p:
-				default:
-				}
-			}
+			m.notify(id, p)
+			s = m.snapshotThrottled(id, p)
 		}
 	}
+	m.mu.Unlock()
+	m.persist(s)
 }
 
 func (m *Manager) Finish(id string) {
+	var s *pendingSave
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	if p, ok := m.progresses[id]; ok {
 		p.Status = "completed"
 		p.Percentage = 100
 		p.Uploaded = p.Total
+		p.UpdateTime = time.Now()
 
-		// 最后通知一次订阅者
-		for ch := range m.subscribers[id] {
-			select {
-			case ch <- *p:
-			default:
-			}
-		}
+		// 最后通知一次订阅者，并以较短的过期时间保存最终状态
+		m.notify(id, p)
+		s = m.snapshot(id, p, m.opts.FinishedTTL)
 	}
+	m.mu.Unlock()
+	m.persist(s)
 
-	// 延迟删除进度信息，让客户端有时间接收完成状态
-	go func() {
-		time.Sleep(5 * time.Second)
-		m.mu.Lock()
-		defer m.mu.Unlock()
-		delete(m.progresses, id)
-		if subs, ok := m.subscribers[id]; ok {
-			for ch := range subs {
-				close(ch)
-			}
-			delete(m.subscribers, id)
-		}
-	}()
+	m.releaseLater(id)
 }
 
 func (m *Manager) Fail(id string, errorMsg string) {
+	var s *pendingSave
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	if p, ok := m.progresses[id]; ok {
 		p.Status = "failed"
+		p.Error = errorMsg
+		p.UpdateTime = time.Now()
 
 		// 通知订阅者失败状态
-		for ch := range m.subscribers[id] {
-			select {
-			case ch <- *p:
-			default:
-			}
-		}
+		m.notify(id, p)
+		s = m.snapshot(id, p, m.opts.FinishedTTL)
 	}
+	m.mu.Unlock()
+	m.persist(s)
+
+	m.releaseLater(id)
 }
 
+// Get 获取进度，本实例没有时从 Store 读取（任务可能在其他实例上传）
 func (m *Manager) Get(id string) (Progress, bool) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
 	p, ok := m.progresses[id]
-	if !ok {
+	if ok {
+		progress := *p
+		m.mu.RUnlock()
+		return progress, true
+	}
+	m.mu.RUnlock()
+
+	progress, ok, err := m.store.Load(id)
+	if err != nil {
+		logger.Warn("读取上传进度失败", zap.String("task_id", id), zap.Error(err))
 		return Progress{}, false
 	}
-	return *p, true
+	return progress, ok
 }
 
 func (m *Manager) Subscribe(id string) chan Progress {
 	ch := make(chan Progress, 10)
+	current, ok := m.Get(id)
+
 	m.mu.Lock()
 	if _, ok := m.subscribers[id]; !ok {
 		m.subscribers[id] = make(map[chan Progress]struct{})
 	}
 	m.subscribers[id][ch] = struct{}{}
-	if p, ok := m.progresses[id]; ok {
+	if p, local := m.progresses[id]; local {
 		ch <- *p
+	} else if ok {
+		ch <- current
+		m.lastSent[id] = current.UpdateTime
 	}
 	m.mu.Unlock()
 	return ch
@@ -225,7 +296,189 @@ func (m *Manager) Unsubscribe(id string, ch chan Progress) {
 		}
 		if len(subs) == 0 {
 			delete(m.subscribers, id)
+			delete(m.lastSent, id)
 		}
 	}
 	m.mu.Unlock()
 }
+
+// notify 通知本实例的订阅者，调用方需持有锁
+func (m *Manager) notify(id string, p *Progress) {
+	for ch := range m.subscribers[id] {
+		select {
+		case ch <- *p:
+		default:
+		}
+	}
+}
+
+// closeSubscribers 关闭任务的所有订阅通道，调用方需持有锁
+func (m *Manager) closeSubscribers(id string) {
+	if subs, ok := m.subscribers[id]; ok {
+		for ch := range subs {
+			close(ch)
+		}
+		delete(m.subscribers, id)
+	}
+	delete(m.lastSent, id)
+}
+
+// taskSaver 串行写入同一任务的快照，较旧的快照不会覆盖已写入的较新快照
+type taskSaver struct {
+	mu  sync.Mutex
+	seq uint64
+}
+
+// pendingSave 在锁内复制的进度快照，释放锁后由 persist 写入 Store
+type pendingSave struct {
+	id       string
+	progress Progress
+	ttl      time.Duration
+	seq      uint64
+	saver    *taskSaver
+}
+
+// snapshot 复制进度等待写入 Store，调用方需持有锁
+// Store 可能是远程存储，不能在持有锁时写入，否则一次慢写入会阻塞所有任务的进度更新和查询
+func (m *Manager) snapshot(id string, p *Progress, ttl time.Duration) *pendingSave {
+	m.lastSaved[id] = time.Now()
+	m.saveSeq++
+	saver, ok := m.savers[id]
+	if !ok {
+		saver = &taskSaver{}
+		m.savers[id] = saver
+	}
+
+	progress := *p
+	progress.Chunks = append([]ChunkInfo(nil), p.Chunks...)
+	return &pendingSave{id: id, progress: progress, ttl: ttl, seq: m.saveSeq, saver: saver}
+}
+
+// snapshotThrottled 按 SaveInterval 节流写入 Store，避免每次读取数据都访问存储；无需写入时返回 nil
+func (m *Manager) snapshotThrottled(id string, p *Progress) *pendingSave {
+	if time.Since(m.lastSaved[id]) < m.opts.SaveInterval {
+		return nil
+	}
+	return m.snapshot(id, p, m.opts.TTL)
+}
+
+// persist 将快照写入 Store，调用方不能持有锁
+func (m *Manager) persist(s *pendingSave) {
+	if s == nil {
+		return
+	}
+	s.saver.mu.Lock()
+	defer s.saver.mu.Unlock()
+	if s.seq <= s.saver.seq {
+		return
+	}
+	s.saver.seq = s.seq
+	if err := m.store.Save(s.id, s.progress, s.ttl); err != nil {
+		logger.Warn("保存上传进度失败", zap.String("task_id", s.id), zap.Error(err))
+	}
+}
+
+// releaseLater 延迟释放本地进度，让客户端有时间接收完成状态
+func (m *Manager) releaseLater(id string) {
+	go func() {
+		time.Sleep(finishGrace)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.progresses, id)
+		delete(m.lastSaved, id)
+		delete(m.savers, id)
+		m.closeSubscribers(id)
+	}()
+}
+
+// pollLoop 为订阅了其他实例任务的连接轮询 Store
+func (m *Manager) pollLoop() {
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			m.pollRemote()
+		}
+	}
+}
+
+func (m *Manager) pollRemote() {
+	m.mu.RLock()
+	var ids []string
+	for id := range m.subscribers {
+		if _, local := m.progresses[id]; !local {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, id := range ids {
+		p, ok, err := m.store.Load(id)
+		if err != nil {
+			logger.Warn("轮询上传进度失败", zap.String("task_id", id), zap.Error(err))
+			continue
+		}
+
+		m.mu.Lock()
+		if _, local := m.progresses[id]; local {
+			m.mu.Unlock()
+			continue
+		}
+		if !ok {
+			// 记录已过期，结束订阅
+			m.closeSubscribers(id)
+			m.mu.Unlock()
+			continue
+		}
+		if p.UpdateTime.After(m.lastSent[id]) {
+			m.lastSent[id] = p.UpdateTime
+			m.notify(id, &p)
+		}
+		if p.Status != "uploading" {
+			m.closeSubscribers(id)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// cleanupLoop 定期清理过期的进度记录以及长时间没有更新的本地任务
+func (m *Manager) cleanupLoop() {
+	interval := m.opts.FinishedTTL
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			m.cleanup()
+		}
+	}
+}
+
+func (m *Manager) cleanup() {
+	if err := m.store.DeleteExpired(); err != nil {
+		logger.Warn("清理过期上传进度失败", zap.Error(err))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, p := range m.progresses {
+		// 客户端中断等原因未调用 Finish/Fail 的任务
+		if now.Sub(p.UpdateTime) > m.opts.TTL {
+			delete(m.progresses, id)
+			delete(m.lastSaved, id)
+			delete(m.savers, id)
+			m.closeSubscribers(id)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package upload
+
+import (
+	"testing"
+	"time"
+)
+
+func testOptions() Options {
+	return Options{
+		TTL:          time.Hour,
+		FinishedTTL:  50 * time.Millisecond,
+		SaveInterval: time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	}
+}
+
+func TestManagerSharedStoreAcrossInstances(t *testing.T) {
+	store := NewMemoryStore()
+	writer := NewManagerWithStore(store, testOptions())
+	reader := NewManagerWithStore(store, testOptions())
+	defer writer.Close()
+	defer reader.Close()
+
+	writer.Start("task", 100)
+
+	// 另一个实例可以读取到进度
+	if _, ok := reader.Get("task"); !ok {
+		t.Fatal("reader.Get() ok = false, want true")
+	}
+
+	ch := reader.Subscribe("task")
+	first := <-ch
+	if first.Status != "uploading" {
+		t.Fatalf("initial status = %q, want uploading", first.Status)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	writer.Update("task", 40)
+	writer.Finish("task")
+
+	timeout := time.After(time.Second)
+	var last Progress
+	for {
+		select {
+		case p, ok := <-ch:
+			if !ok {
+				if last.Status != "completed" {
+					t.Fatalf("last status = %q, want completed", last.Status)
+				}
+				return
+			}
+			last = p
+		case <-timeout:
+			t.Fatal("subscription on remote instance was not closed after completion")
+		}
+	}
+}
+
+func TestManagerEvictsFinishedTasks(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManagerWithStore(store, testOptions())
+	defer m.Close()
+
+	m.Start("done", 10)
+	m.Fail("failed", "never started")
+	m.Finish("done")
+
+	if p, ok := m.Get("done"); !ok || p.Status != "completed" {
+		t.Fatalf("Get() = %+v, %v; want completed task", p, ok)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	m.cleanup()
+	if n := store.Len(); n != 0 {
+		t.Errorf("store.Len() = %d after TTL, want 0", n)
+	}
+	if _, ok, _ := store.Load("done"); ok {
+		t.Error("finished task still present in store after FinishedTTL")
+	}
+}
+
+func TestManagerEvictsStaleLocalTasks(t *testing.T) {
+	opts := testOptions()
+	opts.TTL = 10 * time.Millisecond
+	m := NewManagerWithStore(NewMemoryStore(), opts)
+	defer m.Close()
+
+	m.Start("stale", 10)
+	ch := m.Subscribe("stale")
+	<-ch
+
+	time.Sleep(20 * time.Millisecond)
+	m.cleanup()
+
+	if _, ok := m.Get("stale"); ok {
+		t.Error("stale task still returned by Get()")
+	}
+	if _, ok := <-ch; ok {
+		t.Error("subscriber channel not closed for evicted task")
+	}
+}
+
+// blockingStore 在 release 关闭前阻塞所有写入
+type blockingStore struct {
+	Store
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Save(id string, p Progress, ttl time.Duration) error {
+	select {
+	case s.saving <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return s.Store.Save(id, p, ttl)
+}
+
+func TestManagerSavesOutsideLock(t *testing.T) {
+	store := &blockingStore{Store: NewMemoryStore(), saving: make(chan struct{}, 1), release: make(chan struct{})}
+	m := NewManagerWithStore(store, testOptions())
+	defer m.Close()
+
+	go m.Start("task", 100)
+	<-store.saving
+
+	// 写入 Store 阻塞时仍然可以查询本地进度
+	done := make(chan Progress)
+	go func() {
+		p, _ := m.Get("task")
+		done <- p
+	}()
+	select {
+	case p := <-done:
+		if p.Total != 100 {
+			t.Errorf("Get().Total = %d, want 100", p.Total)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Get() blocked while the store was saving")
+	}
+	close(store.release)
+}
+
+func TestManagerCleanupEvictsAbandonedTasks(t *testing.T) {
+	opts := testOptions()
+	opts.TTL = 10 * time.Millisecond
+	m := NewManagerWithStore(NewMemoryStore(), opts)
+	defer m.Close()
+
+	m.Start("abandoned", 10)
+	time.Sleep(20 * time.Millisecond)
+	m.cleanup()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.progresses) != 0 || len(m.lastSaved) != 0 || len(m.savers) != 0 {
+		t.Errorf("abandoned task not evicted: progresses=%d lastSaved=%d savers=%d",
+			len(m.progresses), len(m.lastSaved), len(m.savers))
+	}
+}
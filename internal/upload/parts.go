@@ -0,0 +1,178 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/myysophia/ossmanager/internal/oss"
+)
+
+const (
+	// DefaultPartSize 默认分片大小
+	DefaultPartSize int64 = 10 * 1024 * 1024
+	// MaxPartSize 客户端可指定的最大分片大小，超大文件按 MaxParts 自动放大的分片不受此限制
+	MaxPartSize int64 = 512 * 1024 * 1024
+	// MaxParts 存储端允许的最大分片数（阿里云 OSS 与 S3 均为 10000）
+	MaxParts = 10000
+
+	partSizeAlign int64 = 1024 * 1024
+)
+
+// PartSize 根据文件大小和期望的分片大小计算实际分片大小
+// 分片数超过 MaxParts 时放大分片（按 1MB 对齐），保证分片数不超过存储端限制
+func PartSize(totalSize, requested int64) int64 {
+	partSize := requested
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	if partSize > MaxPartSize {
+		partSize = MaxPartSize
+	}
+	if totalSize > partSize*MaxParts {
+		partSize = (totalSize + MaxParts - 1) / MaxParts
+		partSize = (partSize + partSizeAlign - 1) / partSizeAlign * partSizeAlign
+	}
+	return partSize
+}
+
+// PartFunc 上传单个分片并返回 ETag，data 在函数返回后会被复用，不能保留引用
+type PartFunc func(ctx context.Context, partNumber int, data []byte) (string, error)
+
+// PartsOptions 分片流水线参数
+type PartsOptions struct {
+	PartSize    int64 // 分片大小，最后一片可以更小
+	Size        int64 // 需要从 reader 读取的字节数，小于 0 表示读到 EOF 为止
+	Concurrency int   // 同时上传的分片数
+	FirstPart   int   // 第一个分片的编号，续传时为已上传分片数 + 1
+
+	// BeforeRead 每次读取分片前调用，例如为请求体设置读超时
+	BeforeRead func()
+	// OnPart 分片上传成功后调用
+	OnPart func(partNumber int)
+}
+
+type partJob struct {
+	number int
+	buf    []byte
+	data   []byte
+}
+
+// UploadParts 从 r 顺序读取分片，交给最多 Concurrency 个 worker 并发上传
+// 分片缓冲区循环复用且按需分配，内存占用不超过 Concurrency × PartSize
+// 任一分片失败时停止读取并返回第一个错误，返回的分片按编号排序
+func UploadParts(ctx context.Context, r io.Reader, opts PartsOptions, uploadPart PartFunc) ([]oss.Part, error) {
+	if opts.PartSize <= 0 {
+		return nil, fmt.Errorf("invalid part size %d", opts.PartSize)
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.FirstPart <= 0 {
+		opts.FirstPart = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		parts    []oss.Part
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	// 缓冲池中的 nil 表示尚未分配的名额，小文件不会一次性分配 Concurrency 个缓冲区
+	pool := make(chan []byte, opts.Concurrency)
+	for i := 0; i < opts.Concurrency; i++ {
+		pool <- nil
+	}
+
+	jobs := make(chan partJob)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() == nil {
+					etag, err := uploadPart(ctx, job.number, job.data)
+					if err != nil {
+						fail(err)
+					} else {
+						mu.Lock()
+						parts = append(parts, oss.Part{PartNumber: job.number, ETag: etag})
+						mu.Unlock()
+						if opts.OnPart != nil {
+							opts.OnPart(job.number)
+						}
+					}
+				}
+				pool <- job.buf
+			}
+		}()
+	}
+
+	partNumber := opts.FirstPart
+	unknownSize := opts.Size < 0
+	for remaining := opts.Size; unknownSize || remaining > 0; {
+		var buf []byte
+		select {
+		case <-ctx.Done():
+		case buf = <-pool:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if buf == nil {
+			buf = make([]byte, opts.PartSize)
+		}
+
+		size := opts.PartSize
+		if !unknownSize && remaining < size {
+			size = remaining
+		}
+		if opts.BeforeRead != nil {
+			opts.BeforeRead()
+		}
+		n, err := io.ReadFull(r, buf[:size])
+		eof := unknownSize && (err == io.EOF || err == io.ErrUnexpectedEOF)
+		if err != nil && !eof {
+			pool <- buf
+			fail(fmt.Errorf("读取分片 %d 数据失败: %w", partNumber, err))
+			break
+		}
+		if n == 0 {
+			pool <- buf
+			break
+		}
+
+		jobs <- partJob{number: partNumber, buf: buf, data: buf[:n]}
+		remaining -= int64(n)
+		partNumber++
+		if eof {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
@@ -0,0 +1,177 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPartSize(t *testing.T) {
+	const mb = 1024 * 1024
+	tests := []struct {
+		name      string
+		totalSize int64
+		requested int64
+		want      int64
+	}{
+		{"default", 100 * mb, 0, DefaultPartSize},
+		{"requested", 100 * mb, 20 * mb, 20 * mb},
+		{"requested too large", 100 * mb, 10 * 1024 * mb, MaxPartSize},
+		{"exactly max parts", DefaultPartSize * MaxParts, 0, DefaultPartSize},
+		{"grows for huge files", 200 * 1024 * mb, 0, 21 * mb},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PartSize(tt.totalSize, tt.requested)
+			if got != tt.want {
+				t.Errorf("PartSize(%d, %d) = %d, want %d", tt.totalSize, tt.requested, got, tt.want)
+			}
+			if parts := (tt.totalSize + got - 1) / got; parts > MaxParts {
+				t.Errorf("PartSize(%d, %d) gives %d parts", tt.totalSize, tt.requested, parts)
+			}
+		})
+	}
+}
+
+func TestUploadParts(t *testing.T) {
+	content := strings.Repeat("0123456789", 105) // 1050 字节，11 个分片
+	const concurrency = 3
+
+	var (
+		mu       sync.Mutex
+		received = map[int]string{}
+		buffers  = map[*byte]bool{}
+		active   int32
+		peak     int32
+	)
+	parts, err := UploadParts(context.Background(), strings.NewReader(content), PartsOptions{
+		PartSize:    100,
+		Size:        int64(len(content)),
+		Concurrency: concurrency,
+	}, func(ctx context.Context, partNumber int, data []byte) (string, error) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		mu.Lock()
+		if n > peak {
+			peak = n
+		}
+		received[partNumber] = string(data)
+		buffers[&data[:1][0]] = true
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		return fmt.Sprintf("etag-%d", partNumber), nil
+	})
+	if err != nil {
+		t.Fatalf("UploadParts() error = %v", err)
+	}
+
+	if len(parts) != 11 {
+		t.Fatalf("len(parts) = %d, want 11", len(parts))
+	}
+	var joined bytes.Buffer
+	for i, p := range parts {
+		if p.PartNumber != i+1 || p.ETag != fmt.Sprintf("etag-%d", i+1) {
+			t.Errorf("parts[%d] = %+v", i, p)
+		}
+		joined.WriteString(received[p.PartNumber])
+	}
+	if joined.String() != content {
+		t.Error("uploaded parts do not reassemble the content")
+	}
+	if peak > concurrency {
+		t.Errorf("peak concurrency = %d, want <= %d", peak, concurrency)
+	}
+	if len(buffers) > concurrency {
+		t.Errorf("allocated %d buffers, want <= %d", len(buffers), concurrency)
+	}
+}
+
+func TestUploadPartsFirstPart(t *testing.T) {
+	parts, err := UploadParts(context.Background(), strings.NewReader("abcdefgh"), PartsOptions{
+		PartSize:  4,
+		Size:      8,
+		FirstPart: 3,
+	}, func(ctx context.Context, partNumber int, data []byte) (string, error) {
+		return string(data), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].PartNumber != 3 || parts[0].ETag != "abcd" || parts[1].PartNumber != 4 {
+		t.Errorf("parts = %+v", parts)
+	}
+}
+
+func TestUploadPartsStopsOnError(t *testing.T) {
+	errUpload := errors.New("upload failed")
+	var calls int32
+	reader := &countingReader{r: strings.NewReader(strings.Repeat("x", 10000))}
+
+	_, err := UploadParts(context.Background(), reader, PartsOptions{
+		PartSize:    10,
+		Size:        10000,
+		Concurrency: 2,
+	}, func(ctx context.Context, partNumber int, data []byte) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		if partNumber == 2 {
+			return "", errUpload
+		}
+		return "etag", nil
+	})
+	if !errors.Is(err, errUpload) {
+		t.Fatalf("UploadParts() error = %v, want %v", err, errUpload)
+	}
+	if reader.n >= 10000 {
+		t.Error("UploadParts() kept reading after a part failed")
+	}
+}
+
+func TestUploadPartsShortRead(t *testing.T) {
+	_, err := UploadParts(context.Background(), strings.NewReader("short"), PartsOptions{
+		PartSize: 4,
+		Size:     10,
+	}, func(ctx context.Context, partNumber int, data []byte) (string, error) {
+		return "etag", nil
+	})
+	if err == nil {
+		t.Fatal("UploadParts() error = nil for truncated input")
+	}
+}
+
+type countingReader struct {
+	r *strings.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestUploadPartsUnknownSize(t *testing.T) {
+	for _, content := range []string{"", "abc", "abcdefgh", "abcdefghij"} {
+		var joined strings.Builder
+		parts, err := UploadParts(context.Background(), strings.NewReader(content), PartsOptions{
+			PartSize: 4,
+			Size:     -1,
+		}, func(ctx context.Context, partNumber int, data []byte) (string, error) {
+			return string(data), nil
+		})
+		if err != nil {
+			t.Fatalf("UploadParts(%q) error = %v", content, err)
+		}
+		for _, p := range parts {
+			joined.WriteString(p.ETag)
+		}
+		if want := (len(content) + 3) / 4; len(parts) != want || joined.String() != content {
+			t.Errorf("UploadParts(%q) = %+v", content, parts)
+		}
+	}
+}
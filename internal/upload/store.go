@@ -0,0 +1,79 @@
+package upload
+
+import (
+	"sync"
+	"time"
+)
+
+// Store 上传进度持久化存储
+// Manager 通过 Store 在多个实例之间共享进度：写入进度的实例负责保存，
+// 其他实例上的 SSE 订阅者通过轮询 Store 获取最新进度
+type Store interface {
+	// Save 保存进度，ttl 为该条记录的存活时间
+	Save(id string, p Progress, ttl time.Duration) error
+	// Load 读取进度，不存在或已过期时返回 false
+	Load(id string) (Progress, bool, error)
+	// Delete 删除进度
+	Delete(id string) error
+	// DeleteExpired 清理所有过期的进度记录
+	DeleteExpired() error
+}
+
+type memoryEntry struct {
+	progress  Progress
+	expiresAt time.Time
+}
+
+// MemoryStore 进程内进度存储，仅适用于单实例部署
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryStore 创建进程内进度存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryStore) Save(id string, p Progress, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id] = memoryEntry{progress: p, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Load(id string) (Progress, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[id]
+	if !ok || time.Now().After(e.expiresAt) {
+		return Progress{}, false, nil
+	}
+	return e.progress, true, nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpired() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, e := range s.entries {
+		if now.After(e.expiresAt) {
+			delete(s.entries, id)
+		}
+	}
+	return nil
+}
+
+// Len 返回当前保存的记录数（含尚未清理的过期记录）
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
@@ -0,0 +1,64 @@
+package upload
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/myysophia/ossmanager/internal/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore 基于数据库的进度存储，多个实例共享同一张 upload_progresses 表
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore 创建数据库进度存储
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Save(id string, p Progress, ttl time.Duration) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	record := models.UploadProgress{
+		TaskID:    id,
+		Status:    p.Status,
+		Data:      string(data),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "data", "expires_at", "updated_at"}),
+	}).Create(&record).Error
+}
+
+func (s *PostgresStore) Load(id string) (Progress, bool, error) {
+	var record models.UploadProgress
+	err := s.db.Where("task_id = ? AND expires_at > ?", id, time.Now()).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Progress{}, false, nil
+	}
+	if err != nil {
+		return Progress{}, false, err
+	}
+
+	var p Progress
+	if err := json.Unmarshal([]byte(record.Data), &p); err != nil {
+		return Progress{}, false, err
+	}
+	return p, true, nil
+}
+
+func (s *PostgresStore) Delete(id string) error {
+	return s.db.Unscoped().Where("task_id = ?", id).Delete(&models.UploadProgress{}).Error
+}
+
+func (s *PostgresStore) DeleteExpired() error {
+	return s.db.Unscoped().Where("expires_at <= ?", time.Now()).Delete(&models.UploadProgress{}).Error
+}
@@ -31,6 +31,9 @@ const (
 	CodeFileNotFound   = 40405 // 文件不存在
 	CodeConfigInUse    = 40001 // 配置正在使用中
 	CodeFileExists     = 40009 // 文件已存在
+	CodeQuotaExceeded  = 40013 // 存储配额不足
+	CodePolicyViolation = 40014 // 上传内容不符合策略
+	CodeChecksumMismatch = 40015 // 文件校验和不匹配
 )
 
 // 对应的消息
@@ -49,6 +52,9 @@ var codeMsgMap = map[int]string{
 	CodeFileNotFound:   "文件不存在",
 	CodeConfigInUse:    "配置正在使用中",
 	CodeFileExists:     "文件已存在",
+	CodeQuotaExceeded:  "存储配额不足",
+	CodePolicyViolation: "上传内容不符合存储桶策略",
+	CodeChecksumMismatch: "文件校验和不匹配",
 }
 
 // ResponseWithJSON 返回JSON响应
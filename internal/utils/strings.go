@@ -0,0 +1,10 @@
+package utils
+
+// Truncate 将 s 截断到最多 n 个字符
+// 按字符而不是字节截断：PostgreSQL 的 varchar(n) 按字符计算长度，按字节截断还可能切开多字节字符，写入时报错
+func Truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -3,16 +3,23 @@ package webdav
 import (
 	"bytes"
 	"context"
-	"crypto/md5"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"golang.org/x/net/webdav"
 	"gorm.io/gorm"
+	"github.com/myysophia/ossmanager/internal/checksum"
 	models "github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"github.com/myysophia/ossmanager/internal/trash"
 )
 
 // OSSFile 实现 WebDAV File 接口
@@ -24,6 +31,7 @@ type OSSFile struct {
 	isCreate bool
 	closed   bool
 	isDir    bool
+	expected *checksum.Expected // 客户端声明的校验和
 }
 
 // NewOSSFile 创建新的OSS文件对象
@@ -65,6 +73,17 @@ func (f *OSSFile) Close() error {
 			return fmt.Errorf("large file upload not implemented yet (size: %d bytes)", fileSize)
 		}
 
+		// 内容完整缓存在内存中，先校验客户端声明的校验和，不一致时不写入存储端
+		verifier := checksum.NewVerifier(f.expected)
+		verifier.Write(f.buffer.Bytes())
+		if err := verifier.Verify(); err != nil {
+			return err
+		}
+
+		if err := f.fs.checkUpload(f.name, f.buffer.Bytes()); err != nil {
+			return err
+		}
+
 		// 小文件直接上传
 		err := f.fs.storage.PutObject(
 			context.Background(),
@@ -78,13 +97,8 @@ func (f *OSSFile) Close() error {
 			return fmt.Errorf("failed to upload file: %w", err)
 		}
 
-		// 计算文件MD5
-		bufferData := f.buffer.Bytes()
-		hash := md5.Sum(bufferData)
-		md5Hash := fmt.Sprintf("%x", hash)
-
 		// 同步到数据库
-		err = f.syncToDatabase(fileSize, md5Hash)
+		err = f.syncToDatabase(fileSize, verifier.Sums())
 		if err != nil {
 			return fmt.Errorf("failed to sync to database: %w", err)
 		}
@@ -98,39 +112,58 @@ func (f *OSSFile) Close() error {
 }
 
 // syncToDatabase 同步文件信息到数据库
-func (f *OSSFile) syncToDatabase(fileSize int64, md5Hash string) error {
-	// 检查文件是否已存在
+func (f *OSSFile) syncToDatabase(fileSize int64, sums map[string]string) error {
+	// 检查文件是否已存在，只匹配在用的记录，覆盖后保留的 REPLACED 历史记录不参与
 	var existingFile models.OSSFile
-	err := f.fs.db.Where("object_key = ? AND bucket = ?", f.name, f.fs.bucket).
-		First(&existingFile).Error
+	err := f.fs.db.Where("object_key = ? AND bucket = ? AND status IN ?", f.name, f.fs.bucket, models.LiveFileStatuses).
+		Order("id DESC").First(&existingFile).Error
 
 	if err == nil {
-		// 文件已存在，更新记录
+		// 文件已存在，更新记录，旧内容的摘要一并替换
 		updates := map[string]interface{}{
-			"file_size":    fileSize,
-			"md5":          md5Hash,
-			"md5_status":   models.MD5StatusCompleted,
-			"updated_at":   time.Now(),
-			"status":       "ACTIVE",
+			"file_size":   fileSize,
+			"md5":         sums[hashing.MD5],
+			"md5_status":  models.MD5StatusCompleted,
+			"etag":        "",
+			"updated_at":  time.Now(),
+			"status":      "ACTIVE",
+			"scan_status": scan.InitialStatus(),
 		}
-
-		return f.fs.db.Model(&existingFile).Updates(updates).Error
+		if err := f.fs.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&existingFile).Updates(updates).Error; err != nil {
+				return err
+			}
+			return hashing.Replace(tx, existingFile.ID, sums)
+		}); err != nil {
+			return err
+		}
+		scan.Notify()
+		return nil
 	} else if err == gorm.ErrRecordNotFound {
 		// 文件不存在，创建新记录
 		ossFile := &models.OSSFile{
 			Filename:         path.Base(f.name),
 			OriginalFilename: path.Base(f.name),
 			FileSize:         fileSize,
-			MD5:              md5Hash,
+			MD5:              sums[hashing.MD5],
 			MD5Status:        models.MD5StatusCompleted,
 			StorageType:      f.fs.storage.GetType(),
 			Bucket:           f.fs.bucket,
 			ObjectKey:        f.name,
 			UploaderID:       f.fs.userID,
 			Status:           "ACTIVE",
+			ScanStatus:       scan.InitialStatus(),
 		}
-
-		return f.fs.db.Create(ossFile).Error
+		if err := f.fs.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(ossFile).Error; err != nil {
+				return err
+			}
+			return hashing.Save(tx, ossFile.ID, sums)
+		}); err != nil {
+			return err
+		}
+		scan.Notify()
+		return nil
 	}
 
 	// 其他数据库错误
@@ -183,11 +216,15 @@ func (f *OSSFile) Readdir(count int) ([]os.FileInfo, error) {
 
 	var fileInfos []os.FileInfo
 	seen := make(map[string]bool)
+	bin := trash.Default()
 
 	for _, obj := range objects {
 		if obj.Key == dirPath {
 			continue // 跳过目录本身
 		}
+		if bin != nil && bin.Contains(obj.Key) {
+			continue // 回收站中的对象不在 WebDAV 中显示
+		}
 
 		// 获取相对路径
 		relativePath := strings.TrimPrefix(obj.Key, dirPath)
@@ -250,6 +287,50 @@ func (f *OSSFile) Stat() (os.FileInfo, error) {
 	return f.fs.Stat(context.Background(), f.name)
 }
 
+// 配额相关的 WebDAV 属性（RFC 4331）
+var (
+	propQuotaAvailableBytes = xml.Name{Space: "DAV:", Local: "quota-available-bytes"}
+	propQuotaUsedBytes      = xml.Name{Space: "DAV:", Local: "quota-used-bytes"}
+)
+
+// DeadProps 为目录返回配额属性，实现 webdav.DeadPropsHolder
+func (f *OSSFile) DeadProps() (map[xml.Name]webdav.Property, error) {
+	if !f.isDir {
+		return nil, nil
+	}
+
+	report, err := f.fs.QuotaReport()
+	if err != nil {
+		return nil, err
+	}
+
+	props := map[xml.Name]webdav.Property{
+		propQuotaUsedBytes: {
+			XMLName:  propQuotaUsedBytes,
+			InnerXML: []byte(strconv.FormatInt(report.Usage.Bytes, 10)),
+		},
+	}
+	// 不限制时按 RFC 4331 省略 quota-available-bytes
+	if report.AvailableBytes >= 0 {
+		props[propQuotaAvailableBytes] = webdav.Property{
+			XMLName:  propQuotaAvailableBytes,
+			InnerXML: []byte(strconv.FormatInt(report.AvailableBytes, 10)),
+		}
+	}
+	return props, nil
+}
+
+// Patch 不支持修改属性，所有 PROPPATCH 均返回 403
+func (f *OSSFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	stat := webdav.Propstat{Status: http.StatusForbidden}
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			stat.Props = append(stat.Props, webdav.Property{XMLName: p.XMLName})
+		}
+	}
+	return []webdav.Propstat{stat}, nil
+}
+
 // getFileMode 根据是否是目录返回文件模式
 func getFileMode(isDir bool) os.FileMode {
 	if isDir {
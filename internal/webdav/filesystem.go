@@ -2,17 +2,24 @@ package webdav
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/webdav"
 	"gorm.io/gorm"
 
 	models "github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/checksum"
 	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/policy"
+	"github.com/myysophia/ossmanager/internal/quota"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"github.com/myysophia/ossmanager/internal/trash"
 )
 
 // OSSFileSystem 实现 WebDAV FileSystem 接口
@@ -21,8 +28,17 @@ type OSSFileSystem struct {
 	db      *gorm.DB
 	userID  uint
 	bucket  string
+	quota   *quota.Service
+	policy  *policy.Service
+
+	quotaMu       sync.Mutex
+	quotaReport   *quota.Report
+	quotaReportAt time.Time
 }
 
+// quotaReportTTL 配额属性的缓存时间
+const quotaReportTTL = 5 * time.Second
+
 // NewOSSFileSystem 创建新的OSS文件系统
 func NewOSSFileSystem(service oss.StorageService, db *gorm.DB, userID uint, bucket string) *OSSFileSystem {
 	return &OSSFileSystem{
@@ -30,7 +46,59 @@ func NewOSSFileSystem(service oss.StorageService, db *gorm.DB, userID uint, buck
 		db:      db,
 		userID:  userID,
 		bucket:  bucket,
+		quota:   quota.NewService(db),
+		policy:  policy.NewService(db),
+	}
+}
+
+// CheckQuota 校验当前用户向存储桶写入 size 字节的新文件是否超出配额
+func (fs *OSSFileSystem) CheckQuota(size int64) error {
+	return fs.quota.Check(fs.userID, fs.bucket, size)
+}
+
+// QuotaReport 返回当前用户在存储桶上的配额使用情况
+// 配额与目录无关，PROPFIND Depth:1 会为每个子目录读取一次属性，短时间内复用同一份结果
+func (fs *OSSFileSystem) QuotaReport() (*quota.Report, error) {
+	fs.quotaMu.Lock()
+	defer fs.quotaMu.Unlock()
+	if fs.quotaReport != nil && time.Since(fs.quotaReportAt) < quotaReportTTL {
+		return fs.quotaReport, nil
+	}
+
+	report, err := fs.quota.Report(fs.userID, fs.bucket)
+	if err != nil {
+		return nil, err
 	}
+	fs.quotaReport, fs.quotaReportAt = report, time.Now()
+	return report, nil
+}
+
+// CheckPolicy 按存储桶上传策略校验文件名和大小，size 小于 0 表示大小未知
+func (fs *OSSFileSystem) CheckPolicy(name string, size int64) error {
+	p, err := fs.policy.Load("", fs.bucket)
+	if err != nil {
+		return err
+	}
+	return p.CheckFile(name, size)
+}
+
+// checkUpload 写入对象前按策略和配额校验文件名、大小和文件头
+func (fs *OSSFileSystem) checkUpload(name string, data []byte) error {
+	p, err := fs.policy.Load("", fs.bucket)
+	if err != nil {
+		return err
+	}
+	if err := p.CheckFile(name, int64(len(data))); err != nil {
+		return err
+	}
+	head := data
+	if len(head) > policy.SniffLen {
+		head = head[:policy.SniffLen]
+	}
+	if err := p.CheckContent(name, head); err != nil {
+		return err
+	}
+	return fs.CheckQuota(int64(len(data)))
 }
 
 // Mkdir 创建目录
@@ -50,8 +118,10 @@ func (fs *OSSFileSystem) OpenFile(ctx context.Context, name string, flag int, pe
 	name = strings.TrimPrefix(name, "/")
 
 	if flag&os.O_CREATE != 0 {
-		// 创建新文件
-		return NewOSSFile(fs, name, true), nil
+		// 创建新文件，写入完成时按 context 中声明的校验和校验内容
+		file := NewOSSFile(fs, name, true)
+		file.expected = checksum.FromContext(ctx)
+		return file, nil
 	}
 
 	// 检查是否是目录
@@ -59,6 +129,15 @@ func (fs *OSSFileSystem) OpenFile(ctx context.Context, name string, flag int, pe
 		return NewOSSFile(fs, name, false), nil
 	}
 
+	// 感染病毒或尚未完成扫描的文件拒绝读取
+	var record models.OSSFile
+	if err := fs.db.Where("object_key = ? AND bucket = ? AND status IN ?", name, fs.bucket,
+		[]string{"ACTIVE", models.FileStatusQuarantined}).First(&record).Error; err == nil {
+		if scan.CheckDownload(&record) != nil {
+			return nil, os.ErrPermission
+		}
+	}
+
 	// 打开现有文件
 	reader, err := fs.storage.GetObject(ctx, fs.bucket, name)
 	if err != nil {
@@ -70,7 +149,7 @@ func (fs *OSSFileSystem) OpenFile(ctx context.Context, name string, flag int, pe
 	return file, nil
 }
 
-// RemoveAll 删除文件或目录
+// RemoveAll 删除文件或目录，启用回收站时文件移入回收站
 func (fs *OSSFileSystem) RemoveAll(ctx context.Context, name string) error {
 	name = strings.TrimPrefix(name, "/")
 
@@ -80,14 +159,47 @@ func (fs *OSSFileSystem) RemoveAll(ctx context.Context, name string) error {
 	}
 
 	// 删除单个文件
-	err := fs.storage.DeleteObject(ctx, fs.bucket, name)
-	if err != nil {
-		return err
+	var size int64
+	if objects, err := fs.storage.ListObjects(ctx, fs.bucket, name, 1); err == nil && len(objects) > 0 && objects[0].Key == name {
+		size = objects[0].Size
 	}
+	return fs.removeObject(ctx, name, size)
+}
 
-	// 从数据库中删除记录
-	return fs.db.Where("object_key = ? AND user_id = ?", name, fs.userID).
-		Delete(&models.OSSFile{}).Error
+// removeObject 删除单个对象：启用回收站时将文件记录连同对象移入回收站，
+// 没有文件记录的对象先补建记录，以便从回收站恢复；目录占位对象直接删除
+func (fs *OSSFileSystem) removeObject(ctx context.Context, key string, size int64) error {
+	bin := trash.Default()
+	if !bin.Enabled() || strings.HasSuffix(key, "/") {
+		if err := fs.storage.DeleteObject(ctx, fs.bucket, key); err != nil {
+			return err
+		}
+		return fs.db.Where("object_key = ? AND bucket = ?", key, fs.bucket).
+			Delete(&models.OSSFile{}).Error
+	}
+
+	// 覆盖上传会在同一对象键留下 REPLACED 历史记录，只移动当前的记录
+	var record models.OSSFile
+	err := fs.db.Where("object_key = ? AND bucket = ? AND status IN ?", key, fs.bucket, models.LiveFileStatuses).
+		Order("id DESC").First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		record = models.OSSFile{
+			Filename:         path.Base(key),
+			OriginalFilename: path.Base(key),
+			FileSize:         size,
+			MD5Status:        models.MD5StatusPending,
+			StorageType:      fs.storage.GetType(),
+			Bucket:           fs.bucket,
+			ObjectKey:        key,
+			UploaderID:       fs.userID,
+			Status:           "ACTIVE",
+		}
+		err = fs.db.Create(&record).Error
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	return bin.Move(&record, fs.userID)
 }
 
 // Rename 重命名文件或目录
@@ -209,16 +321,15 @@ func (fs *OSSFileSystem) removeDirectory(ctx context.Context, dirName string) er
 		return err
 	}
 
-	// 删除所有子对象
+	// 删除所有子对象，回收站中的对象不受影响
+	bin := trash.Default()
 	for _, obj := range objects {
-		err = fs.storage.DeleteObject(ctx, fs.bucket, obj.Key)
-		if err != nil {
+		if bin != nil && bin.Contains(obj.Key) {
+			continue
+		}
+		if err := fs.removeObject(ctx, obj.Key, obj.Size); err != nil {
 			return fmt.Errorf("failed to delete object %s: %v", obj.Key, err)
 		}
-
-		// 从数据库中删除记录
-		fs.db.Where("object_key = ? AND user_id = ?", obj.Key, fs.userID).
-			Delete(&models.OSSFile{})
 	}
 
 	return nil
@@ -7,6 +7,7 @@ import (
 	"golang.org/x/net/webdav"
 	"gorm.io/gorm"
 
+	"github.com/myysophia/ossmanager/internal/checksum"
 	"github.com/myysophia/ossmanager/internal/oss"
 )
 
@@ -38,7 +39,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// 添加浏览器兼容的CORS头
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, MKCOL, COPY, MOVE, PROPFIND, PROPPATCH, LOCK, UNLOCK, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Depth, If, If-None-Match, Lock-Token, Overwrite, Timeout, Destination, X-User-ID, X-Bucket")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Depth, If, If-None-Match, Lock-Token, Overwrite, Timeout, Destination, X-User-ID, X-Bucket, Content-MD5, X-Checksum-Sha256")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
 	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, Last-Modified, ETag, DAV")
 	w.Header().Set("Access-Control-Max-Age", "86400") // 24小时预检缓存
@@ -48,6 +49,18 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// PUT 请求声明的校验和通过 context 传给文件系统
+	if r.Method == http.MethodPut {
+		expected, err := checksum.FromHeader(r.Header)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if expected != nil {
+			r = r.WithContext(checksum.NewContext(r.Context(), expected))
+		}
+	}
+
 	// 调用底层WebDAV处理器
 	h.Handler.ServeHTTP(w, r)
 }
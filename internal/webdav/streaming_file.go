@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/md5"
 	"fmt"
-	"hash"
 	"io"
 	"os"
 	"path"
@@ -13,8 +12,11 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"github.com/myysophia/ossmanager/internal/checksum"
 	models "github.com/myysophia/ossmanager/internal/db/models"
+	"github.com/myysophia/ossmanager/internal/hashing"
 	"github.com/myysophia/ossmanager/internal/logger"
+	"github.com/myysophia/ossmanager/internal/scan"
 	"go.uber.org/zap"
 )
 
@@ -38,7 +40,7 @@ type StreamingWriter struct {
 	bufferSize  int
 	totalSize   int64
 	written     int64
-	hash        hash.Hash
+	hash        *checksum.Verifier
 	chunks      []ChunkInfo
 	maxMemory   int64 // Maximum memory to use (default 50MB)
 }
@@ -72,7 +74,7 @@ func NewStreamingOSSFile(fs *OSSFileSystem, name string, isCreate bool) *Streami
 			buffer:     make([]byte, 0, defaultBufferSize),
 			bufferSize: defaultBufferSize,
 			maxMemory:  maxMemoryUsage,
-			hash:       md5.New(),
+			hash:       checksum.NewVerifier(nil),
 		}
 	}
 
@@ -236,11 +238,8 @@ func (w *StreamingWriter) Finalize() error {
 		}
 	}
 
-	// Calculate final hash
-	finalHash := fmt.Sprintf("%x", w.hash.Sum(nil))
-
-	// Update database
-	return w.syncToDatabase(w.totalSize, finalHash)
+	// Update database with the hashes calculated while streaming
+	return w.syncToDatabase(w.totalSize, w.hash.Sums())
 }
 
 // combineChunks combines all uploaded chunks into the final file
@@ -262,36 +261,57 @@ func (w *StreamingWriter) combineChunks(ctx context.Context) error {
 }
 
 // syncToDatabase updates file metadata in database
-func (w *StreamingWriter) syncToDatabase(fileSize int64, md5Hash string) error {
+func (w *StreamingWriter) syncToDatabase(fileSize int64, sums map[string]string) error {
 	var existingFile models.OSSFile
 	err := w.fs.db.Where("object_key = ? AND bucket = ?", w.filename, w.fs.bucket).
 		First(&existingFile).Error
 
 	if err == nil {
-		// Update existing file
+		// Update existing file and replace the hashes of the old content
 		updates := map[string]interface{}{
-			"file_size":    fileSize,
-			"md5":          md5Hash,
-			"md5_status":   models.MD5StatusCompleted,
-			"updated_at":   time.Now(),
-			"status":       "ACTIVE",
+			"file_size":   fileSize,
+			"md5":         sums[hashing.MD5],
+			"md5_status":  models.MD5StatusCompleted,
+			"etag":        "",
+			"updated_at":  time.Now(),
+			"status":      "ACTIVE",
+			"scan_status": scan.InitialStatus(),
+		}
+		if err := w.fs.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&existingFile).Updates(updates).Error; err != nil {
+				return err
+			}
+			return hashing.Replace(tx, existingFile.ID, sums)
+		}); err != nil {
+			return err
 		}
-		return w.fs.db.Model(&existingFile).Updates(updates).Error
+		scan.Notify()
+		return nil
 	} else if err == gorm.ErrRecordNotFound {
 		// Create new file record
 		ossFile := &models.OSSFile{
 			Filename:         path.Base(w.filename),
 			OriginalFilename: path.Base(w.filename),
 			FileSize:         fileSize,
-			MD5:              md5Hash,
+			MD5:              sums[hashing.MD5],
 			MD5Status:        models.MD5StatusCompleted,
 			StorageType:      w.fs.storage.GetType(),
 			Bucket:           w.fs.bucket,
 			ObjectKey:        w.filename,
 			UploaderID:       w.fs.userID,
 			Status:           "ACTIVE",
+			ScanStatus:       scan.InitialStatus(),
 		}
-		return w.fs.db.Create(ossFile).Error
+		if err := w.fs.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(ossFile).Error; err != nil {
+				return err
+			}
+			return hashing.Save(tx, ossFile.ID, sums)
+		}); err != nil {
+			return err
+		}
+		scan.Notify()
+		return nil
 	}
 
 	return fmt.Errorf("database error: %w", err)
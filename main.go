@@ -21,9 +21,17 @@ import (
 	"github.com/myysophia/ossmanager/internal/api/middleware"
 	"github.com/myysophia/ossmanager/internal/config"
 	"github.com/myysophia/ossmanager/internal/db"
+	"github.com/myysophia/ossmanager/internal/events"
 	"github.com/myysophia/ossmanager/internal/function"
+	"github.com/myysophia/ossmanager/internal/janitor"
 	"github.com/myysophia/ossmanager/internal/logger"
 	"github.com/myysophia/ossmanager/internal/oss"
+	"github.com/myysophia/ossmanager/internal/scan"
+	"github.com/myysophia/ossmanager/internal/scrub"
+	"github.com/myysophia/ossmanager/internal/tasks"
+	"github.com/myysophia/ossmanager/internal/throttle"
+	"github.com/myysophia/ossmanager/internal/trash"
+	"github.com/myysophia/ossmanager/internal/upload"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -76,16 +84,66 @@ func main() {
 		logger.Info("✅ 数据库初始化成功")
 	}
 
+	// 初始化上传进度存储，多实例部署时使用数据库共享进度
+	var progressStore upload.Store = upload.NewMemoryStore()
+	if cfg.App.Progress.Store == "postgres" && db.GetDB() != nil {
+		progressStore = upload.NewPostgresStore(db.GetDB())
+	}
+	upload.SetDefaultManager(upload.NewManagerWithStore(progressStore, upload.Options{
+		TTL:         time.Duration(cfg.App.Progress.TTL) * time.Second,
+		FinishedTTL: time.Duration(cfg.App.Progress.FinishedTTL) * time.Second,
+	}))
+	logger.Info("上传进度存储初始化成功", zap.String("store", cfg.App.Progress.Store))
+
 	// 创建存储服务工厂
 	storageFactory := oss.NewStorageFactory(&cfg.OSS)
 
-	// 创建MD5计算器
-	md5Calculator := function.NewMD5Calculator(storageFactory, cfg.App.Workers)
+	// 创建摘要计算器（MD5 及配置的其他算法）
+	md5Calculator := function.NewMD5Calculator(storageFactory, cfg.App.Workers, cfg.Hash)
 	logger.Info("MD5计算器初始化成功", zap.Int("workers", cfg.App.Workers))
 
+	// 启动未完成分片上传清理器
+	multipartJanitor := janitor.NewMultipartJanitor(storageFactory, db.GetDB(), cfg.Janitor)
+	multipartJanitor.Start()
+
+	// 启动病毒扫描器
+	scanner := scan.NewScanner(storageFactory, db.GetDB(), cfg.Scan)
+	scanner.Start()
+	scan.SetDefault(scanner)
+
+	// 启动存储完整性巡检
+	scrubber := scrub.NewScrubber(storageFactory, db.GetDB(), cfg.Scrub)
+	scrubber.Start()
+	scrub.SetDefault(scrubber)
+
+	// 初始化带宽限速
+	throttle.SetDefault(throttle.NewService(db.GetDB(), cfg.Throttle))
+	if cfg.Throttle.Enabled {
+		logger.Info("带宽限速已启用",
+			zap.Int64("upload_rate", cfg.Throttle.UploadRate),
+			zap.Int64("download_rate", cfg.Throttle.DownloadRate))
+	}
+
+	// 启动回收站过期文件清理
+	trashBin := trash.NewBin(storageFactory, db.GetDB(), cfg.Trash)
+	trashBin.Start()
+	trash.SetDefault(trashBin)
+
+	// 启动存储桶事件接入，把外部写入存储桶的对象同步到文件记录
+	ingestor := events.NewIngestor(db.GetDB(), cfg.Events, trashBin, cfg.OSS.AWSS3.UploadDir)
+	ingestor.Start()
+	events.SetDefault(ingestor)
+
+	// 创建后台任务管理器，任务类型在设置路由时注册
+	taskManager := tasks.NewManager(db.GetDB(), cfg.Tasks)
+	tasks.SetDefault(taskManager)
+
 	// 设置API路由
 	apiRouter := api.SetupRouter(storageFactory, md5Calculator, db.GetDB(), cfg)
 
+	// 启动后台任务
+	taskManager.Start()
+
 	// 创建主路由器，整合API和静态文件服务
 	mainRouter := setupIntegratedRouter(apiRouter)
 
@@ -138,6 +196,27 @@ func main() {
 	md5Calculator.Stop()
 	logger.Info("MD5计算器已关闭")
 
+	// 停止分片上传清理器
+	multipartJanitor.Stop()
+
+	// 停止病毒扫描器
+	scanner.Stop()
+
+	// 停止完整性巡检
+	scrubber.Stop()
+
+	// 停止回收站清理
+	trashBin.Stop()
+
+	// 停止存储桶事件接入
+	ingestor.Stop()
+
+	// 停止后台任务，执行中的任务交还给其他实例
+	taskManager.Stop()
+
+	// 停止上传进度管理器的后台协程
+	upload.DefaultManager.Close()
+
 	// 设置关闭超时时间
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -331,6 +410,12 @@ func setupIntegratedRouter(apiRouter *gin.Engine) *gin.Engine {
 	// WebDAV路由 - 高优先级，代理到API路由器但不去掉前缀
 	mainRouter.Any("/webdav/*proxyPath", gin.WrapH(apiRouter))
 
+	// 分享链接 - 匿名访问，代理到API路由器但不去掉前缀
+	mainRouter.Any("/s/*proxyPath", gin.WrapH(apiRouter))
+
+	// 上传请求链接 - 匿名上传，代理到API路由器但不去掉前缀
+	mainRouter.Any("/u/*proxyPath", gin.WrapH(apiRouter))
+
 	// 静态文件和SPA路由 - 最低优先级
 	mainRouter.NoRoute(func(c *gin.Context) {
 		serveStaticFile(c)
@@ -178,7 +178,15 @@ CREATE TABLE "public"."oss_files" (
   "uploader_id" int8 NOT NULL,
   "upload_ip" varchar(50) COLLATE "pg_catalog"."default",
   "status" varchar(20) COLLATE "pg_catalog"."default" DEFAULT 'ACTIVE'::character varying,
-  "config_id" int8 NOT NULL
+  "config_id" int8 NOT NULL,
+  "scan_status" varchar(20) COLLATE "pg_catalog"."default",
+  "scan_result" varchar(255) COLLATE "pg_catalog"."default",
+  "scanned_at" timestamptz(6),
+  "etag" varchar(100) COLLATE "pg_catalog"."default",
+  "verified_at" timestamptz(6),
+  "original_key" varchar(255) COLLATE "pg_catalog"."default",
+  "trashed_at" timestamptz(6),
+  "trashed_by" int8
 )
 ;
 
@@ -391,6 +399,23 @@ CREATE INDEX "idx_oss_files_md5" ON "public"."oss_files" USING btree (
 CREATE INDEX "idx_oss_files_uploader_id" ON "public"."oss_files" USING btree (
   "uploader_id" "pg_catalog"."int8_ops" ASC NULLS LAST
 );
+CREATE INDEX "idx_oss_files_scan_status" ON "public"."oss_files" USING btree (
+  "scan_status" COLLATE "pg_catalog"."default" "pg_catalog"."text_ops" ASC NULLS LAST
+);
+CREATE INDEX "idx_oss_files_verified_at" ON "public"."oss_files" USING btree (
+  "verified_at" "pg_catalog"."timestamptz_ops" ASC NULLS FIRST
+);
+CREATE INDEX "idx_oss_files_trashed_at" ON "public"."oss_files" USING btree (
+  "trashed_at" "pg_catalog"."timestamptz_ops" ASC NULLS LAST
+);
+-- 文件搜索：文件名子串/通配符和路径前缀使用 trigram 索引，按存储桶和上传时间排序分页使用组合索引
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX "idx_oss_files_original_filename_trgm" ON "public"."oss_files" USING gin ("original_filename" gin_trgm_ops);
+CREATE INDEX "idx_oss_files_object_key_trgm" ON "public"."oss_files" USING gin ("object_key" gin_trgm_ops);
+CREATE INDEX "idx_oss_files_bucket_created_at" ON "public"."oss_files" USING btree ("bucket", "created_at", "id");
+CREATE INDEX "idx_oss_files_file_size" ON "public"."oss_files" USING btree ("file_size", "id");
+-- 文件列表：每个文件名取最新一条记录并按文件名翻页
+CREATE INDEX "idx_oss_files_original_filename_latest" ON "public"."oss_files" USING btree ("original_filename", "created_at" DESC, "id" DESC);
 
 -- ----------------------------
 -- Primary Key structure for table oss_files
@@ -518,6 +543,366 @@ ALTER TABLE "public"."role_region_bucket_access" ADD CONSTRAINT "role_region_buc
 ALTER TABLE "public"."user_roles" ADD CONSTRAINT "fk_user_roles_role" FOREIGN KEY ("role_id") REFERENCES "public"."roles" ("id") ON DELETE NO ACTION ON UPDATE NO ACTION;
 ALTER TABLE "public"."user_roles" ADD CONSTRAINT "fk_user_roles_user" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("id") ON DELETE NO ACTION ON UPDATE NO ACTION;
 
+-- ----------------------------
+-- Table structure for tus_uploads
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."tus_uploads";
+CREATE TABLE "public"."tus_uploads" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "upload_key" varchar(64) COLLATE "pg_catalog"."default" NOT NULL,
+  "user_id" int8 NOT NULL,
+  "config_id" int8 NOT NULL,
+  "region_code" varchar(50) COLLATE "pg_catalog"."default" NOT NULL,
+  "bucket_name" varchar(100) COLLATE "pg_catalog"."default" NOT NULL,
+  "object_key" varchar(255) COLLATE "pg_catalog"."default" NOT NULL,
+  "original_filename" varchar(255) COLLATE "pg_catalog"."default" NOT NULL,
+  "upload_length" int8 NOT NULL,
+  "upload_offset" int8 NOT NULL DEFAULT 0,
+  "part_size" int8 NOT NULL,
+  "multipart_upload_id" varchar(255) COLLATE "pg_catalog"."default",
+  "parts" text COLLATE "pg_catalog"."default",
+  "metadata" text COLLATE "pg_catalog"."default",
+  "upload_ip" varchar(50) COLLATE "pg_catalog"."default",
+  "status" varchar(20) COLLATE "pg_catalog"."default" DEFAULT 'UPLOADING'::character varying,
+  "file_id" int8,
+  "expires_at" timestamptz(6),
+  CONSTRAINT "tus_uploads_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE UNIQUE INDEX "idx_tus_uploads_upload_key" ON "public"."tus_uploads" USING btree ("upload_key");
+CREATE INDEX "idx_tus_uploads_user_id" ON "public"."tus_uploads" USING btree ("user_id");
+CREATE INDEX "idx_tus_uploads_status" ON "public"."tus_uploads" USING btree ("status");
+CREATE INDEX "idx_tus_uploads_expires_at" ON "public"."tus_uploads" USING btree ("expires_at");
+CREATE INDEX "idx_tus_uploads_deleted_at" ON "public"."tus_uploads" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for upload_progresses
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."upload_progresses";
+CREATE TABLE "public"."upload_progresses" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "task_id" varchar(64) COLLATE "pg_catalog"."default" NOT NULL,
+  "status" varchar(20) COLLATE "pg_catalog"."default" NOT NULL,
+  "data" jsonb NOT NULL,
+  "expires_at" timestamptz(6) NOT NULL,
+  CONSTRAINT "upload_progresses_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE UNIQUE INDEX "idx_upload_progresses_task_id" ON "public"."upload_progresses" USING btree ("task_id");
+CREATE INDEX "idx_upload_progresses_expires_at" ON "public"."upload_progresses" USING btree ("expires_at");
+CREATE INDEX "idx_upload_progresses_deleted_at" ON "public"."upload_progresses" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for storage_quotas
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."storage_quotas";
+CREATE TABLE "public"."storage_quotas" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "scope_type" varchar(20) COLLATE "pg_catalog"."default" NOT NULL,
+  "scope_id" int8 NOT NULL DEFAULT 0,
+  "bucket_name" varchar(255) COLLATE "pg_catalog"."default" NOT NULL DEFAULT ''::character varying,
+  "max_bytes" int8 NOT NULL DEFAULT 0,
+  "max_files" int8 NOT NULL DEFAULT 0,
+  CONSTRAINT "storage_quotas_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE INDEX "idx_storage_quotas_scope" ON "public"."storage_quotas" USING btree ("scope_type", "scope_id");
+CREATE INDEX "idx_storage_quotas_deleted_at" ON "public"."storage_quotas" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for upload_policies
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."upload_policies";
+CREATE TABLE "public"."upload_policies" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "region_bucket_mapping_id" int8 NOT NULL,
+  "allowed_extensions" text COLLATE "pg_catalog"."default",
+  "allowed_mime_types" text COLLATE "pg_catalog"."default",
+  "max_file_size" int8 NOT NULL DEFAULT 0,
+  "forbidden_patterns" text COLLATE "pg_catalog"."default",
+  CONSTRAINT "upload_policies_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE UNIQUE INDEX "idx_upload_policies_region_bucket_mapping_id" ON "public"."upload_policies" USING btree ("region_bucket_mapping_id");
+CREATE INDEX "idx_upload_policies_deleted_at" ON "public"."upload_policies" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for bandwidth_limits
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."bandwidth_limits";
+CREATE TABLE "public"."bandwidth_limits" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "scope_type" varchar(20) COLLATE "pg_catalog"."default" NOT NULL,
+  "scope_id" int8 NOT NULL,
+  "upload_rate" int8 NOT NULL DEFAULT 0,
+  "download_rate" int8 NOT NULL DEFAULT 0,
+  CONSTRAINT "bandwidth_limits_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE INDEX "idx_bandwidth_limits_scope" ON "public"."bandwidth_limits" USING btree ("scope_type", "scope_id");
+CREATE INDEX "idx_bandwidth_limits_deleted_at" ON "public"."bandwidth_limits" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for file_hashes
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."file_hashes";
+CREATE TABLE "public"."file_hashes" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "file_id" int8 NOT NULL,
+  "algorithm" varchar(20) COLLATE "pg_catalog"."default" NOT NULL,
+  "value" varchar(128) COLLATE "pg_catalog"."default" NOT NULL,
+  CONSTRAINT "file_hashes_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE UNIQUE INDEX "idx_file_hashes_file_algorithm" ON "public"."file_hashes" USING btree ("file_id", "algorithm");
+CREATE INDEX "idx_file_hashes_value" ON "public"."file_hashes" USING btree ("algorithm", "value");
+CREATE INDEX "idx_file_hashes_deleted_at" ON "public"."file_hashes" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for hash_jobs
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."hash_jobs";
+CREATE TABLE "public"."hash_jobs" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "file_id" int8 NOT NULL,
+  "status" varchar(20) COLLATE "pg_catalog"."default" NOT NULL DEFAULT 'PENDING'::character varying,
+  "run_at" timestamptz(6) NOT NULL,
+  "attempts" int8 NOT NULL DEFAULT 0,
+  "lease_owner" varchar(100) COLLATE "pg_catalog"."default",
+  "leased_until" timestamptz(6),
+  "last_error" text COLLATE "pg_catalog"."default",
+  CONSTRAINT "hash_jobs_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE UNIQUE INDEX "idx_hash_jobs_file_id" ON "public"."hash_jobs" USING btree ("file_id");
+CREATE INDEX "idx_hash_jobs_status_run_at" ON "public"."hash_jobs" USING btree ("status", "run_at");
+
+-- ----------------------------
+-- Table structure for integrity_issues
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."integrity_issues";
+CREATE TABLE "public"."integrity_issues" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "file_id" int8 NOT NULL,
+  "bucket" varchar(100) COLLATE "pg_catalog"."default" NOT NULL,
+  "object_key" varchar(255) COLLATE "pg_catalog"."default" NOT NULL,
+  "kind" varchar(20) COLLATE "pg_catalog"."default" NOT NULL,
+  "expected" varchar(255) COLLATE "pg_catalog"."default",
+  "actual" varchar(255) COLLATE "pg_catalog"."default",
+  "detected_at" timestamptz(6) NOT NULL,
+  "resolved_at" timestamptz(6),
+  CONSTRAINT "integrity_issues_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE INDEX "idx_integrity_issues_file_id" ON "public"."integrity_issues" USING btree ("file_id");
+CREATE INDEX "idx_integrity_issues_resolved_at" ON "public"."integrity_issues" USING btree ("resolved_at");
+CREATE INDEX "idx_integrity_issues_deleted_at" ON "public"."integrity_issues" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for tasks
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."tasks";
+CREATE TABLE "public"."tasks" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "type" varchar(50) COLLATE "pg_catalog"."default" NOT NULL,
+  "status" varchar(20) COLLATE "pg_catalog"."default" NOT NULL DEFAULT 'PENDING'::character varying,
+  "user_id" int8 NOT NULL,
+  "params" text COLLATE "pg_catalog"."default",
+  "result" text COLLATE "pg_catalog"."default",
+  "error" text COLLATE "pg_catalog"."default",
+  "message" varchar(500) COLLATE "pg_catalog"."default",
+  "done" int8 NOT NULL DEFAULT 0,
+  "total" int8 NOT NULL DEFAULT 0,
+  "attempts" int8 NOT NULL DEFAULT 0,
+  "cancel_requested" bool NOT NULL DEFAULT false,
+  "lease_owner" varchar(100) COLLATE "pg_catalog"."default",
+  "leased_until" timestamptz(6),
+  "started_at" timestamptz(6),
+  "finished_at" timestamptz(6),
+  CONSTRAINT "tasks_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE INDEX "idx_tasks_type" ON "public"."tasks" USING btree ("type");
+CREATE INDEX "idx_tasks_status" ON "public"."tasks" USING btree ("status");
+CREATE INDEX "idx_tasks_user_id" ON "public"."tasks" USING btree ("user_id");
+
+-- ----------------------------
+-- Table structure for file_tags
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."file_tags";
+CREATE TABLE "public"."file_tags" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "file_id" int8 NOT NULL,
+  "name" varchar(50) COLLATE "pg_catalog"."default" NOT NULL,
+  CONSTRAINT "file_tags_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE UNIQUE INDEX "idx_file_tags_file_name" ON "public"."file_tags" USING btree ("file_id", "name");
+CREATE INDEX "idx_file_tags_name" ON "public"."file_tags" USING btree ("name");
+
+-- ----------------------------
+-- Table structure for shares
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."shares";
+CREATE TABLE "public"."shares" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "slug" varchar(32) COLLATE "pg_catalog"."default" NOT NULL,
+  "owner_id" int8 NOT NULL,
+  "file_id" int8,
+  "bucket" varchar(100) COLLATE "pg_catalog"."default" NOT NULL,
+  "prefix" varchar(255) COLLATE "pg_catalog"."default",
+  "name" varchar(255) COLLATE "pg_catalog"."default",
+  "password_hash" varchar(100) COLLATE "pg_catalog"."default",
+  "expires_at" timestamptz(6),
+  "max_downloads" int8 NOT NULL DEFAULT 0,
+  "downloads" int8 NOT NULL DEFAULT 0,
+  "preview_only" bool NOT NULL DEFAULT false,
+  "last_access_at" timestamptz(6),
+  CONSTRAINT "shares_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE UNIQUE INDEX "idx_shares_slug" ON "public"."shares" USING btree ("slug");
+CREATE INDEX "idx_shares_owner_id" ON "public"."shares" USING btree ("owner_id");
+CREATE INDEX "idx_shares_file_id" ON "public"."shares" USING btree ("file_id");
+CREATE INDEX "idx_shares_deleted_at" ON "public"."shares" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for share_access_logs
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."share_access_logs";
+CREATE TABLE "public"."share_access_logs" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "share_id" int8 NOT NULL,
+  "file_id" int8,
+  "action" varchar(20) COLLATE "pg_catalog"."default" NOT NULL,
+  "allowed" bool NOT NULL,
+  "reason" varchar(100) COLLATE "pg_catalog"."default",
+  "ip" varchar(50) COLLATE "pg_catalog"."default",
+  "user_agent" varchar(255) COLLATE "pg_catalog"."default",
+  CONSTRAINT "share_access_logs_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE INDEX "idx_share_access_logs_share_id" ON "public"."share_access_logs" USING btree ("share_id");
+CREATE INDEX "idx_share_access_logs_created_at" ON "public"."share_access_logs" USING btree ("created_at");
+
+-- ----------------------------
+-- Table structure for upload_requests
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."upload_requests";
+CREATE TABLE "public"."upload_requests" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "slug" varchar(32) COLLATE "pg_catalog"."default" NOT NULL,
+  "owner_id" int8 NOT NULL,
+  "bucket" varchar(100) COLLATE "pg_catalog"."default" NOT NULL,
+  "prefix" varchar(255) COLLATE "pg_catalog"."default" NOT NULL,
+  "name" varchar(255) COLLATE "pg_catalog"."default",
+  "description" text COLLATE "pg_catalog"."default",
+  "password_hash" varchar(100) COLLATE "pg_catalog"."default",
+  "expires_at" timestamptz(6),
+  "allowed_extensions" text COLLATE "pg_catalog"."default",
+  "allowed_mime_types" text COLLATE "pg_catalog"."default",
+  "max_total_size" int8 NOT NULL DEFAULT 0,
+  "uploaded_size" int8 NOT NULL DEFAULT 0,
+  "file_count" int8 NOT NULL DEFAULT 0,
+  "last_upload_at" timestamptz(6),
+  CONSTRAINT "upload_requests_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE UNIQUE INDEX "idx_upload_requests_slug" ON "public"."upload_requests" USING btree ("slug");
+CREATE INDEX "idx_upload_requests_owner_id" ON "public"."upload_requests" USING btree ("owner_id");
+CREATE INDEX "idx_upload_requests_deleted_at" ON "public"."upload_requests" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for reconcile_diffs
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."reconcile_diffs";
+CREATE TABLE "public"."reconcile_diffs" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "deleted_at" timestamptz(6),
+  "task_id" int8 NOT NULL,
+  "bucket" varchar(100) COLLATE "pg_catalog"."default" NOT NULL,
+  "object_key" text COLLATE "pg_catalog"."default" NOT NULL,
+  "kind" varchar(20) COLLATE "pg_catalog"."default" NOT NULL,
+  "file_id" int8,
+  "object_size" int8,
+  "record_size" int8,
+  "object_etag" varchar(100) COLLATE "pg_catalog"."default",
+  "record_etag" varchar(100) COLLATE "pg_catalog"."default",
+  "last_modified" timestamptz(6),
+  "resolution" varchar(20) COLLATE "pg_catalog"."default",
+  "resolved_by" int8,
+  "resolved_at" timestamptz(6),
+  CONSTRAINT "reconcile_diffs_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE INDEX "idx_reconcile_diffs_task_id" ON "public"."reconcile_diffs" USING btree ("task_id");
+CREATE INDEX "idx_reconcile_diffs_file_id" ON "public"."reconcile_diffs" USING btree ("file_id");
+CREATE INDEX "idx_reconcile_diffs_resolved_at" ON "public"."reconcile_diffs" USING btree ("resolved_at");
+CREATE INDEX "idx_reconcile_diffs_deleted_at" ON "public"."reconcile_diffs" USING btree ("deleted_at");
+
+-- ----------------------------
+-- Table structure for object_events
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."object_events";
+CREATE TABLE "public"."object_events" (
+  "id" bigserial NOT NULL,
+  "created_at" timestamptz(6),
+  "updated_at" timestamptz(6),
+  "source" varchar(20) COLLATE "pg_catalog"."default" NOT NULL,
+  "event_name" varchar(100) COLLATE "pg_catalog"."default",
+  "type" varchar(20) COLLATE "pg_catalog"."default" NOT NULL,
+  "bucket" varchar(100) COLLATE "pg_catalog"."default" NOT NULL,
+  "object_key" text COLLATE "pg_catalog"."default" NOT NULL,
+  "size" int8,
+  "etag" varchar(100) COLLATE "pg_catalog"."default",
+  "event_time" timestamptz(6) NOT NULL,
+  "status" varchar(20) COLLATE "pg_catalog"."default" NOT NULL DEFAULT 'PENDING'::character varying,
+  "run_at" timestamptz(6) NOT NULL,
+  "file_id" int8,
+  "result" text COLLATE "pg_catalog"."default",
+  "processed_at" timestamptz(6),
+  CONSTRAINT "object_events_pkey" PRIMARY KEY ("id")
+)
+;
+CREATE INDEX "idx_object_events_bucket_key" ON "public"."object_events" USING btree ("bucket", "object_key");
+CREATE INDEX "idx_object_events_status_run_at" ON "public"."object_events" USING btree ("status", "run_at");
+CREATE INDEX "idx_object_events_processed_at" ON "public"."object_events" USING btree ("processed_at");
+
 -- ----------------------------
 -- Initial data setup
 -- ----------------------------